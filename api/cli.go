@@ -3,26 +3,62 @@ package api
 import (
 	"bufio"
 	"dbngin3/engine"
+	"dbngin3/executor"
 	"dbngin3/parser"
+	"dbngin3/transaction"
+	"errors"
 	"fmt"
 	"os"
 	"strings"
 )
 
+var (
+	ErrTransactionInProgress = errors.New("a transaction is already in progress")
+	ErrNoTransaction         = errors.New("no transaction in progress")
+)
+
 type CLI struct {
 	lexer            *parser.Lexer
 	parser           *parser.Parser
-	semanticAnalyzer *parser.SelectSemanticAnalyzer
+	semanticAnalyzer *parser.SemanticAnalyzer
 	queryOptimizer   *parser.SelectQueryOptimizer
+	deleteOptimizer  *parser.DeleteQueryOptimizer
+	insertOptimizer  *parser.InsertQueryOptimizer
+	updateOptimizer  *parser.UpdateQueryOptimizer
+	planner          *executor.Planner
+
+	db *engine.Database
+	// txn is the transaction opened by BEGIN; without one every statement
+	// runs in a transaction of its own
+	txn *transaction.Transaction
+	// isolation is the level of the session, nextIsolation overrides it for
+	// the next transaction only
+	isolation     transaction.IsolationLevel
+	nextIsolation *transaction.IsolationLevel
 }
 
 func NewCLI() *CLI {
-	schema := engine.NewSchemaManager()
+	db, err := engine.OpenDatabase(engine.DataDir, engine.NewSchemaManager())
+	if err != nil {
+		panic(err)
+	}
+
+	return NewCLIWithDatabase(db)
+}
+
+func NewCLIWithDatabase(db *engine.Database) *CLI {
+	schema := db.Schema()
 	return &CLI{
 		lexer:            &parser.Lexer{},
 		parser:           &parser.Parser{},
-		semanticAnalyzer: &parser.SelectSemanticAnalyzer{Schema: schema},
+		semanticAnalyzer: &parser.SemanticAnalyzer{Schema: schema},
 		queryOptimizer:   &parser.SelectQueryOptimizer{Schema: schema},
+		deleteOptimizer:  &parser.DeleteQueryOptimizer{Schema: schema},
+		insertOptimizer:  &parser.InsertQueryOptimizer{Schema: schema},
+		updateOptimizer:  &parser.UpdateQueryOptimizer{Schema: schema},
+		planner:          &executor.Planner{DB: db},
+		db:               db,
+		isolation:        transaction.DefaultIsolationLevel,
 	}
 }
 
@@ -39,15 +75,46 @@ func (cli *CLI) Run() {
 			fmt.Println("Exiting...")
 			break
 		}
-		result := cli.ExecuteQuery(query)
-		fmt.Println(result)
+		if query == "" {
+			continue
+		}
+		if err := cli.ExecuteQuery(query); err != nil {
+			fmt.Println(err)
+		}
+	}
+
+	if err := cli.Close(); err != nil {
+		fmt.Println(err)
 	}
 }
 
+// Close rolls back an unfinished transaction and closes the database.
+func (cli *CLI) Close() error {
+	if cli.txn != nil {
+		if err := cli.db.Transactions().Rollback(cli.txn); err != nil {
+			return err
+		}
+		cli.txn = nil
+	}
+	return cli.db.Close()
+}
+
+// InTransaction reports whether the session is inside BEGIN ... COMMIT.
+func (cli *CLI) InTransaction() bool {
+	return cli.txn != nil
+}
+
+// SetIdentifierCase changes how queries name tables and columns. It is
+// saved in the catalog and can only be changed while it has no tables.
+func (cli *CLI) SetIdentifierCase(identifierCase engine.IdentifierCase) error {
+	return cli.db.Schema().SetIdentifierCase(identifierCase)
+}
+
 func (cli *CLI) ExecuteQuery(query string) error {
 	if err := cli.lexer.SetInput(query); err != nil {
 		return err
 	}
+	cli.lexer.IdentifierCase = cli.db.Schema().IdentifierCase()
 
 	var tokens []parser.Token
 	tokens, err := cli.lexer.Tokenize()
@@ -58,26 +125,181 @@ func (cli *CLI) ExecuteQuery(query string) error {
 	if err = cli.parser.SetToken(tokens); err != nil {
 		return err
 	}
+	if err = cli.parser.SetInput(query); err != nil {
+		return err
+	}
 
 	nodes, err := cli.parser.Parse()
 	if err != nil {
 		return err
 	}
 
-	if node, ok := nodes.(*parser.SelectStatement); ok {
-		if err := cli.semanticAnalyzer.Analyze(node); err != nil {
+	if err := cli.semanticAnalyzer.Analyze(nodes); err != nil {
+		return err
+	}
+
+	switch node := nodes.(type) {
+	case *parser.SelectStatement:
+		if err := cli.queryOptimizer.Optimize(node); err != nil {
+			return err
+		}
+		return cli.execute(node)
+	case *parser.DeleteStatement:
+		if err := cli.deleteOptimizer.Optimize(node); err != nil {
 			return err
 		}
+		return cli.execute(node)
+	case *parser.InsertStatement:
+		if err := cli.insertOptimizer.Optimize(node); err != nil {
+			return err
+		}
+		return cli.execute(node)
+	case *parser.UpdateStatement:
+		if err := cli.updateOptimizer.Optimize(node); err != nil {
+			return err
+		}
+		return cli.execute(node)
+	case *parser.CreateTableStatement, *parser.DropTableStatement, *parser.AlterTableStatement:
+		// like in MySQL, schema changes commit the open transaction first
+		if cli.txn != nil {
+			if err := cli.commit(); err != nil {
+				return err
+			}
+		}
+		return cli.execute(node)
+	case *parser.BeginStatement:
+		return cli.begin()
+	case *parser.CommitStatement:
+		return cli.commit()
+	case *parser.RollbackStatement:
+		return cli.rollback(node)
+	case *parser.SavepointStatement:
+		if cli.txn == nil {
+			return ErrNoTransaction
+		}
+		return cli.db.Transactions().Savepoint(cli.txn, node.Name)
+	case *parser.SetTransactionStatement:
+		return cli.setIsolation(node)
+	default:
+		fmt.Println("Invalid Syntax")
+	}
+	return nil
+}
 
-		if err := cli.queryOptimizer.Optimize(node); err != nil {
+func (cli *CLI) begin() error {
+	if cli.txn != nil {
+		return ErrTransactionInProgress
+	}
+
+	txn, err := cli.db.Transactions().Begin(cli.takeIsolation())
+	if err != nil {
+		return err
+	}
+
+	cli.txn = txn
+	return nil
+}
+
+func (cli *CLI) setIsolation(node *parser.SetTransactionStatement) error {
+	level, err := transaction.ParseIsolationLevel(node.Isolation)
+	if err != nil {
+		return err
+	}
+
+	if node.Session {
+		cli.isolation = level
+		return nil
+	}
+
+	if cli.txn != nil {
+		return errors.New("isolation level cannot be changed while a transaction is in progress")
+	}
+	cli.nextIsolation = &level
+	return nil
+}
+
+// takeIsolation returns the level for a new transaction.
+func (cli *CLI) takeIsolation() transaction.IsolationLevel {
+	if level := cli.nextIsolation; level != nil {
+		cli.nextIsolation = nil
+		return *level
+	}
+	return cli.isolation
+}
+
+// Isolation returns the isolation level of the session.
+func (cli *CLI) Isolation() transaction.IsolationLevel {
+	return cli.isolation
+}
+
+func (cli *CLI) commit() error {
+	if cli.txn == nil {
+		return ErrNoTransaction
+	}
+
+	txn := cli.txn
+	cli.txn = nil
+	return cli.db.Transactions().Commit(txn)
+}
+
+func (cli *CLI) rollback(node *parser.RollbackStatement) error {
+	if cli.txn == nil {
+		return ErrNoTransaction
+	}
+
+	if node.Savepoint != "" {
+		return cli.db.Transactions().RollbackTo(cli.txn, node.Savepoint)
+	}
+
+	txn := cli.txn
+	cli.txn = nil
+	return cli.db.Transactions().Rollback(txn)
+}
+
+// run executes a statement inside the session transaction. Outside of
+// BEGIN ... COMMIT the statement gets a transaction of its own that commits
+// when the statement succeeds.
+func (cli *CLI) run(fn func(txn *transaction.Transaction) error) error {
+	txns := cli.db.Transactions()
+	if cli.txn != nil {
+		err := txns.Run(cli.txn, func() error { return fn(cli.txn) })
+		if cli.txn.State() != transaction.Active {
+			// the transaction lost a write conflict and was rolled back
+			cli.txn = nil
+		}
+		return err
+	}
+
+	txn, err := txns.Begin(cli.takeIsolation())
+	if err != nil {
+		return err
+	}
+
+	if err := fn(txn); err != nil {
+		if rollbackErr := txns.Rollback(txn); rollbackErr != nil {
+			return rollbackErr
+		}
+		return err
+	}
+	return txns.Commit(txn)
+}
+
+// execute plans and runs a statement and prints its result.
+func (cli *CLI) execute(node parser.ASTNode) error {
+	var result *executor.Result
+	err := cli.run(func(txn *transaction.Transaction) error {
+		plan, err := cli.planner.Plan(txn, node)
+		if err != nil {
 			return err
 		}
 
-		fmt.Println("Select Statement")
-		fmt.Println("Table: ", node.Table)
-		fmt.Println("Columns: ", node.Columns)
-	} else {
-		fmt.Println("Invalid Syntax")
+		result, err = executor.Execute(plan)
+		return err
+	})
+	if err != nil {
+		return err
 	}
+
+	fmt.Print(formatResult(result))
 	return nil
 }
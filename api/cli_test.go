@@ -1 +1,659 @@
 package api
+
+import (
+	"dbngin3/engine"
+	"dbngin3/parser"
+	"dbngin3/transaction"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func newTestCLI(t *testing.T) *CLI {
+	cli := openTestCLI(t, t.TempDir())
+	execute(t, cli, "CREATE TABLE users (id INT PRIMARY KEY, name VARCHAR)")
+	return cli
+}
+
+// openTestCLI opens a session on the database in dir, which keeps its
+// catalog next to the data.
+func openTestCLI(t *testing.T, dir string) *CLI {
+	schema, err := engine.OpenSchemaManager(filepath.Join(dir, "schema.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := engine.OpenDatabase(dir, schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cli := NewCLIWithDatabase(db)
+	t.Cleanup(func() { _ = cli.Close() })
+	return cli
+}
+
+func execute(t *testing.T, cli *CLI, queries ...string) {
+	for _, query := range queries {
+		if err := cli.ExecuteQuery(query); err != nil {
+			t.Fatalf("%s: %s", query, err)
+		}
+	}
+}
+
+func userNames(t *testing.T, cli *CLI) []string {
+	store, err := cli.db.Store("users")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	txn, err := cli.db.Transactions().Begin(transaction.RepeatableRead)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.db.Transactions().Commit(txn)
+
+	var names []string
+	it := store.Scan(txn)
+	for {
+		tuple, err := it.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tuple == nil {
+			return names
+		}
+		names = append(names, tuple.Row[1])
+	}
+}
+
+func TestCLI_RollbackUndoesTransaction(t *testing.T) {
+	cli := newTestCLI(t)
+
+	execute(t, cli,
+		"INSERT INTO users (id, name) VALUES (1, 'John')",
+		"BEGIN",
+		"INSERT INTO users (id, name) VALUES (2, 'Jane')",
+		"UPDATE users SET name = 'Johnny' WHERE id = 1",
+		"ROLLBACK",
+	)
+
+	t.Run("Only the autocommitted insert is left", func(t *testing.T) {
+		names := userNames(t, cli)
+		if len(names) != 1 || names[0] != "John" {
+			t.Errorf("expected [John], got %v", names)
+		}
+
+		if cli.InTransaction() {
+			t.Errorf("expected transaction to be finished")
+		}
+	})
+}
+
+func TestCLI_CommitKeepsTransaction(t *testing.T) {
+	cli := newTestCLI(t)
+
+	execute(t, cli,
+		"BEGIN",
+		"INSERT INTO users (id, name) VALUES (1, 'John')",
+		"INSERT INTO users (id, name) VALUES (2, 'Jane')",
+		"COMMIT",
+	)
+
+	if names := userNames(t, cli); len(names) != 2 {
+		t.Errorf("expected 2 users, got %v", names)
+	}
+}
+
+func TestCLI_RollbackToSavepoint(t *testing.T) {
+	cli := newTestCLI(t)
+
+	execute(t, cli,
+		"START TRANSACTION",
+		"INSERT INTO users (id, name) VALUES (1, 'John')",
+		"SAVEPOINT afterJohn",
+		"INSERT INTO users (id, name) VALUES (2, 'Jane')",
+		"ROLLBACK TO SAVEPOINT afterJohn",
+		"COMMIT",
+	)
+
+	names := userNames(t, cli)
+	if len(names) != 1 || names[0] != "John" {
+		t.Errorf("expected [John], got %v", names)
+	}
+}
+
+func TestCLI_FailedStatementKeepsTransaction(t *testing.T) {
+	cli := newTestCLI(t)
+
+	execute(t, cli,
+		"BEGIN",
+		"INSERT INTO users (id, name) VALUES (1, 'John')",
+	)
+
+	err := cli.ExecuteQuery("INSERT INTO users (id, name) VALUES (1, 'Jane')")
+	t.Run("Duplicate key fails the statement", func(t *testing.T) {
+		if err == nil {
+			t.Fatalf("expected duplicate key error")
+		}
+
+		if !cli.InTransaction() {
+			t.Errorf("expected transaction to stay open")
+		}
+	})
+
+	execute(t, cli, "COMMIT")
+	t.Run("Earlier statements are committed", func(t *testing.T) {
+		names := userNames(t, cli)
+		if len(names) != 1 || names[0] != "John" {
+			t.Errorf("expected [John], got %v", names)
+		}
+	})
+}
+
+func TestCLI_TransactionStateErrors(t *testing.T) {
+	cli := newTestCLI(t)
+
+	t.Run("Commit without transaction", func(t *testing.T) {
+		if err := cli.ExecuteQuery("COMMIT"); err != ErrNoTransaction {
+			t.Errorf("expected %v, got %v", ErrNoTransaction, err)
+		}
+	})
+
+	t.Run("Nested begin", func(t *testing.T) {
+		execute(t, cli, "BEGIN")
+		if err := cli.ExecuteQuery("BEGIN"); err != ErrTransactionInProgress {
+			t.Errorf("expected %v, got %v", ErrTransactionInProgress, err)
+		}
+	})
+
+	t.Run("Unknown savepoint", func(t *testing.T) {
+		if err := cli.ExecuteQuery("ROLLBACK TO missing"); err == nil {
+			t.Errorf("expected unknown savepoint error")
+		}
+	})
+}
+
+func TestCLI_SetIsolationLevel(t *testing.T) {
+	cli := newTestCLI(t)
+
+	t.Run("Session level", func(t *testing.T) {
+		execute(t, cli, "SET SESSION TRANSACTION ISOLATION LEVEL READ COMMITTED")
+		if cli.Isolation() != transaction.ReadCommitted {
+			t.Errorf("expected %s, got %s", transaction.ReadCommitted, cli.Isolation())
+		}
+	})
+
+	t.Run("Level of the next transaction only", func(t *testing.T) {
+		execute(t, cli, "SET TRANSACTION ISOLATION LEVEL SNAPSHOT", "BEGIN")
+		if cli.txn.Isolation() != transaction.RepeatableRead {
+			t.Errorf("expected %s, got %s", transaction.RepeatableRead, cli.txn.Isolation())
+		}
+		execute(t, cli, "COMMIT", "BEGIN")
+		if cli.txn.Isolation() != transaction.ReadCommitted {
+			t.Errorf("expected %s, got %s", transaction.ReadCommitted, cli.txn.Isolation())
+		}
+	})
+
+	t.Run("Level cannot change inside a transaction", func(t *testing.T) {
+		if err := cli.ExecuteQuery("SET TRANSACTION ISOLATION LEVEL READ COMMITTED"); err == nil {
+			t.Errorf("expected error inside a transaction")
+		}
+	})
+}
+
+func TestCLI_WriteConflictAbortsLaterSession(t *testing.T) {
+	first := newTestCLI(t)
+	second := NewCLIWithDatabase(first.db)
+
+	execute(t, first, "INSERT INTO users (id, name) VALUES (1, 'John')", "BEGIN")
+	execute(t, second, "BEGIN", "INSERT INTO users (id, name) VALUES (2, 'Jane')")
+	execute(t, first, "UPDATE users SET name = 'first' WHERE id = 1")
+
+	done := make(chan error)
+	go func() {
+		done <- second.ExecuteQuery("UPDATE users SET name = 'second' WHERE id = 1")
+	}()
+	execute(t, first, "COMMIT")
+
+	err := <-done
+	t.Run("Later writer waits and gets a serialization error", func(t *testing.T) {
+		if err != transaction.ErrWriteConflict {
+			t.Errorf("expected %v, got %v", transaction.ErrWriteConflict, err)
+		}
+
+		if second.InTransaction() {
+			t.Errorf("expected the transaction of the second session to be rolled back")
+		}
+	})
+
+	t.Run("Only the first writer's changes remain", func(t *testing.T) {
+		names := userNames(t, first)
+		if len(names) != 1 || names[0] != "first" {
+			t.Errorf("expected [first], got %v", names)
+		}
+	})
+}
+
+func TestCLI_DeadlockRollsBackYoungestSession(t *testing.T) {
+	first := newTestCLI(t)
+	second := NewCLIWithDatabase(first.db)
+
+	execute(t, first, "INSERT INTO users (id, name) VALUES (1, 'John')", "INSERT INTO users (id, name) VALUES (2, 'Jane')")
+	execute(t, first, "BEGIN", "UPDATE users SET name = 'first' WHERE id = 1")
+	execute(t, second, "BEGIN", "UPDATE users SET name = 'second' WHERE id = 2")
+
+	done := make(chan error)
+	go func() {
+		done <- first.ExecuteQuery("UPDATE users SET name = 'first' WHERE id = 2")
+	}()
+	err := second.ExecuteQuery("UPDATE users SET name = 'second' WHERE id = 1")
+
+	t.Run("Younger session is the victim", func(t *testing.T) {
+		if err != transaction.ErrDeadlock {
+			t.Errorf("expected %v, got %v", transaction.ErrDeadlock, err)
+		}
+		if second.InTransaction() {
+			t.Errorf("expected the transaction of the second session to be rolled back")
+		}
+	})
+
+	t.Run("Older session goes on", func(t *testing.T) {
+		if err := <-done; err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+		execute(t, first, "COMMIT")
+
+		names := userNames(t, first)
+		if len(names) != 2 || names[0] != "first" || names[1] != "first" {
+			t.Errorf("expected [first first], got %v", names)
+		}
+	})
+}
+
+func TestCLI_CreateAndDropTable(t *testing.T) {
+	dir := t.TempDir()
+	cli := openTestCLI(t, dir)
+
+	execute(t, cli,
+		"CREATE TABLE users (id INT PRIMARY KEY, name VARCHAR(20) NOT NULL, email TEXT UNIQUE)",
+		"INSERT INTO users (id, name, email) VALUES (1, 'John', 'john@example.com')",
+	)
+
+	t.Run("Unique column rejects duplicates", func(t *testing.T) {
+		if err := cli.ExecuteQuery("INSERT INTO users (id, name, email) VALUES (2, 'Jane', 'john@example.com')"); err == nil {
+			t.Errorf("expected duplicate entry error")
+		}
+	})
+
+	t.Run("NOT NULL column rejects NULL", func(t *testing.T) {
+		if err := cli.ExecuteQuery("INSERT INTO users (id, name, email) VALUES (2, NULL, 'jane@example.com')"); err == nil {
+			t.Errorf("expected error for NULL name")
+		}
+		// NULL is no duplicate of NULL in a unique column
+		execute(t, cli,
+			"BEGIN",
+			"INSERT INTO users (id, name, email) VALUES (3, 'Jim', NULL)",
+			"INSERT INTO users (id, name, email) VALUES (4, 'Joe', NULL)",
+			"ROLLBACK",
+		)
+	})
+
+	t.Run("Existing table is rejected unless IF NOT EXISTS", func(t *testing.T) {
+		if err := cli.ExecuteQuery("CREATE TABLE users (id INT)"); err == nil {
+			t.Errorf("expected error for existing table")
+		}
+		execute(t, cli, "CREATE TABLE IF NOT EXISTS users (id INT)")
+	})
+
+	_ = cli.Close()
+	cli = openTestCLI(t, dir)
+	t.Run("Table survives a restart", func(t *testing.T) {
+		names := userNames(t, cli)
+		if len(names) != 1 || names[0] != "John" {
+			t.Errorf("expected [John], got %v", names)
+		}
+	})
+
+	t.Run("Schema change commits the open transaction", func(t *testing.T) {
+		execute(t, cli, "BEGIN", "INSERT INTO users (id, name, email) VALUES (2, 'Jane', 'jane@example.com')")
+		execute(t, cli, "CREATE TABLE orders (id INT)")
+
+		if cli.InTransaction() {
+			t.Errorf("expected the transaction to be committed")
+		}
+		if names := userNames(t, cli); len(names) != 2 {
+			t.Errorf("expected 2 users, got %v", names)
+		}
+	})
+
+	t.Run("Dropped table is gone", func(t *testing.T) {
+		execute(t, cli, "DROP TABLE users")
+		if err := cli.ExecuteQuery("SELECT * FROM users"); err == nil {
+			t.Errorf("expected error for dropped table")
+		}
+		if err := cli.ExecuteQuery("DROP TABLE users"); err == nil {
+			t.Errorf("expected error for missing table")
+		}
+		execute(t, cli, "DROP TABLE IF EXISTS users")
+	})
+}
+
+func TestCLI_AlterTable(t *testing.T) {
+	cli := newTestCLI(t)
+	execute(t, cli, "INSERT INTO users (id, name) VALUES (1, 'John')")
+
+	execute(t, cli,
+		"ALTER TABLE users ADD COLUMN age INT DEFAULT 30, RENAME COLUMN name TO fullname",
+		"INSERT INTO users (id, fullname, age) VALUES (2, 'Jane', 25)",
+		"UPDATE users SET age = 31 WHERE id = 1",
+	)
+
+	if err := cli.ExecuteQuery("SELECT name FROM users"); err == nil {
+		t.Errorf("expected the old column name to be gone")
+	}
+	if err := cli.ExecuteQuery("ALTER TABLE users MODIFY fullname VARCHAR(3)"); err == nil {
+		t.Errorf("expected too long values to reject the change")
+	}
+
+	execute(t, cli, "ALTER TABLE users DROP COLUMN age, RENAME TO people")
+	if err := cli.ExecuteQuery("SELECT * FROM users"); err == nil {
+		t.Errorf("expected the old table name to be gone")
+	}
+	execute(t, cli, "SELECT fullname FROM people WHERE id = 2")
+}
+
+func TestCLI_DataTypes(t *testing.T) {
+	cli := newTestCLI(t)
+	execute(t, cli,
+		"CREATE TABLE events (id BIGINT PRIMARY KEY, day DATE, at TIMESTAMP, price DECIMAL(6,2), done BOOLEAN, score DOUBLE)",
+		"INSERT INTO events (id, day, at, price, done, score) VALUES (9000000000, DATE '2024-02-29', '2024-02-29 12:30:00', 19.999, TRUE, -0.5)",
+		"INSERT INTO events (id, day, at, price, done, score) VALUES (2, '2023-12-31', '2024-01-01', 5, 0, 10)",
+	)
+
+	store, err := cli.db.Store("events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	txn, _ := cli.db.Transactions().Begin(transaction.RepeatableRead)
+	defer cli.db.Transactions().Commit(txn)
+
+	tuple, err := store.Lookup(txn, "9000000000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := engine.Row{"9000000000", "2024-02-29", "2024-02-29 12:30:00", "20.00", "true", "-0.5"}
+	if !reflect.DeepEqual(tuple.Row, expected) {
+		t.Errorf("expected %v, got %v", expected, tuple.Row)
+	}
+
+	for _, query := range []string{
+		"INSERT INTO events (id, day, at, price, done, score) VALUES (3, '2023-02-29', '2024-01-01', 1, TRUE, 1)",
+		"INSERT INTO events (id, day, at, price, done, score) VALUES (3, '2023-01-01', '2024-01-01', 10000, TRUE, 1)",
+		"INSERT INTO events (id, day, at, price, done, score) VALUES (3, '2023-01-01', '2024-01-01', 1, maybe, 1)",
+	} {
+		if err := cli.ExecuteQuery(query); err == nil {
+			t.Errorf("expected %q to be rejected", query)
+		}
+	}
+
+	execute(t, cli, "UPDATE events SET done = TRUE WHERE day < '2024-01-01'")
+	tuple, _ = store.Lookup(txn, "2")
+	if tuple.Row[4] != "false" {
+		t.Errorf("expected the snapshot to keep false, got %s", tuple.Row[4])
+	}
+}
+
+func TestCLI_SyntaxError(t *testing.T) {
+	cli := newTestCLI(t)
+
+	err := cli.ExecuteQuery("SELECT id,\n  name\nFROM users WHERE id = = 1")
+	syntaxErr, ok := err.(*parser.SyntaxError)
+	if !ok {
+		t.Fatalf("expected a syntax error, got %v", err)
+	}
+
+	expected := "syntax error at line 3, column 23: expected expression, found \"=\"\n" +
+		"FROM users WHERE id = = 1\n" +
+		"                      ^"
+	if syntaxErr.Error() != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, syntaxErr.Error())
+	}
+
+	if _, ok := cli.ExecuteQuery("SELECT id FROM users WHERE id ~ 1").(*parser.SyntaxError); !ok {
+		t.Errorf("expected an unknown character to be a syntax error")
+	}
+}
+
+func TestCLI_IdentifierCase(t *testing.T) {
+	t.Run("Keywords in any case", func(t *testing.T) {
+		cli := newTestCLI(t)
+		execute(t, cli,
+			"insert into users (id, name) values (1, 'John')",
+			"Update users Set name = 'Jane' wHeRe id = 1 and name is not null",
+			"select name from users where id = 1",
+		)
+		if names := userNames(t, cli); len(names) != 1 || names[0] != "Jane" {
+			t.Errorf("expected [Jane], got %v", names)
+		}
+	})
+
+	t.Run("Case sensitive names", func(t *testing.T) {
+		cli := newTestCLI(t)
+		if err := cli.ExecuteQuery("SELECT name FROM Users"); err == nil {
+			t.Errorf("expected Users to be another table than users")
+		}
+		execute(t, cli, `CREATE TABLE "Users" ("select" INT, Name TEXT)`, "SELECT `select`, Name FROM Users")
+	})
+
+	t.Run("Column names in any case", func(t *testing.T) {
+		cli := newTestCLI(t)
+		execute(t, cli,
+			"CREATE TABLE T_1 (Id INT PRIMARY KEY, Name VARCHAR)",
+			"INSERT INTO T_1 (ID, name) VALUES (1, 'John')",
+			"UPDATE T_1 SET NAME = 'Jane' WHERE id = 1",
+			"SELECT id, t.NAME FROM T_1 t WHERE \"ID\" = 1 ORDER BY iD",
+		)
+		store, err := cli.db.Store("T_1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		txn, _ := cli.db.Transactions().Begin(transaction.RepeatableRead)
+		defer cli.db.Transactions().Commit(txn)
+		if tuple, err := store.Lookup(txn, "1"); err != nil || tuple.Row[1] != "Jane" {
+			t.Errorf("expected the update to find the row, got %v, %v", tuple, err)
+		}
+
+		if err := cli.ExecuteQuery("SELECT id FROM t_1"); err == nil {
+			t.Errorf("expected table names to stay case sensitive")
+		}
+		if err := cli.ExecuteQuery("CREATE TABLE t_2 (id INT, ID INT)"); err == nil || err.Error() != "duplicate column name ID" {
+			t.Errorf("expected id and ID to be the same column, got %v", err)
+		}
+	})
+
+	t.Run("Lower case names", func(t *testing.T) {
+		dir := t.TempDir()
+		cli := openTestCLI(t, dir)
+		if err := cli.SetIdentifierCase(engine.LowerCase); err != nil {
+			t.Fatal(err)
+		}
+		execute(t, cli,
+			"CREATE TABLE Users (ID INT PRIMARY KEY, Name VARCHAR)",
+			"INSERT INTO USERS (id, NAME) VALUES (1, 'John')",
+			`SELECT "name" FROM users WHERE Id = 1`,
+		)
+		if err := cli.ExecuteQuery(`SELECT name FROM "Users"`); err == nil {
+			t.Errorf("expected a quoted table name to keep its case")
+		}
+		if err := cli.SetIdentifierCase(engine.CaseSensitive); err == nil {
+			t.Errorf("expected the case to be fixed once there are tables")
+		}
+
+		_ = cli.Close()
+		cli = openTestCLI(t, dir)
+		execute(t, cli, "SELECT NAME FROM USERS")
+	})
+}
+
+func TestCLI_Delete(t *testing.T) {
+	cli := newTestCLI(t)
+	execute(t, cli,
+		"INSERT INTO users (id, name) VALUES (1, 'John')",
+		"INSERT INTO users (id, name) VALUES (2, 'Jane')",
+		"BEGIN",
+		"DELETE FROM users WHERE id = 1",
+		"ROLLBACK",
+	)
+	if names := userNames(t, cli); len(names) != 2 {
+		t.Errorf("expected the rollback to keep both users, got %v", names)
+	}
+
+	execute(t, cli, "DELETE FROM users WHERE name = 'John'")
+	if names := userNames(t, cli); !reflect.DeepEqual(names, []string{"Jane"}) {
+		t.Errorf("expected [Jane], got %v", names)
+	}
+
+	if err := cli.ExecuteQuery("DELETE FROM users WHERE email = 'x'"); err == nil {
+		t.Errorf("expected unknown column error")
+	}
+	if err := cli.ExecuteQuery("DELETE FROM people"); err == nil {
+		t.Errorf("expected unknown table error")
+	}
+}
+
+func TestCLI_GroupBy(t *testing.T) {
+	cli := newTestCLI(t)
+	execute(t, cli,
+		"INSERT INTO users (id, name) VALUES (1, 'John')",
+		"INSERT INTO users (id, name) VALUES (2, 'John')",
+		"SELECT name, COUNT(*) AS users FROM users GROUP BY name HAVING users > 1",
+		"select count(distinct name), max(id) from users",
+	)
+
+	if err := cli.ExecuteQuery("SELECT id, COUNT(*) FROM users GROUP BY name"); err == nil {
+		t.Errorf("expected an error for a column that is not grouped")
+	}
+	if err := cli.ExecuteQuery("SELECT SUM(name) FROM users"); err == nil {
+		t.Errorf("expected an error for the sum of strings")
+	}
+}
+
+func TestCLI_Join(t *testing.T) {
+	cli := newTestCLI(t)
+	execute(t, cli,
+		"CREATE TABLE orders (id INT PRIMARY KEY, user_id INT)",
+		"INSERT INTO users (id, name) VALUES (1, 'John')",
+		"INSERT INTO orders (id, user_id) VALUES (10, 1)",
+		"SELECT u.name, o.id FROM users u JOIN orders o ON u.id = o.user_id",
+		"SELECT name FROM users LEFT JOIN orders ON users.id = orders.user_id WHERE orders.id IS NULL",
+	)
+
+	if err := cli.ExecuteQuery("SELECT id FROM users JOIN orders ON users.id = user_id"); err == nil {
+		t.Errorf("expected an error for an ambiguous column")
+	}
+}
+
+func TestCLI_Subqueries(t *testing.T) {
+	cli := newTestCLI(t)
+	execute(t, cli,
+		"CREATE TABLE orders (id INT PRIMARY KEY, user_id INT)",
+		"INSERT INTO users (id, name) VALUES (1, 'John')",
+		"INSERT INTO orders (id, user_id) VALUES (10, 1)",
+		"SELECT name, (SELECT COUNT(*) FROM orders WHERE user_id = users.id) FROM users",
+		"SELECT name FROM users WHERE id IN (SELECT user_id FROM orders)",
+		"SELECT name FROM users u WHERE NOT EXISTS (SELECT * FROM orders o WHERE o.user_id = u.id)",
+		"SELECT d.n FROM (SELECT user_id, COUNT(*) AS n FROM orders GROUP BY user_id) AS d",
+		"DELETE FROM orders WHERE user_id NOT IN (SELECT id FROM users)",
+		"UPDATE users SET name = 'Jane' WHERE id IN (SELECT user_id FROM orders)",
+		"UPDATE users SET name = (SELECT MAX(name) FROM users)",
+	)
+
+	if err := cli.ExecuteQuery("SELECT name FROM users WHERE id IN (SELECT id, user_id FROM orders)"); err == nil {
+		t.Errorf("expected an error for a subquery with two columns")
+	}
+}
+
+func TestCLI_InsertBatch(t *testing.T) {
+	cli := newTestCLI(t)
+	execute(t, cli,
+		"INSERT INTO users VALUES (1, 'John'), (2, 'Jane');",
+		"INSERT INTO users (id) SELECT id + 2 FROM users",
+	)
+
+	if err := cli.ExecuteQuery("INSERT INTO users VALUES (5, 'Marty'), (1, 'Doc')"); err == nil {
+		t.Errorf("expected a duplicate key error")
+	}
+	if names := userNames(t, cli); len(names) != 4 {
+		t.Errorf("expected the failed batch to insert no row, got %v", names)
+	}
+}
+
+func TestCLI_Upsert(t *testing.T) {
+	cli := newTestCLI(t)
+	execute(t, cli,
+		"INSERT INTO users VALUES (1, 'John'), (2, 'Jane')",
+		"INSERT INTO users VALUES (1, 'Johnny'), (3, 'Marty') ON DUPLICATE KEY UPDATE name = VALUES(name)",
+		"REPLACE INTO users VALUES (2, 'Doc')",
+	)
+
+	names := userNames(t, cli)
+	sort.Strings(names)
+	if !reflect.DeepEqual(names, []string{"Doc", "Johnny", "Marty"}) {
+		t.Errorf("expected the rows to be upserted, got %v", names)
+	}
+	if err := cli.ExecuteQuery("REPLACE INTO users VALUES (4, 'Biff') ON DUPLICATE KEY UPDATE name = 'x'"); err == nil {
+		t.Errorf("expected a syntax error")
+	}
+}
+
+func TestCLI_ChangesAreAnalyzed(t *testing.T) {
+	cli := newTestCLI(t)
+	execute(t, cli,
+		"INSERT INTO users VALUES (1, 'John')",
+		"UPDATE users SET name = (SELECT MAX(name) FROM users u WHERE u.id < users.id)",
+	)
+
+	for query, expected := range map[string]string{
+		"INSERT INTO users (id, email) VALUES (2, 'x')": "column email not found in table users",
+		"INSERT INTO users VALUES (2)":                  "column count doesn't match value count at row 1",
+		"UPDATE users SET email = 'x'":                  "column email not found in table users",
+		"UPDATE users SET id = NULL":                    "column id of table users cannot be null",
+	} {
+		if err := cli.ExecuteQuery(query); err == nil || err.Error() != expected {
+			t.Errorf("%s: expected error %q, got %v", query, expected, err)
+		}
+	}
+	if names := userNames(t, cli); !reflect.DeepEqual(names, []string{engine.Null}) {
+		t.Errorf("expected the subquery to find no name, got %v", names)
+	}
+}
+
+func TestCLI_TypesAreChecked(t *testing.T) {
+	cli := newTestCLI(t)
+	execute(t, cli,
+		"CREATE TABLE events (id INT PRIMARY KEY, day DATE)",
+		"INSERT INTO users VALUES ('1', 'John'), (2, 'Jane')",
+		"INSERT INTO events VALUES (1, '2024-01-05')",
+		"UPDATE users SET name = 'Jack' WHERE id IN (SELECT id FROM events WHERE day = '2024-01-05')",
+	)
+
+	for query, expected := range map[string]string{
+		"SELECT name FROM users WHERE id = 'abc'":        "invalid number \"abc\" for column id",
+		"SELECT id FROM events WHERE day > 'tomorrow'":   "invalid date \"tomorrow\" for column day",
+		"SELECT id FROM events WHERE day = id":           "cannot compare DATE with INT",
+		"SELECT name - 1 FROM users":                     "operator - needs numbers, got VARCHAR",
+		"INSERT INTO events VALUES (2, '2024-02-30')":    "invalid date \"2024-02-30\" for column day",
+		"UPDATE users SET id = 'three' WHERE name = 'x'": "invalid integer \"three\" for column id",
+	} {
+		if err := cli.ExecuteQuery(query); err == nil || err.Error() != expected {
+			t.Errorf("%s: expected error %q, got %v", query, expected, err)
+		}
+	}
+
+	execute(t, cli, "DELETE FROM users WHERE id = '2'")
+	if names := userNames(t, cli); !reflect.DeepEqual(names, []string{"Jack"}) {
+		t.Errorf("expected [Jack], got %v", names)
+	}
+}
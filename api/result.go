@@ -0,0 +1,84 @@
+package api
+
+import (
+	"dbngin3/engine"
+	"dbngin3/executor"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// formatResult renders a result the way the mysql client does: rows as a
+// table followed by their count, changes as the number of affected rows.
+// BLOB values are shown in hex, columns are as wide as their widest value
+// in characters.
+func formatResult(result *executor.Result) string {
+	if !result.IsQuery() {
+		out := fmt.Sprintf("Query OK, %s affected\n", plural(result.Affected, "row"))
+		if result.Records > 0 {
+			out += fmt.Sprintf("Records: %d  Duplicates: %d  Warnings: 0\n", result.Records, result.Duplicates)
+		}
+		return out
+	}
+	if len(result.Rows) == 0 {
+		return "Empty set\n"
+	}
+
+	rows := make([][]string, len(result.Rows))
+	for i, row := range result.Rows {
+		rows[i] = make([]string, len(row))
+		for j, value := range row {
+			switch {
+			case value == engine.Null:
+				value = "NULL"
+			case j < len(result.Types) && result.Types[j] == engine.Blob:
+				value = "0x" + strings.ToUpper(hex.EncodeToString([]byte(value)))
+			}
+			rows[i][j] = value
+		}
+	}
+
+	widths := make([]int, len(result.Columns))
+	for i, column := range result.Columns {
+		widths[i] = utf8.RuneCountInString(column)
+	}
+	for _, row := range rows {
+		for i, value := range row {
+			if n := utf8.RuneCountInString(value); n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+
+	var sb strings.Builder
+	border := func() {
+		for _, width := range widths {
+			sb.WriteString("+" + strings.Repeat("-", width+2))
+		}
+		sb.WriteString("+\n")
+	}
+	line := func(values []string) {
+		for i, value := range values {
+			fmt.Fprintf(&sb, "| %-*s ", widths[i], value)
+		}
+		sb.WriteString("|\n")
+	}
+
+	border()
+	line(result.Columns)
+	border()
+	for _, row := range rows {
+		line(row)
+	}
+	border()
+	fmt.Fprintf(&sb, "%s in set\n", plural(len(result.Rows), "row"))
+	return sb.String()
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, noun)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
@@ -0,0 +1,100 @@
+package api
+
+import (
+	"dbngin3/engine"
+	"dbngin3/executor"
+	"testing"
+)
+
+func TestFormatResult(t *testing.T) {
+	tests := []struct {
+		name     string
+		result   *executor.Result
+		expected string
+	}{
+		{
+			name: "Rows as a table",
+			result: &executor.Result{
+				Columns: []string{"id", "name"},
+				Rows:    []engine.Row{{"1", "John"}, {"10", "Jo"}},
+			},
+			expected: "+----+------+\n" +
+				"| id | name |\n" +
+				"+----+------+\n" +
+				"| 1  | John |\n" +
+				"| 10 | Jo   |\n" +
+				"+----+------+\n" +
+				"2 rows in set\n",
+		},
+		{
+			name: "NULL is spelled out",
+			result: &executor.Result{
+				Columns: []string{"id", "name"},
+				Rows:    []engine.Row{{"1", engine.Null}},
+			},
+			expected: "+----+------+\n" +
+				"| id | name |\n" +
+				"+----+------+\n" +
+				"| 1  | NULL |\n" +
+				"+----+------+\n" +
+				"1 row in set\n",
+		},
+		{
+			name: "Widths count characters",
+			result: &executor.Result{
+				Columns: []string{"word"},
+				Rows:    []engine.Row{{"naïve"}, {"ab"}},
+			},
+			expected: "+-------+\n" +
+				"| word  |\n" +
+				"+-------+\n" +
+				"| naïve |\n" +
+				"| ab    |\n" +
+				"+-------+\n" +
+				"2 rows in set\n",
+		},
+		{
+			name: "BLOB values in hex",
+			result: &executor.Result{
+				Columns: []string{"id", "data"},
+				Types:   []engine.DataType{engine.Int, engine.Blob},
+				Rows:    []engine.Row{{"1", "\x00\x1b\xff"}, {"2", engine.Null}},
+			},
+			expected: "+----+----------+\n" +
+				"| id | data     |\n" +
+				"+----+----------+\n" +
+				"| 1  | 0x001BFF |\n" +
+				"| 2  | NULL     |\n" +
+				"+----+----------+\n" +
+				"2 rows in set\n",
+		},
+		{
+			name:     "Query without rows",
+			result:   &executor.Result{Columns: []string{"id"}},
+			expected: "Empty set\n",
+		},
+		{
+			name:     "Single affected row",
+			result:   &executor.Result{Affected: 1},
+			expected: "Query OK, 1 row affected\n",
+		},
+		{
+			name:     "Upsert with duplicates",
+			result:   &executor.Result{Affected: 3, Records: 2, Duplicates: 1},
+			expected: "Query OK, 3 rows affected\nRecords: 2  Duplicates: 1  Warnings: 0\n",
+		},
+		{
+			name:     "No affected rows",
+			result:   &executor.Result{},
+			expected: "Query OK, 0 rows affected\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatResult(tt.result); got != tt.expected {
+				t.Errorf("expected:\n%s\ngot:\n%s", tt.expected, got)
+			}
+		})
+	}
+}
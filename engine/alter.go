@@ -0,0 +1,178 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+)
+
+// The schema changes below never modify a table, they return a changed copy
+// with the next schema version. Stored rows are not rewritten: they are
+// upgraded to the current layout whenever they are read, and written in it
+// once they are updated.
+
+// layout returns the column ids of the current version.
+func (t *Table) layout() []int {
+	ids := make([]int, len(t.Columns))
+	for i, column := range t.Columns {
+		ids[i] = column.ID
+	}
+	return ids
+}
+
+// Upgrade converts a row stored with the given schema version to the current
+// columns. Columns added later get the default they were added with, values
+// of columns whose type changed are converted to the new type. A value that
+// does not fit it fails, which the check of the stored rows before a schema
+// change is committed prevents for the rows that are still live.
+func (t *Table) Upgrade(version int, row Row) (Row, error) {
+	if version == t.Version {
+		return row, nil
+	}
+
+	layout, ok := t.Layouts[version]
+	if !ok || len(layout) != len(row) {
+		return nil, fmt.Errorf("row of unknown schema version %d of table %s", version, t.Name)
+	}
+
+	upgraded := make(Row, len(t.Columns))
+	for i, column := range t.Columns {
+		value, ok := t.Added[column.ID]
+		if !ok {
+			// catalogs written before the defaults were kept
+			value = column.DefaultValue()
+		}
+		for j, id := range layout {
+			if id == column.ID {
+				value = row[j]
+				break
+			}
+		}
+
+		var err error
+		if upgraded[i], err = column.Normalize(value); err != nil {
+			return nil, err
+		}
+	}
+	return upgraded, nil
+}
+
+// nextColumnID returns an id no version of the table has used.
+func (t *Table) nextColumnID() int {
+	next := 1
+	for _, id := range t.layout() {
+		if id >= next {
+			next = id + 1
+		}
+	}
+	for _, layout := range t.Layouts {
+		for _, id := range layout {
+			if id >= next {
+				next = id + 1
+			}
+		}
+	}
+	return next
+}
+
+// evolve copies the table for the next schema version.
+func (t *Table) evolve() *Table {
+	next := &Table{
+		ID:      t.ID,
+		Name:    t.Name,
+		Columns: append([]Column(nil), t.Columns...),
+		Version: t.Version + 1,
+		Layouts: make(map[int][]int, len(t.Layouts)+1),
+		Added:   make(map[int]string, len(t.Added)+1),
+	}
+	for version, layout := range t.Layouts {
+		next.Layouts[version] = layout
+	}
+	for id, value := range t.Added {
+		next.Added[id] = value
+	}
+	next.Layouts[t.Version] = t.layout()
+	return next
+}
+
+func (t *Table) AddColumn(column Column) (*Table, error) {
+	if t.ColumnIndex(column.Name) >= 0 {
+		return nil, errors.New("duplicate column name " + column.Name)
+	}
+	if column.PrimaryKey {
+		return nil, errors.New("cannot add a primary key to table " + t.Name)
+	}
+
+	value, err := column.Normalize(column.DefaultValue())
+	if err != nil {
+		return nil, err
+	}
+
+	next := t.evolve()
+	column.ID = t.nextColumnID()
+	next.Columns = append(next.Columns, column)
+	next.Added[column.ID] = value
+	return next, nil
+}
+
+func (t *Table) DropColumn(name string) (*Table, error) {
+	i := t.ColumnIndex(name)
+	if i < 0 {
+		return nil, errors.New("column " + name + " not found in table " + t.Name)
+	}
+	if t.Columns[i].PrimaryKey {
+		return nil, errors.New("cannot drop the primary key column " + name)
+	}
+	if len(t.Columns) == 1 {
+		return nil, errors.New("cannot drop the only column of table " + t.Name)
+	}
+
+	next := t.evolve()
+	next.Columns = append(next.Columns[:i], next.Columns[i+1:]...)
+	return next, nil
+}
+
+func (t *Table) RenameColumn(name, newName string) (*Table, error) {
+	i := t.ColumnIndex(name)
+	if i < 0 {
+		return nil, errors.New("column " + name + " not found in table " + t.Name)
+	}
+	if j := t.ColumnIndex(newName); j >= 0 && j != i {
+		return nil, errors.New("duplicate column name " + newName)
+	}
+
+	next := t.evolve()
+	next.Columns[i].Name = newName
+	return next, nil
+}
+
+// ModifyColumn replaces the definition of the column of the same name. The
+// primary key stays where it is and keeps its type, its values are the keys
+// of the index.
+func (t *Table) ModifyColumn(column Column) (*Table, error) {
+	i := t.ColumnIndex(column.Name)
+	if i < 0 {
+		return nil, errors.New("column " + column.Name + " not found in table " + t.Name)
+	}
+
+	old := t.Columns[i]
+	if column.PrimaryKey && !old.PrimaryKey {
+		return nil, errors.New("cannot add a primary key to table " + t.Name)
+	}
+	if old.PrimaryKey {
+		if column.Type != old.Type {
+			return nil, errors.New("cannot change the type of the primary key column " + old.Name)
+		}
+		column.PrimaryKey, column.Unique, column.NotNull = true, true, true
+	}
+
+	next := t.evolve()
+	column.ID = old.ID
+	next.Columns[i] = column
+	return next, nil
+}
+
+func (t *Table) Rename(name string) *Table {
+	next := t.evolve()
+	next.Name = name
+	return next
+}
@@ -0,0 +1,127 @@
+package engine
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTable_Upgrade(t *testing.T) {
+	v0 := NewTable("users", []Column{
+		{ID: 1, Name: "id", Type: Int, PrimaryKey: true},
+		{ID: 2, Name: "name", Type: Varchar},
+	})
+
+	active := "yes"
+	v1, err := v0.AddColumn(Column{Name: "active", Type: Varchar, Default: &active})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v2, err := v1.DropColumn("name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v3, err := v2.AddColumn(Column{Name: "name", Type: Varchar})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v3.Columns[2].ID == 2 {
+		t.Errorf("expected the new name column to get a new id")
+	}
+
+	tests := []struct {
+		version  int
+		row      Row
+		expected Row
+	}{
+		{0, Row{"1", "alice"}, Row{"1", "yes", Null}},
+		{1, Row{"1", "alice", "no"}, Row{"1", "no", Null}},
+		{2, Row{"1", "no"}, Row{"1", "no", Null}},
+		{3, Row{"1", "no", "bob"}, Row{"1", "no", "bob"}},
+	}
+	for _, tt := range tests {
+		row, err := v3.Upgrade(tt.version, tt.row)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(row, tt.expected) {
+			t.Errorf("version %d: expected %v, got %v", tt.version, tt.expected, row)
+		}
+	}
+
+	if _, err := v3.Upgrade(7, Row{"1"}); err == nil {
+		t.Errorf("expected an error for an unknown version")
+	}
+	if len(v0.Columns) != 2 || v0.Version != 0 {
+		t.Errorf("expected the old version to stay unchanged, got %+v", v0)
+	}
+}
+
+func TestTable_Upgrade_AddedDefaults(t *testing.T) {
+	v0 := NewTable("t", []Column{{ID: 1, Name: "id", Type: Int, PrimaryKey: true}})
+
+	seven, nine := "7", "9"
+	v1, err := v0.AddColumn(Column{Name: "n", Type: Int, Default: &seven})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v2, err := v1.AddColumn(Column{Name: "z", Type: Int, NotNull: true, Default: &nine})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v3, err := v2.ModifyColumn(Column{Name: "n", Type: Varchar})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v4, err := v3.ModifyColumn(Column{Name: "z", Type: Int})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	row, err := v4.Upgrade(0, Row{"1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := (Row{"1", "7", "9"}); !reflect.DeepEqual(row, expected) {
+		t.Errorf("expected the defaults the columns were added with %v, got %v", expected, row)
+	}
+
+	t.Run("Values that do not fit the new type fail", func(t *testing.T) {
+		v5, err := v4.ModifyColumn(Column{Name: "n", Type: Int})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := v5.Upgrade(3, Row{"1", "abc", "9"}); err == nil {
+			t.Errorf("expected an error for a value that is no integer")
+		}
+	})
+}
+
+func TestTable_AlterErrors(t *testing.T) {
+	table := NewTable("users", []Column{
+		{ID: 1, Name: "id", Type: Int, PrimaryKey: true},
+		{ID: 2, Name: "name", Type: Varchar},
+	})
+
+	if _, err := table.AddColumn(Column{Name: "Name", Type: Varchar}); err == nil {
+		t.Errorf("expected adding a duplicate column to fail")
+	}
+	if renamed, err := table.RenameColumn("name", "NAME"); err != nil || renamed.Columns[1].Name != "NAME" {
+		t.Errorf("expected a column to be renamed to another case, got %v", err)
+	}
+	if _, err := table.DropColumn("id"); err == nil {
+		t.Errorf("expected dropping the primary key to fail")
+	}
+	if _, err := table.DropColumn("age"); err == nil {
+		t.Errorf("expected dropping an unknown column to fail")
+	}
+	if _, err := table.RenameColumn("name", "id"); err == nil {
+		t.Errorf("expected renaming onto an existing column to fail")
+	}
+	if _, err := table.ModifyColumn(Column{Name: "id", Type: Varchar}); err == nil {
+		t.Errorf("expected changing the type of the primary key to fail")
+	}
+	if _, err := table.ModifyColumn(Column{Name: "name", Type: Int, PrimaryKey: true}); err == nil {
+		t.Errorf("expected a second primary key to fail")
+	}
+}
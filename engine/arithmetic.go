@@ -0,0 +1,176 @@
+package engine
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"strconv"
+)
+
+// divisionScale is the number of fraction digits a quotient has beyond those
+// of its dividend.
+const divisionScale = 4
+
+var errOutOfRange = errors.New("value out of range")
+
+// Calculate applies the arithmetic operator + - * / or % to two literals of
+// the type. Integers of any size are calculated as BIGINT, a division by zero
+// is NULL, and so is the result if either value is.
+func Calculate(operator string, dataType DataType, a, b string) (string, error) {
+	if a == Null || b == Null {
+		return Null, nil
+	}
+
+	switch dataType {
+	case Int, SmallInt, BigInt:
+		if operator == "/" {
+			return Calculate(operator, Decimal, a, b)
+		}
+		return calculateInteger(operator, a, b)
+	case Double:
+		return calculateDouble(operator, a, b)
+	case Decimal:
+		return calculateDecimal(operator, a, b)
+	}
+	return "", errors.New("cannot calculate with values of type " + dataType.String())
+}
+
+func calculateInteger(operator string, a, b string) (string, error) {
+	x, err := strconv.ParseInt(a, 10, 64)
+	if err != nil {
+		return "", errors.New("invalid integer " + a)
+	}
+	y, err := strconv.ParseInt(b, 10, 64)
+	if err != nil {
+		return "", errors.New("invalid integer " + b)
+	}
+
+	var r int64
+	switch operator {
+	case "+":
+		r = x + y
+		if (r > x) != (y > 0) {
+			return "", errOutOfRange
+		}
+	case "-":
+		r = x - y
+		if (r < x) != (y > 0) {
+			return "", errOutOfRange
+		}
+	case "*":
+		r = x * y
+		if x != 0 && (r/x != y || x == -1 && y == math.MinInt64) {
+			return "", errOutOfRange
+		}
+	case "%":
+		if y == 0 {
+			return Null, nil
+		}
+		r = x % y
+	default:
+		return "", errors.New("unsupported operator " + operator)
+	}
+	return strconv.FormatInt(r, 10), nil
+}
+
+func calculateDouble(operator string, a, b string) (string, error) {
+	x, err := parseDouble(a)
+	if err != nil {
+		return "", err
+	}
+	y, err := parseDouble(b)
+	if err != nil {
+		return "", err
+	}
+
+	var r float64
+	switch operator {
+	case "+":
+		r = x + y
+	case "-":
+		r = x - y
+	case "*":
+		r = x * y
+	case "/", "%":
+		if y == 0 {
+			return Null, nil
+		}
+		if operator == "/" {
+			r = x / y
+		} else {
+			r = math.Mod(x, y)
+		}
+	default:
+		return "", errors.New("unsupported operator " + operator)
+	}
+
+	if math.IsInf(r, 0) || math.IsNaN(r) {
+		return "", errOutOfRange
+	}
+	if r == 0 {
+		r = 0
+	}
+	return formatDouble(r), nil
+}
+
+func calculateDecimal(operator string, a, b string) (string, error) {
+	x, err := parseDecimal(a)
+	if err != nil {
+		return "", err
+	}
+	y, err := parseDecimal(b)
+	if err != nil {
+		return "", err
+	}
+
+	scale := x.scale
+	if y.scale > scale {
+		scale = y.scale
+	}
+
+	var r decimal
+	switch operator {
+	case "+":
+		r = decimal{unscaled: new(big.Int).Add(x.rescale(scale).unscaled, y.rescale(scale).unscaled), scale: scale}
+	case "-":
+		r = decimal{unscaled: new(big.Int).Sub(x.rescale(scale).unscaled, y.rescale(scale).unscaled), scale: scale}
+	case "*":
+		r = decimal{unscaled: new(big.Int).Mul(x.unscaled, y.unscaled), scale: x.scale + y.scale}
+	case "/":
+		if y.unscaled.Sign() == 0 {
+			return Null, nil
+		}
+		r = x.divide(y, x.scale+divisionScale)
+	case "%":
+		if y.unscaled.Sign() == 0 {
+			return Null, nil
+		}
+		r = decimal{unscaled: new(big.Int).Rem(x.rescale(scale).unscaled, y.rescale(scale).unscaled), scale: scale}
+	default:
+		return "", errors.New("unsupported operator " + operator)
+	}
+
+	if r.scale > MaxScale {
+		r = r.rescale(MaxScale)
+	}
+	return r.String(), nil
+}
+
+// divide returns d / by with the given number of fraction digits, rounding
+// half away from zero.
+func (d decimal) divide(by decimal, scale int) decimal {
+	// d.unscaled * 10^(scale - d.scale + by.scale) / by.unscaled has the
+	// wanted scale, the exponent is never negative for scale >= d.scale
+	pow := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale-d.scale+by.scale)), nil)
+	numerator := new(big.Int).Mul(new(big.Int).Abs(d.unscaled), pow)
+	denominator := new(big.Int).Abs(by.unscaled)
+
+	q, r := new(big.Int).QuoRem(numerator, denominator, new(big.Int))
+	if r.Lsh(r, 1).Cmp(denominator) >= 0 {
+		q.Add(q, big.NewInt(1))
+	}
+	if d.unscaled.Sign()*by.unscaled.Sign() < 0 {
+		q.Neg(q)
+	}
+	return decimal{unscaled: q, scale: scale}
+}
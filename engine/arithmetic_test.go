@@ -0,0 +1,61 @@
+package engine
+
+import "testing"
+
+func TestCalculate(t *testing.T) {
+	tests := []struct {
+		operator string
+		dataType DataType
+		a, b     string
+		expected string
+	}{
+		{"+", BigInt, "2", "3", "5"},
+		{"-", Int, "2", "3", "-1"},
+		{"*", SmallInt, "-4", "3", "-12"},
+		{"%", BigInt, "-7", "3", "-1"},
+		{"/", BigInt, "7", "2", "3.5000"},
+		{"/", BigInt, "2", "3", "0.6667"},
+		{"/", Decimal, "-1.00", "3", "-0.333333"},
+		{"+", Decimal, "1.5", "2.25", "3.75"},
+		{"*", Decimal, "1.5", "2.25", "3.375"},
+		{"%", Decimal, "7.5", "2", "1.5"},
+		{"+", Double, "0.5", "1", "1.5"},
+		{"-", Double, "1", "1", "0"},
+		{"/", Double, "1", "4", "0.25"},
+		{"/", BigInt, "1", "0", Null},
+		{"%", BigInt, "1", "0", Null},
+		{"/", Double, "1", "0", Null},
+		{"+", BigInt, Null, "1", Null},
+	}
+
+	for _, test := range tests {
+		t.Run(test.a+" "+test.operator+" "+test.b, func(t *testing.T) {
+			result, err := Calculate(test.operator, test.dataType, test.a, test.b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, result)
+			}
+		})
+	}
+
+	for _, test := range []struct {
+		operator string
+		dataType DataType
+		a, b     string
+	}{
+		{"+", BigInt, "9223372036854775807", "1"},
+		{"-", BigInt, "-9223372036854775808", "1"},
+		{"*", BigInt, "-1", "-9223372036854775808"},
+		{"*", Double, "1e308", "10"},
+		{"+", Varchar, "1", "1"},
+		{"+", BigInt, "one", "1"},
+	} {
+		t.Run("reject "+test.a+" "+test.operator+" "+test.b, func(t *testing.T) {
+			if _, err := Calculate(test.operator, test.dataType, test.a, test.b); err == nil {
+				t.Errorf("expected an error")
+			}
+		})
+	}
+}
@@ -1,8 +1,24 @@
 package engine
 
 type Column struct {
-	Name string   `json:"name"`
-	Type DataType `json:"type"`
+	// ID identifies the column across renames, stored rows are laid out by
+	// column id.
+	ID         int      `json:"id,omitempty"`
+	Name       string   `json:"name"`
+	Type       DataType `json:"type"`
+	PrimaryKey bool     `json:"primary_key,omitempty"`
+	Unique     bool     `json:"unique,omitempty"`
+	NotNull    bool     `json:"not_null,omitempty"`
+	// Length limits the number of characters of a VARCHAR column, zero means
+	// unlimited.
+	Length int `json:"length,omitempty"`
+	// Precision and Scale are the number of digits of a DECIMAL column and
+	// how many of them follow the decimal point.
+	Precision int `json:"precision,omitempty"`
+	Scale     int `json:"scale,omitempty"`
+	// Default is the value of the column in rows that do not set it, it is
+	// never Null.
+	Default *string `json:"default,omitempty"`
 }
 
 func NewColumn(name string, dataType DataType) *Column {
@@ -11,3 +27,34 @@ func NewColumn(name string, dataType DataType) *Column {
 		Type: dataType,
 	}
 }
+
+// DefaultValue returns the default of the column. Without one it is NULL,
+// or the zero value of its type if the column is NOT NULL.
+func (c *Column) DefaultValue() string {
+	if c.Default != nil {
+		return *c.Default
+	}
+	if !c.NotNull && !c.PrimaryKey {
+		return Null
+	}
+	if value, err := c.Normalize(c.Type.zeroValue()); err == nil {
+		return value
+	}
+	return c.Type.zeroValue()
+}
+
+// DefaultPrecision is the precision of a DECIMAL column declared without one.
+const DefaultPrecision = 10
+
+// MaxPrecision and MaxScale limit the digits of a DECIMAL column.
+const (
+	MaxPrecision = 65
+	MaxScale     = 30
+)
+
+func (c *Column) precision() int {
+	if c.Precision == 0 {
+		return DefaultPrecision
+	}
+	return c.Precision
+}
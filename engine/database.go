@@ -0,0 +1,229 @@
+package engine
+
+import (
+	"dbngin3/storage"
+	"dbngin3/transaction"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	DataDir = "data"
+	LogFile = "wal.log"
+)
+
+// Database owns the data files of every table and the write-ahead log they
+// share. Opening it runs crash recovery before any statement is executed.
+//
+// Every table is stored in <dir>/table_<id>.db; tables with a primary key
+// also have an index in <dir>/table_<id>.idx. Files are named after the id of
+// the table rather than its name: ids are never reused, so log records of a
+// dropped table can never be replayed into a new table of the same name.
+type Database struct {
+	mu     sync.Mutex
+	dir    string
+	schema *SchemaManager
+	log    *storage.LogManager
+	txns   *transaction.Manager
+	stores map[string]*TableStore
+}
+
+func OpenDatabase(dir string, schema *SchemaManager) (*Database, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	log, err := storage.OpenLogManager(filepath.Join(dir, LogFile))
+	if err != nil {
+		return nil, err
+	}
+
+	db := &Database{
+		dir:    dir,
+		schema: schema,
+		log:    log,
+		stores: make(map[string]*TableStore),
+	}
+
+	// recovery has to see the files of every table before it replays the log
+	for _, table := range schema.Tables() {
+		if _, err := db.openStore(table); err != nil {
+			_ = db.Close()
+			return nil, err
+		}
+	}
+
+	lastTxn, err := log.Recover()
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	db.txns = transaction.NewManager(log, lastTxn)
+	for _, store := range db.stores {
+		store.txns = db.txns
+	}
+	return db, nil
+}
+
+func (db *Database) Schema() *SchemaManager {
+	return db.schema
+}
+
+func (db *Database) Transactions() *transaction.Manager {
+	return db.txns
+}
+
+// Store returns the storage of the table.
+func (db *Database) Store(name string) (*TableStore, error) {
+	table, err := db.schema.GetTable(name)
+	if err != nil {
+		return nil, err
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if store, ok := db.stores[name]; ok {
+		return store, nil
+	}
+	return db.openStore(table)
+}
+
+func (db *Database) heapPath(table *Table) string {
+	return filepath.Join(db.dir, fmt.Sprintf("table_%d.db", table.ID))
+}
+
+func (db *Database) indexPath(table *Table) string {
+	return filepath.Join(db.dir, fmt.Sprintf("table_%d.idx", table.ID))
+}
+
+func (db *Database) openStore(table *Table) (*TableStore, error) {
+	heap, err := storage.OpenHeapFile(db.heapPath(table))
+	if err != nil {
+		return nil, err
+	}
+	heap.SetLogManager(db.log)
+
+	store := &TableStore{heap: heap, txns: db.txns}
+	store.table.Store(table)
+	if pk := table.PrimaryKey(); pk != nil {
+		index, err := storage.OpenBTree(db.indexPath(table))
+		if err != nil {
+			_ = heap.Close()
+			return nil, err
+		}
+		index.SetLogManager(db.log)
+		store.index = index
+	}
+
+	db.stores[table.Name] = store
+	return store, nil
+}
+
+// CreateTable adds the table to the catalog and creates its files.
+func (db *Database) CreateTable(table *Table) error {
+	if err := db.schema.CreateTable(table); err != nil {
+		return err
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	_, err := db.openStore(table)
+	return err
+}
+
+// DropTable removes the table from the catalog and deletes its files. The
+// transaction locks the table exclusively first, so the table is only
+// dropped once every transaction that used it has finished.
+func (db *Database) DropTable(txn *transaction.Transaction, name string) error {
+	store, err := db.Store(name)
+	if err != nil {
+		return err
+	}
+	if err := store.LockTable(txn, transaction.Exclusive); err != nil {
+		return err
+	}
+
+	if err := db.schema.DropTable(name); err != nil {
+		return err
+	}
+
+	db.mu.Lock()
+	delete(db.stores, name)
+	db.mu.Unlock()
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	store.dropped = true
+	if err := store.close(); err != nil {
+		return err
+	}
+
+	if err := os.Remove(db.heapPath(store.Table())); err != nil {
+		return err
+	}
+	if store.index != nil {
+		return os.Remove(db.indexPath(store.Table()))
+	}
+	return nil
+}
+
+// AlterTable replaces the definition of the table with the one change
+// derives from it. The transaction locks the table exclusively first; the
+// stored rows are checked against the new definition but not rewritten.
+func (db *Database) AlterTable(txn *transaction.Transaction, name string, change func(table *Table) (*Table, error)) error {
+	store, err := db.Store(name)
+	if err != nil {
+		return err
+	}
+	if err := store.LockTable(txn, transaction.Exclusive); err != nil {
+		return err
+	}
+
+	table, err := change(store.Table())
+	if err != nil {
+		return err
+	}
+	if err := store.check(txn, table); err != nil {
+		return err
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if err := db.schema.ReplaceTable(name, table); err != nil {
+		return err
+	}
+
+	store.table.Store(table)
+	if table.Name != name {
+		delete(db.stores, name)
+		db.stores[table.Name] = store
+	}
+	return nil
+}
+
+// Close flushes every table and the log. Dirty pages are written back in
+// log order, so closing in the middle of a transaction is safe: recovery
+// rolls it back on the next open.
+func (db *Database) Close() error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	var err error
+	for name, store := range db.stores {
+		if closeErr := store.close(); err == nil {
+			err = closeErr
+		}
+		delete(db.stores, name)
+	}
+
+	if closeErr := db.log.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
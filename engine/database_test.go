@@ -0,0 +1,345 @@
+package engine
+
+import (
+	"dbngin3/storage"
+	"dbngin3/transaction"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestSchema(t *testing.T) *SchemaManager {
+	schema, err := OpenSchemaManager(filepath.Join(t.TempDir(), "schema.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema.AddTable("users", NewTable("users", []Column{
+		{Name: "id", Type: Int, PrimaryKey: true},
+		{Name: "name", Type: Varchar},
+	}))
+	return schema
+}
+
+func openTestDatabase(t *testing.T, dir string) *Database {
+	db, err := OpenDatabase(dir, newTestSchema(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func scanRows(t *testing.T, store *TableStore, txn *transaction.Transaction) []Row {
+	var rows []Row
+	it := store.Scan(txn)
+	for {
+		tuple, err := it.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tuple == nil {
+			return rows
+		}
+		rows = append(rows, tuple.Row)
+	}
+}
+
+func TestDatabase_RecoveryOnOpen(t *testing.T) {
+	dir := t.TempDir()
+	db := openTestDatabase(t, dir)
+
+	store, _ := db.Store("users")
+	committed, _ := db.Transactions().Begin(transaction.RepeatableRead)
+	_, _ = store.Insert(committed, Row{"1", "John Doe"})
+	_ = db.Transactions().Commit(committed)
+
+	unfinished, _ := db.Transactions().Begin(transaction.RepeatableRead)
+	_, _ = store.Insert(unfinished, Row{"2", "Jane Doe"})
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	db = openTestDatabase(t, dir)
+	defer db.Close()
+	store, _ = db.Store("users")
+	txn, _ := db.Transactions().Begin(transaction.RepeatableRead)
+
+	t.Run("Only committed rows are left", func(t *testing.T) {
+		rows := scanRows(t, store, txn)
+		if len(rows) != 1 || rows[0][1] != "John Doe" {
+			t.Errorf("expected only John Doe, got %v", rows)
+		}
+
+		if _, err := store.Lookup(txn, "2"); err == nil {
+			t.Errorf("expected key of the unfinished transaction to be gone")
+		}
+	})
+
+	t.Run("New transactions get fresh ids", func(t *testing.T) {
+		if txn.ID() <= unfinished.ID() {
+			t.Errorf("expected id after %d, got %d", unfinished.ID(), txn.ID())
+		}
+	})
+}
+
+func TestDatabase_CreateAndDropTable(t *testing.T) {
+	dir := t.TempDir()
+	schema, err := OpenSchemaManager(filepath.Join(dir, "schema.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := OpenDatabase(dir, schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = db.Close() }()
+	txns := db.Transactions()
+
+	create := func() *TableStore {
+		table := NewTable("orders", []Column{{Name: "id", Type: Int, PrimaryKey: true}, {Name: "item", Type: Varchar}})
+		if err := db.CreateTable(table); err != nil {
+			t.Fatal(err)
+		}
+		store, err := db.Store("orders")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return store
+	}
+
+	store := create()
+	txn, _ := txns.Begin(transaction.RepeatableRead)
+	_, _ = store.Insert(txn, Row{"1", "book"})
+	_ = txns.Commit(txn)
+
+	t.Run("Drop waits for transactions using the table", func(t *testing.T) {
+		writer, _ := txns.Begin(transaction.RepeatableRead)
+		_, _ = store.Insert(writer, Row{"2", "pen"})
+
+		ddl, _ := txns.Begin(transaction.RepeatableRead)
+		txns.Locks().SetTimeout(10 * time.Millisecond)
+		defer txns.Locks().SetTimeout(transaction.DefaultLockTimeout)
+		if err := db.DropTable(ddl, "orders"); !errors.Is(err, transaction.ErrLockTimeout) {
+			t.Errorf("expected %v, got %v", transaction.ErrLockTimeout, err)
+		}
+		_ = txns.Rollback(ddl)
+		_ = txns.Commit(writer)
+	})
+
+	t.Run("Dropped table and its files are gone", func(t *testing.T) {
+		ddl, _ := txns.Begin(transaction.RepeatableRead)
+		if err := db.DropTable(ddl, "orders"); err != nil {
+			t.Fatal(err)
+		}
+		_ = txns.Commit(ddl)
+
+		if _, err := db.Store("orders"); err == nil {
+			t.Errorf("expected the table to be gone")
+		}
+		if _, err := os.Stat(db.heapPath(store.Table())); !os.IsNotExist(err) {
+			t.Errorf("expected the heap file to be removed, got %v", err)
+		}
+		late, _ := txns.Begin(transaction.RepeatableRead)
+		defer txns.Rollback(late)
+		if _, err := store.Insert(late, Row{"3", "ink"}); err == nil || errors.Is(err, transaction.ErrNotActive) {
+			t.Errorf("expected writes through the old store to fail, got %v", err)
+		}
+	})
+
+	t.Run("Table of the same name starts empty after a restart", func(t *testing.T) {
+		store = create()
+		_ = db.Close()
+
+		if db, err = OpenDatabase(dir, schema); err != nil {
+			t.Fatal(err)
+		}
+		store, _ = db.Store("orders")
+		txn, _ := db.Transactions().Begin(transaction.RepeatableRead)
+		defer db.Transactions().Commit(txn)
+
+		if rows := scanRows(t, store, txn); len(rows) != 0 {
+			t.Errorf("expected no rows, got %v", rows)
+		}
+	})
+}
+
+func TestDatabase_AlterTable(t *testing.T) {
+	dir := t.TempDir()
+	schema, err := OpenSchemaManager(filepath.Join(dir, "schema.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := OpenDatabase(dir, schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = db.Close() }()
+	txns := db.Transactions()
+
+	if err := db.CreateTable(NewTable("orders", []Column{{Name: "id", Type: Int, PrimaryKey: true}, {Name: "item", Type: Varchar}})); err != nil {
+		t.Fatal(err)
+	}
+	store, _ := db.Store("orders")
+	txn, _ := txns.Begin(transaction.RepeatableRead)
+	_, _ = store.Insert(txn, Row{"1", "book"})
+	_, _ = store.Insert(txn, Row{"2", "pen"})
+	_ = txns.Commit(txn)
+
+	alter := func(change func(*Table) (*Table, error)) error {
+		ddl, _ := txns.Begin(transaction.RepeatableRead)
+		defer txns.Commit(ddl)
+		return db.AlterTable(ddl, "orders", change)
+	}
+
+	t.Run("Old rows get the default of an added column", func(t *testing.T) {
+		qty := "1"
+		err := alter(func(table *Table) (*Table, error) {
+			return table.AddColumn(Column{Name: "qty", Type: Int, Default: &qty})
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		txn, _ := txns.Begin(transaction.RepeatableRead)
+		defer txns.Commit(txn)
+		expected := []Row{{"1", "book", "1"}, {"2", "pen", "1"}}
+		if rows := scanRows(t, store, txn); !reflect.DeepEqual(rows, expected) {
+			t.Errorf("expected %v, got %v", expected, rows)
+		}
+		if _, err := store.Insert(txn, Row{"3", "ink", "5"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("Modify fails on rows that do not fit", func(t *testing.T) {
+		err := alter(func(table *Table) (*Table, error) {
+			return table.ModifyColumn(Column{Name: "item", Type: Int})
+		})
+		if err == nil {
+			t.Errorf("expected an error")
+		}
+		if store.Table().Columns[1].Type != Varchar {
+			t.Errorf("expected the table to stay unchanged")
+		}
+	})
+
+	t.Run("Renames and drops survive a restart", func(t *testing.T) {
+		err := alter(func(table *Table) (*Table, error) {
+			table, err := table.DropColumn("item")
+			if err != nil {
+				return nil, err
+			}
+			if table, err = table.RenameColumn("qty", "quantity"); err != nil {
+				return nil, err
+			}
+			return table.Rename("purchases"), nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Store("orders"); err == nil {
+			t.Errorf("expected the old name to be gone")
+		}
+		_ = db.Close()
+
+		if schema, err = OpenSchemaManager(filepath.Join(dir, "schema.json")); err != nil {
+			t.Fatal(err)
+		}
+		if db, err = OpenDatabase(dir, schema); err != nil {
+			t.Fatal(err)
+		}
+		store, err := db.Store("purchases")
+		if err != nil {
+			t.Fatal(err)
+		}
+		txn, _ := db.Transactions().Begin(transaction.RepeatableRead)
+		defer db.Transactions().Commit(txn)
+
+		expected := []Row{{"1", "1"}, {"2", "1"}, {"3", "5"}}
+		if rows := scanRows(t, store, txn); !reflect.DeepEqual(rows, expected) {
+			t.Errorf("expected %v, got %v", expected, rows)
+		}
+		if name := store.Table().Columns[1].Name; name != "quantity" {
+			t.Errorf("expected column quantity, got %s", name)
+		}
+	})
+}
+
+func TestDatabase_AlterTable_OldRowsStayInPlace(t *testing.T) {
+	db := openTestDatabase(t, t.TempDir())
+	defer db.Close()
+	txns := db.Transactions()
+
+	if err := db.CreateTable(NewTable("notes", []Column{{Name: "id", Type: Int, PrimaryKey: true}, {Name: "text", Type: Varchar}})); err != nil {
+		t.Fatal(err)
+	}
+	store, _ := db.Store("notes")
+
+	// four long rows fill the first page
+	long := strings.Repeat("x", 900)
+	setup := begin(t, db, transaction.RepeatableRead)
+	var rids []storage.RID
+	for _, id := range []string{"1", "2", "3", "4"} {
+		rid, err := store.Insert(setup, Row{id, long})
+		if err != nil {
+			t.Fatal(err)
+		}
+		rids = append(rids, rid)
+	}
+	_ = txns.Commit(setup)
+
+	old := begin(t, db, transaction.RepeatableRead)
+
+	// upgraded to the new definition the old rows no longer fit their page
+	note := strings.Repeat("y", 600)
+	ddl := begin(t, db, transaction.RepeatableRead)
+	err := db.AlterTable(ddl, "notes", func(table *Table) (*Table, error) {
+		return table.AddColumn(Column{Name: "note", Type: Varchar, Default: &note})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = txns.Commit(ddl)
+
+	txn := begin(t, db, transaction.RepeatableRead)
+	if err := store.Delete(txn, rids[0]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Update(txn, rids[1], Row{"2", "short", "n"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Insert(txn, Row{"5", long, "n"}); err != nil {
+		t.Fatal(err)
+	}
+	_ = txns.Commit(txn)
+
+	txn = begin(t, db, transaction.RepeatableRead)
+	if _, err := store.Insert(txn, Row{"1", "again", "n"}); err != nil {
+		t.Fatalf("expected the deleted key to be free, got %v", err)
+	}
+	_ = txns.Commit(txn)
+
+	tests := []struct {
+		txn      *transaction.Transaction
+		key      string
+		expected Row
+	}{
+		{begin(t, db, transaction.RepeatableRead), "1", Row{"1", "again", "n"}},
+		{begin(t, db, transaction.RepeatableRead), "2", Row{"2", "short", "n"}},
+		{begin(t, db, transaction.RepeatableRead), "5", Row{"5", long, "n"}},
+		{old, "1", Row{"1", long, note}},
+		{old, "2", Row{"2", long, note}},
+	}
+	for _, test := range tests {
+		tuple, err := store.Lookup(test.txn, test.key)
+		if err != nil {
+			t.Fatalf("key %s: %v", test.key, err)
+		}
+		if !reflect.DeepEqual(tuple.Row, test.expected) {
+			t.Errorf("key %s: expected %.20v, got %.20v", test.key, test.expected, tuple.Row)
+		}
+	}
+}
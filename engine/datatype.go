@@ -1,8 +1,137 @@
 package engine
 
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
 type DataType int
 
+// The values of the types are stored in the catalog and in every encoded row,
+// new types have to be added at the end.
 const (
 	Int DataType = iota
 	Varchar
+	Boolean
+	SmallInt
+	BigInt
+	Double
+	Decimal
+	Date
+	Time
+	Timestamp
+	Text
+	Blob
 )
+
+var dataTypeNames = map[DataType]string{
+	Int:       "INT",
+	Varchar:   "VARCHAR",
+	Boolean:   "BOOLEAN",
+	SmallInt:  "SMALLINT",
+	BigInt:    "BIGINT",
+	Double:    "DOUBLE",
+	Decimal:   "DECIMAL",
+	Date:      "DATE",
+	Time:      "TIME",
+	Timestamp: "TIMESTAMP",
+	Text:      "TEXT",
+	Blob:      "BLOB",
+}
+
+func (d DataType) String() string {
+	if name, ok := dataTypeNames[d]; ok {
+		return name
+	}
+	return "UNKNOWN"
+}
+
+// MarshalJSON writes the type by name, the catalog stays readable and does
+// not depend on the order of the constants.
+func (d DataType) MarshalJSON() ([]byte, error) {
+	if _, ok := dataTypeNames[d]; !ok {
+		return nil, errors.New("unknown data type " + d.String())
+	}
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON reads a type name, or the number older catalogs stored.
+func (d *DataType) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err != nil {
+		var number int
+		if json.Unmarshal(data, &number) != nil {
+			return err
+		}
+		if _, ok := dataTypeNames[DataType(number)]; !ok {
+			return errors.New("unknown data type in catalog")
+		}
+		*d = DataType(number)
+		return nil
+	}
+
+	dataType, err := ParseDataType(name)
+	if err != nil {
+		return err
+	}
+	*d = dataType
+	return nil
+}
+
+// ParseDataType maps the type name of a column definition to its type.
+func ParseDataType(name string) (DataType, error) {
+	switch strings.ToUpper(name) {
+	case "INT", "INTEGER":
+		return Int, nil
+	case "VARCHAR", "CHAR":
+		return Varchar, nil
+	case "BOOLEAN", "BOOL":
+		return Boolean, nil
+	case "SMALLINT":
+		return SmallInt, nil
+	case "BIGINT":
+		return BigInt, nil
+	case "DOUBLE", "FLOAT", "REAL":
+		return Double, nil
+	case "DECIMAL", "NUMERIC":
+		return Decimal, nil
+	case "DATE":
+		return Date, nil
+	case "TIME":
+		return Time, nil
+	case "TIMESTAMP", "DATETIME":
+		return Timestamp, nil
+	case "TEXT":
+		return Text, nil
+	case "BLOB":
+		return Blob, nil
+	}
+	return 0, errors.New("unknown data type " + name)
+}
+
+// IsNumeric reports whether values of the type are numbers.
+func (d DataType) IsNumeric() bool {
+	switch d {
+	case Int, SmallInt, BigInt, Double, Decimal:
+		return true
+	}
+	return false
+}
+
+// zeroValue is the value a column of the type has when nothing else is known.
+func (d DataType) zeroValue() string {
+	switch d {
+	case Int, SmallInt, BigInt, Double, Decimal:
+		return "0"
+	case Boolean:
+		return "false"
+	case Date:
+		return "1970-01-01"
+	case Time:
+		return "00:00:00"
+	case Timestamp:
+		return "1970-01-01 00:00:00"
+	}
+	return ""
+}
@@ -0,0 +1,63 @@
+package engine
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDataType_JSON(t *testing.T) {
+	t.Run("Types are stored by name", func(t *testing.T) {
+		data, err := json.Marshal(Column{Name: "price", Type: Decimal, Precision: 8, Scale: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := `{"name":"price","type":"DECIMAL","precision":8,"scale":2}`
+		if string(data) != expected {
+			t.Errorf("expected %s, got %s", expected, data)
+		}
+
+		var column Column
+		if err := json.Unmarshal(data, &column); err != nil {
+			t.Fatal(err)
+		}
+		if column.Type != Decimal || column.Precision != 8 || column.Scale != 2 {
+			t.Errorf("expected DECIMAL(8,2), got %+v", column)
+		}
+	})
+
+	t.Run("Older catalogs store numbers", func(t *testing.T) {
+		var column Column
+		if err := json.Unmarshal([]byte(`{"name":"name","type":1}`), &column); err != nil {
+			t.Fatal(err)
+		}
+		if column.Type != Varchar {
+			t.Errorf("expected VARCHAR, got %s", column.Type)
+		}
+	})
+
+	t.Run("Unknown type is rejected", func(t *testing.T) {
+		var column Column
+		if err := json.Unmarshal([]byte(`{"name":"name","type":"MONEY"}`), &column); err == nil {
+			t.Errorf("expected error for unknown type")
+		}
+	})
+}
+
+func TestParseDataType(t *testing.T) {
+	tests := map[string]DataType{
+		"int":      Int,
+		"INTEGER":  Int,
+		"bool":     Boolean,
+		"FLOAT":    Double,
+		"numeric":  Decimal,
+		"DATETIME": Timestamp,
+		"TEXT":     Text,
+		"blob":     Blob,
+	}
+
+	for name, expected := range tests {
+		if dataType, err := ParseDataType(name); err != nil || dataType != expected {
+			t.Errorf("%s: expected %s, got %s (%v)", name, expected, dataType, err)
+		}
+	}
+}
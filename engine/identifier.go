@@ -0,0 +1,72 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// IdentifierCase is how the names of tables written in a query are matched
+// against the catalog. Quoted names are always taken as written. Column
+// names match in any case either way, see Table.ColumnIndex.
+type IdentifierCase int
+
+const (
+	// CaseSensitive keeps names as written, like MySQL on Linux: users and
+	// Users are two different tables.
+	CaseSensitive IdentifierCase = iota
+	// LowerCase folds unquoted names to lower case, Users and USERS both
+	// name the table users.
+	LowerCase
+)
+
+var identifierCaseNames = map[IdentifierCase]string{
+	CaseSensitive: "sensitive",
+	LowerCase:     "lower",
+}
+
+func (c IdentifierCase) String() string {
+	if name, ok := identifierCaseNames[c]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// ParseIdentifierCase maps sensitive or lower to its IdentifierCase.
+func ParseIdentifierCase(name string) (IdentifierCase, error) {
+	for identifierCase, n := range identifierCaseNames {
+		if strings.EqualFold(name, n) {
+			return identifierCase, nil
+		}
+	}
+	return 0, errors.New("unknown identifier case " + name)
+}
+
+// Fold returns the name the catalog knows the identifier by.
+func (c IdentifierCase) Fold(name string, quoted bool) string {
+	if c == LowerCase && !quoted {
+		return strings.ToLower(name)
+	}
+	return name
+}
+
+func (c IdentifierCase) MarshalJSON() ([]byte, error) {
+	if _, ok := identifierCaseNames[c]; !ok {
+		return nil, errors.New("unknown identifier case " + c.String())
+	}
+	return json.Marshal(c.String())
+}
+
+func (c *IdentifierCase) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err != nil {
+		return err
+	}
+
+	identifierCase, err := ParseIdentifierCase(name)
+	if err != nil {
+		return err
+	}
+	*c = identifierCase
+	return nil
+}
@@ -0,0 +1,82 @@
+package engine
+
+import (
+	"dbngin3/storage"
+	"encoding/binary"
+	"errors"
+	"math"
+	"strconv"
+)
+
+// EncodeKey converts a literal of the given column type into a B+ tree key
+// that preserves the ordering of the type.
+func EncodeKey(dataType DataType, value string) (storage.Key, error) {
+	if value == Null {
+		return nil, errors.New("NULL has no key")
+	}
+
+	switch dataType {
+	case Int, SmallInt, BigInt:
+		v, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, errors.New("invalid integer key " + value)
+		}
+		return storage.IntKey(v), nil
+	case Boolean:
+		b, err := parseBool(value)
+		if err != nil {
+			return nil, err
+		}
+		if b {
+			return storage.Key{1}, nil
+		}
+		return storage.Key{0}, nil
+	case Double:
+		f, err := parseDouble(value)
+		if err != nil {
+			return nil, err
+		}
+		// positive numbers sort by their bits once the sign bit is set,
+		// negative ones by their inverted bits
+		bits := math.Float64bits(f)
+		if f < 0 {
+			bits = ^bits
+		} else {
+			bits |= 1 << 63
+		}
+		return binary.BigEndian.AppendUint64(nil, bits), nil
+	case Decimal:
+		d, err := parseDecimal(value)
+		if err != nil {
+			return nil, err
+		}
+		return d.key(), nil
+	case Date:
+		days, err := parseDate(value)
+		return storage.IntKey(days), err
+	case Time:
+		micros, err := parseTime(value)
+		return storage.IntKey(micros), err
+	case Timestamp:
+		micros, err := parseTimestamp(value)
+		return storage.IntKey(micros), err
+	case Varchar, Text, Blob:
+		return storage.StringKey(value), nil
+	}
+
+	return nil, errors.New("unsupported key type")
+}
+
+// CompareValues orders two literals of the given type. NULL is not ordered,
+// comparing it is an error.
+func CompareValues(dataType DataType, a, b string) (int, error) {
+	x, err := EncodeKey(dataType, a)
+	if err != nil {
+		return 0, err
+	}
+	y, err := EncodeKey(dataType, b)
+	if err != nil {
+		return 0, err
+	}
+	return storage.CompareKeys(x, y), nil
+}
@@ -0,0 +1,76 @@
+package engine
+
+import (
+	"dbngin3/storage"
+	"testing"
+)
+
+func TestEncodeKey_IntOrder(t *testing.T) {
+	small, err := EncodeKey(Int, "-12")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	large, err := EncodeKey(Int, "9")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Negative numbers sort first", func(t *testing.T) {
+		if storage.CompareKeys(small, large) >= 0 {
+			t.Errorf("expected -12 to sort before 9")
+		}
+	})
+
+	t.Run("Invalid integer", func(t *testing.T) {
+		if _, err := EncodeKey(Int, "abc"); err == nil {
+			t.Errorf("expected error for non numeric key")
+		}
+	})
+}
+
+func TestEncodeKey_Varchar(t *testing.T) {
+	key, err := EncodeKey(Varchar, "john")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(key) != "john" {
+		t.Errorf("expected john, got %s", key)
+	}
+}
+
+func TestCompareValues(t *testing.T) {
+	tests := []struct {
+		dataType DataType
+		a, b     string
+		expected int
+	}{
+		{Int, "-12", "9", -1},
+		{Boolean, "false", "true", -1},
+		{Double, "-2.5", "-1", -1},
+		{Double, "-1", "0.25", -1},
+		{Double, "10", "9.5", 1},
+		{Decimal, "12.50", "12.5", 0},
+		{Decimal, "-1.5", "-1.55", 1},
+		{Decimal, "-3", "0", -1},
+		{Decimal, "0.05", "0.5", -1},
+		{Decimal, "99.9", "100", -1},
+		{Date, "1999-12-31", "2000-01-01", -1},
+		{Time, "10:00:00", "09:59:59.9", 1},
+		{Timestamp, "2024-01-01", "2024-01-01 00:00:00", 0},
+		{Varchar, "abc", "abd", -1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.dataType.String()+" "+test.a+" "+test.b, func(t *testing.T) {
+			cmp, err := CompareValues(test.dataType, test.a, test.b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cmp != test.expected {
+				t.Errorf("expected %d, got %d", test.expected, cmp)
+			}
+		})
+	}
+}
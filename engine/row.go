@@ -0,0 +1,68 @@
+package engine
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+var errCorruptRow = errors.New("corrupt row")
+
+// Row holds the values of a record in the column order of its table.
+type Row []string
+
+// EncodeRow serializes the values of a row of the given columns:
+//
+//	| number of values (uvarint) | null bitmap | values that are not NULL |
+//
+// Values are stored in their binary form and record their type, so a row
+// decodes without the definition it was written with.
+func EncodeRow(columns []Column, row Row) ([]byte, error) {
+	if len(row) != len(columns) {
+		return nil, errCorruptRow
+	}
+
+	buf := binary.AppendUvarint(nil, uint64(len(row)))
+	nulls := len(buf)
+	buf = append(buf, make([]byte, (len(row)+7)/8)...)
+	for i, value := range row {
+		if value == Null {
+			buf[nulls+i/8] |= 1 << (i % 8)
+			continue
+		}
+
+		var err error
+		if buf, err = appendValue(buf, columns[i].Type, value); err != nil {
+			return nil, err
+		}
+	}
+	return buf, nil
+}
+
+// DecodeRow returns the values of an encoded row in their canonical form.
+func DecodeRow(data []byte) (Row, error) {
+	n, size := binary.Uvarint(data)
+	if size <= 0 || uint64(len(data)-size) < (n+7)/8 {
+		return nil, errCorruptRow
+	}
+	nulls := data[size : size+int(n+7)/8]
+	data = data[size+len(nulls):]
+
+	row := make(Row, n)
+	for i := range row {
+		if nulls[i/8]&(1<<(i%8)) != 0 {
+			row[i] = Null
+			continue
+		}
+
+		value, size, err := readValue(data)
+		if err != nil {
+			return nil, err
+		}
+		row[i] = value
+		data = data[size:]
+	}
+	if len(data) != 0 {
+		return nil, errCorruptRow
+	}
+	return row, nil
+}
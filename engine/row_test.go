@@ -0,0 +1,74 @@
+package engine
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRow_EncodeDecode(t *testing.T) {
+	columns := []Column{
+		{Name: "id", Type: Int},
+		{Name: "note", Type: Varchar},
+		{Name: "name", Type: Text},
+		{Name: "active", Type: Boolean},
+		{Name: "rank", Type: SmallInt},
+		{Name: "views", Type: BigInt},
+		{Name: "score", Type: Double},
+		{Name: "price", Type: Decimal, Precision: 8, Scale: 2},
+		{Name: "born", Type: Date},
+		{Name: "alarm", Type: Time},
+		{Name: "seen", Type: Timestamp},
+		{Name: "photo", Type: Blob},
+	}
+	row := Row{"1", "", "Marty McFly", "true", "-7", "9000000000", "0.5", "-12.30", "1968-06-12", "07:30:00.25", "1985-10-26 01:21:00", "\x00\xff"}
+
+	data, err := EncodeRow(columns, row)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Row survives encoding", func(t *testing.T) {
+		res, err := DecodeRow(data)
+		if err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+
+		if !reflect.DeepEqual(res, row) {
+			t.Errorf("expected %v, got %v", row, res)
+		}
+	})
+
+	t.Run("Truncated row is rejected", func(t *testing.T) {
+		if _, err := DecodeRow(data[:len(data)-1]); err == nil {
+			t.Errorf("expected error for truncated row")
+		}
+	})
+
+	t.Run("NULL values are only in the bitmap", func(t *testing.T) {
+		nulls := make(Row, len(row))
+		copy(nulls, row)
+		nulls[0], nulls[9], nulls[11] = Null, Null, Null
+
+		encoded, err := EncodeRow(columns, nulls)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(encoded) >= len(data) {
+			t.Errorf("expected NULL values to take no space")
+		}
+
+		res, err := DecodeRow(encoded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(res, nulls) {
+			t.Errorf("expected %v, got %v", nulls, res)
+		}
+	})
+
+	t.Run("Invalid value is rejected", func(t *testing.T) {
+		if _, err := EncodeRow(columns[:1], Row{"one"}); err == nil {
+			t.Errorf("expected error for invalid integer")
+		}
+	})
+}
@@ -4,48 +4,247 @@ import (
 	"dbngin3/storage"
 	"encoding/json"
 	"errors"
+	"os"
+	"sort"
+	"sync"
 )
 
+const SchemaFile = "storage/schema.json"
+
 type Schema struct {
 	Tables []*Table `json:"tables"`
+	// NextTableID is the id the next created table gets.
+	NextTableID int `json:"next_table_id,omitempty"`
+	// IdentifierCase is how queries name the tables and columns, it is fixed
+	// once the catalog has tables.
+	IdentifierCase IdentifierCase `json:"identifier_case,omitempty"`
 }
 
+// SchemaManager is the catalog of tables. It is shared by every session and
+// safe for concurrent use. CreateTable, DropTable and ReplaceTable save the
+// catalog before they return.
 type SchemaManager struct {
+	mu     sync.RWMutex
+	path   string
 	tables map[string]*Table
+	nextID int
+
+	identifierCase IdentifierCase
 }
 
+// NewSchemaManager opens the catalog at SchemaFile.
 func NewSchemaManager() *SchemaManager {
-	storageObj, err := storage.Open("storage/schema.json")
+	sm, err := OpenSchemaManager(SchemaFile)
 	if err != nil {
 		panic(err)
 	}
+	return sm
+}
+
+// OpenSchemaManager loads the catalog saved at path. A missing file is an
+// empty catalog.
+func OpenSchemaManager(path string) (*SchemaManager, error) {
+	sm := &SchemaManager{path: path, tables: make(map[string]*Table), nextID: 1}
+
+	// a fresh working directory has no catalog yet
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return sm, nil
+	}
+
+	storageObj, err := storage.Open(path)
+	if err != nil {
+		return nil, err
+	}
 
 	file := storageObj.Read()
 	var schema Schema
 	err = json.Unmarshal(file, &schema)
 	if err != nil {
-		panic(err)
+		_ = storageObj.Close()
+		return nil, err
 	}
 	err = storageObj.Close()
 	if err != nil {
-		return nil
+		return nil, err
 	}
 
-	tables := make(map[string]*Table)
+	sm.identifierCase = schema.IdentifierCase
+	if schema.NextTableID > sm.nextID {
+		sm.nextID = schema.NextTableID
+	}
+	for _, table := range schema.Tables {
+		if table.ID >= sm.nextID {
+			sm.nextID = table.ID + 1
+		}
+	}
+	// catalogs written before tables had ids number them in file order
 	for _, table := range schema.Tables {
-		tables[table.Name] = table
+		sm.assignID(table)
+		sm.tables[table.Name] = table
 	}
 
-	return &SchemaManager{
-		tables: tables,
+	return sm, nil
+}
+
+// assignID numbers the table and its columns. It must be called with sm.mu
+// held.
+func (sm *SchemaManager) assignID(table *Table) {
+	if table.ID == 0 {
+		table.ID = sm.nextID
+		sm.nextID++
+	}
+
+	for i := range table.Columns {
+		if table.Columns[i].ID == 0 {
+			table.Columns[i].ID = table.nextColumnID()
+		}
 	}
 }
 
+// AddTable registers the table without saving the catalog.
 func (sm *SchemaManager) AddTable(name string, table *Table) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.assignID(table)
 	sm.tables[name] = table
 }
 
+// CreateTable adds the table and saves the catalog.
+func (sm *SchemaManager) CreateTable(table *Table) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if _, ok := sm.tables[table.Name]; ok {
+		return errors.New("table " + table.Name + " already exists")
+	}
+
+	table.ID = 0
+	sm.assignID(table)
+	sm.tables[table.Name] = table
+	if err := sm.save(); err != nil {
+		delete(sm.tables, table.Name)
+		return err
+	}
+	return nil
+}
+
+// DropTable removes the table and saves the catalog.
+func (sm *SchemaManager) DropTable(name string) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	table, ok := sm.tables[name]
+	if !ok {
+		return errors.New("table not found")
+	}
+
+	delete(sm.tables, name)
+	if err := sm.save(); err != nil {
+		sm.tables[name] = table
+		return err
+	}
+	return nil
+}
+
+// ReplaceTable stores a changed version of the table and saves the catalog.
+// The table may have been renamed.
+func (sm *SchemaManager) ReplaceTable(name string, table *Table) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	old, ok := sm.tables[name]
+	if !ok {
+		return errors.New("table not found")
+	}
+	if _, ok := sm.tables[table.Name]; ok && table.Name != name {
+		return errors.New("table " + table.Name + " already exists")
+	}
+
+	delete(sm.tables, name)
+	sm.tables[table.Name] = table
+	if err := sm.save(); err != nil {
+		delete(sm.tables, table.Name)
+		sm.tables[name] = old
+		return err
+	}
+	return nil
+}
+
+// IdentifierCase returns how queries name the tables and columns of the
+// catalog. Lookups compare names exactly, the lexer folds them beforehand.
+func (sm *SchemaManager) IdentifierCase() IdentifierCase {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	return sm.identifierCase
+}
+
+// SetIdentifierCase changes how queries name tables and columns and saves
+// the catalog. Existing names were matched the old way and might not be
+// found anymore, so it fails once the catalog has tables.
+func (sm *SchemaManager) SetIdentifierCase(identifierCase IdentifierCase) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if identifierCase == sm.identifierCase {
+		return nil
+	}
+	if len(sm.tables) > 0 {
+		return errors.New("identifier case cannot be changed once the catalog has tables")
+	}
+
+	old := sm.identifierCase
+	sm.identifierCase = identifierCase
+	if err := sm.save(); err != nil {
+		sm.identifierCase = old
+		return err
+	}
+	return nil
+}
+
+// save writes the catalog through a temporary file that replaces the old
+// one, a crash leaves either catalog behind but never a partial one. It must
+// be called with sm.mu held.
+func (sm *SchemaManager) save() error {
+	schema := Schema{Tables: sm.sortedTables(), NextTableID: sm.nextID, IdentifierCase: sm.identifierCase}
+	data, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	storageObj, err := storage.Open(sm.path)
+	if err != nil {
+		return err
+	}
+	if err := storageObj.Write(data); err != nil {
+		_ = storageObj.Close()
+		return err
+	}
+	return storageObj.Close()
+}
+
+// Tables returns every table of the catalog ordered by name.
+func (sm *SchemaManager) Tables() []*Table {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	return sm.sortedTables()
+}
+
+func (sm *SchemaManager) sortedTables() []*Table {
+	tables := make([]*Table, 0, len(sm.tables))
+	for _, table := range sm.tables {
+		tables = append(tables, table)
+	}
+
+	sort.Slice(tables, func(i, j int) bool {
+		return tables[i].Name < tables[j].Name
+	})
+	return tables
+}
+
 func (sm *SchemaManager) GetTable(name string) (*Table, error) {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
 	res, ok := sm.tables[name]
 	if !ok {
 		return nil, errors.New("table not found")
@@ -55,6 +254,9 @@ func (sm *SchemaManager) GetTable(name string) (*Table, error) {
 }
 
 func (sm *SchemaManager) IsTableExists(name string) bool {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
 	_, ok := sm.tables[name]
 	return ok
 }
@@ -1 +1,147 @@
 package engine
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSchemaManager_PersistsChanges(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "schema.json")
+
+	schema, err := OpenSchemaManager(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	users := NewTable("users", []Column{{Name: "id", Type: Int, PrimaryKey: true}})
+	if err := schema.CreateTable(users); err != nil {
+		t.Fatal(err)
+	}
+	if err := schema.CreateTable(NewTable("orders", []Column{{Name: "id", Type: Int}})); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Existing table cannot be created again", func(t *testing.T) {
+		if err := schema.CreateTable(NewTable("users", nil)); err == nil {
+			t.Errorf("expected error for existing table")
+		}
+	})
+
+	t.Run("Catalog survives a restart", func(t *testing.T) {
+		reopened, err := OpenSchemaManager(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		table, err := reopened.GetTable("users")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if table.ID != users.ID || table.PrimaryKey() == nil {
+			t.Errorf("expected %+v, got %+v", users, table)
+		}
+	})
+
+	t.Run("Dropped table is gone after a restart", func(t *testing.T) {
+		if err := schema.DropTable("orders"); err != nil {
+			t.Fatal(err)
+		}
+
+		reopened, err := OpenSchemaManager(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reopened.IsTableExists("orders") {
+			t.Errorf("expected orders to be dropped")
+		}
+	})
+
+	t.Run("Ids are not reused", func(t *testing.T) {
+		reopened, _ := OpenSchemaManager(path)
+		orders := NewTable("orders", []Column{{Name: "id", Type: Int}})
+		if err := reopened.CreateTable(orders); err != nil {
+			t.Fatal(err)
+		}
+		if orders.ID <= users.ID+1 {
+			t.Errorf("expected a fresh id after %d, got %d", users.ID+1, orders.ID)
+		}
+	})
+
+	t.Run("No temporary files are left", func(t *testing.T) {
+		entries, _ := os.ReadDir(dir)
+		if len(entries) != 1 {
+			t.Errorf("expected only the catalog, got %d files", len(entries))
+		}
+	})
+}
+
+func TestSchemaManager_NumbersLegacyCatalog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schema.json")
+	legacy := `{"tables": [{"name": "users", "columns": [{"name": "id", "type": 0}]}, {"name": "orders", "columns": []}]}`
+	if err := os.WriteFile(path, []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	schema, err := OpenSchemaManager(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	users, _ := schema.GetTable("users")
+	orders, _ := schema.GetTable("orders")
+	if users.ID != 1 || orders.ID != 2 {
+		t.Errorf("expected ids in file order, got %d and %d", users.ID, orders.ID)
+	}
+}
+
+func TestSchemaManager_IdentifierCase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schema.json")
+	schema, err := OpenSchemaManager(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if schema.IdentifierCase() != CaseSensitive {
+		t.Errorf("expected names to be case sensitive by default")
+	}
+	if err := schema.SetIdentifierCase(LowerCase); err != nil {
+		t.Fatal(err)
+	}
+	if err := schema.CreateTable(NewTable("users", nil)); err != nil {
+		t.Fatal(err)
+	}
+	if err := schema.SetIdentifierCase(CaseSensitive); err == nil {
+		t.Errorf("expected the case to be fixed once there are tables")
+	}
+
+	reopened, err := OpenSchemaManager(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reopened.IdentifierCase() != LowerCase {
+		t.Errorf("expected lower case names after a restart, got %v", reopened.IdentifierCase())
+	}
+}
+
+func TestIdentifierCase_Fold(t *testing.T) {
+	tests := []struct {
+		identifierCase IdentifierCase
+		name           string
+		quoted         bool
+		expected       string
+	}{
+		{CaseSensitive, "Users", false, "Users"},
+		{CaseSensitive, "Users", true, "Users"},
+		{LowerCase, "Users", false, "users"},
+		{LowerCase, "Users", true, "Users"},
+		{LowerCase, "ÄRGER", false, "ärger"},
+	}
+
+	for _, test := range tests {
+		if got := test.identifierCase.Fold(test.name, test.quoted); got != test.expected {
+			t.Errorf("expected %s to fold to %s, got %s", test.name, test.expected, got)
+		}
+	}
+}
@@ -1,8 +1,24 @@
 package engine
 
+import (
+	"fmt"
+	"strings"
+)
+
 type Table struct {
+	// ID is assigned by the schema manager and never reused, the files of
+	// the table are named after it.
+	ID      int      `json:"id,omitempty"`
 	Name    string   `json:"name"`
 	Columns []Column `json:"columns"`
+	// Version counts the schema changes of the table. Every stored row
+	// records the version it was written with, Layouts keeps the column ids
+	// of the older versions so that such rows can still be decoded. Added
+	// keeps, by column id, the value that rows written before a column was
+	// added have for it: its default at the time.
+	Version int            `json:"version,omitempty"`
+	Layouts map[int][]int  `json:"layouts,omitempty"`
+	Added   map[int]string `json:"added,omitempty"`
 }
 
 func NewTable(name string, columns []Column) *Table {
@@ -11,3 +27,42 @@ func NewTable(name string, columns []Column) *Table {
 		Columns: columns,
 	}
 }
+
+// PrimaryKey returns the primary key column, or nil if the table has none.
+func (t *Table) PrimaryKey() *Column {
+	for i := range t.Columns {
+		if t.Columns[i].PrimaryKey {
+			return &t.Columns[i]
+		}
+	}
+	return nil
+}
+
+// ColumnIndex returns the position of the column, or -1 if the table has no
+// such column. Like in MySQL column names match in any case, whatever the
+// identifier case of the catalog.
+func (t *Table) ColumnIndex(name string) int {
+	for i := range t.Columns {
+		if strings.EqualFold(t.Columns[i].Name, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Normalize checks that every value of the row fits its column and returns
+// the row with the values in their canonical form.
+func (t *Table) Normalize(row Row) (Row, error) {
+	if len(row) != len(t.Columns) {
+		return nil, fmt.Errorf("expected %d values, got %d", len(t.Columns), len(row))
+	}
+
+	normalized := make(Row, len(row))
+	for i := range t.Columns {
+		var err error
+		if normalized[i], err = t.Columns[i].Normalize(row[i]); err != nil {
+			return nil, err
+		}
+	}
+	return normalized, nil
+}
@@ -0,0 +1,658 @@
+package engine
+
+import (
+	"dbngin3/storage"
+	"dbngin3/transaction"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// Tuple is a row together with the place it is stored at.
+type Tuple struct {
+	RID storage.RID
+	Row Row
+}
+
+// TableStore keeps the rows of a table in a heap file and, for tables with a
+// primary key, maps every key to its newest row version through a B+ tree.
+//
+// Rows are never changed in place: an update stamps the current version with
+// the updating transaction and adds a new version, a delete only stamps it.
+// Readers pick the versions their snapshot sees and never wait for writers.
+// Writers hold an intention lock on the table and an exclusive lock on every
+// row they change until they finish, so a second writer of the same row waits
+// for the first one to commit or roll back. Changes to the files are
+// serialized per table so that checking and stamping a version is atomic.
+//
+// Unique columns other than the primary key have no index: inserting a value
+// locks it and reads the whole table to check that no row holds it yet.
+type TableStore struct {
+	mu      sync.Mutex
+	table   atomic.Pointer[Table]
+	heap    *storage.HeapFile
+	index   *storage.BTree
+	txns    *transaction.Manager
+	dropped bool
+}
+
+// Table returns the current definition of the table. A schema change
+// replaces it, rows read afterwards are decoded with the new definition.
+func (s *TableStore) Table() *Table {
+	return s.table.Load()
+}
+
+func (s *TableStore) Index() *storage.BTree {
+	return s.index
+}
+
+// Size is the number of bytes the rows of the table take on disk, versions
+// no transaction sees any more included.
+func (s *TableStore) Size() int {
+	return s.heap.NumPages() * storage.PageSize
+}
+
+func (s *TableStore) key(row Row) (storage.Key, error) {
+	pk := s.Table().PrimaryKey()
+	return EncodeKey(pk.Type, row[s.Table().ColumnIndex(pk.Name)])
+}
+
+// errTableChanged is returned to a writer that prepared a row for a table
+// definition that a schema change replaced while the writer waited for its
+// locks.
+var errTableChanged = errors.New("table definition has changed, please retry transaction")
+
+func (s *TableStore) version(rid storage.RID) (*version, error) {
+	data, err := s.heap.Get(rid)
+	if err != nil {
+		return nil, err
+	}
+	return s.decode(data)
+}
+
+// decode reads a stored version and upgrades its row to the current table
+// definition.
+func (s *TableStore) decode(data []byte) (*version, error) {
+	v, err := decodeVersion(data)
+	if err != nil {
+		return nil, err
+	}
+
+	table := s.Table()
+	if v.row, err = table.Upgrade(v.schema, v.row); err != nil {
+		return nil, err
+	}
+	v.schema = table.Version
+	return v, nil
+}
+
+// rowLock names the row a version belongs to: the primary key value, or the
+// place of the version in tables without a primary key.
+func (s *TableStore) rowLock(rid storage.RID, key storage.Key) transaction.Resource {
+	if s.index != nil {
+		return transaction.Resource{Table: s.Table().Name, Row: string(key)}
+	}
+	return transaction.Resource{Table: s.Table().Name, Row: rid.String()}
+}
+
+// uniqueLock names a value of a unique column.
+func (s *TableStore) uniqueLock(column int, value string) (transaction.Resource, error) {
+	key, err := EncodeKey(s.Table().Columns[column].Type, value)
+	if err != nil {
+		return transaction.Resource{}, err
+	}
+	return transaction.Resource{Table: s.Table().Name, Row: s.Table().Columns[column].Name + "=" + string(key)}, nil
+}
+
+// uniqueColumns returns the unique columns besides the primary key whose
+// value differs between the rows; old is nil for an insert. NULL is never a
+// duplicate.
+func (s *TableStore) uniqueColumns(old, row Row) []int {
+	var columns []int
+	for i, column := range s.Table().Columns {
+		if column.Unique && !column.PrimaryKey && row[i] != Null && (old == nil || old[i] != row[i]) {
+			columns = append(columns, i)
+		}
+	}
+	return columns
+}
+
+// lockRows takes the locks a writer holds until it finishes. It must be
+// called without s.mu held, the lock may have to wait for another writer.
+func (s *TableStore) lockRows(txn *transaction.Transaction, rows ...transaction.Resource) error {
+	if err := s.LockTable(txn, transaction.IntentionExclusive); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if err := s.txns.Lock(txn, row, transaction.Exclusive); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LockTable locks the whole table for the transaction. It fails if the table
+// was dropped while the lock was awaited.
+func (s *TableStore) LockTable(txn *transaction.Transaction, mode transaction.LockMode) error {
+	if err := s.txns.Lock(txn, transaction.Resource{Table: s.Table().Name}, mode); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.dropped {
+		return errors.New("table " + s.Table().Name + " doesn't exist")
+	}
+	return nil
+}
+
+func (s *TableStore) Insert(txn *transaction.Transaction, row Row) (storage.RID, error) {
+	table := s.Table()
+	row, err := table.Normalize(row)
+	if err != nil {
+		return storage.RID{}, err
+	}
+
+	key, unique, err := s.lockNew(txn, row)
+	if err != nil {
+		return storage.RID{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.Table() != table {
+		return storage.RID{}, errTableChanged
+	}
+
+	v := &version{xmin: txn.ID(), prev: noVersion, schema: table.Version, row: row}
+	if s.index != nil {
+		var err error
+		if v.prev, err = s.claimKey(txn, key); err != nil {
+			return storage.RID{}, err
+		}
+	}
+	if err := s.checkUnique(txn, row, unique, noVersion); err != nil {
+		return storage.RID{}, err
+	}
+
+	data, err := encodeVersion(table, v)
+	if err != nil {
+		return storage.RID{}, err
+	}
+	rid, err := s.heap.Insert(txn.ID(), data)
+	if err != nil {
+		return rid, err
+	}
+	return rid, s.pointIndex(txn, key, v.prev, rid)
+}
+
+// lockNew locks the primary key and the unique values of a new row and
+// returns the key and the unique columns to check.
+func (s *TableStore) lockNew(txn *transaction.Transaction, row Row) (storage.Key, []int, error) {
+	var key storage.Key
+	var rows []transaction.Resource
+	if s.index != nil {
+		var err error
+		if key, err = s.key(row); err != nil {
+			return nil, nil, err
+		}
+		rows = append(rows, s.rowLock(noVersion, key))
+	}
+	unique := s.uniqueColumns(nil, row)
+	for _, i := range unique {
+		lock, err := s.uniqueLock(i, row[i])
+		if err != nil {
+			return nil, nil, err
+		}
+		rows = append(rows, lock)
+	}
+	return key, unique, s.lockRows(txn, rows...)
+}
+
+// Conflicts returns the live rows that an insert of the row would collide
+// with: the one with its primary key, found through the index, followed by
+// those holding one of its unique values. The key and the values stay
+// locked like an insert locks them, so the rows remain the conflicts until
+// the transaction finishes.
+func (s *TableStore) Conflicts(txn *transaction.Transaction, row Row) ([]*Tuple, error) {
+	table := s.Table()
+	row, err := table.Normalize(row)
+	if err != nil {
+		return nil, err
+	}
+
+	key, unique, err := s.lockNew(txn, row)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.Table() != table {
+		return nil, errTableChanged
+	}
+
+	var tuples []*Tuple
+	if s.index != nil {
+		head, err := s.index.Get(key)
+		switch {
+		case errors.Is(err, storage.ErrKeyNotFound):
+		case err != nil:
+			return nil, err
+		default:
+			v, err := s.version(head)
+			if err != nil {
+				return nil, err
+			}
+			live, err := s.live(txn, v)
+			if err != nil {
+				return nil, err
+			}
+			if live {
+				tuples = append(tuples, &Tuple{RID: head, Row: v.row})
+			}
+		}
+	}
+	if len(unique) == 0 {
+		return tuples, nil
+	}
+
+	it := s.heap.Scan()
+	for {
+		rec, err := it.Next()
+		if err != nil || rec == nil {
+			return tuples, err
+		}
+		if len(tuples) > 0 && tuples[0].RID == rec.RID {
+			continue
+		}
+
+		v, err := s.decode(rec.Data)
+		if err != nil {
+			return nil, err
+		}
+		for _, i := range unique {
+			if cmp, err := CompareValues(table.Columns[i].Type, v.row[i], row[i]); err != nil || cmp != 0 {
+				continue
+			}
+
+			live, err := s.live(txn, v)
+			if err != nil {
+				return nil, err
+			}
+			if live {
+				tuples = append(tuples, &Tuple{RID: rec.RID, Row: v.row})
+			}
+			break
+		}
+	}
+}
+
+// Update replaces the row version at rid with a new version and returns
+// where the new version is stored.
+func (s *TableStore) Update(txn *transaction.Transaction, rid storage.RID, row Row) (storage.RID, error) {
+	table := s.Table()
+	row, err := table.Normalize(row)
+	if err != nil {
+		return rid, err
+	}
+
+	old, err := s.version(rid)
+	if err != nil {
+		return rid, err
+	}
+
+	var key, oldKey storage.Key
+	var rows []transaction.Resource
+	if s.index != nil {
+		if oldKey, err = s.key(old.row); err != nil {
+			return rid, err
+		}
+		if key, err = s.key(row); err != nil {
+			return rid, err
+		}
+		rows = append(rows, s.rowLock(rid, oldKey))
+		if storage.CompareKeys(oldKey, key) != 0 {
+			rows = append(rows, s.rowLock(rid, key))
+		}
+	} else {
+		rows = append(rows, s.rowLock(rid, nil))
+	}
+	unique := s.uniqueColumns(old.row, row)
+	for _, i := range unique {
+		lock, err := s.uniqueLock(i, row[i])
+		if err != nil {
+			return rid, err
+		}
+		rows = append(rows, lock)
+	}
+	if err := s.lockRows(txn, rows...); err != nil {
+		return rid, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.Table() != table {
+		return rid, errTableChanged
+	}
+	// the version may have been replaced while the lock was awaited
+	if old, err = s.version(rid); err != nil {
+		return rid, err
+	}
+	if err := checkWritable(txn, old); err != nil {
+		return rid, err
+	}
+
+	v := &version{xmin: txn.ID(), prev: rid, schema: table.Version, row: row}
+	// a new key starts a new chain, the old key keeps pointing at the old
+	// version for snapshots that still see it
+	if s.index != nil && storage.CompareKeys(oldKey, key) != 0 {
+		if v.prev, err = s.claimKey(txn, key); err != nil {
+			return rid, err
+		}
+	}
+	if err := s.checkUnique(txn, row, unique, rid); err != nil {
+		return rid, err
+	}
+
+	if err := s.stamp(txn, rid); err != nil {
+		return rid, err
+	}
+
+	data, err := encodeVersion(table, v)
+	if err != nil {
+		return rid, err
+	}
+	newRID, err := s.heap.Insert(txn.ID(), data)
+	if err != nil {
+		return newRID, err
+	}
+	return newRID, s.pointIndex(txn, key, v.prev, newRID)
+}
+
+func (s *TableStore) Delete(txn *transaction.Transaction, rid storage.RID) error {
+	v, err := s.version(rid)
+	if err != nil {
+		return err
+	}
+
+	var key storage.Key
+	if s.index != nil {
+		if key, err = s.key(v.row); err != nil {
+			return err
+		}
+	}
+	if err := s.lockRows(txn, s.rowLock(rid, key)); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if v, err = s.version(rid); err != nil {
+		return err
+	}
+	if err := checkWritable(txn, v); err != nil {
+		return err
+	}
+	return s.stamp(txn, rid)
+}
+
+// checkWritable makes the first writer of a version win: a version another
+// transaction already replaced or deleted cannot be changed anymore, whether
+// that transaction committed or is still running.
+func checkWritable(txn *transaction.Transaction, v *version) error {
+	switch v.xmax {
+	case storage.SystemTxn:
+		return nil
+	case txn.ID():
+		return errors.New("row was already changed by this statement")
+	}
+	return transaction.ErrWriteConflict
+}
+
+// stamp marks the version as deleted by the transaction. Only the header of
+// the stored record changes: a row written with an older definition of the
+// table keeps its encoding, re-encoding it upgraded could grow it out of its
+// page and move it away from the index entry and the versions chained to it.
+func (s *TableStore) stamp(txn *transaction.Transaction, rid storage.RID) error {
+	data, err := s.heap.Get(rid)
+	if err != nil {
+		return err
+	}
+	if err := setXmax(data, txn.ID()); err != nil {
+		return err
+	}
+
+	moved, err := s.heap.Update(txn.ID(), rid, data)
+	if err == nil && moved != rid {
+		err = fmt.Errorf("version %s moved to %s while stamped", rid, moved)
+	}
+	return err
+}
+
+// claimKey checks that no live version holds the key and returns the newest
+// version of the key, if there is one, to chain the new version to.
+func (s *TableStore) claimKey(txn *transaction.Transaction, key storage.Key) (storage.RID, error) {
+	head, err := s.index.Get(key)
+	if errors.Is(err, storage.ErrKeyNotFound) {
+		return noVersion, nil
+	}
+	if err != nil {
+		return noVersion, err
+	}
+
+	v, err := s.version(head)
+	if err != nil {
+		return noVersion, err
+	}
+
+	live, err := s.live(txn, v)
+	if err != nil {
+		return noVersion, err
+	}
+	if live {
+		return noVersion, fmt.Errorf("duplicate entry for primary key %s of table %s", s.Table().PrimaryKey().Name, s.Table().Name)
+	}
+	return head, nil
+}
+
+// live reports whether the version still holds its values for a writer: it
+// was not deleted, or only by the writer itself. A version whose fate depends
+// on a transaction that is still running is a conflict.
+func (s *TableStore) live(txn *transaction.Transaction, v *version) (bool, error) {
+	switch {
+	case v.xmax == storage.SystemTxn && (v.xmin == txn.ID() || !s.txns.IsActive(v.xmin)):
+		return true, nil
+	case v.xmax == storage.SystemTxn:
+		// inserted by a transaction that is still running
+		return false, transaction.ErrWriteConflict
+	case v.xmax != txn.ID() && s.txns.IsActive(v.xmax):
+		// deleted by a transaction that may still roll back
+		return false, transaction.ErrWriteConflict
+	}
+	return false, nil
+}
+
+// checkUnique fails if a live version other than skip holds the value of one
+// of the columns.
+func (s *TableStore) checkUnique(txn *transaction.Transaction, row Row, columns []int, skip storage.RID) error {
+	if len(columns) == 0 {
+		return nil
+	}
+
+	it := s.heap.Scan()
+	for {
+		rec, err := it.Next()
+		if err != nil || rec == nil {
+			return err
+		}
+		if rec.RID == skip {
+			continue
+		}
+
+		v, err := s.decode(rec.Data)
+		if err != nil {
+			return err
+		}
+		for _, i := range columns {
+			if cmp, err := CompareValues(s.Table().Columns[i].Type, v.row[i], row[i]); err != nil || cmp != 0 {
+				continue
+			}
+
+			live, err := s.live(txn, v)
+			if err != nil {
+				return err
+			}
+			if live {
+				return fmt.Errorf("duplicate entry '%s' for unique column %s of table %s", row[i], s.Table().Columns[i].Name, s.Table().Name)
+			}
+		}
+	}
+}
+
+// pointIndex makes the index entry of the key point at the new version.
+func (s *TableStore) pointIndex(txn *transaction.Transaction, key storage.Key, prev, rid storage.RID) error {
+	if s.index == nil {
+		return nil
+	}
+	if prev == noVersion {
+		return s.index.Insert(txn.ID(), key, rid)
+	}
+
+	head, err := s.index.Get(key)
+	if err != nil {
+		return err
+	}
+	if head == rid {
+		return nil
+	}
+	return s.index.Update(txn.ID(), key, rid)
+}
+
+// check makes sure the live rows fit the new definition of the table: their
+// values have to be valid for the column types and unique columns may not
+// hold a value twice.
+func (s *TableStore) check(txn *transaction.Transaction, table *Table) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seen := make(map[int]map[string]bool)
+	it := s.heap.Scan()
+	for {
+		rec, err := it.Next()
+		if err != nil || rec == nil {
+			return err
+		}
+
+		v, err := decodeVersion(rec.Data)
+		if err != nil {
+			return err
+		}
+		live, err := s.live(txn, v)
+		if err != nil {
+			return err
+		}
+		if !live {
+			continue
+		}
+
+		row, err := table.Upgrade(v.schema, v.row)
+		if err != nil {
+			return err
+		}
+		if row, err = table.Normalize(row); err != nil {
+			return err
+		}
+
+		for i, column := range table.Columns {
+			if !column.Unique || column.PrimaryKey || row[i] == Null {
+				continue
+			}
+			key, err := EncodeKey(column.Type, row[i])
+			if err != nil {
+				return err
+			}
+			if seen[i] == nil {
+				seen[i] = make(map[string]bool)
+			}
+			if seen[i][string(key)] {
+				return fmt.Errorf("duplicate entry '%s' for unique column %s of table %s", row[i], column.Name, table.Name)
+			}
+			seen[i][string(key)] = true
+		}
+	}
+}
+
+// Lookup finds the version of the row with the given primary key value that
+// the transaction sees. It returns storage.ErrKeyNotFound if there is none.
+func (s *TableStore) Lookup(txn *transaction.Transaction, value string) (*Tuple, error) {
+	if s.index == nil {
+		return nil, errors.New("table " + s.Table().Name + " has no primary key")
+	}
+
+	key, err := EncodeKey(s.Table().PrimaryKey().Type, value)
+	if err != nil {
+		return nil, err
+	}
+
+	rid, err := s.index.Get(key)
+	for err == nil && rid != noVersion {
+		var v *version
+		if v, err = s.version(rid); err != nil {
+			break
+		}
+		if txn.Visible(v.xmin, v.xmax) {
+			return &Tuple{RID: rid, Row: v.row}, nil
+		}
+		rid = v.prev
+	}
+	if err == nil {
+		err = storage.ErrKeyNotFound
+	}
+	return nil, err
+}
+
+// Scan returns the rows the transaction sees.
+func (s *TableStore) Scan(txn *transaction.Transaction) *TupleIterator {
+	return &TupleIterator{store: s, it: s.heap.Scan(), txn: txn}
+}
+
+func (s *TableStore) close() error {
+	err := s.heap.Close()
+	if s.index != nil {
+		if indexErr := s.index.Close(); err == nil {
+			err = indexErr
+		}
+	}
+	return err
+}
+
+// TupleIterator walks the visible rows of a table in storage order.
+type TupleIterator struct {
+	store *TableStore
+	it    *storage.HeapIterator
+	txn   *transaction.Transaction
+}
+
+// Next returns the next tuple, or nil once the table is exhausted.
+func (it *TupleIterator) Next() (*Tuple, error) {
+	for {
+		rec, err := it.it.Next()
+		if err != nil || rec == nil {
+			return nil, err
+		}
+
+		v, err := it.store.decode(rec.Data)
+		if err != nil {
+			return nil, err
+		}
+		if it.txn.Visible(v.xmin, v.xmax) {
+			return &Tuple{RID: rec.RID, Row: v.row}, nil
+		}
+	}
+}
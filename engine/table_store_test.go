@@ -0,0 +1,445 @@
+package engine
+
+import (
+	"dbngin3/storage"
+	"dbngin3/transaction"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTableStore_InsertUpdateDelete(t *testing.T) {
+	db := openTestDatabase(t, t.TempDir())
+	defer db.Close()
+
+	store, err := db.Store("users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	txn, _ := db.Transactions().Begin(transaction.RepeatableRead)
+
+	rid, err := store.Insert(txn, Row{"1", "John Doe"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Row is found through the primary key", func(t *testing.T) {
+		tuple, err := store.Lookup(txn, "1")
+		if err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+
+		if tuple.RID != rid || tuple.Row[1] != "John Doe" {
+			t.Errorf("expected John Doe at %v, got %v at %v", rid, tuple.Row, tuple.RID)
+		}
+	})
+
+	t.Run("Duplicate primary key is rejected", func(t *testing.T) {
+		if _, err := store.Insert(txn, Row{"1", "Jane Doe"}); err == nil {
+			t.Errorf("expected duplicate key error")
+		}
+	})
+
+	t.Run("Invalid integer is rejected", func(t *testing.T) {
+		if _, err := store.Insert(txn, Row{"one", "Jane Doe"}); err == nil {
+			t.Errorf("expected invalid integer error")
+		}
+	})
+
+	t.Run("Update of the primary key moves the index entry", func(t *testing.T) {
+		var err error
+		if rid, err = store.Update(txn, rid, Row{"2", "John Doe"}); err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+
+		if _, err := store.Lookup(txn, "1"); err == nil {
+			t.Errorf("expected old key to be gone")
+		}
+		if _, err := store.Lookup(txn, "2"); err != nil {
+			t.Errorf("expected new key, got %s", err)
+		}
+	})
+
+	t.Run("Delete removes row and index entry", func(t *testing.T) {
+		if err := store.Delete(txn, rid); err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+
+		if _, err := store.Lookup(txn, "2"); err == nil {
+			t.Errorf("expected key to be gone")
+		}
+		if rows := scanRows(t, store, txn); len(rows) != 0 {
+			t.Errorf("expected no rows, got %v", rows)
+		}
+	})
+}
+
+func openTestStore(t *testing.T) (*Database, *TableStore) {
+	db := openTestDatabase(t, t.TempDir())
+	t.Cleanup(func() { _ = db.Close() })
+
+	store, err := db.Store("users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db, store
+}
+
+func begin(t *testing.T, db *Database, isolation transaction.IsolationLevel) *transaction.Transaction {
+	txn, err := db.Transactions().Begin(isolation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return txn
+}
+
+func names(t *testing.T, store *TableStore, txn *transaction.Transaction) []string {
+	var res []string
+	for _, row := range scanRows(t, store, txn) {
+		res = append(res, row[1])
+	}
+	return res
+}
+
+func TestTableStore_SnapshotIsolation(t *testing.T) {
+	db, store := openTestStore(t)
+	txns := db.Transactions()
+
+	setup := begin(t, db, transaction.RepeatableRead)
+	rid, _ := store.Insert(setup, Row{"1", "John Doe"})
+	_ = txns.Commit(setup)
+
+	reader := begin(t, db, transaction.RepeatableRead)
+
+	writer := begin(t, db, transaction.RepeatableRead)
+	if _, err := store.Update(writer, rid, Row{"1", "Johnny"}); err != nil {
+		t.Fatal(err)
+	}
+	_, _ = store.Insert(writer, Row{"2", "Jane Doe"})
+
+	t.Run("Uncommitted changes are invisible to others", func(t *testing.T) {
+		if res := names(t, store, reader); len(res) != 1 || res[0] != "John Doe" {
+			t.Errorf("expected [John Doe], got %v", res)
+		}
+	})
+
+	t.Run("Writer sees its own changes", func(t *testing.T) {
+		if res := names(t, store, writer); len(res) != 2 {
+			t.Errorf("expected 2 rows, got %v", res)
+		}
+	})
+
+	_ = txns.Commit(writer)
+
+	t.Run("Snapshot is kept after the writer commits", func(t *testing.T) {
+		if res := names(t, store, reader); len(res) != 1 || res[0] != "John Doe" {
+			t.Errorf("expected [John Doe], got %v", res)
+		}
+
+		tuple, err := store.Lookup(reader, "1")
+		if err != nil || tuple.Row[1] != "John Doe" {
+			t.Errorf("expected old version through the index, got %v (%v)", tuple, err)
+		}
+
+		if _, err := store.Lookup(reader, "2"); err == nil {
+			t.Errorf("expected row inserted later to be invisible")
+		}
+	})
+
+	t.Run("New transactions see the committed changes", func(t *testing.T) {
+		txn := begin(t, db, transaction.RepeatableRead)
+		if res := names(t, store, txn); len(res) != 2 || res[0] != "Johnny" {
+			t.Errorf("expected [Johnny Jane Doe], got %v", res)
+		}
+	})
+}
+
+func TestTableStore_ReadCommittedSeesNewCommits(t *testing.T) {
+	db, store := openTestStore(t)
+	txns := db.Transactions()
+
+	reader := begin(t, db, transaction.ReadCommitted)
+	var before, after []string
+	_ = txns.Run(reader, func() error {
+		before = names(t, store, reader)
+		return nil
+	})
+
+	writer := begin(t, db, transaction.RepeatableRead)
+	_, _ = store.Insert(writer, Row{"1", "John Doe"})
+	_ = txns.Commit(writer)
+
+	_ = txns.Run(reader, func() error {
+		after = names(t, store, reader)
+		return nil
+	})
+
+	if len(before) != 0 || len(after) != 1 {
+		t.Errorf("expected the second statement to see the new row, got %v then %v", before, after)
+	}
+}
+
+func TestTableStore_WriteConflict(t *testing.T) {
+	db, store := openTestStore(t)
+	txns := db.Transactions()
+
+	setup := begin(t, db, transaction.RepeatableRead)
+	rid, _ := store.Insert(setup, Row{"1", "John Doe"})
+	_ = txns.Commit(setup)
+
+	first := begin(t, db, transaction.RepeatableRead)
+	second := begin(t, db, transaction.RepeatableRead)
+
+	if _, err := store.Update(first, rid, Row{"1", "first"}); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Second writer times out while the row is locked", func(t *testing.T) {
+		txns.Locks().SetTimeout(10 * time.Millisecond)
+		defer txns.Locks().SetTimeout(transaction.DefaultLockTimeout)
+
+		_, err := store.Update(second, rid, Row{"1", "second"})
+		if !errors.Is(err, transaction.ErrLockTimeout) {
+			t.Errorf("expected %v, got %v", transaction.ErrLockTimeout, err)
+		}
+
+		if err := store.Delete(second, rid); !errors.Is(err, transaction.ErrLockTimeout) {
+			t.Errorf("expected %v, got %v", transaction.ErrLockTimeout, err)
+		}
+		if second.State() != transaction.Active {
+			t.Errorf("expected the transaction to stay active, got %s", second.State())
+		}
+	})
+
+	t.Run("Waiting writer loses once the first one commits", func(t *testing.T) {
+		done := make(chan error)
+		go func() {
+			done <- txns.Run(second, func() error {
+				_, err := store.Update(second, rid, Row{"1", "second"})
+				return err
+			})
+		}()
+		_ = txns.Commit(first)
+
+		err := <-done
+		if !errors.Is(err, transaction.ErrWriteConflict) || second.State() != transaction.Aborted {
+			t.Errorf("expected aborted transaction, got %s (%v)", second.State(), err)
+		}
+	})
+
+	t.Run("Conflict stays after the first writer committed", func(t *testing.T) {
+		late := begin(t, db, transaction.RepeatableRead)
+
+		if _, err := store.Update(late, rid, Row{"1", "late"}); !errors.Is(err, transaction.ErrWriteConflict) {
+			t.Errorf("expected %v for outdated version, got %v", transaction.ErrWriteConflict, err)
+		}
+		_ = txns.Rollback(late)
+	})
+
+	t.Run("Rolled back writer releases the row", func(t *testing.T) {
+		txn := begin(t, db, transaction.RepeatableRead)
+		current, _ := store.Lookup(txn, "1")
+		if _, err := store.Update(txn, current.RID, Row{"1", "rolled back"}); err != nil {
+			t.Fatal(err)
+		}
+
+		waiter := begin(t, db, transaction.RepeatableRead)
+		done := make(chan error)
+		go func() {
+			_, err := store.Update(waiter, current.RID, Row{"1", "again"})
+			done <- err
+		}()
+		_ = txns.Rollback(txn)
+
+		if err := <-done; err != nil {
+			t.Errorf("expected no error, got %s", err)
+		}
+	})
+}
+
+func TestTableStore_PrimaryKeyAcrossVersions(t *testing.T) {
+	db, store := openTestStore(t)
+	txns := db.Transactions()
+
+	setup := begin(t, db, transaction.RepeatableRead)
+	rid, _ := store.Insert(setup, Row{"1", "John Doe"})
+	_ = txns.Commit(setup)
+
+	t.Run("Concurrent insert of the same key waits for the first inserter", func(t *testing.T) {
+		first := begin(t, db, transaction.RepeatableRead)
+		second := begin(t, db, transaction.RepeatableRead)
+
+		_, _ = store.Insert(first, Row{"2", "Jane Doe"})
+		done := make(chan error)
+		go func() {
+			_, err := store.Insert(second, Row{"2", "Jane Roe"})
+			done <- err
+		}()
+		_ = txns.Rollback(first)
+
+		if err := <-done; err != nil {
+			t.Errorf("expected the key to be free after the rollback, got %v", err)
+		}
+		_ = txns.Rollback(second)
+	})
+
+	old := begin(t, db, transaction.RepeatableRead)
+
+	t.Run("Deleted key can be inserted again", func(t *testing.T) {
+		txn := begin(t, db, transaction.RepeatableRead)
+		if err := store.Delete(txn, rid); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := store.Insert(txn, Row{"1", "Marty McFly"}); err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+		_ = txns.Commit(txn)
+
+		tuple, err := store.Lookup(begin(t, db, transaction.RepeatableRead), "1")
+		if err != nil || tuple.Row[1] != "Marty McFly" {
+			t.Errorf("expected Marty McFly, got %v (%v)", tuple, err)
+		}
+	})
+
+	t.Run("Old snapshot follows the version chain", func(t *testing.T) {
+		tuple, err := store.Lookup(old, "1")
+		if err != nil || tuple.Row[1] != "John Doe" {
+			t.Errorf("expected John Doe, got %v (%v)", tuple, err)
+		}
+	})
+}
+
+func TestTableStore_UniqueColumn(t *testing.T) {
+	db := openTestDatabase(t, t.TempDir())
+	defer db.Close()
+	txns := db.Transactions()
+
+	table := NewTable("accounts", []Column{
+		{Name: "id", Type: Int, PrimaryKey: true},
+		{Name: "email", Type: Varchar, Unique: true},
+	})
+	if err := db.CreateTable(table); err != nil {
+		t.Fatal(err)
+	}
+	store, _ := db.Store("accounts")
+
+	setup := begin(t, db, transaction.RepeatableRead)
+	rid, _ := store.Insert(setup, Row{"1", "john@example.com"})
+	_ = txns.Commit(setup)
+
+	t.Run("Duplicate value is rejected", func(t *testing.T) {
+		txn := begin(t, db, transaction.RepeatableRead)
+		defer txns.Rollback(txn)
+
+		if _, err := store.Insert(txn, Row{"2", "john@example.com"}); err == nil {
+			t.Errorf("expected duplicate entry error")
+		}
+	})
+
+	t.Run("Update keeping the value is allowed", func(t *testing.T) {
+		txn := begin(t, db, transaction.RepeatableRead)
+		defer txns.Rollback(txn)
+
+		if _, err := store.Update(txn, rid, Row{"5", "john@example.com"}); err != nil {
+			t.Errorf("expected no error, got %s", err)
+		}
+	})
+
+	t.Run("Value of a deleted row can be used again", func(t *testing.T) {
+		txn := begin(t, db, transaction.RepeatableRead)
+		defer txns.Rollback(txn)
+
+		if err := store.Delete(txn, rid); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := store.Insert(txn, Row{"2", "john@example.com"}); err != nil {
+			t.Errorf("expected no error, got %s", err)
+		}
+	})
+
+	t.Run("Concurrent insert of the same value waits for the first one", func(t *testing.T) {
+		first := begin(t, db, transaction.RepeatableRead)
+		second := begin(t, db, transaction.RepeatableRead)
+		defer txns.Rollback(second)
+
+		if _, err := store.Insert(first, Row{"3", "jane@example.com"}); err != nil {
+			t.Fatal(err)
+		}
+		done := make(chan error)
+		go func() {
+			_, err := store.Insert(second, Row{"4", "jane@example.com"})
+			done <- err
+		}()
+		_ = txns.Commit(first)
+
+		if err := <-done; err == nil {
+			t.Errorf("expected duplicate entry error")
+		}
+	})
+}
+
+func TestTableStore_Conflicts(t *testing.T) {
+	db := openTestDatabase(t, t.TempDir())
+	defer db.Close()
+	txns := db.Transactions()
+
+	table := NewTable("accounts", []Column{
+		{Name: "id", Type: Int, PrimaryKey: true},
+		{Name: "email", Type: Varchar, Unique: true},
+	})
+	if err := db.CreateTable(table); err != nil {
+		t.Fatal(err)
+	}
+	store, _ := db.Store("accounts")
+
+	setup := begin(t, db, transaction.RepeatableRead)
+	john, _ := store.Insert(setup, Row{"1", "john@example.com"})
+	jane, _ := store.Insert(setup, Row{"2", "jane@example.com"})
+	_ = txns.Commit(setup)
+
+	tests := []struct {
+		name     string
+		row      Row
+		expected []storage.RID
+	}{
+		{"No conflict", Row{"3", "joe@example.com"}, nil},
+		{"Primary key", Row{"1", "joe@example.com"}, []storage.RID{john}},
+		{"Unique value", Row{"3", "jane@example.com"}, []storage.RID{jane}},
+		{"Primary key first", Row{"2", "john@example.com"}, []storage.RID{jane, john}},
+		{"Both of the same row", Row{"1", "john@example.com"}, []storage.RID{john}},
+		{"NULL is no conflict", Row{"3", Null}, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			txn := begin(t, db, transaction.RepeatableRead)
+			defer txns.Rollback(txn)
+
+			tuples, err := store.Conflicts(txn, test.row)
+			if err != nil {
+				t.Fatalf("expected no error, got %s", err)
+			}
+			var rids []storage.RID
+			for _, tuple := range tuples {
+				rids = append(rids, tuple.RID)
+			}
+			if !reflect.DeepEqual(rids, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, rids)
+			}
+		})
+	}
+
+	t.Run("Deleted row is no conflict", func(t *testing.T) {
+		txn := begin(t, db, transaction.RepeatableRead)
+		defer txns.Rollback(txn)
+
+		if err := store.Delete(txn, john); err != nil {
+			t.Fatal(err)
+		}
+		tuples, err := store.Conflicts(txn, Row{"1", "john@example.com"})
+		if err != nil || len(tuples) != 0 {
+			t.Errorf("expected no conflicts, got %v, %v", tuples, err)
+		}
+	})
+}
@@ -31,3 +31,23 @@ func TestNewTable(t *testing.T) {
 	})
 
 }
+
+func TestTable_PrimaryKey(t *testing.T) {
+	table := NewTable("t_users", []Column{
+		{Name: "id", Type: Int, PrimaryKey: true},
+		{Name: "name", Type: Varchar},
+	})
+
+	t.Run("find primary key column", func(t *testing.T) {
+		column := table.PrimaryKey()
+		if column == nil || column.Name != "id" {
+			t.Errorf("expected primary key id, got %v", column)
+		}
+	})
+
+	t.Run("table without primary key", func(t *testing.T) {
+		if NewTable("logs", []Column{{Name: "msg", Type: Varchar}}).PrimaryKey() != nil {
+			t.Errorf("expected no primary key")
+		}
+	})
+}
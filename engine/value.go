@@ -0,0 +1,422 @@
+package engine
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Values travel through the engine as literals. The functions below parse a
+// literal of a type into its native form and format that back into the
+// canonical literal, which is what a stored row decodes to.
+
+// Null is the value of a NULL in a row. It is not valid UTF-8, so no string
+// value can be mistaken for it, and no blob may hold it.
+const Null = "\xffNULL"
+
+const (
+	dateLayout      = "2006-01-02"
+	timeLayout      = "15:04:05.999999"
+	timestampLayout = "2006-01-02 15:04:05.999999"
+)
+
+// timestampLayouts are the accepted forms of a timestamp literal, a date
+// alone is midnight of that day.
+var timestampLayouts = []string{
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	time.RFC3339Nano,
+	dateLayout,
+}
+
+func parseBool(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "true", "1":
+		return true, nil
+	case "false", "0":
+		return false, nil
+	}
+	return false, errors.New("invalid boolean " + value)
+}
+
+func formatBool(b bool) string {
+	if b {
+		return "true"
+	}
+	return "false"
+}
+
+func parseDouble(value string) (float64, error) {
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, errors.New("invalid number " + value)
+	}
+	// -0 and 0 are the same value
+	if f == 0 {
+		f = 0
+	}
+	return f, nil
+}
+
+func formatDouble(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// parseDate returns the days since 1970-01-01.
+func parseDate(value string) (int64, error) {
+	t, err := time.Parse(dateLayout, value)
+	if err != nil {
+		return 0, errors.New("invalid date " + value)
+	}
+	return t.Unix() / (24 * 60 * 60), nil
+}
+
+func formatDate(days int64) string {
+	return time.Unix(days*24*60*60, 0).UTC().Format(dateLayout)
+}
+
+// parseTime returns the microseconds since midnight.
+func parseTime(value string) (int64, error) {
+	t, err := time.Parse("15:04:05", value)
+	if err != nil {
+		return 0, errors.New("invalid time " + value)
+	}
+	seconds := int64(t.Hour()*60*60 + t.Minute()*60 + t.Second())
+	return seconds*1000000 + int64(t.Nanosecond()/1000), nil
+}
+
+func formatTime(micros int64) string {
+	return time.UnixMicro(micros).UTC().Format(timeLayout)
+}
+
+// parseTimestamp returns the microseconds since 1970-01-01 00:00:00 UTC.
+// Timestamps without a zone are UTC.
+func parseTimestamp(value string) (int64, error) {
+	for _, layout := range timestampLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.UnixMicro(), nil
+		}
+	}
+	return 0, errors.New("invalid timestamp " + value)
+}
+
+func formatTimestamp(micros int64) string {
+	return time.UnixMicro(micros).UTC().Format(timestampLayout)
+}
+
+// decimal is an exact number: unscaled / 10^scale.
+type decimal struct {
+	unscaled *big.Int
+	scale    int
+}
+
+func parseDecimal(value string) (decimal, error) {
+	digits := value
+	if strings.HasPrefix(digits, "-") || strings.HasPrefix(digits, "+") {
+		digits = digits[1:]
+	}
+	whole, fraction, _ := strings.Cut(digits, ".")
+	if whole+fraction == "" || strings.Trim(whole+fraction, "0123456789") != "" {
+		return decimal{}, errors.New("invalid decimal " + value)
+	}
+
+	unscaled, _ := new(big.Int).SetString(whole+fraction, 10)
+	if strings.HasPrefix(value, "-") {
+		unscaled.Neg(unscaled)
+	}
+	return decimal{unscaled: unscaled, scale: len(fraction)}, nil
+}
+
+// rescale returns the decimal with the given number of fraction digits,
+// rounding half away from zero.
+func (d decimal) rescale(scale int) decimal {
+	if scale >= d.scale {
+		pow := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale-d.scale)), nil)
+		return decimal{unscaled: new(big.Int).Mul(d.unscaled, pow), scale: scale}
+	}
+
+	pow := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(d.scale-scale)), nil)
+	q, r := new(big.Int).QuoRem(new(big.Int).Abs(d.unscaled), pow, new(big.Int))
+	if r.Lsh(r, 1).Cmp(pow) >= 0 {
+		q.Add(q, big.NewInt(1))
+	}
+	if d.unscaled.Sign() < 0 {
+		q.Neg(q)
+	}
+	return decimal{unscaled: q, scale: scale}
+}
+
+// precision is the number of digits of the decimal.
+func (d decimal) precision() int {
+	return len(new(big.Int).Abs(d.unscaled).String())
+}
+
+func (d decimal) String() string {
+	digits := new(big.Int).Abs(d.unscaled).String()
+	if d.scale > 0 {
+		if len(digits) <= d.scale {
+			digits = strings.Repeat("0", d.scale-len(digits)+1) + digits
+		}
+		digits = digits[:len(digits)-d.scale] + "." + digits[len(digits)-d.scale:]
+	}
+	if d.unscaled.Sign() < 0 {
+		return "-" + digits
+	}
+	return digits
+}
+
+// key orders decimals by value whatever their scale: the sign, then the
+// position of the first digit, then the digits without trailing zeros.
+// Negative numbers have the last two inverted and end with a byte above
+// every digit, so that -1.5 sorts after -1.55.
+func (d decimal) key() []byte {
+	if d.unscaled.Sign() == 0 {
+		return []byte{1}
+	}
+
+	digits := new(big.Int).Abs(d.unscaled).String()
+	exponent := uint16(len(digits) - d.scale + 1<<15)
+	digits = strings.TrimRight(digits, "0")
+
+	if d.unscaled.Sign() > 0 {
+		key := binary.BigEndian.AppendUint16([]byte{2}, exponent)
+		return append(key, digits...)
+	}
+
+	key := binary.BigEndian.AppendUint16([]byte{0}, ^exponent)
+	for i := 0; i < len(digits); i++ {
+		key = append(key, '9'-digits[i]+'0')
+	}
+	return append(key, 0xff)
+}
+
+// Normalize checks that the value is a literal of the column and returns it
+// in the canonical form of its type.
+func (c *Column) Normalize(value string) (string, error) {
+	if value == Null {
+		if c.NotNull || c.PrimaryKey {
+			return "", errors.New("column " + c.Name + " cannot be null")
+		}
+		return Null, nil
+	}
+
+	// a boolean is the number 1 or 0, BOOLEAN is TINYINT(1) in MySQL
+	if c.Type.IsNumeric() {
+		switch strings.ToLower(value) {
+		case "true":
+			value = "1"
+		case "false":
+			value = "0"
+		}
+	}
+
+	switch c.Type {
+	case Int, SmallInt, BigInt:
+		bits := map[DataType]int{SmallInt: 16, Int: 32, BigInt: 64}[c.Type]
+		v, err := strconv.ParseInt(value, 10, bits)
+		if errors.Is(err, strconv.ErrRange) {
+			return "", errors.New("value " + value + " out of range for column " + c.Name)
+		}
+		if err != nil {
+			return "", errors.New("invalid integer \"" + value + "\" for column " + c.Name)
+		}
+		return strconv.FormatInt(v, 10), nil
+	case Boolean:
+		b, err := parseBool(value)
+		if err != nil {
+			return "", errors.New("invalid boolean \"" + value + "\" for column " + c.Name)
+		}
+		return formatBool(b), nil
+	case Double:
+		f, err := parseDouble(value)
+		if err != nil {
+			return "", errors.New("invalid number \"" + value + "\" for column " + c.Name)
+		}
+		return formatDouble(f), nil
+	case Decimal:
+		d, err := parseDecimal(value)
+		if err != nil {
+			return "", errors.New("invalid decimal \"" + value + "\" for column " + c.Name)
+		}
+		d = d.rescale(c.Scale)
+		if d.precision() > c.precision() {
+			return "", errors.New("value " + value + " out of range for column " + c.Name)
+		}
+		return d.String(), nil
+	case Date:
+		days, err := parseDate(value)
+		if err != nil {
+			return "", errors.New("invalid date \"" + value + "\" for column " + c.Name)
+		}
+		return formatDate(days), nil
+	case Time:
+		micros, err := parseTime(value)
+		if err != nil {
+			return "", errors.New("invalid time \"" + value + "\" for column " + c.Name)
+		}
+		return formatTime(micros), nil
+	case Timestamp:
+		micros, err := parseTimestamp(value)
+		if err != nil {
+			return "", errors.New("invalid timestamp \"" + value + "\" for column " + c.Name)
+		}
+		return formatTimestamp(micros), nil
+	case Varchar, Text:
+		if !utf8.ValidString(value) {
+			return "", errors.New("invalid string value for column " + c.Name)
+		}
+		if c.Length > 0 && utf8.RuneCountInString(value) > c.Length {
+			return "", errors.New("value too long for column " + c.Name)
+		}
+	}
+	return value, nil
+}
+
+// appendValue appends the binary form of a literal of the type: its type,
+// then fixed size integers for numbers and points in time, or the length of
+// the bytes followed by the bytes.
+func appendValue(buf []byte, dataType DataType, value string) ([]byte, error) {
+	buf = append(buf, byte(dataType))
+
+	switch dataType {
+	case Boolean:
+		b, err := parseBool(value)
+		if err != nil {
+			return nil, err
+		}
+		if b {
+			return append(buf, 1), nil
+		}
+		return append(buf, 0), nil
+	case SmallInt:
+		v, err := strconv.ParseInt(value, 10, 16)
+		return binary.LittleEndian.AppendUint16(buf, uint16(v)), err
+	case Int:
+		v, err := strconv.ParseInt(value, 10, 32)
+		return binary.LittleEndian.AppendUint32(buf, uint32(v)), err
+	case BigInt:
+		v, err := strconv.ParseInt(value, 10, 64)
+		return binary.LittleEndian.AppendUint64(buf, uint64(v)), err
+	case Double:
+		f, err := parseDouble(value)
+		return binary.LittleEndian.AppendUint64(buf, math.Float64bits(f)), err
+	case Decimal:
+		d, err := parseDecimal(value)
+		if err != nil {
+			return nil, err
+		}
+		sign := byte(0)
+		if d.unscaled.Sign() < 0 {
+			sign = 1
+		}
+		magnitude := new(big.Int).Abs(d.unscaled).Bytes()
+		buf = append(buf, byte(d.scale), sign)
+		buf = binary.AppendUvarint(buf, uint64(len(magnitude)))
+		return append(buf, magnitude...), nil
+	case Date:
+		days, err := parseDate(value)
+		return binary.LittleEndian.AppendUint32(buf, uint32(days)), err
+	case Time:
+		micros, err := parseTime(value)
+		return binary.LittleEndian.AppendUint64(buf, uint64(micros)), err
+	case Timestamp:
+		micros, err := parseTimestamp(value)
+		return binary.LittleEndian.AppendUint64(buf, uint64(micros)), err
+	case Varchar, Text, Blob:
+		buf = binary.AppendUvarint(buf, uint64(len(value)))
+		return append(buf, value...), nil
+	}
+	return nil, errors.New("unsupported data type " + dataType.String())
+}
+
+// readValue decodes the value at the start of data and returns it with the
+// number of bytes it took.
+func readValue(data []byte) (string, int, error) {
+	if len(data) == 0 {
+		return "", 0, errCorruptRow
+	}
+
+	dataType, data := DataType(data[0]), data[1:]
+	fixed := func(size int) ([]byte, error) {
+		if len(data) < size {
+			return nil, errCorruptRow
+		}
+		return data[:size], nil
+	}
+
+	switch dataType {
+	case Boolean:
+		b, err := fixed(1)
+		if err != nil {
+			return "", 0, err
+		}
+		return formatBool(b[0] != 0), 2, nil
+	case SmallInt:
+		b, err := fixed(2)
+		if err != nil {
+			return "", 0, err
+		}
+		return strconv.FormatInt(int64(int16(binary.LittleEndian.Uint16(b))), 10), 3, nil
+	case Int:
+		b, err := fixed(4)
+		if err != nil {
+			return "", 0, err
+		}
+		return strconv.FormatInt(int64(int32(binary.LittleEndian.Uint32(b))), 10), 5, nil
+	case BigInt:
+		b, err := fixed(8)
+		if err != nil {
+			return "", 0, err
+		}
+		return strconv.FormatInt(int64(binary.LittleEndian.Uint64(b)), 10), 9, nil
+	case Double:
+		b, err := fixed(8)
+		if err != nil {
+			return "", 0, err
+		}
+		return formatDouble(math.Float64frombits(binary.LittleEndian.Uint64(b))), 9, nil
+	case Decimal:
+		if len(data) < 2 {
+			return "", 0, errCorruptRow
+		}
+		n, size := binary.Uvarint(data[2:])
+		if size <= 0 || uint64(len(data)-2-size) < n {
+			return "", 0, errCorruptRow
+		}
+		d := decimal{unscaled: new(big.Int).SetBytes(data[2+size : 2+size+int(n)]), scale: int(data[0])}
+		if data[1] == 1 {
+			d.unscaled.Neg(d.unscaled)
+		}
+		return d.String(), 1 + 2 + size + int(n), nil
+	case Date:
+		b, err := fixed(4)
+		if err != nil {
+			return "", 0, err
+		}
+		return formatDate(int64(int32(binary.LittleEndian.Uint32(b)))), 5, nil
+	case Time:
+		b, err := fixed(8)
+		if err != nil {
+			return "", 0, err
+		}
+		return formatTime(int64(binary.LittleEndian.Uint64(b))), 9, nil
+	case Timestamp:
+		b, err := fixed(8)
+		if err != nil {
+			return "", 0, err
+		}
+		return formatTimestamp(int64(binary.LittleEndian.Uint64(b))), 9, nil
+	case Varchar, Text, Blob:
+		n, size := binary.Uvarint(data)
+		if size <= 0 || uint64(len(data)-size) < n {
+			return "", 0, errCorruptRow
+		}
+		return string(data[size : size+int(n)]), 1 + size + int(n), nil
+	}
+	return "", 0, errCorruptRow
+}
@@ -0,0 +1,70 @@
+package engine
+
+import "testing"
+
+func TestColumn_Normalize(t *testing.T) {
+	tests := []struct {
+		column   Column
+		value    string
+		expected string
+	}{
+		{Column{Type: Int}, "007", "7"},
+		{Column{Type: Int}, "TRUE", "1"},
+		{Column{Type: Decimal, Precision: 3, Scale: 1}, "false", "0.0"},
+		{Column{Type: Boolean}, "TRUE", "true"},
+		{Column{Type: Boolean}, "0", "false"},
+		{Column{Type: Double}, "1.50", "1.5"},
+		{Column{Type: Double}, "-0", "0"},
+		{Column{Type: Decimal, Precision: 5, Scale: 2}, "3.14159", "3.14"},
+		{Column{Type: Decimal, Precision: 5, Scale: 2}, "-0.005", "-0.01"},
+		{Column{Type: Decimal, Precision: 5, Scale: 2}, "12", "12.00"},
+		{Column{Type: Decimal}, "12.5", "13"},
+		{Column{Type: Date}, "2024-02-29", "2024-02-29"},
+		{Column{Type: Time}, "23:59:59.500000", "23:59:59.5"},
+		{Column{Type: Timestamp}, "2024-01-02", "2024-01-02 00:00:00"},
+		{Column{Type: Timestamp}, "2024-01-02T03:04:05+01:00", "2024-01-02 02:04:05"},
+		{Column{Type: Varchar, Length: 3}, "äöü", "äöü"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.column.Type.String()+" "+test.value, func(t *testing.T) {
+			value, err := test.column.Normalize(test.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if value != test.expected {
+				t.Errorf("expected %s, got %s", test.expected, value)
+			}
+		})
+	}
+}
+
+func TestColumn_NormalizeInvalid(t *testing.T) {
+	tests := []struct {
+		column Column
+		value  string
+	}{
+		{Column{Type: Int}, "3000000000"},
+		{Column{Type: SmallInt}, "40000"},
+		{Column{Type: BigInt}, "1.5"},
+		{Column{Type: Boolean}, "yes"},
+		{Column{Type: Double}, "NaN"},
+		{Column{Type: Decimal, Precision: 5, Scale: 2}, "1000"},
+		{Column{Type: Decimal}, "1e5"},
+		{Column{Type: Date}, "2023-02-29"},
+		{Column{Type: Time}, "24:00:00"},
+		{Column{Type: Timestamp}, "yesterday"},
+		{Column{Type: Varchar, Length: 2}, "abc"},
+		{Column{Type: Text}, "\xff"},
+		{Column{Type: Int, NotNull: true}, Null},
+		{Column{Type: Int, PrimaryKey: true}, Null},
+	}
+
+	for _, test := range tests {
+		t.Run(test.column.Type.String()+" "+test.value, func(t *testing.T) {
+			if _, err := test.column.Normalize(test.value); err == nil {
+				t.Errorf("expected %q to be rejected", test.value)
+			}
+		})
+	}
+}
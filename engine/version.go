@@ -0,0 +1,74 @@
+package engine
+
+import (
+	"dbngin3/storage"
+	"encoding/binary"
+)
+
+// Every row stored in a heap file is one version of a logical row:
+//
+//	| xmin (8) | xmax (8) | previous page (4) | previous slot (2) | schema (4) | row |
+//
+// xmin is the transaction that created the version and xmax the one that
+// deleted or replaced it, zero while the version is the live one. Versions of
+// the same primary key are chained from newest to oldest, the index points at
+// the newest. schema is the version of the table the row was written with.
+const versionHeaderSize = 26
+
+type version struct {
+	xmin   storage.TxnID
+	xmax   storage.TxnID
+	prev   storage.RID
+	schema int
+	row    Row
+}
+
+// encodeVersion needs the table definition of the schema version of the row.
+func encodeVersion(table *Table, v *version) ([]byte, error) {
+	row, err := EncodeRow(table.Columns, v.row)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, versionHeaderSize, versionHeaderSize+len(row))
+	binary.LittleEndian.PutUint64(buf[0:], uint64(v.xmin))
+	binary.LittleEndian.PutUint64(buf[8:], uint64(v.xmax))
+	binary.LittleEndian.PutUint32(buf[16:], uint32(v.prev.PageID))
+	binary.LittleEndian.PutUint16(buf[20:], v.prev.Slot)
+	binary.LittleEndian.PutUint32(buf[22:], uint32(v.schema))
+	return append(buf, row...), nil
+}
+
+func decodeVersion(data []byte) (*version, error) {
+	if len(data) < versionHeaderSize {
+		return nil, errCorruptRow
+	}
+
+	row, err := DecodeRow(data[versionHeaderSize:])
+	if err != nil {
+		return nil, err
+	}
+
+	return &version{
+		xmin: storage.TxnID(binary.LittleEndian.Uint64(data[0:])),
+		xmax: storage.TxnID(binary.LittleEndian.Uint64(data[8:])),
+		prev: storage.RID{
+			PageID: storage.PageID(binary.LittleEndian.Uint32(data[16:])),
+			Slot:   binary.LittleEndian.Uint16(data[20:]),
+		},
+		schema: int(binary.LittleEndian.Uint32(data[22:])),
+		row:    row,
+	}, nil
+}
+
+// setXmax stamps a stored version without decoding its row, the record keeps
+// its size and so its place in the heap file.
+func setXmax(data []byte, xmax storage.TxnID) error {
+	if len(data) < versionHeaderSize {
+		return errCorruptRow
+	}
+	binary.LittleEndian.PutUint64(data[8:], uint64(xmax))
+	return nil
+}
+
+var noVersion = storage.RID{PageID: storage.InvalidPageID}
@@ -0,0 +1,364 @@
+package executor
+
+import (
+	"dbngin3/engine"
+	"dbngin3/parser"
+	"encoding/binary"
+	"hash/fnv"
+	"strconv"
+)
+
+// aggregatePartitions is the number of files the rows of the groups that do
+// not fit in memory are spread over. A partition that does not fit either is
+// split again, up to maxPartitionDepth times; deeper ones stay in memory.
+const (
+	aggregatePartitions = 8
+	maxPartitionDepth   = 4
+)
+
+// HashAggregate groups the rows of its child by the GROUP BY expressions and
+// computes the aggregate functions over every group. input describes the
+// rows of the child. It returns a row per group holding the values of the
+// GROUP BY expressions followed by the results of the aggregates; the
+// columns are named after the expressions. Without a GROUP BY all rows form
+// one group, even if there are none.
+//
+// Groups are kept in a hash table until it exceeds the memory budget. The
+// rows of groups that are not in the table by then are written to partition
+// files in dir by the hash of their group, and every partition is
+// aggregated on its own after the groups in memory are returned.
+type HashAggregate struct {
+	child         Operator
+	input         []engine.Column
+	groupBy       []parser.Expression
+	aggregates    []*parser.AggregateExpression
+	argumentTypes []engine.DataType
+	columns       []engine.Column
+	memory        int
+	dir           string
+
+	groups     map[string]*group
+	order      []*group
+	size       int
+	partitions []partition
+	written    int
+}
+
+// group is the values of the GROUP BY expressions of a group and the state
+// of every aggregate function over its rows.
+type group struct {
+	values       []string
+	accumulators []accumulator
+}
+
+// accumulator is the state of an aggregate function: the number of values
+// it took, the sum of them or the least or greatest one so far, and for
+// DISTINCT the keys of the values seen.
+type accumulator struct {
+	count    int64
+	result   parser.Value
+	distinct map[string]bool
+}
+
+// partition is a file of rows spilled at the given depth of partitioning.
+type partition struct {
+	run   *runFile
+	depth int
+}
+
+func NewHashAggregate(child Operator, input []engine.Column, groupBy []parser.Expression, aggregates []*parser.AggregateExpression, memory int, dir string) (*HashAggregate, error) {
+	if memory <= 0 {
+		memory = DefaultWorkMemory
+	}
+	h := &HashAggregate{child: child, input: input, groupBy: groupBy, aggregates: aggregates, memory: memory, dir: dir}
+
+	for _, expr := range groupBy {
+		dataType, err := parser.TypeOf(expr, input)
+		if err != nil {
+			return nil, err
+		}
+		h.columns = append(h.columns, engine.Column{Name: expr.String(), Type: dataType})
+	}
+	for _, aggregate := range aggregates {
+		dataType, err := parser.TypeOf(aggregate, input)
+		if err != nil {
+			return nil, err
+		}
+		h.columns = append(h.columns, engine.Column{Name: aggregate.String(), Type: dataType})
+
+		argumentType := engine.BigInt
+		if aggregate.Argument != nil {
+			if argumentType, err = parser.TypeOf(aggregate.Argument, input); err != nil {
+				return nil, err
+			}
+		}
+		h.argumentTypes = append(h.argumentTypes, argumentType)
+	}
+	return h, nil
+}
+
+func (h *HashAggregate) Open() error {
+	if err := h.child.Open(); err != nil {
+		return err
+	}
+
+	h.reset()
+	err := h.consume(func() (engine.Row, error) {
+		tuple, err := h.child.Next()
+		if err != nil || tuple == nil {
+			return nil, err
+		}
+		return tuple.Row, nil
+	}, 0)
+	if err != nil {
+		return err
+	}
+
+	if len(h.groupBy) == 0 && len(h.order) == 0 {
+		h.newGroup("", nil)
+	}
+	return nil
+}
+
+func (h *HashAggregate) reset() {
+	h.groups, h.order, h.size, h.written = make(map[string]*group), nil, 0, 0
+}
+
+// consume adds the rows next returns to their groups. Once the groups take
+// more memory than allowed, the rows of new groups go to partitions instead.
+func (h *HashAggregate) consume(next func() (engine.Row, error), depth int) error {
+	var runs []*runFile
+	defer func() {
+		for _, run := range runs {
+			if run != nil {
+				h.partitions = append(h.partitions, partition{run: run, depth: depth + 1})
+			}
+		}
+	}()
+
+	for {
+		row, err := next()
+		if err != nil {
+			return err
+		}
+		if row == nil {
+			break
+		}
+
+		values, key, err := h.groupKey(row)
+		if err != nil {
+			return err
+		}
+
+		g := h.groups[key]
+		if g == nil && h.size > h.memory && depth < maxPartitionDepth {
+			if runs == nil {
+				runs = make([]*runFile, aggregatePartitions)
+			}
+			i := partitionOf(key, depth)
+			if runs[i] == nil {
+				if runs[i], err = createRunFile(h.dir); err != nil {
+					return err
+				}
+			}
+			if err := runs[i].write(row, 0); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if g == nil {
+			g = h.newGroup(key, values)
+		}
+		if err := h.accumulate(g, row); err != nil {
+			return err
+		}
+	}
+
+	for _, run := range runs {
+		if run != nil {
+			if err := run.rewind(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// groupKey evaluates the GROUP BY expressions for the row. The key holds
+// for each value a NULL marker or its encoded index key, so that equal
+// values of different forms such as 1.5 and 1.50 fall into one group.
+func (h *HashAggregate) groupKey(row engine.Row) ([]string, string, error) {
+	values := make([]string, len(h.groupBy))
+	var key []byte
+	for i, expr := range h.groupBy {
+		value, err := expr.Eval(h.input, row)
+		if err != nil {
+			return nil, "", err
+		}
+		values[i] = value.Literal
+
+		if value.IsNull() {
+			key = append(key, 0)
+			continue
+		}
+		encoded, err := engine.EncodeKey(h.columns[i].Type, value.Literal)
+		if err != nil {
+			return nil, "", err
+		}
+		key = append(key, 1)
+		key = binary.AppendUvarint(key, uint64(len(encoded)))
+		key = append(key, encoded...)
+	}
+	return values, string(key), nil
+}
+
+// partitionOf spreads the groups over the partitions. The depth takes part
+// in the hash, a partition split again spreads over all partitions as well.
+func partitionOf(key string, depth int) int {
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte{byte(depth)})
+	_, _ = hash.Write([]byte(key))
+	return int(hash.Sum32() % aggregatePartitions)
+}
+
+func (h *HashAggregate) newGroup(key string, values []string) *group {
+	g := &group{values: values, accumulators: make([]accumulator, len(h.aggregates))}
+	for i, aggregate := range h.aggregates {
+		if aggregate.Distinct {
+			g.accumulators[i].distinct = make(map[string]bool)
+		}
+	}
+	h.groups[key] = g
+	h.order = append(h.order, g)
+
+	h.size += 64 + len(key) + 48*len(g.accumulators)
+	for _, value := range values {
+		h.size += 16 + len(value)
+	}
+	return g
+}
+
+// accumulate adds the row to the aggregates of its group. Aggregates skip
+// NULL, and a DISTINCT one every value it has seen before.
+func (h *HashAggregate) accumulate(g *group, row engine.Row) error {
+	for i, aggregate := range h.aggregates {
+		acc := &g.accumulators[i]
+		if aggregate.Argument == nil {
+			acc.count++
+			continue
+		}
+
+		value, err := aggregate.Argument.Eval(h.input, row)
+		if err != nil {
+			return err
+		}
+		if value.IsNull() {
+			continue
+		}
+
+		if aggregate.Distinct {
+			key, err := engine.EncodeKey(h.argumentTypes[i], value.Literal)
+			if err != nil {
+				return err
+			}
+			if acc.distinct[string(key)] {
+				continue
+			}
+			acc.distinct[string(key)] = true
+			h.size += 16 + len(key)
+		}
+
+		if acc.count == 0 {
+			acc.result = value
+			acc.count++
+			continue
+		}
+
+		switch aggregate.Function {
+		case parser.SUM, parser.AVG:
+			dataType := h.columns[len(h.groupBy)+i].Type
+			sum, err := engine.Calculate(parser.PLUS, dataType, acc.result.Literal, value.Literal)
+			if err != nil {
+				return err
+			}
+			acc.result = parser.Value{Type: dataType, Literal: sum}
+		case parser.MIN, parser.MAX:
+			cmp, err := parser.CompareValues(value, acc.result)
+			if err != nil {
+				return err
+			}
+			if aggregate.Function == parser.MIN && cmp < 0 || aggregate.Function == parser.MAX && cmp > 0 {
+				acc.result = value
+			}
+		}
+		acc.count++
+	}
+	return nil
+}
+
+// result is the value of the aggregate over the group. Only COUNT has one
+// for a group without values, the others are NULL.
+func (h *HashAggregate) result(g *group, i int) (string, error) {
+	acc := g.accumulators[i]
+	switch {
+	case h.aggregates[i].Function == parser.COUNT:
+		return strconv.FormatInt(acc.count, 10), nil
+	case acc.count == 0:
+		return engine.Null, nil
+	case h.aggregates[i].Function == parser.AVG:
+		return engine.Calculate(parser.DIVIDE, h.columns[len(h.groupBy)+i].Type, acc.result.Literal, strconv.FormatInt(acc.count, 10))
+	}
+	return acc.result.Literal, nil
+}
+
+func (h *HashAggregate) Next() (*engine.Tuple, error) {
+	for h.written == len(h.order) {
+		if len(h.partitions) == 0 {
+			return nil, nil
+		}
+
+		next := h.partitions[0]
+		h.partitions = h.partitions[1:]
+		h.reset()
+		err := h.consume(func() (engine.Row, error) {
+			row, _, err := next.run.read()
+			return row, err
+		}, next.depth)
+		next.run.close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	g := h.order[h.written]
+	h.written++
+
+	row := make(engine.Row, 0, len(h.columns))
+	row = append(row, g.values...)
+	for i := range h.aggregates {
+		value, err := h.result(g, i)
+		if err != nil {
+			return nil, err
+		}
+		row = append(row, value)
+	}
+	return &engine.Tuple{Row: row}, nil
+}
+
+func (h *HashAggregate) Close() error {
+	for _, p := range h.partitions {
+		p.run.close()
+	}
+	h.partitions = nil
+	h.reset()
+	return h.child.Close()
+}
+
+func (h *HashAggregate) Columns() []string {
+	names := make([]string, len(h.columns))
+	for i, column := range h.columns {
+		names[i] = column.Name
+	}
+	return names
+}
@@ -0,0 +1,124 @@
+package executor
+
+import (
+	"dbngin3/engine"
+	"dbngin3/parser"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+)
+
+func TestHashAggregate_SpillsPartitions(t *testing.T) {
+	// 1000 groups of 3 rows each, the rows of a group far apart
+	var rows []engine.Row
+	for i := 0; i < 3000; i++ {
+		rows = append(rows, engine.Row{strconv.Itoa(i % 1000), strconv.Itoa(i)})
+	}
+	rows[0][1] = engine.Null
+
+	groupBy := []parser.Expression{&parser.ColumnRef{Name: "a"}}
+	aggregates := []*parser.AggregateExpression{
+		{Function: parser.COUNT},
+		{Function: parser.COUNT, Argument: &parser.ColumnRef{Name: "b"}},
+		{Function: parser.SUM, Argument: &parser.ColumnRef{Name: "b"}},
+		{Function: parser.MAX, Argument: &parser.ColumnRef{Name: "b"}},
+	}
+
+	var expected []engine.Row
+	for i := 0; i < 1000; i++ {
+		count, sum, max := "3", strconv.Itoa(3*i+3000), strconv.Itoa(i+2000)
+		if i == 0 {
+			count, sum = "2", "3000"
+		}
+		expected = append(expected, engine.Row{strconv.Itoa(i), "3", count, sum, max})
+	}
+
+	for _, memory := range []int{0, 20000, 1} {
+		t.Run("memory "+strconv.Itoa(memory), func(t *testing.T) {
+			dir := t.TempDir()
+			plan, err := NewHashAggregate(&rowsOperator{rows: rows}, sortColumns, groupBy, aggregates, memory, dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if columns := plan.Columns(); !reflect.DeepEqual(columns, []string{"a", "COUNT(*)", "COUNT(b)", "SUM(b)", "MAX(b)"}) {
+				t.Errorf("unexpected columns %v", columns)
+			}
+
+			if err := plan.Open(); err != nil {
+				t.Fatal(err)
+			}
+			if spilled := len(plan.partitions) > 0; spilled != (memory > 0) {
+				t.Errorf("expected spilling to be %v", memory > 0)
+			}
+
+			var got []engine.Row
+			for {
+				tuple, err := plan.Next()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if tuple == nil {
+					break
+				}
+				got = append(got, tuple.Row)
+			}
+			if err := plan.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			sort.Slice(got, func(i, j int) bool {
+				a, _ := strconv.Atoi(got[i][0])
+				b, _ := strconv.Atoi(got[j][0])
+				return a < b
+			})
+			if !reflect.DeepEqual(got, expected) {
+				t.Errorf("unexpected groups, first %v", got[:3])
+			}
+			if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+				t.Errorf("expected the partitions to be removed, found %d", len(entries))
+			}
+		})
+	}
+}
+
+func TestHashAggregate_NoRows(t *testing.T) {
+	aggregates := []*parser.AggregateExpression{
+		{Function: parser.COUNT},
+		{Function: parser.AVG, Argument: &parser.ColumnRef{Name: "a"}},
+	}
+
+	result, err := Execute(mustHashAggregate(t, nil, aggregates))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []engine.Row{{"0", engine.Null}}; !reflect.DeepEqual(result.Rows, expected) {
+		t.Errorf("expected %v without GROUP BY, got %v", expected, result.Rows)
+	}
+
+	result, err = Execute(mustHashAggregate(t, []parser.Expression{&parser.ColumnRef{Name: "a"}}, aggregates))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Rows) != 0 {
+		t.Errorf("expected no groups, got %v", result.Rows)
+	}
+}
+
+func mustHashAggregate(t *testing.T, groupBy []parser.Expression, aggregates []*parser.AggregateExpression) *HashAggregate {
+	plan, err := NewHashAggregate(&rowsOperator{}, sortColumns, groupBy, aggregates, 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return plan
+}
+
+func TestNewHashAggregate_InvalidArgument(t *testing.T) {
+	columns := []engine.Column{{Name: "name", Type: engine.Varchar}}
+	aggregates := []*parser.AggregateExpression{{Function: parser.SUM, Argument: &parser.ColumnRef{Name: "name"}}}
+
+	if _, err := NewHashAggregate(&rowsOperator{}, columns, nil, aggregates, 0, ""); err == nil {
+		t.Errorf("expected SUM over a VARCHAR to fail")
+	}
+}
@@ -0,0 +1,98 @@
+package executor
+
+import (
+	"dbngin3/engine"
+	"dbngin3/transaction"
+)
+
+// CreateTable adds a table to the database when it is opened. It returns no
+// rows.
+type CreateTable struct {
+	db          *engine.Database
+	table       *engine.Table
+	ifNotExists bool
+}
+
+func NewCreateTable(db *engine.Database, table *engine.Table, ifNotExists bool) *CreateTable {
+	return &CreateTable{db: db, table: table, ifNotExists: ifNotExists}
+}
+
+func (c *CreateTable) Open() error {
+	if c.ifNotExists && c.db.Schema().IsTableExists(c.table.Name) {
+		return nil
+	}
+	return c.db.CreateTable(c.table)
+}
+
+func (c *CreateTable) Next() (*engine.Tuple, error) {
+	return nil, nil
+}
+
+func (c *CreateTable) Close() error {
+	return nil
+}
+
+func (c *CreateTable) Columns() []string {
+	return nil
+}
+
+// DropTable removes a table and its rows from the database when it is
+// opened. It returns no rows.
+type DropTable struct {
+	db       *engine.Database
+	txn      *transaction.Transaction
+	table    string
+	ifExists bool
+}
+
+func NewDropTable(db *engine.Database, txn *transaction.Transaction, table string, ifExists bool) *DropTable {
+	return &DropTable{db: db, txn: txn, table: table, ifExists: ifExists}
+}
+
+func (d *DropTable) Open() error {
+	if d.ifExists && !d.db.Schema().IsTableExists(d.table) {
+		return nil
+	}
+	return d.db.DropTable(d.txn, d.table)
+}
+
+func (d *DropTable) Next() (*engine.Tuple, error) {
+	return nil, nil
+}
+
+func (d *DropTable) Close() error {
+	return nil
+}
+
+func (d *DropTable) Columns() []string {
+	return nil
+}
+
+// AlterTable changes the definition of a table when it is opened. It returns
+// no rows.
+type AlterTable struct {
+	db     *engine.Database
+	txn    *transaction.Transaction
+	table  string
+	change func(*engine.Table) (*engine.Table, error)
+}
+
+func NewAlterTable(db *engine.Database, txn *transaction.Transaction, table string, change func(*engine.Table) (*engine.Table, error)) *AlterTable {
+	return &AlterTable{db: db, txn: txn, table: table, change: change}
+}
+
+func (a *AlterTable) Open() error {
+	return a.db.AlterTable(a.txn, a.table, a.change)
+}
+
+func (a *AlterTable) Next() (*engine.Tuple, error) {
+	return nil, nil
+}
+
+func (a *AlterTable) Close() error {
+	return nil
+}
+
+func (a *AlterTable) Columns() []string {
+	return nil
+}
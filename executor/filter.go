@@ -0,0 +1,127 @@
+package executor
+
+import (
+	"dbngin3/engine"
+	"dbngin3/parser"
+	"errors"
+)
+
+// Filter passes on the rows of its child that match the condition. columns
+// describes the rows of the child.
+type Filter struct {
+	child   Operator
+	columns []engine.Column
+	where   parser.Expression
+}
+
+func NewFilter(child Operator, columns []engine.Column, where parser.Expression) *Filter {
+	return &Filter{child: child, columns: columns, where: where}
+}
+
+func (f *Filter) Open() error {
+	return f.child.Open()
+}
+
+func (f *Filter) Next() (*engine.Tuple, error) {
+	for {
+		tuple, err := f.child.Next()
+		if err != nil || tuple == nil {
+			return nil, err
+		}
+
+		ok, err := parser.Matches(f.where, f.columns, tuple.Row)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return tuple, nil
+		}
+	}
+}
+
+func (f *Filter) Close() error {
+	return f.child.Close()
+}
+
+func (f *Filter) Columns() []string {
+	return f.child.Columns()
+}
+
+// Project computes the given expressions over the rows of its child. input
+// describes the rows of the child, columns names the results.
+type Project struct {
+	child       Operator
+	input       []engine.Column
+	columns     []string
+	types       []engine.DataType
+	expressions []parser.Expression
+}
+
+func NewProject(child Operator, input []engine.Column, columns []string, expressions []parser.Expression) (*Project, error) {
+	if err := checkColumns(input, expressions...); err != nil {
+		return nil, err
+	}
+
+	types := make([]engine.DataType, len(expressions))
+	for i, expr := range expressions {
+		dataType, err := parser.TypeOf(expr, input)
+		if err != nil {
+			return nil, err
+		}
+		types[i] = dataType
+	}
+	return &Project{child: child, input: input, columns: columns, types: types, expressions: expressions}, nil
+}
+
+func (p *Project) Open() error {
+	return p.child.Open()
+}
+
+func (p *Project) Next() (*engine.Tuple, error) {
+	tuple, err := p.child.Next()
+	if err != nil || tuple == nil {
+		return nil, err
+	}
+
+	row := make(engine.Row, len(p.expressions))
+	for i, expr := range p.expressions {
+		value, err := expr.Eval(p.input, tuple.Row)
+		if err != nil {
+			return nil, err
+		}
+		row[i] = value.Literal
+	}
+	return &engine.Tuple{RID: tuple.RID, Row: row}, nil
+}
+
+func (p *Project) Close() error {
+	return p.child.Close()
+}
+
+func (p *Project) Columns() []string {
+	return p.columns
+}
+
+func (p *Project) Types() []engine.DataType {
+	return p.types
+}
+
+// checkColumns fails for the first column the expressions refer to that is
+// not one of the given columns.
+func checkColumns(columns []engine.Column, expressions ...parser.Expression) error {
+	for _, expr := range expressions {
+		for _, name := range parser.ColumnNames(expr) {
+			found := false
+			for _, column := range columns {
+				if column.Name == name {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return errors.New("unknown column " + name)
+			}
+		}
+	}
+	return nil
+}
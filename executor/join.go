@@ -0,0 +1,652 @@
+package executor
+
+import (
+	"dbngin3/engine"
+	"dbngin3/parser"
+	"dbngin3/storage"
+	"dbngin3/transaction"
+	"encoding/binary"
+	"errors"
+)
+
+// JoinMethod is the algorithm the planner joins a table with.
+type JoinMethod int
+
+const (
+	// AutoJoin lets the planner choose: an index nested-loop join when the
+	// condition fixes the primary key of the joined table, else for a
+	// condition that compares both sides for equality a hash join, or a
+	// merge join if the joined table exceeds the work memory, and a
+	// nested-loop join for any other condition.
+	AutoJoin JoinMethod = iota
+	NestedLoopJoinMethod
+	IndexNestedLoopJoinMethod
+	HashJoinMethod
+	MergeJoinMethod
+)
+
+// joiner is what all joins share: the kind of join, the columns of the left
+// and right rows and the condition a pair of them has to match. Joined rows
+// are the left row followed by the right one, the side an outer join finds
+// no match for is all NULL. SEMI and ANTI joins return left rows only: a
+// SEMI join those with a match, once, an ANTI join those without one.
+type joiner struct {
+	kind      string
+	left      []engine.Column
+	right     []engine.Column
+	columns   []engine.Column
+	condition parser.Expression
+}
+
+func newJoiner(kind string, left, right []engine.Column, condition parser.Expression) joiner {
+	columns := append(append([]engine.Column{}, left...), right...)
+	return joiner{kind: kind, left: left, right: right, columns: columns, condition: condition}
+}
+
+// keepsLeft reports whether left rows without a match are returned.
+func (j *joiner) keepsLeft() bool {
+	return j.kind == parser.LEFT || j.kind == parser.FULL || j.kind == parser.ANTI
+}
+
+// keepsRight reports whether right rows without a match are returned.
+func (j *joiner) keepsRight() bool {
+	return j.kind == parser.RIGHT || j.kind == parser.FULL
+}
+
+// filters reports whether the join only returns left rows, which are done
+// with at their first match.
+func (j *joiner) filters() bool {
+	return j.kind == parser.SEMI || j.kind == parser.ANTI
+}
+
+// combine joins two rows, a nil one stands for a row of NULLs.
+func (j *joiner) combine(left, right engine.Row) *engine.Tuple {
+	if j.filters() {
+		return &engine.Tuple{Row: left}
+	}
+	return &engine.Tuple{Row: j.joined(left, right)}
+}
+
+func (j *joiner) joined(left, right engine.Row) engine.Row {
+	row := make(engine.Row, 0, len(j.columns))
+	row = append(row, nullPadded(left, len(j.left))...)
+	return append(row, nullPadded(right, len(j.right))...)
+}
+
+func nullPadded(row engine.Row, width int) engine.Row {
+	if row != nil {
+		return row
+	}
+	row = make(engine.Row, width)
+	for i := range row {
+		row[i] = engine.Null
+	}
+	return row
+}
+
+// match returns the joined row if it matches the condition, or nil.
+func (j *joiner) match(left, right engine.Row) (*engine.Tuple, error) {
+	ok, err := parser.Matches(j.condition, j.columns, j.joined(left, right))
+	if err != nil || !ok {
+		return nil, err
+	}
+	return j.combine(left, right), nil
+}
+
+// returnsMatch reports whether the row of a match is returned, which an
+// ANTI join never does.
+func (j *joiner) returnsMatch() bool {
+	return j.kind != parser.ANTI
+}
+
+func (j *joiner) Columns() []string {
+	columns := j.columns
+	if j.filters() {
+		columns = j.left
+	}
+	names := make([]string, len(columns))
+	for i, column := range columns {
+		names[i] = column.Name
+	}
+	return names
+}
+
+// buildSide is the right rows a join holds in memory, with whether any left
+// row matched each of them, which RIGHT and FULL joins return otherwise.
+type buildSide struct {
+	rows    []engine.Row
+	matched []bool
+	next    int
+}
+
+func (b *buildSide) load(child Operator) error {
+	b.rows, b.matched, b.next = nil, nil, 0
+	for {
+		tuple, err := child.Next()
+		if err != nil || tuple == nil {
+			b.matched = make([]bool, len(b.rows))
+			return err
+		}
+		b.rows = append(b.rows, tuple.Row)
+	}
+}
+
+// nextUnmatched returns the next row no left row matched, or nil.
+func (b *buildSide) nextUnmatched() engine.Row {
+	for b.next < len(b.rows) {
+		b.next++
+		if !b.matched[b.next-1] {
+			return b.rows[b.next-1]
+		}
+	}
+	return nil
+}
+
+// NestedLoopJoin compares every left row with every right row. It holds the
+// right rows in memory and can join by any condition.
+type NestedLoopJoin struct {
+	joiner
+	leftChild  Operator
+	rightChild Operator
+	build      buildSide
+	current    engine.Row
+	pos        int
+	found      bool
+	leftDone   bool
+}
+
+func NewNestedLoopJoin(left, right Operator, kind string, leftColumns, rightColumns []engine.Column, condition parser.Expression) *NestedLoopJoin {
+	return &NestedLoopJoin{joiner: newJoiner(kind, leftColumns, rightColumns, condition), leftChild: left, rightChild: right}
+}
+
+func (n *NestedLoopJoin) Open() error {
+	n.current, n.leftDone = nil, false
+	if err := n.leftChild.Open(); err != nil {
+		return err
+	}
+	if err := n.rightChild.Open(); err != nil {
+		return err
+	}
+	return n.build.load(n.rightChild)
+}
+
+func (n *NestedLoopJoin) Next() (*engine.Tuple, error) {
+	for !n.leftDone {
+		if n.current == nil {
+			tuple, err := n.leftChild.Next()
+			if err != nil {
+				return nil, err
+			}
+			if tuple == nil {
+				n.leftDone = true
+				break
+			}
+			n.current, n.pos, n.found = tuple.Row, 0, false
+		}
+
+		for n.pos < len(n.build.rows) {
+			n.pos++
+			tuple, err := n.match(n.current, n.build.rows[n.pos-1])
+			if err != nil {
+				return nil, err
+			}
+			if tuple != nil {
+				n.found = true
+				n.build.matched[n.pos-1] = true
+				if n.filters() {
+					n.pos = len(n.build.rows)
+				}
+				if n.returnsMatch() {
+					return tuple, nil
+				}
+			}
+		}
+
+		left := n.current
+		n.current = nil
+		if !n.found && n.keepsLeft() {
+			return n.combine(left, nil), nil
+		}
+	}
+
+	if n.keepsRight() {
+		if right := n.build.nextUnmatched(); right != nil {
+			return n.combine(nil, right), nil
+		}
+	}
+	return nil, nil
+}
+
+func (n *NestedLoopJoin) Close() error {
+	n.build = buildSide{}
+	err := n.leftChild.Close()
+	if rightErr := n.rightChild.Close(); err == nil {
+		err = rightErr
+	}
+	return err
+}
+
+// IndexNestedLoopJoin looks up the right row of every left row through the
+// primary key index of the right table. key computes the primary key value
+// from the left row. It cannot return the right rows no left row matched.
+type IndexNestedLoopJoin struct {
+	joiner
+	leftChild Operator
+	store     *engine.TableStore
+	txn       *transaction.Transaction
+	key       parser.Expression
+}
+
+func NewIndexNestedLoopJoin(left Operator, store *engine.TableStore, txn *transaction.Transaction, kind string, leftColumns, rightColumns []engine.Column, key, condition parser.Expression) *IndexNestedLoopJoin {
+	return &IndexNestedLoopJoin{
+		joiner:    newJoiner(kind, leftColumns, rightColumns, condition),
+		leftChild: left,
+		store:     store,
+		txn:       txn,
+		key:       key,
+	}
+}
+
+func (n *IndexNestedLoopJoin) Open() error {
+	if err := n.leftChild.Open(); err != nil {
+		return err
+	}
+	return n.store.LockTable(n.txn, transaction.IntentionShared)
+}
+
+func (n *IndexNestedLoopJoin) Next() (*engine.Tuple, error) {
+	for {
+		tuple, err := n.leftChild.Next()
+		if err != nil || tuple == nil {
+			return nil, err
+		}
+
+		value, err := n.key.Eval(n.left, tuple.Row)
+		if err != nil {
+			return nil, err
+		}
+		if !value.IsNull() {
+			right, err := n.store.Lookup(n.txn, value.Literal)
+			if err != nil && !errors.Is(err, storage.ErrKeyNotFound) {
+				return nil, err
+			}
+			if right != nil {
+				joined, err := n.match(tuple.Row, right.Row)
+				if err != nil {
+					return nil, err
+				}
+				if joined != nil && n.returnsMatch() {
+					return joined, nil
+				} else if joined != nil {
+					continue
+				}
+			}
+		}
+
+		if n.keepsLeft() {
+			return n.combine(tuple.Row, nil), nil
+		}
+	}
+}
+
+func (n *IndexNestedLoopJoin) Close() error {
+	return n.leftChild.Close()
+}
+
+// HashJoin puts the right rows in a hash table by the values of the right
+// keys and probes it with the values of the left keys of every left row.
+// The keys are compared as keyTypes, condition is what else a pair of rows
+// has to match. The hash table is held in memory.
+type HashJoin struct {
+	joiner
+	leftChild  Operator
+	rightChild Operator
+	leftKeys   []parser.Expression
+	rightKeys  []parser.Expression
+	keyTypes   []engine.DataType
+	build      buildSide
+	table      map[string][]int
+	current    engine.Row
+	candidates []int
+	found      bool
+	leftDone   bool
+}
+
+func NewHashJoin(left, right Operator, kind string, leftColumns, rightColumns []engine.Column, leftKeys, rightKeys []parser.Expression, keyTypes []engine.DataType, condition parser.Expression) *HashJoin {
+	return &HashJoin{
+		joiner:     newJoiner(kind, leftColumns, rightColumns, condition),
+		leftChild:  left,
+		rightChild: right,
+		leftKeys:   leftKeys,
+		rightKeys:  rightKeys,
+		keyTypes:   keyTypes,
+	}
+}
+
+func (h *HashJoin) Open() error {
+	h.current, h.leftDone = nil, false
+	if err := h.leftChild.Open(); err != nil {
+		return err
+	}
+	if err := h.rightChild.Open(); err != nil {
+		return err
+	}
+	if err := h.build.load(h.rightChild); err != nil {
+		return err
+	}
+
+	h.table = make(map[string][]int)
+	for i, row := range h.build.rows {
+		key, ok, err := joinKey(h.rightKeys, h.keyTypes, h.right, row)
+		if err != nil {
+			return err
+		}
+		if ok {
+			h.table[key] = append(h.table[key], i)
+		}
+	}
+	return nil
+}
+
+func (h *HashJoin) Next() (*engine.Tuple, error) {
+	for !h.leftDone {
+		if h.current == nil {
+			tuple, err := h.leftChild.Next()
+			if err != nil {
+				return nil, err
+			}
+			if tuple == nil {
+				h.leftDone = true
+				break
+			}
+
+			key, ok, err := joinKey(h.leftKeys, h.keyTypes, h.left, tuple.Row)
+			if err != nil {
+				return nil, err
+			}
+			h.current, h.candidates, h.found = tuple.Row, nil, false
+			if ok {
+				h.candidates = h.table[key]
+			}
+		}
+
+		for len(h.candidates) > 0 {
+			i := h.candidates[0]
+			h.candidates = h.candidates[1:]
+			tuple, err := h.match(h.current, h.build.rows[i])
+			if err != nil {
+				return nil, err
+			}
+			if tuple != nil {
+				h.found = true
+				h.build.matched[i] = true
+				if h.filters() {
+					h.candidates = nil
+				}
+				if h.returnsMatch() {
+					return tuple, nil
+				}
+			}
+		}
+
+		left := h.current
+		h.current = nil
+		if !h.found && h.keepsLeft() {
+			return h.combine(left, nil), nil
+		}
+	}
+
+	if h.keepsRight() {
+		if right := h.build.nextUnmatched(); right != nil {
+			return h.combine(nil, right), nil
+		}
+	}
+	return nil, nil
+}
+
+func (h *HashJoin) Close() error {
+	h.build, h.table = buildSide{}, nil
+	err := h.leftChild.Close()
+	if rightErr := h.rightChild.Close(); err == nil {
+		err = rightErr
+	}
+	return err
+}
+
+// joinKey encodes the values of the keys for the row the way a group key
+// is, each as its index key of the given type so that equal numbers of
+// different types meet. ok is false if a value is NULL, which equals
+// nothing.
+func joinKey(keys []parser.Expression, types []engine.DataType, columns []engine.Column, row engine.Row) (string, bool, error) {
+	var key []byte
+	for i, expr := range keys {
+		value, err := expr.Eval(columns, row)
+		if err != nil || value.IsNull() {
+			return "", false, err
+		}
+		encoded, err := engine.EncodeKey(types[i], value.Literal)
+		if err != nil {
+			return "", false, err
+		}
+		key = binary.AppendUvarint(key, uint64(len(encoded)))
+		key = append(key, encoded...)
+	}
+	return string(key), true, nil
+}
+
+// MergeJoin sorts both sides by their keys and walks them side by side. The
+// right rows with the same key are held in memory while the left rows with
+// that key are joined to them. Rows come out ordered by the keys.
+type MergeJoin struct {
+	joiner
+	leftChild  Operator
+	rightChild Operator
+	leftKeys   []parser.Expression
+	rightKeys  []parser.Expression
+	keyTypes   []engine.DataType
+
+	leftRow  *mergeRow
+	rightRow *mergeRow
+	group    []mergeRow
+	matched  []bool
+	pending  []*engine.Tuple
+}
+
+// mergeRow is a row with the values of its keys. null is set if any of them
+// is NULL, the row then matches nothing.
+type mergeRow struct {
+	row  engine.Row
+	keys []parser.Value
+	null bool
+}
+
+func NewMergeJoin(left, right Operator, kind string, leftColumns, rightColumns []engine.Column, leftKeys, rightKeys []parser.Expression, keyTypes []engine.DataType, condition parser.Expression, memory int, dir string) *MergeJoin {
+	return &MergeJoin{
+		joiner:     newJoiner(kind, leftColumns, rightColumns, condition),
+		leftChild:  NewSort(left, leftColumns, ascending(leftKeys), memory, dir),
+		rightChild: NewSort(right, rightColumns, ascending(rightKeys), memory, dir),
+		leftKeys:   leftKeys,
+		rightKeys:  rightKeys,
+		keyTypes:   keyTypes,
+	}
+}
+
+// ascending orders by the keys, NULL first.
+func ascending(keys []parser.Expression) []parser.OrderByItem {
+	order := make([]parser.OrderByItem, len(keys))
+	for i, key := range keys {
+		order[i] = parser.OrderByItem{Expression: key, NullsFirst: true}
+	}
+	return order
+}
+
+func (m *MergeJoin) Open() error {
+	m.group, m.matched, m.pending = nil, nil, nil
+	if err := m.leftChild.Open(); err != nil {
+		return err
+	}
+	if err := m.rightChild.Open(); err != nil {
+		return err
+	}
+
+	var err error
+	if m.leftRow, err = m.read(m.leftChild, m.leftKeys, m.left); err != nil {
+		return err
+	}
+	m.rightRow, err = m.read(m.rightChild, m.rightKeys, m.right)
+	return err
+}
+
+func (m *MergeJoin) read(child Operator, keys []parser.Expression, columns []engine.Column) (*mergeRow, error) {
+	tuple, err := child.Next()
+	if err != nil || tuple == nil {
+		return nil, err
+	}
+
+	row := &mergeRow{row: tuple.Row, keys: make([]parser.Value, len(keys))}
+	for i, key := range keys {
+		if row.keys[i], err = key.Eval(columns, tuple.Row); err != nil {
+			return nil, err
+		}
+		row.null = row.null || row.keys[i].IsNull()
+	}
+	return row, nil
+}
+
+func (m *MergeJoin) compare(a, b *mergeRow) (int, error) {
+	for i, dataType := range m.keyTypes {
+		cmp, err := engine.CompareValues(dataType, a.keys[i].Literal, b.keys[i].Literal)
+		if err != nil || cmp != 0 {
+			return cmp, err
+		}
+	}
+	return 0, nil
+}
+
+func (m *MergeJoin) Next() (*engine.Tuple, error) {
+	for len(m.pending) == 0 {
+		done, err := m.step()
+		if err != nil || done {
+			return nil, err
+		}
+	}
+
+	tuple := m.pending[0]
+	m.pending = m.pending[1:]
+	return tuple, nil
+}
+
+// step moves on by one row or one group of right rows, adding the joined
+// rows that makes to pending. It reports when both sides are exhausted.
+func (m *MergeJoin) step() (bool, error) {
+	if len(m.group) > 0 {
+		cmp := 1
+		if m.leftRow != nil && !m.leftRow.null {
+			var err error
+			if cmp, err = m.compare(m.leftRow, &m.group[0]); err != nil {
+				return false, err
+			}
+		}
+
+		if cmp == 0 {
+			found := false
+			for i := range m.group {
+				tuple, err := m.match(m.leftRow.row, m.group[i].row)
+				if err != nil {
+					return false, err
+				}
+				if tuple != nil {
+					found = true
+					m.matched[i] = true
+					if m.returnsMatch() {
+						m.pending = append(m.pending, tuple)
+					}
+					if m.filters() {
+						break
+					}
+				}
+			}
+			if !found && m.keepsLeft() {
+				m.pending = append(m.pending, m.combine(m.leftRow.row, nil))
+			}
+			return false, m.advanceLeft()
+		}
+
+		// the left rows moved past the key of the group
+		for i := range m.group {
+			if !m.matched[i] && m.keepsRight() {
+				m.pending = append(m.pending, m.combine(nil, m.group[i].row))
+			}
+		}
+		m.group, m.matched = nil, nil
+		return false, nil
+	}
+
+	switch {
+	case m.leftRow == nil && m.rightRow == nil:
+		return true, nil
+	case m.leftRow != nil && (m.leftRow.null || m.rightRow == nil):
+		return false, m.skipLeft()
+	case m.rightRow.null || m.leftRow == nil:
+		return false, m.skipRight()
+	}
+
+	cmp, err := m.compare(m.leftRow, m.rightRow)
+	switch {
+	case err != nil:
+		return false, err
+	case cmp < 0:
+		return false, m.skipLeft()
+	case cmp > 0:
+		return false, m.skipRight()
+	}
+
+	// collect the right rows with the key of the left row
+	for m.rightRow != nil && !m.rightRow.null {
+		if len(m.group) > 0 {
+			if cmp, err = m.compare(m.rightRow, &m.group[0]); err != nil || cmp != 0 {
+				break
+			}
+		}
+		m.group = append(m.group, *m.rightRow)
+		if m.rightRow, err = m.read(m.rightChild, m.rightKeys, m.right); err != nil {
+			return false, err
+		}
+	}
+	m.matched = make([]bool, len(m.group))
+	return false, err
+}
+
+func (m *MergeJoin) advanceLeft() error {
+	var err error
+	m.leftRow, err = m.read(m.leftChild, m.leftKeys, m.left)
+	return err
+}
+
+// skipLeft passes over a left row that has no match.
+func (m *MergeJoin) skipLeft() error {
+	if m.keepsLeft() {
+		m.pending = append(m.pending, m.combine(m.leftRow.row, nil))
+	}
+	return m.advanceLeft()
+}
+
+// skipRight passes over a right row that has no match.
+func (m *MergeJoin) skipRight() error {
+	if m.keepsRight() {
+		m.pending = append(m.pending, m.combine(nil, m.rightRow.row))
+	}
+
+	var err error
+	m.rightRow, err = m.read(m.rightChild, m.rightKeys, m.right)
+	return err
+}
+
+func (m *MergeJoin) Close() error {
+	m.leftRow, m.rightRow, m.group, m.matched, m.pending = nil, nil, nil, nil, nil
+	err := m.leftChild.Close()
+	if rightErr := m.rightChild.Close(); err == nil {
+		err = rightErr
+	}
+	return err
+}
@@ -0,0 +1,347 @@
+package executor
+
+import (
+	"dbngin3/engine"
+	"dbngin3/parser"
+	"dbngin3/transaction"
+	"errors"
+	"reflect"
+)
+
+// Insert adds its rows to the table and returns them as inserted. The rows
+// of an INSERT ... SELECT come from its child, which is read to the end
+// before the first row is inserted, so that it does not see them. The
+// values of a child row go to the columns at the given positions, the other
+// columns get their defaults.
+type Insert struct {
+	store   *engine.TableStore
+	txn     *transaction.Transaction
+	rows    []engine.Row
+	child   Operator
+	columns []int
+	next    int
+}
+
+func NewInsert(store *engine.TableStore, txn *transaction.Transaction, rows []engine.Row) *Insert {
+	return &Insert{store: store, txn: txn, rows: rows}
+}
+
+func NewInsertSelect(store *engine.TableStore, txn *transaction.Transaction, child Operator, columns []int) *Insert {
+	return &Insert{store: store, txn: txn, child: child, columns: columns}
+}
+
+func (i *Insert) Open() error {
+	i.next = 0
+	if i.child == nil {
+		return nil
+	}
+
+	if err := i.child.Open(); err != nil {
+		return err
+	}
+	tuples, err := collect(i.child)
+	if err != nil {
+		return err
+	}
+
+	i.rows = make([]engine.Row, len(tuples))
+	for n, tuple := range tuples {
+		if i.rows[n], err = defaultRow(i.store.Table(), i.columns); err != nil {
+			return err
+		}
+		for j, column := range i.columns {
+			i.rows[n][column] = tuple.Row[j]
+		}
+	}
+	return nil
+}
+
+func (i *Insert) Next() (*engine.Tuple, error) {
+	row := i.nextRow()
+	if row == nil {
+		return nil, nil
+	}
+
+	rid, err := i.store.Insert(i.txn, row)
+	if err != nil {
+		return nil, err
+	}
+	return &engine.Tuple{RID: rid, Row: row}, nil
+}
+
+func (i *Insert) Close() error {
+	if i.child == nil {
+		return nil
+	}
+	i.rows = nil
+	return i.child.Close()
+}
+
+// nextRow returns the next row to insert, or nil after the last one.
+func (i *Insert) nextRow() engine.Row {
+	if i.next == len(i.rows) {
+		return nil
+	}
+	i.next++
+	return i.rows[i.next-1]
+}
+
+// defaultRow returns a row of the table with the default of every column,
+// for an insert that sets the columns at the given positions. Like MySQL in
+// strict mode it fails for a NOT NULL column without a default of its own
+// that the insert does not set.
+func defaultRow(table *engine.Table, set []int) (engine.Row, error) {
+	row := make(engine.Row, len(table.Columns))
+	for i, column := range table.Columns {
+		if column.Default == nil && (column.NotNull || column.PrimaryKey) && !containsPosition(set, i) {
+			return nil, errors.New("field '" + column.Name + "' doesn't have a default value")
+		}
+		row[i] = column.DefaultValue()
+	}
+	return row, nil
+}
+
+func containsPosition(positions []int, position int) bool {
+	for _, p := range positions {
+		if p == position {
+			return true
+		}
+	}
+	return false
+}
+
+func (i *Insert) Columns() []string {
+	return nil
+}
+
+// collect reads every tuple of the child before the first change is made.
+// A changed row gets a new version further down the heap, a scan that is
+// still running would visit it again.
+func collect(child Operator) ([]*engine.Tuple, error) {
+	var tuples []*engine.Tuple
+	for {
+		tuple, err := child.Next()
+		if err != nil || tuple == nil {
+			return tuples, err
+		}
+		tuples = append(tuples, tuple)
+	}
+}
+
+// Upsert inserts the rows of an Insert like it does, except for the rows
+// that collide with existing ones on the primary key or a unique value.
+// Without expressions to set it replaces the rows it collides with: it
+// deletes them before it inserts. With them it updates the first of those
+// rows instead of inserting, the one with the primary key if there is one.
+//
+// The rows affected are counted the way MySQL counts them: an inserted and
+// a deleted row once each, an updated row twice and a row that an update
+// leaves as it was not at all.
+type Upsert struct {
+	*Insert
+	set        map[int]parser.Expression
+	records    int
+	duplicates int
+	affected   int
+}
+
+// NewUpsert takes the expressions of ON DUPLICATE KEY UPDATE by column
+// position, nil for a REPLACE. They are computed from the columns of the
+// existing row followed by those of upsertColumns.
+func NewUpsert(insert *Insert, set map[int]parser.Expression) *Upsert {
+	return &Upsert{Insert: insert, set: set}
+}
+
+func (u *Upsert) Open() error {
+	u.records, u.duplicates, u.affected = 0, 0, 0
+	return u.Insert.Open()
+}
+
+func (u *Upsert) Next() (*engine.Tuple, error) {
+	row := u.nextRow()
+	if row == nil {
+		return nil, nil
+	}
+	u.records++
+
+	row, err := u.store.Table().Normalize(row)
+	if err != nil {
+		return nil, err
+	}
+	conflicts, err := u.store.Conflicts(u.txn, row)
+	if err != nil {
+		return nil, err
+	}
+	if len(conflicts) > 0 {
+		u.duplicates++
+		if u.set != nil {
+			return u.update(conflicts[0], row)
+		}
+	}
+
+	for _, tuple := range conflicts {
+		if err := u.store.Delete(u.txn, tuple.RID); err != nil {
+			return nil, err
+		}
+		u.affected++
+	}
+	rid, err := u.store.Insert(u.txn, row)
+	if err != nil {
+		return nil, err
+	}
+	u.affected++
+	return &engine.Tuple{RID: rid, Row: row}, nil
+}
+
+// update applies the expressions to the existing row, VALUES(column) reads
+// the row that collided with it.
+func (u *Upsert) update(tuple *engine.Tuple, inserted engine.Row) (*engine.Tuple, error) {
+	table := u.store.Table()
+	columns := upsertColumns(table)
+	values := append(append(engine.Row(nil), tuple.Row...), inserted...)
+
+	row := append(engine.Row(nil), tuple.Row...)
+	for i, expr := range u.set {
+		value, err := expr.Eval(columns, values)
+		if err != nil {
+			return nil, err
+		}
+		row[i] = value.Literal
+	}
+	row, err := table.Normalize(row)
+	if err != nil {
+		return nil, err
+	}
+	if reflect.DeepEqual(row, tuple.Row) {
+		return tuple, nil
+	}
+
+	rid, err := u.store.Update(u.txn, tuple.RID, row)
+	if err != nil {
+		return nil, err
+	}
+	u.affected += 2
+	return &engine.Tuple{RID: rid, Row: row}, nil
+}
+
+// Counts returns how many rows the statement had, how many of them collided
+// with existing rows and how many rows were affected.
+func (u *Upsert) Counts() (records, duplicates, affected int) {
+	return u.records, u.duplicates, u.affected
+}
+
+// upsertColumns returns the columns of the table followed by those that
+// VALUES(column) reads, named like the expression.
+func upsertColumns(table *engine.Table) []engine.Column {
+	columns := append([]engine.Column(nil), table.Columns...)
+	for _, column := range table.Columns {
+		column.Name = (&parser.InsertValue{Column: column.Name}).String()
+		columns = append(columns, column)
+	}
+	return columns
+}
+
+// Update assigns new values to the rows of its child, which has to return
+// whole rows of the table, and returns the new versions.
+type Update struct {
+	store  *engine.TableStore
+	txn    *transaction.Transaction
+	child  Operator
+	set    map[int]parser.Expression
+	tuples []*engine.Tuple
+}
+
+// NewUpdate takes the expressions of the new values by column position. They
+// are computed from the row before the update.
+func NewUpdate(store *engine.TableStore, txn *transaction.Transaction, child Operator, set map[int]parser.Expression) *Update {
+	return &Update{store: store, txn: txn, child: child, set: set}
+}
+
+func (u *Update) Open() error {
+	if err := u.child.Open(); err != nil {
+		return err
+	}
+
+	var err error
+	u.tuples, err = collect(u.child)
+	return err
+}
+
+func (u *Update) Next() (*engine.Tuple, error) {
+	if len(u.tuples) == 0 {
+		return nil, nil
+	}
+
+	tuple := u.tuples[0]
+	u.tuples = u.tuples[1:]
+
+	columns := u.store.Table().Columns
+	row := append(engine.Row(nil), tuple.Row...)
+	for i, expr := range u.set {
+		value, err := expr.Eval(columns, tuple.Row)
+		if err != nil {
+			return nil, err
+		}
+		row[i] = value.Literal
+	}
+
+	rid, err := u.store.Update(u.txn, tuple.RID, row)
+	if err != nil {
+		return nil, err
+	}
+	return &engine.Tuple{RID: rid, Row: row}, nil
+}
+
+func (u *Update) Close() error {
+	u.tuples = nil
+	return u.child.Close()
+}
+
+func (u *Update) Columns() []string {
+	return nil
+}
+
+// Delete removes the rows of its child and returns them.
+type Delete struct {
+	store  *engine.TableStore
+	txn    *transaction.Transaction
+	child  Operator
+	tuples []*engine.Tuple
+}
+
+func NewDelete(store *engine.TableStore, txn *transaction.Transaction, child Operator) *Delete {
+	return &Delete{store: store, txn: txn, child: child}
+}
+
+func (d *Delete) Open() error {
+	if err := d.child.Open(); err != nil {
+		return err
+	}
+
+	var err error
+	d.tuples, err = collect(d.child)
+	return err
+}
+
+func (d *Delete) Next() (*engine.Tuple, error) {
+	if len(d.tuples) == 0 {
+		return nil, nil
+	}
+
+	tuple := d.tuples[0]
+	d.tuples = d.tuples[1:]
+
+	if err := d.store.Delete(d.txn, tuple.RID); err != nil {
+		return nil, err
+	}
+	return tuple, nil
+}
+
+func (d *Delete) Close() error {
+	d.tuples = nil
+	return d.child.Close()
+}
+
+func (d *Delete) Columns() []string {
+	return nil
+}
@@ -0,0 +1,69 @@
+package executor
+
+import (
+	"dbngin3/engine"
+	"dbngin3/parser"
+	"reflect"
+	"testing"
+)
+
+func TestInsert_ReturnsInsertedRows(t *testing.T) {
+	db := openTestDatabase(t)
+	txn := begin(t, db)
+	store, _ := db.Store("users")
+
+	rows := []engine.Row{{"1", "John", "30"}, {"2", "Jane", "25"}}
+	result, err := Execute(NewInsert(store, txn, rows))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Affected != 2 {
+		t.Errorf("expected 2 affected rows, got %d", result.Affected)
+	}
+	if got := run(t, db, txn, "SELECT * FROM users").Rows; !reflect.DeepEqual(got, rows) {
+		t.Errorf("expected %v, got %v", rows, got)
+	}
+}
+
+func TestDelete_RemovesMatchingRows(t *testing.T) {
+	db := openTestDatabase(t)
+	txn := begin(t, db)
+	store, _ := db.Store("users")
+
+	rows := []engine.Row{{"1", "John", "30"}, {"2", "Jane", "25"}, {"3", "Marty", "17"}}
+	if _, err := Execute(NewInsert(store, txn, rows)); err != nil {
+		t.Fatal(err)
+	}
+
+	where := &parser.BinaryExpression{
+		Operator: parser.MORE_THAN,
+		Left:     &parser.ColumnRef{Name: "age"},
+		Right:    &parser.Literal{Value: "20"},
+	}
+	plan := NewDelete(store, txn, NewFilter(NewSeqScan(store, txn), store.Table().Columns, where))
+
+	result, err := Execute(plan)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Affected != 2 {
+		t.Errorf("expected 2 affected rows, got %d", result.Affected)
+	}
+	expected := []engine.Row{{"3", "Marty", "17"}}
+	if got := run(t, db, txn, "SELECT * FROM users").Rows; !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestExecute_StopsAtFirstError(t *testing.T) {
+	db := openTestDatabase(t)
+	txn := begin(t, db)
+	store, _ := db.Store("users")
+
+	plan := NewInsert(store, txn, []engine.Row{{"1", "John", "30"}, {"1", "John", "30"}})
+	if _, err := Execute(plan); err == nil {
+		t.Errorf("expected duplicate key error")
+	}
+}
@@ -0,0 +1,85 @@
+package executor
+
+import (
+	"dbngin3/engine"
+)
+
+// Operator is a node of a query plan. Plans are pulled from the top: every
+// call of Next asks the children for as many tuples as it needs to produce
+// one of its own.
+type Operator interface {
+	Open() error
+	// Next returns the next tuple, or nil once the operator is exhausted.
+	Next() (*engine.Tuple, error)
+	Close() error
+	// Columns names the values of the returned rows. It is nil for operators
+	// that change data; the rows they return are the changed ones.
+	Columns() []string
+}
+
+// DefaultWorkMemory is how many bytes of rows a sort or an aggregation holds
+// in memory before it spills them to temporary files.
+const DefaultWorkMemory = 4 << 20
+
+// Result is what a statement returns: the rows of a query, or the number of
+// rows a data change statement affected. An INSERT ... ON DUPLICATE KEY
+// UPDATE or a REPLACE also reports its Records and how many of them were
+// Duplicates of existing rows. Types holds the types of the columns when
+// the plan knows them.
+type Result struct {
+	Columns    []string
+	Types      []engine.DataType
+	Rows       []engine.Row
+	Affected   int
+	Records    int
+	Duplicates int
+}
+
+// counter is an operator that counts the rows it affects itself, since a
+// row it returns does not stand for exactly one affected row.
+type counter interface {
+	Counts() (records, duplicates, affected int)
+}
+
+// typer is an operator that knows the types of the values it returns.
+type typer interface {
+	Types() []engine.DataType
+}
+
+// IsQuery reports whether the result holds rows, even if there are none.
+func (r *Result) IsQuery() bool {
+	return r.Columns != nil
+}
+
+// Execute runs the plan to completion.
+func Execute(plan Operator) (*Result, error) {
+	if err := plan.Open(); err != nil {
+		_ = plan.Close()
+		return nil, err
+	}
+
+	result := &Result{Columns: plan.Columns()}
+	if t, ok := plan.(typer); ok {
+		result.Types = t.Types()
+	}
+	for {
+		tuple, err := plan.Next()
+		if err != nil {
+			_ = plan.Close()
+			return nil, err
+		}
+		if tuple == nil {
+			break
+		}
+
+		if result.IsQuery() {
+			result.Rows = append(result.Rows, tuple.Row)
+		} else {
+			result.Affected++
+		}
+	}
+	if c, ok := plan.(counter); ok {
+		result.Records, result.Duplicates, result.Affected = c.Counts()
+	}
+	return result, plan.Close()
+}
@@ -0,0 +1,699 @@
+package executor
+
+import (
+	"dbngin3/engine"
+	"dbngin3/parser"
+	"dbngin3/transaction"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Planner builds the operator tree of a statement. Select statements have to
+// be analyzed and optimized first.
+type Planner struct {
+	DB *engine.Database
+	// WorkMemory is the number of bytes of rows a sort or an aggregation
+	// keeps in memory, 0 means DefaultWorkMemory. Larger ones spill to
+	// files in TempDir, or the temporary directory of the system if it is
+	// empty.
+	WorkMemory int
+	TempDir    string
+	// JoinMethod forces the algorithm of every join it can run, any other
+	// join is planned as with AutoJoin.
+	JoinMethod JoinMethod
+
+	// outer is the current rows of the queries a subquery is nested in,
+	// the innermost last
+	outer []*parser.OuterRow
+}
+
+func (p *Planner) Plan(txn *transaction.Transaction, node parser.ASTNode) (Operator, error) {
+	switch stmt := node.(type) {
+	case *parser.SelectStatement:
+		plan, _, err := p.planSelect(txn, stmt)
+		return plan, err
+	case *parser.InsertStatement:
+		return p.planInsert(txn, stmt)
+	case *parser.UpdateStatement:
+		return p.planUpdate(txn, stmt)
+	case *parser.DeleteStatement:
+		return p.planDelete(txn, stmt)
+	case *parser.CreateTableStatement:
+		table, err := newTable(stmt)
+		if err != nil {
+			return nil, err
+		}
+		return NewCreateTable(p.DB, table, stmt.IfNotExists), nil
+	case *parser.DropTableStatement:
+		return NewDropTable(p.DB, txn, stmt.Table, stmt.IfExists), nil
+	case *parser.AlterTableStatement:
+		change, err := alterTable(stmt)
+		if err != nil {
+			return nil, err
+		}
+		return NewAlterTable(p.DB, txn, stmt.Table, change), nil
+	}
+	return nil, fmt.Errorf("cannot execute %T", node)
+}
+
+// planScan reads the rows of the table matching the where clause, through
+// the index when the optimizer found a primary key lookup.
+func (p *Planner) planScan(txn *transaction.Transaction, store *engine.TableStore, where parser.Expression, lookup *parser.IndexLookup) Operator {
+	var scan Operator
+	if lookup != nil {
+		scan = NewIndexScan(store, txn, lookup.Value)
+	} else {
+		scan = NewSeqScan(store, txn)
+	}
+
+	if where == nil {
+		return scan
+	}
+	return NewFilter(scan, store.Table().Columns, where)
+}
+
+// planSelect plans a query and returns the columns of its rows.
+func (p *Planner) planSelect(txn *transaction.Transaction, stmt *parser.SelectStatement) (Operator, []engine.Column, error) {
+	stmt, err := p.bindQuery(txn, stmt)
+	if err != nil {
+		return nil, nil, err
+	}
+	plan, columns, err := p.planFrom(txn, stmt)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	expressions, order := stmt.Expressions, stmt.OrderBy
+	if parser.IsAggregateQuery(stmt) {
+		aggregates := parser.Aggregates(append(append([]parser.Expression{stmt.Having}, expressions...), orderByExpressions(order)...)...)
+		aggregate, err := NewHashAggregate(plan, columns, stmt.GroupBy, aggregates, p.WorkMemory, p.TempDir)
+		if err != nil {
+			return nil, nil, err
+		}
+		plan, columns = aggregate, aggregate.columns
+
+		// everything above the aggregation reads the groups and aggregates
+		// from its columns
+		expressions = bindAggregate(columns, expressions...)
+		order = append([]parser.OrderByItem{}, order...)
+		for i, expr := range bindAggregate(columns, orderByExpressions(order)...) {
+			order[i].Expression = expr
+		}
+		if stmt.Having != nil {
+			having := bindAggregate(columns, stmt.Having)[0]
+			if err := checkColumns(columns, having); err != nil {
+				return nil, nil, err
+			}
+			plan = NewFilter(plan, columns, having)
+		}
+	}
+
+	if len(order) > 0 {
+		if err := checkColumns(columns, orderByExpressions(order)...); err != nil {
+			return nil, nil, err
+		}
+		if n, ok := topNRows(stmt, columns, p.WorkMemory); ok {
+			// only the rows up to the end of the limit are ever returned
+			plan = NewTopN(plan, columns, order, n)
+		} else {
+			plan = NewSort(plan, columns, order, p.WorkMemory, p.TempDir)
+		}
+	}
+
+	project, err := NewProject(plan, columns, stmt.Columns, expressions)
+	if err != nil {
+		return nil, nil, err
+	}
+	results := make([]engine.Column, len(expressions))
+	for i, dataType := range project.Types() {
+		results[i] = engine.Column{Name: stmt.Columns[i], Type: dataType}
+	}
+	if stmt.Limit == nil && stmt.Offset == 0 {
+		return project, results, nil
+	}
+
+	limit := -1
+	if stmt.Limit != nil {
+		limit = *stmt.Limit
+	}
+	return NewLimit(project, stmt.Offset, limit), results, nil
+}
+
+// planFrom reads the rows of the tables in the from clause matching the
+// where clause. The tables are joined from left to right, the columns of
+// joined rows are qualified by the alias or name of their table.
+func (p *Planner) planFrom(txn *transaction.Transaction, stmt *parser.SelectStatement) (Operator, []engine.Column, error) {
+	var plan Operator
+	var columns []engine.Column
+	if stmt.Subquery != nil {
+		var err error
+		if plan, columns, err = p.planSelect(txn, stmt.Subquery); err != nil {
+			return nil, nil, err
+		}
+	} else {
+		store, err := p.DB.Store(stmt.Table)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(stmt.Joins) == 0 {
+			return p.planScan(txn, store, stmt.Where, stmt.IndexLookup), store.Table().Columns, nil
+		}
+		plan, columns = NewSeqScan(store, txn), store.Table().Columns
+	}
+
+	var err error
+	if len(stmt.Joins) > 0 {
+		columns = qualifiedColumns(tableName(stmt.Table, stmt.Alias), columns)
+	}
+	for _, join := range stmt.Joins {
+		if plan, columns, err = p.planJoin(txn, plan, columns, join); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if stmt.Where == nil {
+		return plan, columns, nil
+	}
+	if err := checkColumns(columns, stmt.Where); err != nil {
+		return nil, nil, err
+	}
+	return NewFilter(plan, columns, stmt.Where), columns, nil
+}
+
+func tableName(table, alias string) string {
+	if alias != "" {
+		return alias
+	}
+	return table
+}
+
+func qualifiedColumns(name string, columns []engine.Column) []engine.Column {
+	qualified := make([]engine.Column, len(columns))
+	for i, column := range columns {
+		column.Name = name + "." + column.Name
+		qualified[i] = column
+	}
+	return qualified
+}
+
+// planJoin joins the table of the join to the rows of the plan, which have
+// the left columns. A join the planner's JoinMethod cannot run is planned as
+// with AutoJoin. A derived table has no index and is never merge joined.
+func (p *Planner) planJoin(txn *transaction.Transaction, plan Operator, left []engine.Column, join parser.Join) (Operator, []engine.Column, error) {
+	var store *engine.TableStore
+	var scan Operator
+	var right []engine.Column
+	if join.Subquery != nil {
+		derived, columns, err := p.planSelect(txn, join.Subquery)
+		if err != nil {
+			return nil, nil, err
+		}
+		scan, right = derived, qualifiedColumns(join.Alias, columns)
+	} else {
+		var err error
+		if store, err = p.DB.Store(join.Table); err != nil {
+			return nil, nil, err
+		}
+		scan = NewSeqScan(store, txn)
+		right = qualifiedColumns(tableName(join.Table, join.Alias), store.Table().Columns)
+	}
+	columns := append(append([]engine.Column{}, left...), right...)
+	if err := checkColumns(columns, join.On); err != nil {
+		return nil, nil, err
+	}
+	if join.Kind == parser.SEMI || join.Kind == parser.ANTI {
+		columns = left
+	}
+
+	leftKeys, rightKeys, keyTypes, residual := joinKeys(join.On, left, right)
+	lookup := -1
+	if store != nil && join.Kind != parser.RIGHT && join.Kind != parser.FULL {
+		lookup = lookupKey(store.Table(), right, leftKeys, rightKeys, left)
+	}
+
+	method := p.JoinMethod
+	switch {
+	case method == IndexNestedLoopJoinMethod && lookup < 0,
+		(method == HashJoinMethod || method == MergeJoinMethod) && len(leftKeys) == 0:
+		method = AutoJoin
+	}
+	if method == AutoJoin {
+		switch {
+		case lookup >= 0:
+			method = IndexNestedLoopJoinMethod
+		case len(leftKeys) > 0 && store != nil && store.Size() > p.workMemory():
+			method = MergeJoinMethod
+		case len(leftKeys) > 0:
+			method = HashJoinMethod
+		default:
+			method = NestedLoopJoinMethod
+		}
+	}
+
+	switch method {
+	case IndexNestedLoopJoinMethod:
+		return NewIndexNestedLoopJoin(plan, store, txn, join.Kind, left, right, leftKeys[lookup], join.On), columns, nil
+	case HashJoinMethod:
+		return NewHashJoin(plan, scan, join.Kind, left, right, leftKeys, rightKeys, keyTypes, residual), columns, nil
+	case MergeJoinMethod:
+		return NewMergeJoin(plan, scan, join.Kind, left, right, leftKeys, rightKeys, keyTypes, residual, p.WorkMemory, p.TempDir), columns, nil
+	}
+	return NewNestedLoopJoin(plan, scan, join.Kind, left, right, join.On), columns, nil
+}
+
+func (p *Planner) workMemory() int {
+	if p.WorkMemory <= 0 {
+		return DefaultWorkMemory
+	}
+	return p.WorkMemory
+}
+
+// joinKeys splits the condition of a join into the equalities between an
+// expression over the left columns and one over the right columns, which
+// hash and merge joins match rows by, and the residual rest. The values of
+// a pair of keys are compared as the type of the key.
+func joinKeys(condition parser.Expression, left, right []engine.Column) (leftKeys, rightKeys []parser.Expression, types []engine.DataType, residual parser.Expression) {
+	for _, term := range conjuncts(condition) {
+		if leftKey, rightKey, dataType, ok := equiJoinKey(term, left, right); ok {
+			leftKeys = append(leftKeys, leftKey)
+			rightKeys = append(rightKeys, rightKey)
+			types = append(types, dataType)
+			continue
+		}
+		if residual == nil {
+			residual = term
+		} else {
+			residual = &parser.BinaryExpression{Operator: parser.AND, Left: residual, Right: term}
+		}
+	}
+	return leftKeys, rightKeys, types, residual
+}
+
+func conjuncts(condition parser.Expression) []parser.Expression {
+	if b, ok := condition.(*parser.BinaryExpression); ok && b.Operator == parser.AND {
+		return append(conjuncts(b.Left), conjuncts(b.Right)...)
+	}
+	if condition == nil {
+		return nil
+	}
+	return []parser.Expression{condition}
+}
+
+func equiJoinKey(term parser.Expression, left, right []engine.Column) (parser.Expression, parser.Expression, engine.DataType, bool) {
+	b, ok := term.(*parser.BinaryExpression)
+	if !ok || b.Operator != parser.EQUALS {
+		return nil, nil, 0, false
+	}
+
+	for _, sides := range [][2]parser.Expression{{b.Left, b.Right}, {b.Right, b.Left}} {
+		if !refersTo(sides[0], left) || !refersTo(sides[1], right) {
+			continue
+		}
+		leftType, err := parser.TypeOf(sides[0], left)
+		if err != nil {
+			continue
+		}
+		rightType, err := parser.TypeOf(sides[1], right)
+		if err != nil {
+			continue
+		}
+		if dataType, ok := keyType(leftType, rightType); ok {
+			return sides[0], sides[1], dataType, true
+		}
+	}
+	return nil, nil, 0, false
+}
+
+// refersTo reports whether the expression reads columns and only the given
+// ones.
+func refersTo(expr parser.Expression, columns []engine.Column) bool {
+	return len(parser.ColumnNames(expr)) > 0 && checkColumns(columns, expr) == nil
+}
+
+// keyType is the type the values of two join keys are matched as. Numbers
+// match as the widest of their types and strings as text; keys of other
+// types only match keys of the same type.
+func keyType(a, b engine.DataType) (engine.DataType, bool) {
+	switch {
+	case a == b:
+		return a, true
+	case a == engine.Double || b == engine.Double:
+		return engine.Double, a.IsNumeric() && b.IsNumeric()
+	case a == engine.Decimal || b == engine.Decimal:
+		return engine.Decimal, a.IsNumeric() && b.IsNumeric()
+	case a.IsNumeric() && b.IsNumeric():
+		return engine.BigInt, true
+	case isString(a) && isString(b):
+		return engine.Text, true
+	}
+	return 0, false
+}
+
+func isString(dataType engine.DataType) bool {
+	return dataType == engine.Varchar || dataType == engine.Text
+}
+
+// lookupKey returns the position of the join key that the primary key of
+// the right table equals, whose left values can be looked up in its index,
+// or -1 if there is none.
+func lookupKey(table *engine.Table, right []engine.Column, leftKeys, rightKeys []parser.Expression, left []engine.Column) int {
+	pk := table.PrimaryKey()
+	if pk == nil {
+		return -1
+	}
+
+	for i, key := range rightKeys {
+		ref, ok := key.(*parser.ColumnRef)
+		if !ok || ref.String() != right[table.ColumnIndex(pk.Name)].Name {
+			continue
+		}
+		dataType, err := parser.TypeOf(leftKeys[i], left)
+		if err != nil {
+			continue
+		}
+		// the value has to encode as the key of the primary key type
+		if dataType == pk.Type || isString(dataType) && isString(pk.Type) || isInteger(dataType) && isInteger(pk.Type) {
+			return i
+		}
+	}
+	return -1
+}
+
+func isInteger(dataType engine.DataType) bool {
+	return dataType == engine.Int || dataType == engine.SmallInt || dataType == engine.BigInt
+}
+
+// bindAggregate replaces the GROUP BY expressions and aggregate functions in
+// the expressions by the columns of the aggregation that hold their values.
+func bindAggregate(columns []engine.Column, expressions ...parser.Expression) []parser.Expression {
+	bound := make([]parser.Expression, len(expressions))
+	for i, expr := range expressions {
+		bound[i] = parser.Transform(expr, func(e parser.Expression) parser.Expression {
+			name := e.String()
+			for _, column := range columns {
+				if column.Name == name {
+					return &parser.ColumnRef{Name: name}
+				}
+			}
+			return nil
+		})
+	}
+	return bound
+}
+
+func orderByExpressions(items []parser.OrderByItem) []parser.Expression {
+	expressions := make([]parser.Expression, len(items))
+	for i, item := range items {
+		expressions[i] = item.Expression
+	}
+	return expressions
+}
+
+// planInsert computes the rows of VALUES, or plans the query of an INSERT
+// ... SELECT, whose rows are read before the first one is inserted.
+func (p *Planner) planInsert(txn *transaction.Transaction, stmt *parser.InsertStatement) (Operator, error) {
+	store, err := p.DB.Store(stmt.Table)
+	if err != nil {
+		return nil, err
+	}
+	table := store.Table()
+
+	positions, err := insertColumns(table, stmt.Columns)
+	if err != nil {
+		return nil, err
+	}
+
+	if stmt.Select != nil {
+		plan, columns, err := p.planSelect(txn, stmt.Select)
+		if err != nil {
+			return nil, err
+		}
+		if len(columns) != len(positions) {
+			return nil, errors.New("column count doesn't match value count at row 1")
+		}
+		return p.planUpsert(txn, stmt, NewInsertSelect(store, txn, plan, positions))
+	}
+
+	rows := make([]engine.Row, len(stmt.Rows))
+	for i, values := range stmt.Rows {
+		if len(values) != len(positions) {
+			return nil, fmt.Errorf("column count doesn't match value count at row %d", i+1)
+		}
+
+		// DEFAULT leaves a column unset
+		var set []int
+		for j, expr := range values {
+			if expr != nil {
+				set = append(set, positions[j])
+			}
+		}
+		if rows[i], err = defaultRow(table, set); err != nil {
+			return nil, err
+		}
+
+		for j, expr := range values {
+			if expr == nil {
+				continue
+			}
+			bound, err := p.bind(txn, expr)
+			if err != nil {
+				return nil, err
+			}
+			if err := checkColumns(nil, bound); err != nil {
+				return nil, err
+			}
+			value, err := bound.Eval(nil, nil)
+			if err != nil {
+				return nil, err
+			}
+			rows[i][positions[j]] = value.Literal
+		}
+	}
+
+	return p.planUpsert(txn, stmt, NewInsert(store, txn, rows))
+}
+
+// planUpsert turns the insert of a REPLACE or an INSERT ... ON DUPLICATE KEY
+// UPDATE into an upsert.
+func (p *Planner) planUpsert(txn *transaction.Transaction, stmt *parser.InsertStatement, insert *Insert) (Operator, error) {
+	if !stmt.Replace && stmt.OnDuplicate == nil {
+		return insert, nil
+	}
+
+	table := insert.store.Table()
+	var set map[int]parser.Expression
+	if stmt.OnDuplicate != nil {
+		set = make(map[int]parser.Expression, len(stmt.OnDuplicate))
+	}
+	for name, expr := range stmt.OnDuplicate {
+		idx := table.ColumnIndex(name)
+		if idx < 0 {
+			return nil, errors.New("column " + name + " not found in table " + table.Name)
+		}
+		bound, err := p.bind(txn, expr)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkColumns(upsertColumns(table), bound); err != nil {
+			return nil, err
+		}
+		set[idx] = bound
+	}
+	return NewUpsert(insert, set), nil
+}
+
+// insertColumns returns the positions of the columns an insert names, or of
+// all columns of the table if it names none.
+func insertColumns(table *engine.Table, names []string) ([]int, error) {
+	if len(names) == 0 {
+		positions := make([]int, len(table.Columns))
+		for i := range positions {
+			positions[i] = i
+		}
+		return positions, nil
+	}
+
+	positions := make([]int, len(names))
+	for i, name := range names {
+		idx := table.ColumnIndex(name)
+		if idx < 0 {
+			return nil, errors.New("column " + name + " not found in table " + table.Name)
+		}
+		for _, other := range positions[:i] {
+			if other == idx {
+				return nil, errors.New("column " + name + " specified twice")
+			}
+		}
+		positions[i] = idx
+	}
+	return positions, nil
+}
+
+func (p *Planner) planUpdate(txn *transaction.Transaction, stmt *parser.UpdateStatement) (Operator, error) {
+	store, err := p.DB.Store(stmt.Table)
+	if err != nil {
+		return nil, err
+	}
+	table := store.Table()
+
+	set := make(map[int]parser.Expression, len(stmt.Set))
+	for name, expr := range stmt.Set {
+		idx := table.ColumnIndex(name)
+		if idx < 0 {
+			return nil, errors.New("column " + name + " not found in table " + table.Name)
+		}
+		bound, err := p.bind(txn, expr)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkColumns(table.Columns, bound); err != nil {
+			return nil, err
+		}
+		set[idx] = bound
+	}
+	where, err := p.bind(txn, stmt.Where)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkColumns(table.Columns, where); err != nil {
+		return nil, err
+	}
+
+	return NewUpdate(store, txn, p.planScan(txn, store, where, nil), set), nil
+}
+
+func (p *Planner) planDelete(txn *transaction.Transaction, stmt *parser.DeleteStatement) (Operator, error) {
+	store, err := p.DB.Store(stmt.Table)
+	if err != nil {
+		return nil, err
+	}
+	where, err := p.bind(txn, stmt.Where)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkColumns(store.Table().Columns, where); err != nil {
+		return nil, err
+	}
+
+	return NewDelete(store, txn, p.planScan(txn, store, where, stmt.IndexLookup)), nil
+}
+
+// newColumn checks a column definition and turns it into a column.
+func newColumn(def parser.ColumnDefinition) (engine.Column, error) {
+	dataType, err := engine.ParseDataType(def.Type)
+	if err != nil {
+		return engine.Column{}, err
+	}
+
+	column := engine.Column{
+		Name:       def.Name,
+		Type:       dataType,
+		PrimaryKey: def.PrimaryKey,
+		Unique:     def.Unique || def.PrimaryKey,
+		NotNull:    def.NotNull || def.PrimaryKey,
+		Default:    def.Default,
+	}
+	if def.Default != nil && *def.Default == engine.Null {
+		if column.NotNull {
+			return column, errors.New("invalid default value for column " + def.Name)
+		}
+		column.Default = nil
+	}
+
+	switch {
+	case dataType == engine.Decimal:
+		if def.Length > engine.MaxPrecision || def.Scale > engine.MaxScale {
+			return column, fmt.Errorf("column %s exceeds DECIMAL(%d,%d)", def.Name, engine.MaxPrecision, engine.MaxScale)
+		}
+		if def.Scale > def.Length {
+			return column, errors.New("scale of column " + def.Name + " exceeds its precision")
+		}
+		column.Precision, column.Scale = def.Length, def.Scale
+	case def.Scale > 0:
+		return column, errors.New("column " + def.Name + " of type " + def.Type + " takes no scale")
+	case dataType == engine.Varchar:
+		column.Length = def.Length
+	case def.Length > 0:
+		return column, errors.New("column " + def.Name + " of type " + def.Type + " takes no length")
+	}
+	return column, nil
+}
+
+// newTable checks the column definitions of the statement and turns them into
+// a table.
+func newTable(stmt *parser.CreateTableStatement) (*engine.Table, error) {
+	columns := make([]engine.Column, 0, len(stmt.Columns))
+	primaryKeys := 0
+	for _, def := range stmt.Columns {
+		for _, column := range columns {
+			if strings.EqualFold(column.Name, def.Name) {
+				return nil, errors.New("duplicate column name " + def.Name)
+			}
+		}
+
+		column, err := newColumn(def)
+		if err != nil {
+			return nil, err
+		}
+		if column.PrimaryKey {
+			primaryKeys++
+		}
+		columns = append(columns, column)
+	}
+
+	if primaryKeys > 1 {
+		return nil, errors.New("multiple primary keys defined")
+	}
+	return engine.NewTable(stmt.Table, columns), nil
+}
+
+// alterTable turns the actions of the statement into a change that applies
+// them in order.
+func alterTable(stmt *parser.AlterTableStatement) (func(*engine.Table) (*engine.Table, error), error) {
+	changes := make([]func(*engine.Table) (*engine.Table, error), 0, len(stmt.Actions))
+	for _, action := range stmt.Actions {
+		switch action := action.(type) {
+		case *parser.AddColumnAction:
+			column, err := newColumn(action.Column)
+			if err != nil {
+				return nil, err
+			}
+			changes = append(changes, func(table *engine.Table) (*engine.Table, error) {
+				return table.AddColumn(column)
+			})
+		case *parser.DropColumnAction:
+			changes = append(changes, func(table *engine.Table) (*engine.Table, error) {
+				return table.DropColumn(action.Column)
+			})
+		case *parser.RenameColumnAction:
+			changes = append(changes, func(table *engine.Table) (*engine.Table, error) {
+				return table.RenameColumn(action.Column, action.NewName)
+			})
+		case *parser.ModifyColumnAction:
+			column, err := newColumn(action.Column)
+			if err != nil {
+				return nil, err
+			}
+			changes = append(changes, func(table *engine.Table) (*engine.Table, error) {
+				return table.ModifyColumn(column)
+			})
+		case *parser.RenameTableAction:
+			changes = append(changes, func(table *engine.Table) (*engine.Table, error) {
+				return table.Rename(action.NewName), nil
+			})
+		default:
+			return nil, fmt.Errorf("cannot execute %T", action)
+		}
+	}
+
+	return func(table *engine.Table) (*engine.Table, error) {
+		var err error
+		for _, change := range changes {
+			if table, err = change(table); err != nil {
+				return nil, err
+			}
+		}
+		return table, nil
+	}, nil
+}
@@ -0,0 +1,886 @@
+package executor
+
+import (
+	"dbngin3/engine"
+	"dbngin3/parser"
+	"dbngin3/transaction"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func openTestDatabase(t *testing.T, tables ...*engine.Table) *engine.Database {
+	schema := engine.NewSchemaManager()
+	schema.AddTable("users", engine.NewTable("users", []engine.Column{
+		{Name: "id", Type: engine.Int, PrimaryKey: true},
+		{Name: "name", Type: engine.Varchar},
+		{Name: "age", Type: engine.Int},
+	}))
+	for _, table := range tables {
+		schema.AddTable(table.Name, table)
+	}
+
+	db, err := engine.OpenDatabase(t.TempDir(), schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
+
+func begin(t *testing.T, db *engine.Database) *transaction.Transaction {
+	txn, err := db.Transactions().Begin(transaction.RepeatableRead)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Transactions().Rollback(txn) })
+	return txn
+}
+
+// run parses, plans and executes a statement the way the CLI does.
+func run(t *testing.T, db *engine.Database, txn *transaction.Transaction, query string) *Result {
+	return runWith(t, &Planner{DB: db}, txn, query)
+}
+
+func runWith(t *testing.T, planner *Planner, txn *transaction.Transaction, query string) *Result {
+	db := planner.DB
+	lexer := &parser.Lexer{}
+	if err := lexer.SetInput(query); err != nil {
+		t.Fatal(err)
+	}
+	tokens, err := lexer.Tokenize()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &parser.Parser{}
+	if err := p.SetToken(tokens); err != nil {
+		t.Fatal(err)
+	}
+	node, err := p.Parse()
+	if err != nil {
+		t.Fatalf("%s: %s", query, err)
+	}
+
+	if err := (&parser.SemanticAnalyzer{Schema: db.Schema()}).Analyze(node); err != nil {
+		t.Fatalf("%s: %s", query, err)
+	}
+
+	switch stmt := node.(type) {
+	case *parser.SelectStatement:
+		if err := (&parser.SelectQueryOptimizer{Schema: db.Schema()}).Optimize(stmt); err != nil {
+			t.Fatal(err)
+		}
+	case *parser.DeleteStatement:
+		if err := (&parser.DeleteQueryOptimizer{Schema: db.Schema()}).Optimize(stmt); err != nil {
+			t.Fatal(err)
+		}
+	case *parser.InsertStatement:
+		if err := (&parser.InsertQueryOptimizer{Schema: db.Schema()}).Optimize(stmt); err != nil {
+			t.Fatal(err)
+		}
+	case *parser.UpdateStatement:
+		if err := (&parser.UpdateQueryOptimizer{Schema: db.Schema()}).Optimize(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	plan, err := planner.Plan(txn, node)
+	if err != nil {
+		t.Fatalf("%s: %s", query, err)
+	}
+	result, err := Execute(plan)
+	if err != nil {
+		t.Fatalf("%s: %s", query, err)
+	}
+	return result
+}
+
+func TestPlanner_Select(t *testing.T) {
+	db := openTestDatabase(t)
+	txn := begin(t, db)
+
+	run(t, db, txn, "INSERT INTO users (id, name, age) VALUES (1, 'John', 30)")
+	run(t, db, txn, "INSERT INTO users (name, id, age) VALUES ('Jane', 2, 25)")
+	run(t, db, txn, "INSERT INTO users (id, name, age) VALUES (10, 'Marty', 17)")
+
+	tests := []struct {
+		name    string
+		query   string
+		columns []string
+		rows    []engine.Row
+	}{
+		{
+			name:    "Wildcard returns every column",
+			query:   "SELECT * FROM users",
+			columns: []string{"id", "name", "age"},
+			rows:    []engine.Row{{"1", "John", "30"}, {"2", "Jane", "25"}, {"10", "Marty", "17"}},
+		},
+		{
+			name:    "Columns are projected in the given order",
+			query:   "SELECT name, id FROM users WHERE age < 30",
+			columns: []string{"name", "id"},
+			rows:    []engine.Row{{"Jane", "2"}, {"Marty", "10"}},
+		},
+		{
+			name:    "Integers compare numerically",
+			query:   "SELECT name FROM users WHERE id >= 2 AND id <= 10",
+			columns: []string{"name"},
+			rows:    []engine.Row{{"Jane"}, {"Marty"}},
+		},
+		{
+			name:    "Or of conditions",
+			query:   "SELECT id FROM users WHERE name = 'John' OR age = 17",
+			columns: []string{"id"},
+			rows:    []engine.Row{{"1"}, {"10"}},
+		},
+		{
+			name:    "Primary key lookup",
+			query:   "SELECT name FROM users WHERE id = 2",
+			columns: []string{"name"},
+			rows:    []engine.Row{{"Jane"}},
+		},
+		{
+			name:    "Primary key lookup checks the other conditions",
+			query:   "SELECT name FROM users WHERE id = 2 AND age > 30",
+			columns: []string{"name"},
+		},
+		{
+			name:    "Missing key",
+			query:   "SELECT name FROM users WHERE id = 3",
+			columns: []string{"name"},
+		},
+		{
+			name:    "Whole decimal is an integer key",
+			query:   "SELECT name FROM users WHERE id = 2.0",
+			columns: []string{"name"},
+			rows:    []engine.Row{{"Jane"}},
+		},
+		{
+			name:    "Fraction is no integer key",
+			query:   "SELECT name FROM users WHERE 1.5 = id",
+			columns: []string{"name"},
+		},
+		{
+			name:    "Hex and bit literals are numbers",
+			query:   "SELECT name FROM users WHERE id = 0x02 OR id = b'1010' OR age = x'1E'",
+			columns: []string{"name"},
+			rows:    []engine.Row{{"John"}, {"Jane"}, {"Marty"}},
+		},
+		{
+			name:    "Hex literal as a key",
+			query:   "SELECT name FROM users WHERE id = X'0A'",
+			columns: []string{"name"},
+			rows:    []engine.Row{{"Marty"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := run(t, db, txn, tt.query)
+
+			if !result.IsQuery() || !reflect.DeepEqual(result.Columns, tt.columns) {
+				t.Errorf("expected columns %v, got %v", tt.columns, result.Columns)
+			}
+			if !reflect.DeepEqual(result.Rows, tt.rows) {
+				t.Errorf("expected rows %v, got %v", tt.rows, result.Rows)
+			}
+		})
+	}
+
+	t.Run("Result carries the column types", func(t *testing.T) {
+		result := run(t, db, txn, "SELECT name, id + 1 FROM users LIMIT 1")
+		if expected := []engine.DataType{engine.Varchar, engine.BigInt}; !reflect.DeepEqual(result.Types, expected) {
+			t.Errorf("expected types %v, got %v", expected, result.Types)
+		}
+	})
+}
+
+func TestPlanner_Insert(t *testing.T) {
+	note, zero := "none", "0"
+	db := openTestDatabase(t, engine.NewTable("items", []engine.Column{
+		{Name: "id", Type: engine.Int, PrimaryKey: true},
+		{Name: "qty", Type: engine.Int, NotNull: true, Default: &zero},
+		{Name: "note", Type: engine.Varchar, Default: &note},
+	}), engine.NewTable("weights", []engine.Column{
+		{Name: "id", Type: engine.Int, PrimaryKey: true},
+		{Name: "w", Type: engine.Int, NotNull: true},
+	}))
+	txn := begin(t, db)
+
+	t.Run("Rows are inserted as one batch", func(t *testing.T) {
+		result := run(t, db, txn, "INSERT INTO users VALUES (1, 'John', 30), (2, 'Jane', 20 + 5), (3, 'Marty', NULL);")
+		if result.Affected != 3 {
+			t.Errorf("expected 3 affected rows, got %d", result.Affected)
+		}
+
+		rows := run(t, db, txn, "SELECT id, name, age FROM users ORDER BY id").Rows
+		expected := []engine.Row{{"1", "John", "30"}, {"2", "Jane", "25"}, {"3", "Marty", engine.Null}}
+		if !reflect.DeepEqual(rows, expected) {
+			t.Errorf("expected rows %v, got %v", expected, rows)
+		}
+	})
+
+	t.Run("Left out and DEFAULT columns get their defaults", func(t *testing.T) {
+		run(t, db, txn, "INSERT INTO items (id) VALUES (1)")
+		run(t, db, txn, "INSERT INTO items VALUES (2, 4, DEFAULT), (3, DEFAULT, 'x')")
+		run(t, db, txn, "INSERT INTO items (note, id) VALUES ('y', (SELECT MAX(id) FROM users) + 1)")
+
+		rows := run(t, db, txn, "SELECT * FROM items ORDER BY id").Rows
+		expected := []engine.Row{{"1", "0", "none"}, {"2", "4", "none"}, {"3", "0", "x"}, {"4", "0", "y"}}
+		if !reflect.DeepEqual(rows, expected) {
+			t.Errorf("expected rows %v, got %v", expected, rows)
+		}
+	})
+
+	t.Run("Insert select reads its rows first", func(t *testing.T) {
+		result := run(t, db, txn, "INSERT INTO items (id, qty) SELECT id + 10, qty * 2 FROM items WHERE id > 1")
+		if result.Affected != 3 {
+			t.Errorf("expected 3 affected rows, got %d", result.Affected)
+		}
+
+		rows := run(t, db, txn, "SELECT id, qty, note FROM items WHERE id > 10 ORDER BY id").Rows
+		expected := []engine.Row{{"12", "8", "none"}, {"13", "0", "none"}, {"14", "0", "none"}}
+		if !reflect.DeepEqual(rows, expected) {
+			t.Errorf("expected rows %v, got %v", expected, rows)
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		for query, expected := range map[string]string{
+			"INSERT INTO users VALUES (4, 'Doc')":                               "column count doesn't match value count at row 1",
+			"INSERT INTO users (id) VALUES (4), (5, 'Doc')":                     "column count doesn't match value count at row 2",
+			"INSERT INTO users (id, name) SELECT id FROM items":                 "column count doesn't match value count at row 1",
+			"INSERT INTO users (id, id) VALUES (4, 5)":                          "column id specified twice",
+			"INSERT INTO users (id, email) VALUES (4, 'x')":                     "column email not found in table users",
+			"INSERT INTO users (id, name) VALUES (4, (SELECT name FROM users))": "subquery returns more than 1 row",
+			"INSERT INTO weights (id) VALUES (3)":                               "field 'w' doesn't have a default value",
+			"INSERT INTO weights VALUES (3, DEFAULT)":                           "field 'w' doesn't have a default value",
+			"INSERT INTO weights (w) VALUES (1)":                                "field 'id' doesn't have a default value",
+			"INSERT INTO weights (id) SELECT id FROM users":                     "field 'w' doesn't have a default value",
+		} {
+			tokens, err := parser.NewLexer(query).Tokenize()
+			if err != nil {
+				t.Fatal(err)
+			}
+			node, err := parser.NewParser(tokens).Parse()
+			if err != nil {
+				t.Fatal(err)
+			}
+			stmt := node.(*parser.InsertStatement)
+			if err := (&parser.InsertQueryOptimizer{Schema: db.Schema()}).Optimize(stmt); err != nil {
+				t.Fatal(err)
+			}
+
+			plan, err := (&Planner{DB: db}).Plan(txn, stmt)
+			if err == nil {
+				_, err = Execute(plan)
+			}
+			if err == nil || err.Error() != expected {
+				t.Errorf("%s: expected error %q, got %v", query, expected, err)
+			}
+		}
+	})
+}
+
+func TestPlanner_Upsert(t *testing.T) {
+	db := openTestDatabase(t, engine.NewTable("accounts", []engine.Column{
+		{Name: "id", Type: engine.Int, PrimaryKey: true},
+		{Name: "email", Type: engine.Varchar, Unique: true},
+		{Name: "hits", Type: engine.Int},
+	}))
+	txn := begin(t, db)
+
+	run(t, db, txn, "INSERT INTO accounts VALUES (1, 'john@example.com', 1), (2, 'jane@example.com', 1)")
+
+	counts := func(t *testing.T, result *Result, records, duplicates, affected int) {
+		t.Helper()
+		if result.Records != records || result.Duplicates != duplicates || result.Affected != affected {
+			t.Errorf("expected %d records, %d duplicates and %d affected rows, got %d, %d and %d",
+				records, duplicates, affected, result.Records, result.Duplicates, result.Affected)
+		}
+	}
+	rows := func(t *testing.T, expected []engine.Row) {
+		t.Helper()
+		if rows := run(t, db, txn, "SELECT * FROM accounts ORDER BY id").Rows; !reflect.DeepEqual(rows, expected) {
+			t.Errorf("expected rows %v, got %v", expected, rows)
+		}
+	}
+
+	t.Run("Duplicate key updates the existing row", func(t *testing.T) {
+		result := run(t, db, txn, "INSERT INTO accounts VALUES (1, 'john@example.com', 5), (3, 'joe@example.com', 1) ON DUPLICATE KEY UPDATE hits = hits + VALUES(hits)")
+		counts(t, result, 2, 1, 3)
+		rows(t, []engine.Row{{"1", "john@example.com", "6"}, {"2", "jane@example.com", "1"}, {"3", "joe@example.com", "1"}})
+	})
+
+	t.Run("Duplicate unique value updates the row holding it", func(t *testing.T) {
+		result := run(t, db, txn, "INSERT INTO accounts VALUES (4, 'jane@example.com', 1) ON DUPLICATE KEY UPDATE hits = hits + 1")
+		counts(t, result, 1, 1, 2)
+		rows(t, []engine.Row{{"1", "john@example.com", "6"}, {"2", "jane@example.com", "2"}, {"3", "joe@example.com", "1"}})
+	})
+
+	t.Run("Unchanged row is not affected", func(t *testing.T) {
+		result := run(t, db, txn, "INSERT INTO accounts (id) VALUES (3) ON DUPLICATE KEY UPDATE hits = 1")
+		counts(t, result, 1, 1, 0)
+	})
+
+	t.Run("Rows of the same statement collide too", func(t *testing.T) {
+		result := run(t, db, txn, "INSERT INTO accounts VALUES (5, 'doc@example.com', 1), (5, 'doc@example.com', 2) ON DUPLICATE KEY UPDATE hits = hits + VALUES(hits)")
+		counts(t, result, 2, 1, 3)
+		if rows := run(t, db, txn, "SELECT hits FROM accounts WHERE id = 5").Rows; !reflect.DeepEqual(rows, []engine.Row{{"3"}}) {
+			t.Errorf("expected 3 hits, got %v", rows)
+		}
+	})
+
+	t.Run("Replace deletes every colliding row", func(t *testing.T) {
+		result := run(t, db, txn, "REPLACE INTO accounts VALUES (1, 'jane@example.com', 0), (6, 'marty@example.com', 0)")
+		counts(t, result, 2, 1, 4)
+		rows(t, []engine.Row{{"1", "jane@example.com", "0"}, {"3", "joe@example.com", "1"}, {"5", "doc@example.com", "3"}, {"6", "marty@example.com", "0"}})
+	})
+
+	t.Run("Insert select upserts", func(t *testing.T) {
+		result := run(t, db, txn, "INSERT INTO accounts (id, email) SELECT id, email FROM accounts WHERE id > 4 ON DUPLICATE KEY UPDATE hits = VALUES(id)")
+		counts(t, result, 2, 2, 4)
+		if rows := run(t, db, txn, "SELECT hits FROM accounts WHERE id > 4 ORDER BY id").Rows; !reflect.DeepEqual(rows, []engine.Row{{"5"}, {"6"}}) {
+			t.Errorf("expected the hits to be the ids, got %v", rows)
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		_, err := (&Planner{DB: db}).Plan(txn, &parser.InsertStatement{
+			Table:       "accounts",
+			Rows:        [][]parser.Expression{{&parser.Literal{Value: "1"}, &parser.Literal{Value: "x"}, &parser.Literal{Value: "0"}}},
+			OnDuplicate: map[string]parser.Expression{"unknown": &parser.Literal{Value: "1"}},
+		})
+		if err == nil || err.Error() != "column unknown not found in table accounts" {
+			t.Errorf("expected unknown column error, got %v", err)
+		}
+
+		plan, err := (&Planner{DB: db}).Plan(txn, &parser.InsertStatement{
+			Table:       "accounts",
+			Rows:        [][]parser.Expression{{&parser.Literal{Value: "1"}, &parser.Literal{Value: "x"}, &parser.Literal{Value: "0"}}},
+			OnDuplicate: map[string]parser.Expression{"id": &parser.Literal{Value: "3"}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Execute(plan); err == nil {
+			t.Errorf("expected duplicate key error")
+		}
+	})
+}
+
+func TestPlanner_Update(t *testing.T) {
+	db := openTestDatabase(t)
+	txn := begin(t, db)
+
+	run(t, db, txn, "INSERT INTO users (id, name, age) VALUES (1, 'John', 30)")
+	run(t, db, txn, "INSERT INTO users (id, name, age) VALUES (2, 'Jane', 25)")
+
+	t.Run("Affected rows are counted", func(t *testing.T) {
+		result := run(t, db, txn, "UPDATE users SET age = 40 WHERE age >= 25")
+		if result.IsQuery() || result.Affected != 2 {
+			t.Errorf("expected 2 affected rows, got %d", result.Affected)
+		}
+
+		rows := run(t, db, txn, "SELECT age FROM users").Rows
+		if !reflect.DeepEqual(rows, []engine.Row{{"40"}, {"40"}}) {
+			t.Errorf("expected every row to be updated once, got %v", rows)
+		}
+	})
+
+	t.Run("Updated primary key is found through the index", func(t *testing.T) {
+		run(t, db, txn, "UPDATE users SET id = 3 WHERE name = 'Jane'")
+
+		rows := run(t, db, txn, "SELECT name FROM users WHERE id = 3").Rows
+		if !reflect.DeepEqual(rows, []engine.Row{{"Jane"}}) {
+			t.Errorf("expected [[Jane]], got %v", rows)
+		}
+	})
+
+	t.Run("Booleans are stored as 1 and 0", func(t *testing.T) {
+		run(t, db, txn, "UPDATE users SET age = name = 'Jane'")
+
+		rows := run(t, db, txn, "SELECT name, age FROM users ORDER BY id").Rows
+		if !reflect.DeepEqual(rows, []engine.Row{{"John", "0"}, {"Jane", "1"}}) {
+			t.Errorf("expected ages 0 and 1, got %v", rows)
+		}
+		run(t, db, txn, "UPDATE users SET age = 40")
+	})
+
+	t.Run("Subqueries are run for every row", func(t *testing.T) {
+		run(t, db, txn, "UPDATE users SET age = (SELECT COUNT(*) FROM users u WHERE u.id <= users.id) WHERE id IN (SELECT id FROM users WHERE name = 'Jane')")
+
+		rows := run(t, db, txn, "SELECT name, age FROM users ORDER BY id").Rows
+		if !reflect.DeepEqual(rows, []engine.Row{{"John", "40"}, {"Jane", "2"}}) {
+			t.Errorf("expected Jane to be counted second, got %v", rows)
+		}
+	})
+
+	t.Run("Unknown column", func(t *testing.T) {
+		stmt := &parser.UpdateStatement{Table: "users", Set: map[string]parser.Expression{"email": &parser.Literal{Value: "x"}}}
+		if _, err := (&Planner{DB: db}).Plan(txn, stmt); err == nil {
+			t.Errorf("expected unknown column error")
+		}
+
+		stmt = &parser.UpdateStatement{Table: "users", Set: map[string]parser.Expression{"age": &parser.ColumnRef{Name: "email"}}}
+		if _, err := (&Planner{DB: db}).Plan(txn, stmt); err == nil {
+			t.Errorf("expected unknown column error in the new value")
+		}
+	})
+}
+
+func TestPlanner_Delete(t *testing.T) {
+	db := openTestDatabase(t)
+	txn := begin(t, db)
+
+	run(t, db, txn, "INSERT INTO users (id, name, age) VALUES (1, 'John', 30)")
+	run(t, db, txn, "INSERT INTO users (id, name, age) VALUES (2, 'Jane', 25)")
+	run(t, db, txn, "INSERT INTO users (id, name, age) VALUES (3, 'Jim', 40)")
+
+	t.Run("Affected rows are counted", func(t *testing.T) {
+		result := run(t, db, txn, "DELETE FROM users WHERE age < 35 AND name <> 'John'")
+		if result.IsQuery() || result.Affected != 1 {
+			t.Errorf("expected 1 affected row, got %d", result.Affected)
+		}
+
+		rows := run(t, db, txn, "SELECT name FROM users").Rows
+		if !reflect.DeepEqual(rows, []engine.Row{{"John"}, {"Jim"}}) {
+			t.Errorf("expected [[John] [Jim]], got %v", rows)
+		}
+	})
+
+	t.Run("Deleted primary key is gone from the index and free again", func(t *testing.T) {
+		if result := run(t, db, txn, "DELETE FROM users WHERE id = 1"); result.Affected != 1 {
+			t.Errorf("expected 1 affected row, got %d", result.Affected)
+		}
+		if rows := run(t, db, txn, "SELECT name FROM users WHERE id = 1").Rows; len(rows) != 0 {
+			t.Errorf("expected no row, got %v", rows)
+		}
+
+		run(t, db, txn, "INSERT INTO users (id, name, age) VALUES (1, 'Joe', 20)")
+		rows := run(t, db, txn, "SELECT name FROM users WHERE id = 1").Rows
+		if !reflect.DeepEqual(rows, []engine.Row{{"Joe"}}) {
+			t.Errorf("expected [[Joe]], got %v", rows)
+		}
+	})
+
+	t.Run("Primary key compared with numbers of other types", func(t *testing.T) {
+		if result := run(t, db, txn, "DELETE FROM users WHERE id = 1.5"); result.Affected != 0 {
+			t.Errorf("expected 0 affected rows, got %d", result.Affected)
+		}
+		if result := run(t, db, txn, "DELETE FROM users WHERE id = 3.00"); result.Affected != 1 {
+			t.Errorf("expected 1 affected row, got %d", result.Affected)
+		}
+		run(t, db, txn, "INSERT INTO users (id, name, age) VALUES (0x03, 'Jim', b'101000')")
+		rows := run(t, db, txn, "SELECT name, age FROM users WHERE id = 3").Rows
+		if !reflect.DeepEqual(rows, []engine.Row{{"Jim", "40"}}) {
+			t.Errorf("expected [[Jim 40]], got %v", rows)
+		}
+	})
+
+	t.Run("Without where every row is deleted", func(t *testing.T) {
+		if result := run(t, db, txn, "DELETE FROM users;"); result.Affected != 2 {
+			t.Errorf("expected 2 affected rows, got %d", result.Affected)
+		}
+		if result := run(t, db, txn, "DELETE FROM users"); result.Affected != 0 {
+			t.Errorf("expected 0 affected rows, got %d", result.Affected)
+		}
+	})
+
+	t.Run("Unknown column", func(t *testing.T) {
+		stmt := &parser.DeleteStatement{Table: "users", Where: &parser.IsNullExpression{Operand: &parser.ColumnRef{Name: "email"}}}
+		if _, err := (&Planner{DB: db}).Plan(txn, stmt); err == nil {
+			t.Errorf("expected unknown column error")
+		}
+	})
+}
+
+func TestPlanner_OrderByLimit(t *testing.T) {
+	db := openTestDatabase(t)
+	txn := begin(t, db)
+
+	run(t, db, txn, "INSERT INTO users (id, name, age) VALUES (1, 'John', 30)")
+	run(t, db, txn, "INSERT INTO users (id, name, age) VALUES (2, 'Jane', 25)")
+	run(t, db, txn, "INSERT INTO users (id, name, age) VALUES (3, 'Marty', NULL)")
+	run(t, db, txn, "INSERT INTO users (id, name, age) VALUES (4, 'Jim', 30)")
+
+	tests := []struct {
+		query string
+		rows  []engine.Row
+	}{
+		{"SELECT id FROM users ORDER BY age", []engine.Row{{"3"}, {"2"}, {"1"}, {"4"}}},
+		{"SELECT id FROM users ORDER BY age DESC, name", []engine.Row{{"4"}, {"1"}, {"2"}, {"3"}}},
+		{"SELECT id FROM users ORDER BY age ASC NULLS LAST, id DESC", []engine.Row{{"2"}, {"4"}, {"1"}, {"3"}}},
+		{"SELECT id FROM users ORDER BY age DESC NULLS FIRST", []engine.Row{{"3"}, {"1"}, {"4"}, {"2"}}},
+		{"SELECT id, -id AS down FROM users ORDER BY down", []engine.Row{{"4", "-4"}, {"3", "-3"}, {"2", "-2"}, {"1", "-1"}}},
+		{"SELECT name, id FROM users ORDER BY 2 DESC LIMIT 2", []engine.Row{{"Jim", "4"}, {"Marty", "3"}}},
+		{"SELECT * FROM users ORDER BY 2 LIMIT 1", []engine.Row{{"2", "Jane", "25"}}},
+		{"SELECT id FROM users ORDER BY id % 2, id LIMIT 2 OFFSET 1", []engine.Row{{"4"}, {"1"}}},
+		{"SELECT id FROM users ORDER BY id LIMIT 1, 2", []engine.Row{{"2"}, {"3"}}},
+		{"SELECT id FROM users LIMIT 2", []engine.Row{{"1"}, {"2"}}},
+		{"SELECT id FROM users WHERE age = 30 ORDER BY id DESC LIMIT 5", []engine.Row{{"4"}, {"1"}}},
+		{"SELECT id FROM users ORDER BY id LIMIT 0", nil},
+		{"SELECT id FROM users ORDER BY id LIMIT 10 OFFSET 4", nil},
+		{"SELECT id FROM users ORDER BY id LIMIT 9223372036854775807 OFFSET 1", []engine.Row{{"2"}, {"3"}, {"4"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			if rows := run(t, db, txn, tt.query).Rows; !reflect.DeepEqual(rows, tt.rows) {
+				t.Errorf("expected rows %v, got %v", tt.rows, rows)
+			}
+		})
+	}
+
+	t.Run("Large sorts spill", func(t *testing.T) {
+		stmt := &parser.SelectStatement{
+			Table:       "users",
+			Columns:     []string{"id"},
+			Expressions: []parser.Expression{&parser.ColumnRef{Name: "id"}},
+			OrderBy:     []parser.OrderByItem{{Expression: &parser.ColumnRef{Name: "name"}, NullsFirst: true}},
+		}
+		plan, err := (&Planner{DB: db, WorkMemory: 1, TempDir: t.TempDir()}).Plan(txn, stmt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, err := Execute(plan)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := []engine.Row{{"2"}, {"4"}, {"1"}, {"3"}}; !reflect.DeepEqual(result.Rows, expected) {
+			t.Errorf("expected rows %v, got %v", expected, result.Rows)
+		}
+	})
+
+	t.Run("Limits beyond the memory budget sort", func(t *testing.T) {
+		for _, tt := range []struct {
+			limit, offset, memory int
+			topN                  bool
+			rows                  []engine.Row
+		}{
+			{limit: 2, offset: 1, memory: 0, topN: true, rows: []engine.Row{{"4"}, {"1"}}},
+			{limit: 1000, offset: 0, memory: 1000, topN: false, rows: []engine.Row{{"2"}, {"4"}, {"1"}, {"3"}}},
+			{limit: math.MaxInt, offset: 1, memory: 0, topN: false, rows: []engine.Row{{"4"}, {"1"}, {"3"}}},
+		} {
+			limit := tt.limit
+			stmt := &parser.SelectStatement{
+				Table:       "users",
+				Columns:     []string{"id"},
+				Expressions: []parser.Expression{&parser.ColumnRef{Name: "id"}},
+				OrderBy:     []parser.OrderByItem{{Expression: &parser.ColumnRef{Name: "name"}}},
+				Limit:       &limit,
+				Offset:      tt.offset,
+			}
+			plan, err := (&Planner{DB: db, WorkMemory: tt.memory, TempDir: t.TempDir()}).Plan(txn, stmt)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, topN := plan.(*Limit).child.(*Project).child.(*TopN)
+			if topN != tt.topN {
+				t.Errorf("LIMIT %d OFFSET %d in %d bytes: expected a TopN %v, got %T", tt.limit, tt.offset, tt.memory, tt.topN, plan.(*Limit).child.(*Project).child)
+			}
+			result, err := Execute(plan)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(result.Rows, tt.rows) {
+				t.Errorf("expected rows %v, got %v", tt.rows, result.Rows)
+			}
+		}
+	})
+}
+
+func TestPlanner_GroupBy(t *testing.T) {
+	db := openTestDatabase(t)
+	txn := begin(t, db)
+
+	run(t, db, txn, "INSERT INTO users (id, name, age) VALUES (1, 'John', 30)")
+	run(t, db, txn, "INSERT INTO users (id, name, age) VALUES (2, 'Jane', 25)")
+	run(t, db, txn, "INSERT INTO users (id, name, age) VALUES (3, 'Marty', NULL)")
+	run(t, db, txn, "INSERT INTO users (id, name, age) VALUES (4, 'Jim', 30)")
+	run(t, db, txn, "INSERT INTO users (id, name, age) VALUES (5, 'John', 40)")
+
+	tests := []struct {
+		query string
+		rows  []engine.Row
+	}{
+		{"SELECT COUNT(*), COUNT(age), COUNT(DISTINCT age), SUM(age), AVG(age), MIN(name), MAX(age) FROM users",
+			[]engine.Row{{"5", "4", "3", "125", "31.2500", "Jane", "40"}}},
+		{"SELECT COUNT(*), SUM(age), MAX(name) FROM users WHERE id > 10", []engine.Row{{"0", engine.Null, engine.Null}}},
+		{"SELECT age, COUNT(*) FROM users GROUP BY age ORDER BY age", []engine.Row{{engine.Null, "1"}, {"25", "1"}, {"30", "2"}, {"40", "1"}}},
+		{"SELECT name, SUM(age) total FROM users GROUP BY 1 HAVING total > 30 ORDER BY total DESC", []engine.Row{{"John", "70"}}},
+		{"SELECT age / 10 AS decade, COUNT(*) FROM users WHERE age IS NOT NULL GROUP BY decade ORDER BY 1", []engine.Row{{"2.5000", "1"}, {"3.0000", "2"}, {"4.0000", "1"}}},
+		{"SELECT name FROM users GROUP BY name HAVING COUNT(*) > 1", []engine.Row{{"John"}}},
+		{"SELECT age + 1, MAX(id) - MIN(id) FROM users GROUP BY age + 1 HAVING age + 1 > 30 ORDER BY COUNT(*) DESC, 1", []engine.Row{{"31", "3"}, {"41", "0"}}},
+		{"SELECT COUNT(*) FROM users GROUP BY age ORDER BY COUNT(*) DESC LIMIT 1", []engine.Row{{"2"}}},
+		{"SELECT AVG(id * 1.5) FROM users HAVING COUNT(*) > 10", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			if rows := run(t, db, txn, tt.query).Rows; !reflect.DeepEqual(rows, tt.rows) {
+				t.Errorf("expected rows %v, got %v", tt.rows, rows)
+			}
+		})
+	}
+}
+
+func TestPlanner_Join(t *testing.T) {
+	db := openTestDatabase(t, engine.NewTable("orders", []engine.Column{
+		{Name: "id", Type: engine.Int, PrimaryKey: true},
+		{Name: "user_id", Type: engine.BigInt},
+		{Name: "total", Type: engine.Decimal, Precision: 6, Scale: 2},
+	}))
+	txn := begin(t, db)
+
+	run(t, db, txn, "INSERT INTO users (id, name, age) VALUES (1, 'John', 30)")
+	run(t, db, txn, "INSERT INTO users (id, name, age) VALUES (2, 'Jane', 25)")
+	run(t, db, txn, "INSERT INTO users (id, name, age) VALUES (3, 'Marty', NULL)")
+	run(t, db, txn, "INSERT INTO orders (id, user_id, total) VALUES (10, 1, 5.50)")
+	run(t, db, txn, "INSERT INTO orders (id, user_id, total) VALUES (11, 2, 20)")
+	run(t, db, txn, "INSERT INTO orders (id, user_id, total) VALUES (12, 1, 12.25)")
+	run(t, db, txn, "INSERT INTO orders (id, user_id, total) VALUES (13, 7, 1)")
+	run(t, db, txn, "INSERT INTO orders (id, user_id, total) VALUES (14, NULL, 3)")
+
+	tests := []struct {
+		query string
+		rows  []engine.Row
+	}{
+		{"SELECT u.name, o.id FROM users u JOIN orders o ON u.id = o.user_id ORDER BY o.id",
+			[]engine.Row{{"John", "10"}, {"Jane", "11"}, {"John", "12"}}},
+		{"SELECT o.id, u.name FROM orders o INNER JOIN users AS u ON o.user_id = u.id AND u.age > 25 ORDER BY 1",
+			[]engine.Row{{"10", "John"}, {"12", "John"}}},
+		{"SELECT name, o.id FROM users LEFT JOIN orders o ON users.id = o.user_id ORDER BY name, o.id",
+			[]engine.Row{{"Jane", "11"}, {"John", "10"}, {"John", "12"}, {"Marty", engine.Null}}},
+		{"SELECT o.id, u.name FROM orders o LEFT OUTER JOIN users u ON u.id = o.user_id ORDER BY o.id",
+			[]engine.Row{{"10", "John"}, {"11", "Jane"}, {"12", "John"}, {"13", engine.Null}, {"14", engine.Null}}},
+		{"SELECT u.id, o.id FROM users u RIGHT JOIN orders o ON u.id = o.user_id WHERE o.total < 10 ORDER BY o.id",
+			[]engine.Row{{"1", "10"}, {engine.Null, "13"}, {engine.Null, "14"}}},
+		{"SELECT u.id, o.id FROM users u FULL JOIN orders o ON u.id = o.user_id AND o.total > 10 ORDER BY u.id, o.id",
+			[]engine.Row{{engine.Null, "10"}, {engine.Null, "13"}, {engine.Null, "14"}, {"1", "12"}, {"2", "11"}, {"3", engine.Null}}},
+		{"SELECT u.id, o.id FROM users u CROSS JOIN orders o WHERE o.id < 12 ORDER BY 1, 2",
+			[]engine.Row{{"1", "10"}, {"1", "11"}, {"2", "10"}, {"2", "11"}, {"3", "10"}, {"3", "11"}}},
+		{"SELECT COUNT(*) FROM users, orders", []engine.Row{{"15"}}},
+		{"SELECT u.name, SUM(o.total) FROM users u JOIN orders o ON o.user_id = u.id GROUP BY u.name ORDER BY 2",
+			[]engine.Row{{"John", "17.75"}, {"Jane", "20.00"}}},
+		{"SELECT a.name, b.name FROM users a JOIN users b ON a.age < b.age ORDER BY a.id",
+			[]engine.Row{{"Jane", "John"}}},
+		{"SELECT a.id, b.id FROM users a JOIN users b USING (id) ORDER BY id",
+			[]engine.Row{{"1", "1"}, {"2", "2"}, {"3", "3"}}},
+		{"SELECT u.name, o.id, p.id FROM users u JOIN orders o ON o.user_id = u.id LEFT JOIN orders p ON p.id = o.id + 1 ORDER BY o.id",
+			[]engine.Row{{"John", "10", "11"}, {"Jane", "11", "12"}, {"John", "12", "13"}}},
+	}
+
+	methods := map[string]JoinMethod{
+		"auto":              AutoJoin,
+		"nested loop":       NestedLoopJoinMethod,
+		"index nested loop": IndexNestedLoopJoinMethod,
+		"hash":              HashJoinMethod,
+		"merge":             MergeJoinMethod,
+	}
+	for name, method := range methods {
+		// a work memory of a byte makes merge joins sort on disk
+		planner := &Planner{DB: db, JoinMethod: method, WorkMemory: 1, TempDir: t.TempDir()}
+		for _, tt := range tests {
+			t.Run(name+"/"+tt.query, func(t *testing.T) {
+				if rows := runWith(t, planner, txn, tt.query).Rows; !reflect.DeepEqual(rows, tt.rows) {
+					t.Errorf("expected rows %v, got %v", tt.rows, rows)
+				}
+			})
+		}
+	}
+
+	t.Run("Wildcard returns the columns of every table", func(t *testing.T) {
+		result := run(t, db, txn, "SELECT * FROM users u JOIN orders ON u.id = orders.id")
+		expected := []string{"id", "name", "age", "id", "user_id", "total"}
+		if !reflect.DeepEqual(result.Columns, expected) {
+			t.Errorf("expected columns %v, got %v", expected, result.Columns)
+		}
+	})
+}
+
+func TestPlanner_Subqueries(t *testing.T) {
+	db := openTestDatabase(t, engine.NewTable("orders", []engine.Column{
+		{Name: "id", Type: engine.Int, PrimaryKey: true},
+		{Name: "user_id", Type: engine.BigInt},
+		{Name: "total", Type: engine.Decimal, Precision: 6, Scale: 2},
+	}))
+	txn := begin(t, db)
+
+	run(t, db, txn, "INSERT INTO users (id, name, age) VALUES (1, 'John', 30)")
+	run(t, db, txn, "INSERT INTO users (id, name, age) VALUES (2, 'Jane', 25)")
+	run(t, db, txn, "INSERT INTO users (id, name, age) VALUES (3, 'Marty', NULL)")
+	run(t, db, txn, "INSERT INTO orders (id, user_id, total) VALUES (10, 1, 5.50)")
+	run(t, db, txn, "INSERT INTO orders (id, user_id, total) VALUES (11, 2, 20)")
+	run(t, db, txn, "INSERT INTO orders (id, user_id, total) VALUES (12, 1, 12.25)")
+	run(t, db, txn, "INSERT INTO orders (id, user_id, total) VALUES (13, 7, 1)")
+	run(t, db, txn, "INSERT INTO orders (id, user_id, total) VALUES (14, NULL, 3)")
+
+	tests := []struct {
+		query string
+		rows  []engine.Row
+	}{
+		{"SELECT u.name, (SELECT COUNT(*) FROM orders o WHERE o.user_id = u.id) FROM users u ORDER BY u.id",
+			[]engine.Row{{"John", "2"}, {"Jane", "1"}, {"Marty", "0"}}},
+		{"SELECT id, (SELECT name FROM users WHERE id = o.user_id) FROM orders o ORDER BY id",
+			[]engine.Row{{"10", "John"}, {"11", "Jane"}, {"12", "John"}, {"13", engine.Null}, {"14", engine.Null}}},
+		{"SELECT id FROM orders WHERE total > (SELECT AVG(total) FROM orders) ORDER BY id",
+			[]engine.Row{{"11"}, {"12"}}},
+		{"SELECT name FROM users u WHERE EXISTS (SELECT * FROM orders o WHERE o.user_id = u.id AND o.total > 10) ORDER BY name",
+			[]engine.Row{{"Jane"}, {"John"}}},
+		{"SELECT name FROM users WHERE NOT EXISTS (SELECT 1 FROM orders WHERE user_id = users.id)",
+			[]engine.Row{{"Marty"}}},
+		{"SELECT id FROM orders WHERE user_id IN (SELECT id FROM users WHERE age > 26) ORDER BY id",
+			[]engine.Row{{"10"}, {"12"}}},
+		{"SELECT id FROM orders WHERE user_id + 1 IN (SELECT id FROM users) ORDER BY id",
+			[]engine.Row{{"10"}, {"11"}, {"12"}}},
+		{"SELECT id FROM users WHERE id NOT IN (SELECT id FROM users WHERE age < 28) ORDER BY id",
+			[]engine.Row{{"1"}, {"3"}}},
+		{"SELECT id FROM users WHERE id NOT IN (SELECT user_id FROM orders)", nil},
+		{"SELECT id FROM users WHERE id NOT IN (SELECT user_id FROM orders WHERE user_id IS NOT NULL)",
+			[]engine.Row{{"3"}}},
+		{"SELECT name FROM users u WHERE EXISTS (SELECT 1 FROM orders o WHERE o.user_id = u.id AND EXISTS (SELECT 1 FROM orders p WHERE p.id = o.id + 1 AND p.user_id != u.id)) ORDER BY name",
+			[]engine.Row{{"Jane"}, {"John"}}},
+		{"SELECT t.user_id, t.n FROM (SELECT user_id, COUNT(*) AS n FROM orders GROUP BY user_id) AS t WHERE t.n > 1",
+			[]engine.Row{{"1", "2"}}},
+		{"SELECT u.name, t.total FROM users u JOIN (SELECT user_id, SUM(total) AS total FROM orders GROUP BY user_id) t ON t.user_id = u.id ORDER BY u.name",
+			[]engine.Row{{"Jane", "20.00"}, {"John", "17.75"}}},
+		{"SELECT name FROM users u WHERE age > (SELECT MIN(age) FROM users WHERE id != u.id)",
+			[]engine.Row{{"John"}}},
+	}
+
+	methods := map[string]JoinMethod{
+		"auto":              AutoJoin,
+		"nested loop":       NestedLoopJoinMethod,
+		"index nested loop": IndexNestedLoopJoinMethod,
+		"hash":              HashJoinMethod,
+		"merge":             MergeJoinMethod,
+	}
+	for name, method := range methods {
+		planner := &Planner{DB: db, JoinMethod: method, WorkMemory: 1, TempDir: t.TempDir()}
+		for _, tt := range tests {
+			t.Run(name+"/"+tt.query, func(t *testing.T) {
+				if rows := runWith(t, planner, txn, tt.query).Rows; !reflect.DeepEqual(rows, tt.rows) {
+					t.Errorf("expected rows %v, got %v", tt.rows, rows)
+				}
+			})
+		}
+	}
+
+	t.Run("Scalar subquery returning several rows", func(t *testing.T) {
+		stmt := &parser.SelectStatement{Columns: []string{"*"}, Table: "users", Where: &parser.BinaryExpression{
+			Operator: parser.EQUALS,
+			Left:     &parser.ColumnRef{Name: "id"},
+			Right:    &parser.SubqueryExpression{Select: &parser.SelectStatement{Columns: []string{"id"}, Expressions: []parser.Expression{&parser.ColumnRef{Name: "id"}}, Table: "users"}},
+		}}
+		if err := (&parser.SelectQueryOptimizer{Schema: db.Schema()}).Optimize(stmt); err != nil {
+			t.Fatal(err)
+		}
+		plan, err := (&Planner{DB: db}).Plan(txn, stmt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Execute(plan); err == nil || err.Error() != "subquery returns more than 1 row" {
+			t.Errorf("expected error %q, got %v", "subquery returns more than 1 row", err)
+		}
+	})
+}
+
+func TestPlanner_SelectNull(t *testing.T) {
+	db := openTestDatabase(t)
+	txn := begin(t, db)
+
+	run(t, db, txn, "INSERT INTO users (id, name, age) VALUES (1, 'John', 30)")
+	run(t, db, txn, "INSERT INTO users (id, name, age) VALUES (2, NULL, 25)")
+	run(t, db, txn, "INSERT INTO users (id, name, age) VALUES (3, 'Marty', NULL)")
+
+	tests := []struct {
+		query string
+		rows  []engine.Row
+	}{
+		{"SELECT id FROM users WHERE name IS NULL", []engine.Row{{"2"}}},
+		{"SELECT id FROM users WHERE age IS NOT NULL", []engine.Row{{"1"}, {"2"}}},
+		{"SELECT id FROM users WHERE age = NULL", nil},
+		{"SELECT id FROM users WHERE age > 20", []engine.Row{{"1"}, {"2"}}},
+		{"SELECT id FROM users WHERE NOT age > 20", nil},
+		{"SELECT id FROM users WHERE age != 30", []engine.Row{{"2"}}},
+		{"SELECT id FROM users WHERE age > 40 OR name = 'Marty'", []engine.Row{{"3"}}},
+		{"SELECT id FROM users WHERE NOT (age > 40 AND name = 'Marty')", []engine.Row{{"1"}, {"2"}}},
+		{"SELECT id FROM users WHERE NOT (age < 40 AND name = 'John')", []engine.Row{{"3"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			if rows := run(t, db, txn, tt.query).Rows; !reflect.DeepEqual(rows, tt.rows) {
+				t.Errorf("expected rows %v, got %v", tt.rows, rows)
+			}
+		})
+	}
+
+	t.Run("Update to NULL", func(t *testing.T) {
+		run(t, db, txn, "UPDATE users SET age = NULL WHERE id = 1")
+		rows := run(t, db, txn, "SELECT id FROM users WHERE age IS NULL").Rows
+		if expected := []engine.Row{{"3"}, {"1"}}; !reflect.DeepEqual(rows, expected) {
+			t.Errorf("expected rows %v, got %v", expected, rows)
+		}
+	})
+}
+
+func TestPlanner_Expressions(t *testing.T) {
+	db := openTestDatabase(t)
+	txn := begin(t, db)
+
+	run(t, db, txn, "INSERT INTO users (id, name, age) VALUES (1, 'John', 30)")
+	run(t, db, txn, "INSERT INTO users (id, name, age) VALUES (2, 'Jane', 25)")
+	run(t, db, txn, "INSERT INTO users (id, name, age) VALUES (3, 'Marty', NULL)")
+
+	t.Run("Computed columns", func(t *testing.T) {
+		result := run(t, db, txn, "SELECT id, age * 2 + 1 AS twice, -id, age / 4 FROM users WHERE id < 3")
+
+		if expected := []string{"id", "twice", "-id", "age / 4"}; !reflect.DeepEqual(result.Columns, expected) {
+			t.Errorf("expected columns %v, got %v", expected, result.Columns)
+		}
+		expected := []engine.Row{{"1", "61", "-1", "7.5000"}, {"2", "51", "-2", "6.2500"}}
+		if !reflect.DeepEqual(result.Rows, expected) {
+			t.Errorf("expected rows %v, got %v", expected, result.Rows)
+		}
+	})
+
+	t.Run("Column compared with column", func(t *testing.T) {
+		rows := run(t, db, txn, "SELECT name FROM users WHERE age > id * 10 + 5").Rows
+		if expected := []engine.Row{{"John"}}; !reflect.DeepEqual(rows, expected) {
+			t.Errorf("expected rows %v, got %v", expected, rows)
+		}
+	})
+
+	t.Run("Arithmetic with NULL", func(t *testing.T) {
+		rows := run(t, db, txn, "SELECT age + 1 FROM users WHERE id = 3").Rows
+		if expected := []engine.Row{{engine.Null}}; !reflect.DeepEqual(rows, expected) {
+			t.Errorf("expected rows %v, got %v", expected, rows)
+		}
+	})
+
+	t.Run("Update from the old row", func(t *testing.T) {
+		run(t, db, txn, "UPDATE users SET age = age + id, id = id + 10 WHERE age IS NOT NULL")
+
+		rows := run(t, db, txn, "SELECT id, age FROM users WHERE age IS NOT NULL").Rows
+		if expected := []engine.Row{{"11", "31"}, {"12", "27"}}; !reflect.DeepEqual(rows, expected) {
+			t.Errorf("expected rows %v, got %v", expected, rows)
+		}
+	})
+
+	t.Run("Out of range result", func(t *testing.T) {
+		stmt := &parser.UpdateStatement{Table: "users", Set: map[string]parser.Expression{
+			"age": &parser.BinaryExpression{Operator: parser.MULTIPLY, Left: &parser.ColumnRef{Name: "age"}, Right: &parser.Literal{Value: "100000000"}},
+		}}
+		plan, err := (&Planner{DB: db}).Plan(txn, stmt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Execute(plan); err == nil {
+			t.Errorf("expected the result not to fit the INT column")
+		}
+	})
+}
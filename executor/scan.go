@@ -0,0 +1,91 @@
+package executor
+
+import (
+	"dbngin3/engine"
+	"dbngin3/storage"
+	"dbngin3/transaction"
+	"errors"
+)
+
+func columnNames(table *engine.Table) []string {
+	names := make([]string, len(table.Columns))
+	for i, column := range table.Columns {
+		names[i] = column.Name
+	}
+	return names
+}
+
+// SeqScan returns every row of the table the transaction sees, in storage
+// order.
+type SeqScan struct {
+	store *engine.TableStore
+	txn   *transaction.Transaction
+	it    *engine.TupleIterator
+}
+
+func NewSeqScan(store *engine.TableStore, txn *transaction.Transaction) *SeqScan {
+	return &SeqScan{store: store, txn: txn}
+}
+
+// Open takes an intention lock on the table; readers never wait for
+// writers, but a table cannot be dropped while it is read.
+func (s *SeqScan) Open() error {
+	if err := s.store.LockTable(s.txn, transaction.IntentionShared); err != nil {
+		return err
+	}
+
+	s.it = s.store.Scan(s.txn)
+	return nil
+}
+
+func (s *SeqScan) Next() (*engine.Tuple, error) {
+	return s.it.Next()
+}
+
+func (s *SeqScan) Close() error {
+	s.it = nil
+	return nil
+}
+
+func (s *SeqScan) Columns() []string {
+	return columnNames(s.store.Table())
+}
+
+// IndexScan fetches the row with the given primary key value through the
+// index of the table.
+type IndexScan struct {
+	store *engine.TableStore
+	txn   *transaction.Transaction
+	value string
+	done  bool
+}
+
+func NewIndexScan(store *engine.TableStore, txn *transaction.Transaction, value string) *IndexScan {
+	return &IndexScan{store: store, txn: txn, value: value}
+}
+
+func (s *IndexScan) Open() error {
+	s.done = false
+	return s.store.LockTable(s.txn, transaction.IntentionShared)
+}
+
+func (s *IndexScan) Next() (*engine.Tuple, error) {
+	if s.done {
+		return nil, nil
+	}
+	s.done = true
+
+	tuple, err := s.store.Lookup(s.txn, s.value)
+	if errors.Is(err, storage.ErrKeyNotFound) {
+		return nil, nil
+	}
+	return tuple, err
+}
+
+func (s *IndexScan) Close() error {
+	return nil
+}
+
+func (s *IndexScan) Columns() []string {
+	return columnNames(s.store.Table())
+}
@@ -0,0 +1,583 @@
+package executor
+
+import (
+	"bufio"
+	"container/heap"
+	"dbngin3/engine"
+	"dbngin3/parser"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"os"
+	"sort"
+)
+
+// mergeFanIn is the most run files merged at once, more runs are merged in
+// several passes.
+const mergeFanIn = 64
+
+// sortRow is a row with the values of the ORDER BY expressions. seq is the
+// position of the row in the input, rows with equal keys keep their order.
+type sortRow struct {
+	row  engine.Row
+	keys []parser.Value
+	seq  int
+}
+
+// sorter orders rows by the ORDER BY items. less cannot fail, it keeps the
+// first error for the caller to check afterwards.
+type sorter struct {
+	columns []engine.Column
+	order   []parser.OrderByItem
+	err     error
+}
+
+func (s *sorter) newRow(row engine.Row, seq int) (sortRow, error) {
+	keys := make([]parser.Value, len(s.order))
+	for i, item := range s.order {
+		value, err := item.Expression.Eval(s.columns, row)
+		if err != nil {
+			return sortRow{}, err
+		}
+		keys[i] = value
+	}
+	return sortRow{row: row, keys: keys, seq: seq}, nil
+}
+
+func (s *sorter) compare(a, b sortRow) int {
+	for i, item := range s.order {
+		x, y := a.keys[i], b.keys[i]
+		switch {
+		case x.IsNull() && y.IsNull():
+			continue
+		case x.IsNull() || y.IsNull():
+			if x.IsNull() == item.NullsFirst {
+				return -1
+			}
+			return 1
+		}
+
+		cmp, err := parser.CompareValues(x, y)
+		if err != nil {
+			if s.err == nil {
+				s.err = err
+			}
+			return 0
+		}
+		if item.Desc {
+			cmp = -cmp
+		}
+		if cmp != 0 {
+			return cmp
+		}
+	}
+	return 0
+}
+
+func (s *sorter) less(a, b sortRow) bool {
+	if cmp := s.compare(a, b); cmp != 0 {
+		return cmp < 0
+	}
+	return a.seq < b.seq
+}
+
+// rowSize estimates the memory a row takes.
+func rowSize(row sortRow) int {
+	size := 64 + 40*len(row.keys)
+	for _, value := range row.row {
+		size += 16 + len(value)
+	}
+	for _, key := range row.keys {
+		size += len(key.Literal)
+	}
+	return size
+}
+
+// Sort returns the rows of its child ordered by the ORDER BY items. columns
+// describes the rows of the child. Rows are sorted in memory until they
+// exceed the memory budget, then every sorted batch is written to a run file
+// in dir and the runs are merged.
+type Sort struct {
+	child   Operator
+	sorter  *sorter
+	memory  int
+	dir     string
+	rows    []sortRow
+	runs    []*runFile
+	merge   *merger
+	written int
+}
+
+func NewSort(child Operator, columns []engine.Column, order []parser.OrderByItem, memory int, dir string) *Sort {
+	if memory <= 0 {
+		memory = DefaultWorkMemory
+	}
+	return &Sort{child: child, sorter: &sorter{columns: columns, order: order}, memory: memory, dir: dir}
+}
+
+func (s *Sort) Open() error {
+	if err := s.child.Open(); err != nil {
+		return err
+	}
+
+	size := 0
+	for seq := 0; ; seq++ {
+		tuple, err := s.child.Next()
+		if err != nil {
+			return err
+		}
+		if tuple == nil {
+			break
+		}
+
+		row, err := s.sorter.newRow(tuple.Row, seq)
+		if err != nil {
+			return err
+		}
+		s.rows = append(s.rows, row)
+
+		if size += rowSize(row); size > s.memory {
+			if err := s.spill(); err != nil {
+				return err
+			}
+			size = 0
+		}
+	}
+
+	if len(s.runs) == 0 {
+		return s.sortRows()
+	}
+	if len(s.rows) > 0 {
+		if err := s.spill(); err != nil {
+			return err
+		}
+	}
+	for len(s.runs) > mergeFanIn {
+		if err := s.mergePass(); err != nil {
+			return err
+		}
+	}
+
+	var err error
+	s.merge, err = newMerger(s.sorter, s.runs)
+	return err
+}
+
+func (s *Sort) sortRows() error {
+	sort.Slice(s.rows, func(i, j int) bool {
+		return s.sorter.less(s.rows[i], s.rows[j])
+	})
+	return s.sorter.err
+}
+
+// spill writes the rows held in memory to a new run file, sorted.
+func (s *Sort) spill() error {
+	if err := s.sortRows(); err != nil {
+		return err
+	}
+
+	run, err := createRunFile(s.dir)
+	if err != nil {
+		return err
+	}
+	s.runs = append(s.runs, run)
+
+	for _, row := range s.rows {
+		if err := run.write(row.row, row.seq); err != nil {
+			return err
+		}
+	}
+	s.rows = nil
+	return run.rewind()
+}
+
+// mergePass merges every mergeFanIn neighbouring runs into one. The rows keep
+// their position in the input, which keeps equal rows in order.
+func (s *Sort) mergePass() error {
+	var runs []*runFile
+	for start := 0; start < len(s.runs); start += mergeFanIn {
+		end := start + mergeFanIn
+		if end > len(s.runs) {
+			end = len(s.runs)
+		}
+
+		run, err := s.mergeRuns(s.runs[start:end])
+		if err != nil {
+			closeRuns(runs)
+			return err
+		}
+		runs = append(runs, run)
+	}
+	s.runs = runs
+	return nil
+}
+
+func (s *Sort) mergeRuns(runs []*runFile) (*runFile, error) {
+	defer closeRuns(runs)
+
+	out, err := createRunFile(s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := newMerger(s.sorter, runs)
+	for err == nil {
+		var row *sortRow
+		if row, err = m.next(); err != nil || row == nil {
+			break
+		}
+		err = out.write(row.row, row.seq)
+	}
+	if err == nil {
+		err = out.rewind()
+	}
+	if err != nil {
+		out.close()
+		return nil, err
+	}
+	return out, nil
+}
+
+func (s *Sort) Next() (*engine.Tuple, error) {
+	if s.merge != nil {
+		row, err := s.merge.next()
+		if err != nil || row == nil {
+			return nil, err
+		}
+		return &engine.Tuple{Row: row.row}, nil
+	}
+
+	if s.written == len(s.rows) {
+		return nil, nil
+	}
+	s.written++
+	return &engine.Tuple{Row: s.rows[s.written-1].row}, nil
+}
+
+func (s *Sort) Close() error {
+	closeRuns(s.runs)
+	s.runs, s.rows, s.merge, s.written = nil, nil, nil, 0
+	s.sorter.err = nil
+	return s.child.Close()
+}
+
+func (s *Sort) Columns() []string {
+	return s.child.Columns()
+}
+
+// merger returns the rows of sorted runs in order, through a heap holding
+// the next row of every run.
+type merger struct {
+	sorter *sorter
+	runs   []*runFile
+	heads  []mergeHead
+}
+
+// mergeHead is the next row of a run.
+type mergeHead struct {
+	row sortRow
+	run int
+}
+
+func newMerger(sorter *sorter, runs []*runFile) (*merger, error) {
+	m := &merger{sorter: sorter, runs: runs}
+	for i := range runs {
+		row, err := m.read(i)
+		if err != nil {
+			return nil, err
+		}
+		if row != nil {
+			m.heads = append(m.heads, mergeHead{row: *row, run: i})
+		}
+	}
+	heap.Init(m)
+	return m, sorter.err
+}
+
+// read returns the next row of run i, or nil at its end.
+func (m *merger) read(i int) (*sortRow, error) {
+	values, seq, err := m.runs[i].read()
+	if err != nil || values == nil {
+		return nil, err
+	}
+
+	row, err := m.sorter.newRow(values, seq)
+	if err != nil {
+		return nil, err
+	}
+	return &row, nil
+}
+
+func (m *merger) next() (*sortRow, error) {
+	if len(m.heads) == 0 {
+		return nil, nil
+	}
+
+	head := heap.Pop(m).(mergeHead)
+	following, err := m.read(head.run)
+	if err != nil {
+		return nil, err
+	}
+	if following != nil {
+		heap.Push(m, mergeHead{row: *following, run: head.run})
+	}
+	return &head.row, m.sorter.err
+}
+
+func (m *merger) Len() int           { return len(m.heads) }
+func (m *merger) Less(i, j int) bool { return m.sorter.less(m.heads[i].row, m.heads[j].row) }
+func (m *merger) Swap(i, j int)      { m.heads[i], m.heads[j] = m.heads[j], m.heads[i] }
+func (m *merger) Push(x any)         { m.heads = append(m.heads, x.(mergeHead)) }
+
+func (m *merger) Pop() any {
+	last := m.heads[len(m.heads)-1]
+	m.heads = m.heads[:len(m.heads)-1]
+	return last
+}
+
+// runFile is a temporary file of rows, each written with its position in the
+// input as
+//
+//	| position (uvarint) | number of values (uvarint) | length (uvarint) | value | ...
+//
+// A sort needs the position to keep equal rows in order, the partitions of
+// a HashAggregate store zero. It is removed when closed.
+type runFile struct {
+	file   *os.File
+	writer *bufio.Writer
+	reader *bufio.Reader
+}
+
+func createRunFile(dir string) (*runFile, error) {
+	file, err := os.CreateTemp(dir, "*.run")
+	if err != nil {
+		return nil, err
+	}
+	return &runFile{file: file, writer: bufio.NewWriter(file)}, nil
+}
+
+func (r *runFile) write(row engine.Row, seq int) error {
+	buf := binary.AppendUvarint(nil, uint64(seq))
+	buf = binary.AppendUvarint(buf, uint64(len(row)))
+	for _, value := range row {
+		buf = binary.AppendUvarint(buf, uint64(len(value)))
+		buf = append(buf, value...)
+	}
+	_, err := r.writer.Write(buf)
+	return err
+}
+
+// rewind ends writing and starts reading from the first row.
+func (r *runFile) rewind() error {
+	if err := r.writer.Flush(); err != nil {
+		return err
+	}
+	if _, err := r.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	r.reader = bufio.NewReader(r.file)
+	return nil
+}
+
+// read returns the next row and its position in the input, or nil at the
+// end of the run.
+func (r *runFile) read() (engine.Row, int, error) {
+	seq, err := binary.ReadUvarint(r.reader)
+	if err == io.EOF {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	n, err := binary.ReadUvarint(r.reader)
+	if err != nil {
+		return nil, 0, errCorruptRun
+	}
+	row := make(engine.Row, n)
+	for i := range row {
+		size, err := binary.ReadUvarint(r.reader)
+		if err != nil {
+			return nil, 0, errCorruptRun
+		}
+		value := make([]byte, size)
+		if _, err := io.ReadFull(r.reader, value); err != nil {
+			return nil, 0, errCorruptRun
+		}
+		row[i] = string(value)
+	}
+	return row, int(seq), nil
+}
+
+var errCorruptRun = errors.New("corrupt run file")
+
+func (r *runFile) close() {
+	_ = r.file.Close()
+	_ = os.Remove(r.file.Name())
+}
+
+func closeRuns(runs []*runFile) {
+	for _, run := range runs {
+		run.close()
+	}
+}
+
+// topNRows returns how many rows a TopN keeps for the limit and offset of
+// the query, and whether that many fit the memory budget even as the
+// smallest rows of the columns. Otherwise the rows are sorted, which spills
+// what does not fit, and the limit skips the rest.
+func topNRows(stmt *parser.SelectStatement, columns []engine.Column, memory int) (int, bool) {
+	if stmt.Limit == nil || *stmt.Limit > math.MaxInt-stmt.Offset {
+		return 0, false
+	}
+	if memory <= 0 {
+		memory = DefaultWorkMemory
+	}
+
+	n := stmt.Offset + *stmt.Limit
+	smallest := rowSize(sortRow{row: make(engine.Row, len(columns)), keys: make([]parser.Value, len(stmt.OrderBy))})
+	return n, n <= memory/smallest
+}
+
+// TopN returns the first n rows of its child in the order of the ORDER BY
+// items. It keeps no more than n rows, in a heap with the last of them on
+// top, and never spills.
+type TopN struct {
+	child   Operator
+	sorter  *sorter
+	n       int
+	rows    []sortRow
+	written int
+}
+
+func NewTopN(child Operator, columns []engine.Column, order []parser.OrderByItem, n int) *TopN {
+	return &TopN{child: child, sorter: &sorter{columns: columns, order: order}, n: n}
+}
+
+func (t *TopN) Open() error {
+	if err := t.child.Open(); err != nil {
+		return err
+	}
+
+	for seq := 0; ; seq++ {
+		tuple, err := t.child.Next()
+		if err != nil {
+			return err
+		}
+		if tuple == nil || t.n <= 0 {
+			break
+		}
+
+		row, err := t.sorter.newRow(tuple.Row, seq)
+		if err != nil {
+			return err
+		}
+		if len(t.rows) < t.n {
+			heap.Push(t, row)
+		} else if t.sorter.less(row, t.rows[0]) {
+			t.rows[0] = row
+			heap.Fix(t, 0)
+		}
+		if t.sorter.err != nil {
+			return t.sorter.err
+		}
+	}
+
+	sort.Slice(t.rows, func(i, j int) bool {
+		return t.sorter.less(t.rows[i], t.rows[j])
+	})
+	return t.sorter.err
+}
+
+func (t *TopN) Next() (*engine.Tuple, error) {
+	if t.written == len(t.rows) {
+		return nil, nil
+	}
+	t.written++
+	return &engine.Tuple{Row: t.rows[t.written-1].row}, nil
+}
+
+func (t *TopN) Close() error {
+	t.rows, t.written = nil, 0
+	t.sorter.err = nil
+	return t.child.Close()
+}
+
+func (t *TopN) Columns() []string {
+	return t.child.Columns()
+}
+
+// The heap of TopN has the row that sorts last on top.
+func (t *TopN) Len() int           { return len(t.rows) }
+func (t *TopN) Less(i, j int) bool { return t.sorter.less(t.rows[j], t.rows[i]) }
+func (t *TopN) Swap(i, j int)      { t.rows[i], t.rows[j] = t.rows[j], t.rows[i] }
+func (t *TopN) Push(x any)         { t.rows = append(t.rows, x.(sortRow)) }
+
+func (t *TopN) Pop() any {
+	last := t.rows[len(t.rows)-1]
+	t.rows = t.rows[:len(t.rows)-1]
+	return last
+}
+
+// Limit skips the first offset rows of its child and returns at most limit
+// of the rest; a negative limit returns all of them.
+type Limit struct {
+	child    Operator
+	offset   int
+	limit    int
+	returned int
+	done     bool
+}
+
+func NewLimit(child Operator, offset, limit int) *Limit {
+	return &Limit{child: child, offset: offset, limit: limit}
+}
+
+func (l *Limit) Open() error {
+	l.returned, l.done = 0, false
+	if err := l.child.Open(); err != nil {
+		return err
+	}
+
+	for i := 0; i < l.offset; i++ {
+		tuple, err := l.child.Next()
+		if err != nil {
+			return err
+		}
+		if tuple == nil {
+			l.done = true
+			return nil
+		}
+	}
+	return nil
+}
+
+func (l *Limit) Next() (*engine.Tuple, error) {
+	if l.done || l.limit >= 0 && l.returned >= l.limit {
+		return nil, nil
+	}
+
+	tuple, err := l.child.Next()
+	if err != nil || tuple == nil {
+		l.done = true
+		return nil, err
+	}
+	l.returned++
+	return tuple, nil
+}
+
+func (l *Limit) Close() error {
+	return l.child.Close()
+}
+
+func (l *Limit) Columns() []string {
+	return l.child.Columns()
+}
+
+func (l *Limit) Types() []engine.DataType {
+	if t, ok := l.child.(typer); ok {
+		return t.Types()
+	}
+	return nil
+}
@@ -0,0 +1,225 @@
+package executor
+
+import (
+	"dbngin3/engine"
+	"dbngin3/parser"
+	"math/rand"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+)
+
+// rowsOperator returns the given rows.
+type rowsOperator struct {
+	rows []engine.Row
+	next int
+}
+
+func (r *rowsOperator) Open() error {
+	r.next = 0
+	return nil
+}
+
+func (r *rowsOperator) Next() (*engine.Tuple, error) {
+	if r.next == len(r.rows) {
+		return nil, nil
+	}
+	r.next++
+	return &engine.Tuple{Row: r.rows[r.next-1]}, nil
+}
+
+func (r *rowsOperator) Close() error {
+	return nil
+}
+
+func (r *rowsOperator) Columns() []string {
+	return []string{"a", "b"}
+}
+
+var sortColumns = []engine.Column{{Name: "a", Type: engine.Int}, {Name: "b", Type: engine.Int}}
+
+// randomRows returns rows of a few distinct values for a, some of them
+// NULL, and their position in b.
+func randomRows(n int) []engine.Row {
+	random := rand.New(rand.NewSource(1))
+	rows := make([]engine.Row, n)
+	for i := range rows {
+		a := strconv.Itoa(random.Intn(20) - 10)
+		if random.Intn(10) == 0 {
+			a = engine.Null
+		}
+		rows[i] = engine.Row{a, strconv.Itoa(i)}
+	}
+	return rows
+}
+
+// sortedRows orders the rows by a, descending with NULL last, and keeps rows
+// with equal a in their order.
+func sortedRows(rows []engine.Row) []engine.Row {
+	sorted := append([]engine.Row(nil), rows...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i][0], sorted[j][0]
+		if a == engine.Null || b == engine.Null {
+			return b == engine.Null && a != engine.Null
+		}
+		x, _ := strconv.Atoi(a)
+		y, _ := strconv.Atoi(b)
+		return x > y
+	})
+	return sorted
+}
+
+var descNullsLast = []parser.OrderByItem{{Expression: &parser.ColumnRef{Name: "a"}, Desc: true}}
+
+func TestSort_SpillsAndMerges(t *testing.T) {
+	rows := randomRows(1000)
+	expected := sortedRows(rows)
+
+	tests := []struct {
+		name   string
+		memory int
+	}{
+		{"In memory", 0},
+		{"Few runs", 20000},
+		{"More runs than are merged at once", 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			plan := NewSort(&rowsOperator{rows: rows}, sortColumns, descNullsLast, test.memory, dir)
+
+			if err := plan.Open(); err != nil {
+				t.Fatal(err)
+			}
+			if test.memory > 0 && len(plan.runs) == 0 {
+				t.Errorf("expected the sort to spill")
+			}
+
+			var got []engine.Row
+			for {
+				tuple, err := plan.Next()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if tuple == nil {
+					break
+				}
+				got = append(got, tuple.Row)
+			}
+			if err := plan.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(got, expected) {
+				t.Errorf("rows are not in order")
+			}
+			if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+				t.Errorf("expected the run files to be removed, found %d", len(entries))
+			}
+		})
+	}
+}
+
+func TestSort_NullsFirst(t *testing.T) {
+	rows := []engine.Row{{"2", "0"}, {engine.Null, "1"}, {"1", "2"}}
+	order := []parser.OrderByItem{{Expression: &parser.ColumnRef{Name: "a"}, Desc: true, NullsFirst: true}}
+
+	result, err := Execute(NewSort(&rowsOperator{rows: rows}, sortColumns, order, 0, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []engine.Row{{engine.Null, "1"}, {"2", "0"}, {"1", "2"}}
+	if !reflect.DeepEqual(result.Rows, expected) {
+		t.Errorf("expected %v, got %v", expected, result.Rows)
+	}
+}
+
+func TestTopN_KeepsFirstRows(t *testing.T) {
+	rows := randomRows(500)
+	expected := sortedRows(rows)
+
+	for _, n := range []int{0, 1, 7, 100, 500, 600} {
+		t.Run(strconv.Itoa(n), func(t *testing.T) {
+			result, err := Execute(NewTopN(&rowsOperator{rows: rows}, sortColumns, descNullsLast, n))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := expected
+			if n < len(want) {
+				want = want[:n]
+			}
+			if len(result.Rows) != len(want) || len(want) > 0 && !reflect.DeepEqual(result.Rows, want) {
+				t.Errorf("expected the first %d sorted rows, got %v", n, result.Rows)
+			}
+		})
+	}
+}
+
+func TestLimit(t *testing.T) {
+	rows := []engine.Row{{"1", "0"}, {"2", "1"}, {"3", "2"}}
+
+	tests := []struct {
+		offset, limit int
+		expected      []engine.Row
+	}{
+		{0, -1, rows},
+		{0, 2, rows[:2]},
+		{1, 1, rows[1:2]},
+		{2, 5, rows[2:]},
+		{5, 1, nil},
+		{0, 0, nil},
+	}
+
+	for _, test := range tests {
+		result, err := Execute(NewLimit(&rowsOperator{rows: rows}, test.offset, test.limit))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(result.Rows, test.expected) {
+			t.Errorf("offset %d limit %d: expected %v, got %v", test.offset, test.limit, test.expected, result.Rows)
+		}
+	}
+}
+
+func TestSort_MergeKeepsInputOrder(t *testing.T) {
+	// equal keys whose runs are not in the order of their input positions
+	runs := make([]*runFile, 2)
+	for i, seq := range []int{1, 0} {
+		run, err := createRunFile(t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer run.close()
+		if err := run.write(engine.Row{"1", strconv.Itoa(seq)}, seq); err != nil {
+			t.Fatal(err)
+		}
+		if err := run.rewind(); err != nil {
+			t.Fatal(err)
+		}
+		runs[i] = run
+	}
+
+	m, err := newMerger(&sorter{columns: sortColumns, order: descNullsLast}, runs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []engine.Row
+	for {
+		row, err := m.next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if row == nil {
+			break
+		}
+		got = append(got, row.row)
+	}
+
+	if expected := []engine.Row{{"1", "0"}, {"1", "1"}}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
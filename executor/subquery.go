@@ -0,0 +1,138 @@
+package executor
+
+import (
+	"dbngin3/engine"
+	"dbngin3/parser"
+	"dbngin3/transaction"
+)
+
+// bindQuery returns a copy of the statement with its subqueries planned and
+// the columns of enclosing queries pointed at their current rows.
+func (p *Planner) bindQuery(txn *transaction.Transaction, stmt *parser.SelectStatement) (*parser.SelectStatement, error) {
+	bound := *stmt
+	bound.Expressions = make([]parser.Expression, len(stmt.Expressions))
+	bound.Joins = append([]parser.Join{}, stmt.Joins...)
+	bound.GroupBy = make([]parser.Expression, len(stmt.GroupBy))
+	bound.OrderBy = append([]parser.OrderByItem{}, stmt.OrderBy...)
+
+	var err error
+	for i, expr := range stmt.Expressions {
+		if bound.Expressions[i], err = p.bind(txn, expr); err != nil {
+			return nil, err
+		}
+	}
+	for i, join := range stmt.Joins {
+		if bound.Joins[i].On, err = p.bind(txn, join.On); err != nil {
+			return nil, err
+		}
+	}
+	if bound.Where, err = p.bind(txn, stmt.Where); err != nil {
+		return nil, err
+	}
+	for i, expr := range stmt.GroupBy {
+		if bound.GroupBy[i], err = p.bind(txn, expr); err != nil {
+			return nil, err
+		}
+	}
+	if bound.Having, err = p.bind(txn, stmt.Having); err != nil {
+		return nil, err
+	}
+	for i, item := range stmt.OrderBy {
+		if bound.OrderBy[i].Expression, err = p.bind(txn, item.Expression); err != nil {
+			return nil, err
+		}
+	}
+	return &bound, nil
+}
+
+// bind returns a copy of the expression with its subqueries planned and its
+// outer columns pointed at the rows of the queries they belong to.
+func (p *Planner) bind(txn *transaction.Transaction, expr parser.Expression) (parser.Expression, error) {
+	var err error
+	var fn func(parser.Expression) parser.Expression
+	fn = func(e parser.Expression) parser.Expression {
+		if err != nil {
+			return nil
+		}
+
+		switch e := e.(type) {
+		case *parser.OuterRef:
+			bound := *e
+			if e.Depth <= len(p.outer) {
+				bound.Outer = p.outer[len(p.outer)-e.Depth]
+			}
+			return &bound
+		case *parser.SubqueryExpression:
+			bound := *e
+			bound.Rows, err = p.planSubquery(txn, e.Select)
+			return &bound
+		case *parser.InExpression:
+			bound := *e
+			bound.Operand = parser.Transform(e.Operand, fn)
+			if err == nil {
+				bound.Rows, err = p.planSubquery(txn, e.Select)
+			}
+			return &bound
+		case *parser.ExistsExpression:
+			bound := *e
+			bound.Rows, err = p.planSubquery(txn, e.Select)
+			return &bound
+		}
+		return nil
+	}
+
+	bound := parser.Transform(expr, fn)
+	return bound, err
+}
+
+// planSubquery plans the query of a subquery, which is run again for every
+// row of the enclosing query it is correlated with, and only once otherwise.
+func (p *Planner) planSubquery(txn *transaction.Transaction, stmt *parser.SelectStatement) (parser.SubqueryRows, error) {
+	outer := &parser.OuterRow{}
+	sub := *p
+	sub.outer = append(p.outer[:len(p.outer):len(p.outer)], outer)
+	plan, _, err := sub.planSelect(txn, stmt)
+	if err != nil {
+		return nil, err
+	}
+
+	correlated := parser.IsCorrelated(stmt)
+	var cached []engine.Row
+	done := false
+	return func(columns []engine.Column, row engine.Row, limit int) ([]engine.Row, error) {
+		if done && !correlated {
+			return cached, nil
+		}
+
+		outer.Columns, outer.Row = columns, row
+		rows, err := readRows(plan, limit)
+		if err != nil {
+			return nil, err
+		}
+		cached, done = rows, true
+		return rows, nil
+	}, nil
+}
+
+// readRows runs the plan and returns at most limit of its rows, all of them
+// for a negative limit.
+func readRows(plan Operator, limit int) ([]engine.Row, error) {
+	if err := plan.Open(); err != nil {
+		plan.Close()
+		return nil, err
+	}
+
+	var rows []engine.Row
+	for limit < 0 || len(rows) < limit {
+		tuple, err := plan.Next()
+		if err != nil {
+			plan.Close()
+			return nil, err
+		}
+		if tuple == nil {
+			break
+		}
+		rows = append(rows, tuple.Row)
+	}
+	return rows, plan.Close()
+}
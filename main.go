@@ -1,8 +1,28 @@
 package main
 
-import "dbngin3/api"
+import (
+	"dbngin3/api"
+	"dbngin3/engine"
+	"flag"
+	"fmt"
+	"os"
+)
 
 func main() {
+	identifierCase := flag.String("identifier-case", "", "how unquoted names are matched: sensitive or lower (fixed once tables exist)")
+	flag.Parse()
+
 	cli := api.NewCLI()
+	if *identifierCase != "" {
+		c, err := engine.ParseIdentifierCase(*identifierCase)
+		if err == nil {
+			err = cli.SetIdentifierCase(c)
+		}
+		if err != nil {
+			fmt.Println(err)
+			_ = cli.Close()
+			os.Exit(1)
+		}
+	}
 	cli.Run()
 }
@@ -3,51 +3,190 @@ package parser
 // ASTNode : Abstract Syntax Tree
 type ASTNode interface{}
 
+// SelectStatement is SELECT expression [[AS] alias], ... FROM table [[AS]
+// alias] [join ...] [WHERE condition] [GROUP BY expression, ... [HAVING
+// condition]] [ORDER BY order, ...] [LIMIT count [OFFSET skip]]. Columns
+// names the result columns, by their alias or else the text of their
+// expression. SELECT * has Columns ["*"] and no Expressions until the
+// optimizer expands it. Limit is nil without a LIMIT. Like ORDER BY, GROUP BY
+// may name a result column by its alias or position and HAVING by its alias.
+//
+// A derived table, (SELECT ...) [AS] alias, is read from instead of Table
+// when Subquery is set. It needs the alias, which names it.
+//
+// The optimizer qualifies every column of a query with joins by the alias,
+// or else the name, of its table.
 type SelectStatement struct {
 	Columns     []string
+	Expressions []Expression
 	Table       string
-	WhereClause *WhereClause
+	Subquery    *SelectStatement
+	Alias       string
+	Joins       []Join
+	Where       Expression
+	GroupBy     []Expression
+	Having      Expression
+	OrderBy     []OrderByItem
+	Limit       *int
+	Offset      int
+	IndexLookup *IndexLookup
 }
 
+// Join is [INNER | CROSS | LEFT [OUTER] | RIGHT [OUTER] | FULL [OUTER]] JOIN
+// table [[AS] alias] [ON condition | USING (column, ...)], or a table after
+// a comma in the FROM clause, which is a CROSS join. Kind is INNER, CROSS,
+// LEFT, RIGHT or FULL. Outer joins need a condition, an inner join without
+// one is a cross join. The optimizer turns USING into the equivalent ON
+// condition, and EXISTS and IN subqueries of the where clause into SEMI and
+// ANTI joins, which return the rows that do or do not have a match. Like the
+// FROM clause a join may read a derived table.
+type Join struct {
+	Kind     string
+	Table    string
+	Subquery *SelectStatement
+	Alias    string
+	On       Expression
+	Using    []string
+}
+
+// OrderByItem is expression [ASC | DESC] [NULLS FIRST | NULLS LAST]. NULL
+// sorts before every value unless NULLS says otherwise, so it comes first in
+// ascending and last in descending order. The expression may name a result
+// column by its alias or its position, the optimizer replaces those by the
+// expression of the column.
+type OrderByItem struct {
+	Expression Expression
+	Desc       bool
+	NullsFirst bool
+}
+
+// IndexLookup is set by the optimizer when the rows can be fetched through
+// the primary key index instead of scanning the whole table.
+type IndexLookup struct {
+	Column string
+	Value  string
+}
+
+// InsertStatement is INSERT INTO table [(column, ...)] VALUES (value, ...),
+// ... or INSERT INTO table [(column, ...)] SELECT .... Without a column list
+// the values are for all columns of the table, in order. A value is an
+// expression or DEFAULT, which is nil in Rows. The columns an insert leaves
+// out get their defaults.
+//
+// A row whose primary key or unique value is taken fails the insert, unless
+// the statement is a REPLACE, which deletes the rows holding them first, or
+// ends in ON DUPLICATE KEY UPDATE column = expression, ..., which updates
+// the row holding them instead. The expressions of the update see that row
+// as it was and the values of the row to insert as VALUES(column).
 type InsertStatement struct {
-	Table   string
-	Columns []string
-	Values  []string
+	Table       string
+	Columns     []string
+	Rows        [][]Expression
+	Select      *SelectStatement
+	Replace     bool
+	OnDuplicate map[string]Expression
 }
 
+// UpdateStatement is UPDATE table SET column = expression, ... [WHERE
+// condition]. The expressions see the row before the update.
 type UpdateStatement struct {
+	Table string
+	Set   map[string]Expression
+	Where Expression
+}
+
+// DeleteStatement is DELETE FROM table [WHERE condition]. Without a WHERE
+// every row is deleted.
+type DeleteStatement struct {
 	Table       string
-	Set         map[string]string
-	WhereClause *WhereClause
+	Where       Expression
+	IndexLookup *IndexLookup
 }
 
-type WhereClause struct {
-	Type  string
-	Left  *WhereClause
-	Right *WhereClause
-	Name  string
-	Value string
+// BeginStatement is BEGIN [TRANSACTION | WORK] or START TRANSACTION.
+type BeginStatement struct{}
+
+// CommitStatement is COMMIT [TRANSACTION | WORK].
+type CommitStatement struct{}
+
+// RollbackStatement is ROLLBACK [TRANSACTION | WORK] [TO [SAVEPOINT] name].
+// Savepoint is empty when the whole transaction is rolled back.
+type RollbackStatement struct {
+	Savepoint string
 }
 
-func (w *WhereClause) GetColumnNames() []string {
-	return getColumns(w)
+// SavepointStatement is SAVEPOINT name.
+type SavepointStatement struct {
+	Name string
 }
 
-func getColumns(clause *WhereClause) []string {
-	res := make([]string, 0)
-	if clause == nil {
-		return res
-	}
+// SetTransactionStatement is SET [SESSION] TRANSACTION ISOLATION LEVEL level.
+// Without SESSION the level only applies to the next transaction. Isolation
+// holds the level as written, e.g. "READ COMMITTED".
+type SetTransactionStatement struct {
+	Session   bool
+	Isolation string
+}
 
-	if clause.Left != nil && len(clause.Left.Name) > 0 {
-		return append(res, clause.Left.Name)
-	}
+// CreateTableStatement is CREATE TABLE [IF NOT EXISTS] name (definition, ...)
+// where a definition is a column or a PRIMARY KEY (column) or UNIQUE (column)
+// constraint. Table constraints are folded into the column definitions.
+type CreateTableStatement struct {
+	Table       string
+	IfNotExists bool
+	Columns     []ColumnDefinition
+}
+
+// ColumnDefinition is name type [(length[, scale])] followed by any of
+// PRIMARY KEY, UNIQUE, [NOT] NULL and DEFAULT literal. Type holds the type
+// name as written, Length is the precision of a DECIMAL. Default is nil
+// without a DEFAULT.
+type ColumnDefinition struct {
+	Name       string
+	Type       string
+	Length     int
+	Scale      int
+	PrimaryKey bool
+	Unique     bool
+	NotNull    bool
+	Default    *string
+}
+
+// DropTableStatement is DROP TABLE [IF EXISTS] name.
+type DropTableStatement struct {
+	Table    string
+	IfExists bool
+}
+
+// AlterTableStatement is ALTER TABLE name action, ... where every action is
+// one of the *Action nodes below, applied in order.
+type AlterTableStatement struct {
+	Table   string
+	Actions []ASTNode
+}
+
+// AddColumnAction is ADD [COLUMN] definition.
+type AddColumnAction struct {
+	Column ColumnDefinition
+}
 
-	left := getColumns(clause.Left)
-	right := getColumns(clause.Right)
+// DropColumnAction is DROP [COLUMN] name.
+type DropColumnAction struct {
+	Column string
+}
 
-	res = append(res, left...)
-	res = append(res, right...)
+// RenameColumnAction is RENAME COLUMN name TO new_name.
+type RenameColumnAction struct {
+	Column  string
+	NewName string
+}
+
+// ModifyColumnAction is MODIFY [COLUMN] definition.
+type ModifyColumnAction struct {
+	Column ColumnDefinition
+}
 
-	return res
+// RenameTableAction is RENAME [TO | AS] new_name.
+type RenameTableAction struct {
+	NewName string
 }
@@ -0,0 +1,200 @@
+package parser
+
+import (
+	"dbngin3/engine"
+	"errors"
+	"math/big"
+	"strings"
+)
+
+// coerce checks the types of the operands of the operators in a qualified
+// expression, for rows with the given columns, and converts the literals
+// that meet a value of another type to that type, the way MySQL casts them
+// implicitly. A literal compared with a number only has to be a number,
+// which is cast to the type if it is one of its values, numbers of all types
+// compare with each other. One compared with a string stays a string of any
+// length. A literal that is no value of the type it meets and operands that
+// cannot be compared or calculated with fail.
+func coerce(expr Expression, columns []engine.Column) (Expression, error) {
+	var err error
+	var fn func(Expression) Expression
+	fn = func(e Expression) Expression {
+		if err != nil {
+			return nil
+		}
+
+		switch e := e.(type) {
+		case *UnaryExpression:
+			u := &UnaryExpression{Operator: e.Operator, Operand: Transform(e.Operand, fn)}
+			if err == nil && u.Operator == MINUS {
+				err = checkNumbers(u.Operator, columns, u.Operand)
+			}
+			return u
+		case *BinaryExpression:
+			b := &BinaryExpression{Operator: e.Operator, Left: Transform(e.Left, fn), Right: Transform(e.Right, fn)}
+			switch {
+			case err != nil, b.Operator == AND, b.Operator == OR:
+			case isComparison(b.Operator):
+				b.Left, b.Right, err = coerceOperands(b.Left, b.Right, columns)
+			default:
+				err = checkNumbers(b.Operator, columns, b.Left, b.Right)
+			}
+			return b
+		case *InExpression:
+			in := *e
+			in.Operand = Transform(e.Operand, fn)
+			if err == nil {
+				in.Operand, err = compareAs(in.Operand, e.Type, e.Select.Columns[0], columns)
+			}
+			return &in
+		}
+		return nil
+	}
+
+	coerced := Transform(expr, fn)
+	return coerced, err
+}
+
+// coerceOperands prepares the operands of a comparison, a literal is
+// converted to the type of the other operand unless both are literals.
+func coerceOperands(left, right Expression, columns []engine.Column) (Expression, Expression, error) {
+	_, leftLiteral := left.(*Literal)
+	_, rightLiteral := right.(*Literal)
+	switch {
+	case leftLiteral && rightLiteral:
+		return left, right, nil
+	case leftLiteral:
+		dataType, err := TypeOf(right, columns)
+		if err != nil {
+			return nil, nil, err
+		}
+		left, err = compareAs(left, dataType, right.String(), columns)
+		return left, right, err
+	}
+
+	dataType, err := TypeOf(left, columns)
+	if err != nil {
+		return nil, nil, err
+	}
+	if rightLiteral {
+		right, err = compareAs(right, dataType, left.String(), columns)
+		return left, right, err
+	}
+
+	rightType, err := TypeOf(right, columns)
+	if err != nil {
+		return nil, nil, err
+	}
+	_, err = commonType(Value{Type: dataType}, Value{Type: rightType})
+	return left, right, err
+}
+
+// compareAs returns the operand ready to be compared with the value of the
+// given type that name renders: a literal converted to the type, anything
+// else checked to be comparable with it.
+func compareAs(operand Expression, dataType engine.DataType, name string, columns []engine.Column) (Expression, error) {
+	if literal, ok := operand.(*Literal); ok {
+		column := engine.Column{Name: name, Type: dataType}
+		switch {
+		case literal.Value == engine.Null, isString(dataType):
+			return literal, nil
+		case dataType.IsNumeric():
+			literal = numericLiteral(literal)
+			if !isNumber(literal.Value) {
+				return nil, errors.New("invalid number \"" + literal.Value + "\" for column " + name)
+			}
+			return castNumber(literal, column)
+		}
+		return castLiteral(literal, column)
+	}
+
+	operandType, err := TypeOf(operand, columns)
+	if err != nil {
+		return nil, err
+	}
+	_, err = commonType(Value{Type: operandType}, Value{Type: dataType})
+	return operand, err
+}
+
+// checkNumbers fails for an operand of an arithmetic operator that is no
+// number. NULL is one of any type.
+func checkNumbers(operator string, columns []engine.Column, operands ...Expression) error {
+	for _, operand := range operands {
+		if literal, ok := operand.(*Literal); ok && literal.Value == engine.Null {
+			continue
+		}
+		dataType, err := TypeOf(operand, columns)
+		if err != nil {
+			return err
+		}
+		if dataType.IsNumeric() {
+			continue
+		}
+		if len(operands) == 1 {
+			return errors.New("operator " + operator + " needs a number, got " + dataType.String())
+		}
+		return errors.New("operator " + operator + " needs numbers, got " + dataType.String())
+	}
+	return nil
+}
+
+// castLiteral converts a literal that is not NULL to the type of the column
+// it is stored in or compared with, checking that it is a value of the
+// column.
+func castLiteral(literal *Literal, column engine.Column) (Expression, error) {
+	if column.Type.IsNumeric() || column.Type == engine.Boolean {
+		literal = numericLiteral(literal)
+	}
+	value, err := column.Normalize(literal.Value)
+	if err != nil {
+		return nil, err
+	}
+	return &Cast{Literal: literal, Type: column.Type, Value: value}, nil
+}
+
+// castNumber converts a number compared with a value of a numeric type to
+// the type if it is one of its values, 2.0 becomes the INT 2. A number that
+// is none, like 1.5 or one out of range, stays a literal; it compares by its
+// value and equals no value of the type.
+func castNumber(literal *Literal, column engine.Column) (Expression, error) {
+	value := literal.Value
+	if strings.Contains(value, ".") && !strings.ContainsAny(value, "eE") {
+		value = strings.TrimSuffix(strings.TrimRight(value, "0"), ".")
+	}
+	normalized, err := column.Normalize(value)
+	if err != nil {
+		return literal, nil
+	}
+
+	number, err := literal.Eval(nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if cmp, err := CompareValues(number, Value{Type: column.Type, Literal: normalized}); err != nil || cmp != 0 {
+		return literal, err
+	}
+	return &Cast{Literal: literal, Type: column.Type, Value: normalized}, nil
+}
+
+// numericLiteral returns the literal as a number: a hex or bit literal is
+// the unsigned integer its bytes spell, TRUE and FALSE are 1 and 0.
+func numericLiteral(literal *Literal) *Literal {
+	switch {
+	case literal.Binary:
+		return &Literal{Value: new(big.Int).SetBytes([]byte(literal.Value)).String()}
+	case strings.EqualFold(literal.Value, TRUE):
+		return &Literal{Value: "1"}
+	case strings.EqualFold(literal.Value, FALSE):
+		return &Literal{Value: "0"}
+	}
+	return literal
+}
+
+// assign returns the value of an INSERT or UPDATE for the column, with a
+// literal converted to the type of the column.
+func assign(column engine.Column, expr Expression) (Expression, error) {
+	if literal, ok := expr.(*Literal); ok && literal.Value != engine.Null {
+		return castLiteral(literal, column)
+	}
+	return expr, nil
+}
@@ -0,0 +1,798 @@
+package parser
+
+import (
+	"dbngin3/engine"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// Expression is a node of an expression: a column, a literal, or an operator
+// applied to other expressions.
+type Expression interface {
+	// String renders the expression as SQL, it names a result column.
+	String() string
+	// Eval computes the value of the expression for a row with the given
+	// columns.
+	Eval(columns []engine.Column, row engine.Row) (Value, error)
+}
+
+// ColumnRef is the value of a column of the row. Table is the alias or
+// name of the table the column is qualified with, as in u.id, or empty.
+type ColumnRef struct {
+	Table string
+	Name  string
+}
+
+// Literal is a constant as written in the statement, or engine.Null. A hex
+// or bit literal is Binary, its Value holds the bytes; compared with or
+// stored in a number it is the unsigned integer they spell.
+type Literal struct {
+	Value  string
+	Binary bool
+}
+
+// UnaryExpression is - or NOT applied to its operand.
+type UnaryExpression struct {
+	Operator string
+	Operand  Expression
+}
+
+// BinaryExpression is an arithmetic operator, a comparison, AND or OR. <> is
+// stored as !=.
+type BinaryExpression struct {
+	Operator string
+	Left     Expression
+	Right    Expression
+}
+
+// IsNullExpression is operand IS [NOT] NULL.
+type IsNullExpression struct {
+	Operand Expression
+	Not     bool
+}
+
+// AggregateExpression is an aggregate function over the rows of a group.
+// Function is upper case, Argument is nil for COUNT(*). It cannot be
+// evaluated for a single row, the planner replaces it by the column of the
+// aggregation that holds its value.
+type AggregateExpression struct {
+	Function string
+	Argument Expression
+	Distinct bool
+}
+
+// SubqueryExpression is a scalar subquery, (SELECT expression ...). Its
+// value is that of the single column of the only row of the query, or NULL
+// without a row. The optimizer sets the Type of the column, the planner the
+// Rows that run the query.
+type SubqueryExpression struct {
+	Select *SelectStatement
+	Type   engine.DataType
+	Rows   SubqueryRows
+}
+
+// InExpression is operand [NOT] IN (SELECT expression ...). Type and Rows
+// are set like those of a SubqueryExpression.
+type InExpression struct {
+	Operand Expression
+	Select  *SelectStatement
+	Not     bool
+	Type    engine.DataType
+	Rows    SubqueryRows
+}
+
+// ExistsExpression is EXISTS (SELECT ...), whether the query returns a row.
+type ExistsExpression struct {
+	Select *SelectStatement
+	Rows   SubqueryRows
+}
+
+// SubqueryRows runs a subquery for the current row of the enclosing query,
+// which has the given columns, and returns at most limit rows of it, or all
+// of them for a negative limit.
+type SubqueryRows func(columns []engine.Column, row engine.Row, limit int) ([]engine.Row, error)
+
+// OuterRef is a column of an enclosing query that a correlated subquery
+// refers to. Depth counts the queries out to the one of the column, 1 being
+// the query the subquery is part of. Column is qualified the way that query
+// names its columns and Type is the type of the column. The planner points
+// Outer at the row the column is read from.
+type OuterRef struct {
+	Column *ColumnRef
+	Depth  int
+	Type   engine.DataType
+	Outer  *OuterRow
+}
+
+// InsertValue is VALUES(column) in ON DUPLICATE KEY UPDATE, the value the
+// row that was to be inserted has for the column. The planner hands that row
+// over as columns named like the expression, after those of the row it
+// updates.
+type InsertValue struct {
+	Column string
+}
+
+// Cast is a literal the analyzer converted to the type of the value it is
+// compared with or of the column it is stored in. It renders as the literal
+// as written, Value is the literal in the canonical form of Type.
+type Cast struct {
+	Literal *Literal
+	Type    engine.DataType
+	Value   string
+}
+
+// OuterRow is the current row of a query that a subquery runs for.
+type OuterRow struct {
+	Columns []engine.Column
+	Row     engine.Row
+}
+
+// The precedences of the operators, from the loosest to the tightest
+// binding one.
+const (
+	lowestPrecedence = iota
+	orPrecedence
+	andPrecedence
+	notPrecedence
+	comparisonPrecedence
+	additivePrecedence
+	multiplicativePrecedence
+	unaryPrecedence
+	operandPrecedence
+)
+
+// binaryPrecedence is the precedence of a binary operator, or the lowest one
+// for any other token.
+func binaryPrecedence(operator string) int {
+	switch operator {
+	case OR:
+		return orPrecedence
+	case AND:
+		return andPrecedence
+	case PLUS, MINUS:
+		return additivePrecedence
+	case MULTIPLY, DIVIDE, MODULO:
+		return multiplicativePrecedence
+	}
+	if isComparison(operator) {
+		return comparisonPrecedence
+	}
+	return lowestPrecedence
+}
+
+func precedenceOf(expr Expression) int {
+	switch expr := expr.(type) {
+	case *BinaryExpression:
+		return binaryPrecedence(expr.Operator)
+	case *IsNullExpression, *InExpression:
+		return comparisonPrecedence
+	case *UnaryExpression:
+		if expr.Operator == NOT {
+			return notPrecedence
+		}
+		return unaryPrecedence
+	}
+	return operandPrecedence
+}
+
+// group puts the expression in parentheses if it binds looser than the
+// precedence.
+func group(expr Expression, precedence int) string {
+	if precedenceOf(expr) < precedence {
+		return "(" + expr.String() + ")"
+	}
+	return expr.String()
+}
+
+func (c *ColumnRef) String() string {
+	if c.Table != "" {
+		return c.Table + "." + c.Name
+	}
+	return c.Name
+}
+
+func (l *Literal) String() string {
+	switch {
+	case l.Value == engine.Null:
+		return NULL
+	case l.Binary:
+		return "X'" + strings.ToUpper(hex.EncodeToString([]byte(l.Value))) + "'"
+	case isNumber(l.Value), IsBooleanLiteral(strings.ToUpper(l.Value)):
+		return l.Value
+	}
+	return "'" + strings.ReplaceAll(l.Value, "'", "''") + "'"
+}
+
+func (u *UnaryExpression) String() string {
+	if u.Operator == NOT {
+		return NOT + " " + group(u.Operand, notPrecedence)
+	}
+
+	operand := group(u.Operand, unaryPrecedence)
+	if strings.HasPrefix(operand, MINUS) {
+		operand = "(" + operand + ")"
+	}
+	return u.Operator + operand
+}
+
+func (b *BinaryExpression) String() string {
+	// the operators are left associative, the right operand needs
+	// parentheses for the same precedence as well
+	precedence := binaryPrecedence(b.Operator)
+	return group(b.Left, precedence) + " " + b.Operator + " " + group(b.Right, precedence+1)
+}
+
+func (i *IsNullExpression) String() string {
+	if i.Not {
+		return group(i.Operand, comparisonPrecedence+1) + " " + IS_NOT_NULL
+	}
+	return group(i.Operand, comparisonPrecedence+1) + " " + IS_NULL
+}
+
+func (a *AggregateExpression) String() string {
+	switch {
+	case a.Argument == nil:
+		return a.Function + "(*)"
+	case a.Distinct:
+		return a.Function + "(" + DISTINCT + " " + a.Argument.String() + ")"
+	}
+	return a.Function + "(" + a.Argument.String() + ")"
+}
+
+func (s *SubqueryExpression) String() string {
+	return "(" + s.Select.String() + ")"
+}
+
+func (i *InExpression) String() string {
+	operator := IN
+	if i.Not {
+		operator = NOT + " " + IN
+	}
+	return group(i.Operand, comparisonPrecedence+1) + " " + operator + " (" + i.Select.String() + ")"
+}
+
+func (e *ExistsExpression) String() string {
+	return EXISTS + " (" + e.Select.String() + ")"
+}
+
+func (o *OuterRef) String() string {
+	return o.Column.String()
+}
+
+func (i *InsertValue) String() string {
+	return VALUES + "(" + i.Column + ")"
+}
+
+func (c *Cast) String() string {
+	return c.Literal.String()
+}
+
+// ColumnNames returns the columns the expression refers to, in order of
+// appearance. Qualified columns are named table.column. Subqueries read the
+// columns of their own tables, and those of enclosing queries from the
+// OuterRow, which the columns of the row need not include.
+func ColumnNames(expr Expression) []string {
+	switch expr := expr.(type) {
+	case *ColumnRef:
+		return []string{expr.String()}
+	case *UnaryExpression:
+		return ColumnNames(expr.Operand)
+	case *BinaryExpression:
+		return append(ColumnNames(expr.Left), ColumnNames(expr.Right)...)
+	case *IsNullExpression:
+		return ColumnNames(expr.Operand)
+	case *InExpression:
+		return ColumnNames(expr.Operand)
+	case *InsertValue:
+		return []string{expr.String()}
+	case *AggregateExpression:
+		return ColumnNames(expr.Argument)
+	}
+	return nil
+}
+
+// Aggregates returns the aggregate functions of the expressions, every one
+// once, without those nested in another one.
+func Aggregates(expressions ...Expression) []*AggregateExpression {
+	var aggregates []*AggregateExpression
+	seen := make(map[string]bool)
+	for _, expr := range expressions {
+		Transform(expr, func(e Expression) Expression {
+			aggregate, ok := e.(*AggregateExpression)
+			if !ok {
+				return nil
+			}
+			if !seen[aggregate.String()] {
+				seen[aggregate.String()] = true
+				aggregates = append(aggregates, aggregate)
+			}
+			return aggregate
+		})
+	}
+	return aggregates
+}
+
+// Transform returns a copy of the expression in which fn replaced nodes. fn
+// sees a node before its operands; when it returns nil the node is kept and
+// its operands are transformed, anything else takes the place of the node.
+// The queries of subqueries are not part of the expression.
+func Transform(expr Expression, fn func(Expression) Expression) Expression {
+	if expr == nil {
+		return nil
+	}
+	if replaced := fn(expr); replaced != nil {
+		return replaced
+	}
+
+	switch expr := expr.(type) {
+	case *UnaryExpression:
+		return &UnaryExpression{Operator: expr.Operator, Operand: Transform(expr.Operand, fn)}
+	case *BinaryExpression:
+		return &BinaryExpression{Operator: expr.Operator, Left: Transform(expr.Left, fn), Right: Transform(expr.Right, fn)}
+	case *IsNullExpression:
+		return &IsNullExpression{Operand: Transform(expr.Operand, fn), Not: expr.Not}
+	case *AggregateExpression:
+		return &AggregateExpression{Function: expr.Function, Argument: Transform(expr.Argument, fn), Distinct: expr.Distinct}
+	case *InExpression:
+		in := *expr
+		in.Operand = Transform(expr.Operand, fn)
+		return &in
+	}
+	return expr
+}
+
+// Value is the result of an expression, a literal of its type or
+// engine.Null.
+type Value struct {
+	Type    engine.DataType
+	Literal string
+	// untyped values are literals of the statement, they take the type of
+	// the value they meet
+	untyped bool
+}
+
+func (v Value) IsNull() bool {
+	return v.Literal == engine.Null
+}
+
+func booleanValue(t truth) Value {
+	switch t {
+	case isTrue:
+		return Value{Type: engine.Boolean, Literal: "true"}
+	case isFalse:
+		return Value{Type: engine.Boolean, Literal: "false"}
+	}
+	return Value{Type: engine.Boolean, Literal: engine.Null}
+}
+
+// isNumber reports whether the literal is written as a number.
+func isNumber(literal string) bool {
+	mantissa, exponent, hasExponent := strings.Cut(strings.ToLower(strings.TrimPrefix(literal, "-")), "e")
+	if hasExponent {
+		exponent = strings.TrimPrefix(strings.TrimPrefix(exponent, "+"), "-")
+		if exponent == "" || strings.Trim(exponent, "0123456789") != "" {
+			return false
+		}
+	}
+	whole, fraction, _ := strings.Cut(mantissa, ".")
+	return whole+fraction != "" && strings.Trim(whole+fraction, "0123456789") == ""
+}
+
+// Eval finds the column by its name, which for a qualified column is
+// table.column; the rows of a join name their columns that way.
+func (c *ColumnRef) Eval(columns []engine.Column, row engine.Row) (Value, error) {
+	name := c.String()
+	for i := range columns {
+		if columns[i].Name == name {
+			return Value{Type: columns[i].Type, Literal: row[i]}, nil
+		}
+	}
+	return Value{}, errors.New("unknown column " + name)
+}
+
+// Eval types the literal by its form: a whole number is a BIGINT, a number
+// with a fraction a DECIMAL and one with an exponent a DOUBLE, TRUE and FALSE
+// are booleans and anything else is a string.
+func (l *Literal) Eval([]engine.Column, engine.Row) (Value, error) {
+	value := Value{Type: engine.Varchar, Literal: l.Value, untyped: true}
+
+	switch {
+	case l.Value == engine.Null:
+	case IsBooleanLiteral(strings.ToUpper(l.Value)):
+		value.Type = engine.Boolean
+		value.Literal = strings.ToLower(l.Value)
+	case isNumber(l.Value):
+		if n, err := strconv.ParseInt(l.Value, 10, 64); err == nil {
+			value.Type = engine.BigInt
+			value.Literal = strconv.FormatInt(n, 10)
+		} else if strings.ContainsAny(l.Value, "eE") {
+			f, err := strconv.ParseFloat(l.Value, 64)
+			if err != nil {
+				return Value{}, errors.New("number out of range " + l.Value)
+			}
+			value.Type = engine.Double
+			value.Literal = strconv.FormatFloat(f, 'g', -1, 64)
+		} else {
+			value.Type = engine.Decimal
+		}
+	}
+	return value, nil
+}
+
+func (u *UnaryExpression) Eval(columns []engine.Column, row engine.Row) (Value, error) {
+	operand, err := u.Operand.Eval(columns, row)
+	if err != nil {
+		return Value{}, err
+	}
+
+	if u.Operator == NOT {
+		t, err := operand.truth()
+		if err != nil {
+			return Value{}, err
+		}
+		return booleanValue(isTrue - t), nil
+	}
+
+	if !operand.IsNull() && !operand.Type.IsNumeric() {
+		return Value{}, errors.New("operator " + u.Operator + " needs a number, got " + operand.Type.String())
+	}
+	result, err := engine.Calculate(MINUS, operand.Type, "0", operand.Literal)
+	return Value{Type: arithmeticType(u.Operator, operand.Type, operand.Type), Literal: result, untyped: operand.untyped}, err
+}
+
+func (b *BinaryExpression) Eval(columns []engine.Column, row engine.Row) (Value, error) {
+	left, err := b.Left.Eval(columns, row)
+	if err != nil {
+		return Value{}, err
+	}
+
+	if b.Operator == AND || b.Operator == OR {
+		l, err := left.truth()
+		if err != nil {
+			return Value{}, err
+		}
+		if b.Operator == AND && l == isFalse || b.Operator == OR && l == isTrue {
+			return booleanValue(l), nil
+		}
+
+		right, err := b.Right.Eval(columns, row)
+		if err != nil {
+			return Value{}, err
+		}
+		r, err := right.truth()
+		if err != nil {
+			return Value{}, err
+		}
+		if (b.Operator == AND) == (r < l) {
+			return booleanValue(r), nil
+		}
+		return booleanValue(l), nil
+	}
+
+	right, err := b.Right.Eval(columns, row)
+	if err != nil {
+		return Value{}, err
+	}
+
+	if isComparison(b.Operator) {
+		return compareValues(b.Operator, left, right)
+	}
+	return calculate(b.Operator, left, right)
+}
+
+func (i *IsNullExpression) Eval(columns []engine.Column, row engine.Row) (Value, error) {
+	operand, err := i.Operand.Eval(columns, row)
+	if err != nil {
+		return Value{}, err
+	}
+	return booleanValue(truthOf(operand.IsNull() != i.Not)), nil
+}
+
+func (a *AggregateExpression) Eval([]engine.Column, engine.Row) (Value, error) {
+	return Value{}, errors.New("invalid use of aggregate function " + a.String())
+}
+
+func (s *SubqueryExpression) Eval(columns []engine.Column, row engine.Row) (Value, error) {
+	rows, err := runSubquery(s.Rows, columns, row, 2)
+	switch {
+	case err != nil:
+		return Value{}, err
+	case len(rows) > 1:
+		return Value{}, errors.New("subquery returns more than 1 row")
+	case len(rows) == 0:
+		return Value{Type: s.Type, Literal: engine.Null}, nil
+	}
+	return Value{Type: s.Type, Literal: rows[0][0]}, nil
+}
+
+// Eval is true if the operand equals a value of the subquery. Otherwise it
+// is unknown if the operand or one of the values is NULL, as a comparison
+// with NULL is, and false if not; without any value it is false.
+func (i *InExpression) Eval(columns []engine.Column, row engine.Row) (Value, error) {
+	operand, err := i.Operand.Eval(columns, row)
+	if err != nil {
+		return Value{}, err
+	}
+	rows, err := runSubquery(i.Rows, columns, row, -1)
+	if err != nil {
+		return Value{}, err
+	}
+
+	result := isFalse
+	for _, r := range rows {
+		equal, err := compareValues(EQUALS, operand, Value{Type: i.Type, Literal: r[0]})
+		if err != nil {
+			return Value{}, err
+		}
+		if t, _ := equal.truth(); t > result {
+			result = t
+		}
+		if result == isTrue {
+			break
+		}
+	}
+
+	if i.Not {
+		result = isTrue - result
+	}
+	return booleanValue(result), nil
+}
+
+func (e *ExistsExpression) Eval(columns []engine.Column, row engine.Row) (Value, error) {
+	rows, err := runSubquery(e.Rows, columns, row, 1)
+	return booleanValue(truthOf(len(rows) > 0)), err
+}
+
+func runSubquery(rows SubqueryRows, columns []engine.Column, row engine.Row, limit int) ([]engine.Row, error) {
+	if rows == nil {
+		return nil, errors.New("subquery is not planned")
+	}
+	return rows(columns, row, limit)
+}
+
+func (o *OuterRef) Eval([]engine.Column, engine.Row) (Value, error) {
+	if o.Outer == nil {
+		return Value{}, errors.New("unbound outer column " + o.String())
+	}
+	return o.Column.Eval(o.Outer.Columns, o.Outer.Row)
+}
+
+func (i *InsertValue) Eval(columns []engine.Column, row engine.Row) (Value, error) {
+	return (&ColumnRef{Name: i.String()}).Eval(columns, row)
+}
+
+func (c *Cast) Eval([]engine.Column, engine.Row) (Value, error) {
+	return Value{Type: c.Type, Literal: c.Value}, nil
+}
+
+// TypeOf returns the type of the values the expression has for rows with
+// the given columns, without evaluating it. An untyped NULL is a VARCHAR.
+func TypeOf(expr Expression, columns []engine.Column) (engine.DataType, error) {
+	switch expr := expr.(type) {
+	case *ColumnRef:
+		for _, column := range columns {
+			if column.Name == expr.String() {
+				return column.Type, nil
+			}
+		}
+		return 0, errors.New("unknown column " + expr.String())
+	case *InsertValue:
+		return TypeOf(&ColumnRef{Name: expr.String()}, columns)
+	case *Cast:
+		return expr.Type, nil
+	case *Literal:
+		value, err := expr.Eval(nil, nil)
+		return value.Type, err
+	case *UnaryExpression:
+		operand, err := TypeOf(expr.Operand, columns)
+		if err != nil || expr.Operator == NOT {
+			return engine.Boolean, err
+		}
+		return arithmeticType(expr.Operator, operand, operand), nil
+	case *BinaryExpression:
+		left, err := TypeOf(expr.Left, columns)
+		if err != nil {
+			return 0, err
+		}
+		right, err := TypeOf(expr.Right, columns)
+		if err != nil {
+			return 0, err
+		}
+		if expr.Operator == AND || expr.Operator == OR || isComparison(expr.Operator) {
+			return engine.Boolean, nil
+		}
+		return arithmeticType(expr.Operator, left, right), nil
+	case *IsNullExpression:
+		_, err := TypeOf(expr.Operand, columns)
+		return engine.Boolean, err
+	case *AggregateExpression:
+		return aggregateType(expr, columns)
+	case *SubqueryExpression:
+		return expr.Type, nil
+	case *InExpression:
+		_, err := TypeOf(expr.Operand, columns)
+		return engine.Boolean, err
+	case *ExistsExpression:
+		return engine.Boolean, nil
+	case *OuterRef:
+		return expr.Type, nil
+	}
+	return 0, errors.New("unsupported expression " + expr.String())
+}
+
+// aggregateType is the type of the result of an aggregate function. COUNT
+// is a BIGINT, MIN and MAX keep the type of their argument, SUM and AVG are
+// a DOUBLE over doubles and a DECIMAL over any other numbers.
+func aggregateType(expr *AggregateExpression, columns []engine.Column) (engine.DataType, error) {
+	if expr.Argument == nil {
+		return engine.BigInt, nil
+	}
+	argument, err := TypeOf(expr.Argument, columns)
+	if err != nil {
+		return 0, err
+	}
+
+	switch expr.Function {
+	case COUNT:
+		return engine.BigInt, nil
+	case MIN, MAX:
+		return argument, nil
+	case SUM, AVG:
+		if literal, ok := expr.Argument.(*Literal); ok && literal.Value == engine.Null {
+			return engine.Decimal, nil
+		}
+		if !argument.IsNumeric() {
+			return 0, errors.New(expr.Function + " needs numbers, got " + argument.String())
+		}
+		if argument == engine.Double {
+			return engine.Double, nil
+		}
+		return engine.Decimal, nil
+	}
+	return 0, errors.New("unknown function " + expr.Function)
+}
+
+// truth is the value of a condition under the three-valued logic of SQL. A
+// comparison with NULL is unknown. The order makes AND the minimum and OR
+// the maximum of their operands.
+type truth int
+
+const (
+	isFalse truth = iota
+	isUnknown
+	isTrue
+)
+
+func truthOf(b bool) truth {
+	if b {
+		return isTrue
+	}
+	return isFalse
+}
+
+// truth reads a value as a condition: a boolean, or a number that is true
+// unless it is zero.
+func (v Value) truth() (truth, error) {
+	if v.IsNull() {
+		return isUnknown, nil
+	}
+
+	switch {
+	case v.Type == engine.Boolean:
+		return truthOf(strings.EqualFold(v.Literal, "true") || v.Literal == "1"), nil
+	case v.Type.IsNumeric():
+		cmp, err := engine.CompareValues(v.Type, v.Literal, "0")
+		return truthOf(cmp != 0), err
+	}
+	return isFalse, errors.New("a value of type " + v.Type.String() + " is not a condition")
+}
+
+// Matches evaluates a condition against a row with the given columns. A
+// missing condition matches every row, a row for which it is unknown does not
+// match.
+func Matches(expr Expression, columns []engine.Column, row engine.Row) (bool, error) {
+	if expr == nil {
+		return true, nil
+	}
+
+	value, err := expr.Eval(columns, row)
+	if err != nil {
+		return false, err
+	}
+	t, err := value.truth()
+	return t == isTrue, err
+}
+
+// commonType is the type two values are compared as. Numbers compare as the
+// widest of their types and a literal takes the type of the other value.
+func commonType(a, b Value) (engine.DataType, error) {
+	switch {
+	case a.Type == b.Type:
+		return a.Type, nil
+	case a.Type.IsNumeric() && b.Type.IsNumeric():
+		return arithmeticType(PLUS, a.Type, b.Type), nil
+	case a.untyped && !b.untyped:
+		return b.Type, nil
+	case b.untyped && !a.untyped:
+		return a.Type, nil
+	case isString(a.Type) && isString(b.Type):
+		return engine.Text, nil
+	case isTemporal(a.Type) && isTemporal(b.Type):
+		return engine.Timestamp, nil
+	}
+	return 0, errors.New("cannot compare " + a.Type.String() + " with " + b.Type.String())
+}
+
+func isString(dataType engine.DataType) bool {
+	return dataType == engine.Varchar || dataType == engine.Text
+}
+
+func isTemporal(dataType engine.DataType) bool {
+	return dataType == engine.Date || dataType == engine.Timestamp
+}
+
+// arithmeticType is the type of the result of an arithmetic operator: a
+// DOUBLE if either operand is one, else a DECIMAL if either operand is one or
+// for a division, and a BIGINT for integers.
+func arithmeticType(operator string, a, b engine.DataType) engine.DataType {
+	switch {
+	case a == engine.Double || b == engine.Double:
+		return engine.Double
+	case a == engine.Decimal || b == engine.Decimal || operator == DIVIDE:
+		return engine.Decimal
+	}
+	return engine.BigInt
+}
+
+// CompareValues orders two values that are not NULL by their common type.
+func CompareValues(a, b Value) (int, error) {
+	dataType, err := commonType(a, b)
+	if err != nil {
+		return 0, err
+	}
+	return engine.CompareValues(dataType, a.Literal, b.Literal)
+}
+
+func compareValues(operator string, a, b Value) (Value, error) {
+	if a.IsNull() || b.IsNull() {
+		return booleanValue(isUnknown), nil
+	}
+
+	cmp, err := CompareValues(a, b)
+	if err != nil {
+		return Value{}, err
+	}
+
+	switch operator {
+	case EQUALS:
+		return booleanValue(truthOf(cmp == 0)), nil
+	case NOT_EQUALS, LESS_MORE_THAN:
+		return booleanValue(truthOf(cmp != 0)), nil
+	case LESS_THAN:
+		return booleanValue(truthOf(cmp < 0)), nil
+	case LESS_THAN_EQUALS:
+		return booleanValue(truthOf(cmp <= 0)), nil
+	case MORE_THAN:
+		return booleanValue(truthOf(cmp > 0)), nil
+	case MORE_THAN_EQUALS:
+		return booleanValue(truthOf(cmp >= 0)), nil
+	}
+	return Value{}, errors.New("unsupported operator " + operator)
+}
+
+func calculate(operator string, a, b Value) (Value, error) {
+	for _, v := range []Value{a, b} {
+		if !v.IsNull() && !v.Type.IsNumeric() {
+			return Value{}, errors.New("operator " + operator + " needs numbers, got " + v.Type.String())
+		}
+	}
+
+	dataType := arithmeticType(operator, a.Type, b.Type)
+	result, err := engine.Calculate(operator, dataType, a.Literal, b.Literal)
+	if err != nil {
+		return Value{}, err
+	}
+	return Value{Type: dataType, Literal: result, untyped: a.untyped && b.untyped}, nil
+}
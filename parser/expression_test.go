@@ -0,0 +1,163 @@
+package parser
+
+import (
+	"dbngin3/engine"
+	"testing"
+)
+
+func parseTestExpression(t *testing.T, query string) Expression {
+	tokens, err := NewLexer(query).Tokenize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expr, err := NewParser(tokens).ParseWhere(&TokenValidatorParam{pos: 0})
+	if err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+	return expr
+}
+
+func TestExpression_String(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected string
+	}{
+		{"WHERE a + b * c > 10", "a + b * c > 10"},
+		{"WHERE (a + b) * c = 1", "(a + b) * c = 1"},
+		{"WHERE a - b - c = 0", "a - b - c = 0"},
+		{"WHERE a - (b - c) = 0", "a - (b - c) = 0"},
+		{"WHERE -a * 2 < b", "-a * 2 < b"},
+		{"WHERE -(a + 1) % 3 = 0", "-(a + 1) % 3 = 0"},
+		{"WHERE a*-1 = -5", "a * -1 = -5"},
+		{"WHERE NOT a = b OR c <> d", "NOT a = b OR c != d"},
+		{"WHERE NOT (a OR b) AND c", "NOT (a OR b) AND c"},
+		{"WHERE a OR b AND c", "a OR b AND c"},
+		{"WHERE (a OR b) AND c", "(a OR b) AND c"},
+		{"WHERE x IS NOT NULL AND price * 2 >= total", "x IS NOT NULL AND price * 2 >= total"},
+		{"WHERE count(*) > sum( DISTINCT a+1 ) * Avg((b))", "COUNT(*) > SUM(DISTINCT a + 1) * AVG(b)"},
+		{"WHERE a NOT IN (select b from t where c = 1) and exists (SELECT * FROM u)", "a NOT IN (SELECT b FROM t WHERE c = 1) AND EXISTS (SELECT * FROM u)"},
+		{"WHERE (SELECT MAX(b) AS m FROM t AS x ORDER BY 1 DESC LIMIT 1) + 1 > a", "(SELECT MAX(b) AS m FROM t AS x ORDER BY 1 DESC LIMIT 1) + 1 > a"},
+		{"WHERE NOT EXISTS (SELECT 1 FROM (SELECT a FROM t) d LEFT JOIN u ON u.a = d.a)", "NOT EXISTS (SELECT 1 FROM (SELECT a FROM t) AS d LEFT JOIN u ON u.a = d.a)"},
+		{"WHERE a = values(a) + 1", "a = VALUES(a) + 1"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			if got := parseTestExpression(t, test.query).String(); got != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, got)
+			}
+		})
+	}
+
+	if got := (&Literal{Value: "it's"}).String(); got != "'it''s'" {
+		t.Errorf("expected the quote to be doubled, got %q", got)
+	}
+	if got := (&UnaryExpression{Operator: MINUS, Operand: &Literal{Value: "-1"}}).String(); got != "-(-1)" {
+		t.Errorf("expected -(-1), got %q", got)
+	}
+	if got := (&Literal{Value: "\x00\n", Binary: true}).String(); got != "X'000A'" {
+		t.Errorf("expected X'000A', got %q", got)
+	}
+}
+
+func TestExpression_Eval(t *testing.T) {
+	columns := []engine.Column{
+		{Name: "id", Type: engine.Int},
+		{Name: "name", Type: engine.Varchar},
+		{Name: "price", Type: engine.Decimal, Precision: 6, Scale: 2},
+		{Name: "quantity", Type: engine.SmallInt},
+		{Name: "discount", Type: engine.Double},
+		{Name: "active", Type: engine.Boolean},
+		{Name: "total", Type: engine.Int},
+	}
+	row := engine.Row{"7", "lamp", "12.50", "3", "0.5", "true", engine.Null}
+
+	tests := []struct {
+		query    string
+		expected string
+	}{
+		{"WHERE id + 1", "8"},
+		{"WHERE -id", "-7"},
+		{"WHERE id % 4 * 2", "6"},
+		{"WHERE id / 2", "3.5000"},
+		{"WHERE price * quantity", "37.50"},
+		{"WHERE price - discount", "12"},
+		{"WHERE price / 0", engine.Null},
+		{"WHERE total + 1", engine.Null},
+		{"WHERE price * quantity > 30", "true"},
+		{"WHERE quantity < id AND active", "true"},
+		{"WHERE id = 7.0", "true"},
+		{"WHERE name = 'lamp'", "true"},
+		{"WHERE total = 1", engine.Null},
+		{"WHERE total = 1 OR id = 7", "true"},
+		{"WHERE total = 1 AND id = 8", "false"},
+		{"WHERE NOT total = 1", engine.Null},
+		{"WHERE total IS NULL", "true"},
+		{"WHERE id - 7", "0"},
+		{"WHERE active = 1", "true"},
+		{"WHERE id * 1e2", "700"},
+		{"WHERE price + .5", "13.00"},
+		{"WHERE discount = 5E-1", "true"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			value, err := parseTestExpression(t, test.query).Eval(columns, row)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if value.Literal != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, value.Literal)
+			}
+		})
+	}
+
+	for _, query := range []string{
+		"WHERE name + 1",
+		"WHERE -name",
+		"WHERE name AND active",
+		"WHERE name = id",
+		"WHERE email = 1",
+		"WHERE id = 'seven'",
+		"WHERE id < 1e999",
+	} {
+		t.Run(query, func(t *testing.T) {
+			if _, err := parseTestExpression(t, query).Eval(columns, row); err == nil {
+				t.Errorf("expected %q to fail", query)
+			}
+		})
+	}
+}
+
+func TestMatches(t *testing.T) {
+	columns := []engine.Column{{Name: "a", Type: engine.Int}, {Name: "b", Type: engine.Int}}
+
+	tests := []struct {
+		query    string
+		row      engine.Row
+		expected bool
+	}{
+		{"WHERE a < b", engine.Row{"1", "2"}, true},
+		{"WHERE a < b", engine.Row{"2", "1"}, false},
+		{"WHERE a < b", engine.Row{engine.Null, "1"}, false},
+		{"WHERE NOT a < b", engine.Row{engine.Null, "1"}, false},
+		{"WHERE a", engine.Row{"0", "1"}, false},
+		{"WHERE b", engine.Row{"0", "1"}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			ok, err := Matches(parseTestExpression(t, test.query), columns, test.row)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok != test.expected {
+				t.Errorf("expected %v for %v, got %v", test.expected, test.row, ok)
+			}
+		})
+	}
+
+	if ok, err := Matches(nil, columns, engine.Row{"1", "2"}); !ok || err != nil {
+		t.Errorf("expected a missing condition to match, got %v, %v", ok, err)
+	}
+}
@@ -1,12 +1,19 @@
 package parser
 
 import (
+	"dbngin3/engine"
 	"dbngin3/util"
-	"fmt"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"unicode/utf8"
 )
 
 type Lexer struct {
 	Input string
+	// IdentifierCase folds the unquoted names of tables and columns the
+	// way the catalog matches them.
+	IdentifierCase engine.IdentifierCase
 }
 
 func InitLexer() *Lexer {
@@ -24,88 +31,444 @@ func NewLexer(input string) *Lexer {
 	}
 }
 
+// Tokenize splits the input into tokens, each with its position in the
+// input. Whitespace and comments separate tokens, a character that starts no
+// token is a syntax error. Keywords are recognized in any case and given in
+// upper case, unquoted identifiers are folded by IdentifierCase.
+//
+// Besides keywords, identifiers and operators the lexer reads
+//
+//   - identifiers of letters of any script, digits, _ and $ that do not start
+//     with a digit, or any text in "double quotes" or `backticks`, which
+//     keeps its case and may be a keyword
+//   - numbers like 42, 1.5 or 6.02e23
+//   - strings in 'single quotes', where a quote is doubled or escaped with a
+//     backslash
+//   - hex and binary strings, X'1F', 0x1F, B'1010' and 0b1010
+//   - -- comments to the end of the line and /* block comments */
 func (l *Lexer) Tokenize() ([]Token, error) {
-	var tokens []Token
+	s := &scanner{input: l.Input, identifierCase: l.IdentifierCase, line: 1, column: 1}
 
-	input := l.Input
-	pos := 0
-	for pos < len(input) {
-		char := input[pos]
-
-		if util.IsWhitespace(char) {
-			pos++
-			continue
+	for {
+		if err := s.skipSpace(); err != nil {
+			return nil, err
+		}
+		if s.pos >= len(s.input) {
+			return s.tokens, nil
 		}
+		if err := s.scanToken(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// scanner is the state of Tokenize. offset, line and column are the last
+// position handed out, positions only ever move forward from there.
+type scanner struct {
+	input          string
+	identifierCase engine.IdentifierCase
+	pos            int
+	tokens         []Token
+
+	offset int
+	line   int
+	column int
+}
+
+// at returns the byte i bytes ahead of the current one, or 0 past the end.
+func (s *scanner) at(i int) byte {
+	if s.pos+i < len(s.input) {
+		return s.input[s.pos+i]
+	}
+	return 0
+}
+
+// position returns the line and column of the byte at offset. Columns count
+// characters, not bytes.
+func (s *scanner) position(offset int) Position {
+	skipped := s.input[s.offset:offset]
+	if i := strings.LastIndexByte(skipped, '\n'); i >= 0 {
+		s.line += strings.Count(skipped, "\n")
+		s.column = 1
+		skipped = skipped[i+1:]
+	}
+	s.column += utf8.RuneCountInString(skipped)
+	s.offset = offset
+	return Position{Offset: offset, Line: s.line, Column: s.column}
+}
+
+func (s *scanner) errorAt(offset int, message string) error {
+	return &SyntaxError{Query: s.input, Pos: s.position(offset), Message: message}
+}
 
-		if util.IsLetter(char) {
-			start := pos
-			for pos < len(input) && (util.IsLetter(input[pos])) {
-				pos++
+func (s *scanner) emit(tokenType TokenType, value string, start int) {
+	s.tokens = append(s.tokens, Token{Type: tokenType, Value: value, Pos: s.position(start)})
+}
+
+// skipSpace moves past whitespace and comments.
+func (s *scanner) skipSpace() error {
+	for s.pos < len(s.input) {
+		switch {
+		case util.IsWhitespace(s.at(0)):
+			s.pos++
+		case s.at(0) == '-' && s.at(1) == '-':
+			end := strings.IndexByte(s.input[s.pos:], '\n')
+			if end < 0 {
+				s.pos = len(s.input)
+			} else {
+				s.pos += end + 1
 			}
-			value := input[start:pos]
-			if IsConditionalOperator(value) {
-				tokens = append(tokens, Token{Type: OPERATOR, Value: value})
-				continue
+		case s.at(0) == '/' && s.at(1) == '*':
+			end := strings.Index(s.input[s.pos+2:], "*/")
+			if end < 0 {
+				return s.errorAt(s.pos, "unclosed comment")
 			}
-			tokenType := GetKeywordOrIdentifier(value)
-			tokens = append(tokens, Token{Type: tokenType, Value: value})
-			continue
+			s.pos += 2 + end + 2
+		default:
+			return nil
 		}
+	}
+	return nil
+}
 
-		if util.IsDigit(char) {
-			start := pos
-			for pos < len(input) && util.IsDigit(input[pos]) {
-				pos++
-			}
-			value := input[start:pos]
-			tokens = append(tokens, Token{Type: LITERAL, Value: value})
-			continue
+func (s *scanner) scanToken() error {
+	char := s.at(0)
+	r, size := utf8.DecodeRuneInString(s.input[s.pos:])
+
+	switch {
+	case (char == 'x' || char == 'X') && s.at(1) == '\'':
+		return s.scanHexString()
+	case (char == 'b' || char == 'B') && s.at(1) == '\'':
+		return s.scanBinaryString()
+	case char == '0' && (s.at(1) == 'x' || s.at(1) == 'X') && util.IsHexDigit(s.at(2)):
+		return s.scanHexNumber()
+	case char == '0' && (s.at(1) == 'b' || s.at(1) == 'B') && util.IsBinaryDigit(s.at(2)):
+		return s.scanBinaryNumber()
+	case r != utf8.RuneError && util.IsIdentifierStart(r):
+		s.scanWord()
+		return nil
+	case util.IsDigit(char), char == '.' && util.IsDigit(s.at(1)), char == '-' && s.isNegativeNumber():
+		return s.scanNumber()
+	case char == '\'':
+		return s.scanString()
+	case char == '"' || char == '`':
+		return s.scanQuotedIdentifier()
+	case util.IsOperator(char):
+		operator := string(char)
+		// only comparisons take two characters, a*-1 is a * -1
+		if s.pos+1 < len(s.input) && isComparison(s.input[s.pos:s.pos+2]) {
+			operator = s.input[s.pos : s.pos+2]
 		}
+		s.emit(OPERATOR, operator, s.pos)
+		s.pos += len(operator)
+		return nil
+	case util.IsDelimiter(char):
+		s.emit(DELIMITER, string(char), s.pos)
+		s.pos++
+		return nil
+	case util.IsSymbol(char):
+		s.emit(SYMBOL, string(char), s.pos)
+		s.pos++
+		return nil
+	}
 
-		if char == '\'' || char == '"' {
-			start := pos + 1
-			pos++
+	if r == utf8.RuneError && size <= 1 {
+		return s.errorAt(s.pos, "invalid UTF-8 in query")
+	}
+	return s.errorAt(s.pos, "unexpected character "+strconv.QuoteRune(r))
+}
 
-			for pos < len(input) && !(input[pos] == '\'' || input[pos] == '"') {
-				pos++
-			}
+// scanWord reads a keyword, an identifier, AND, OR, TRUE or FALSE.
+func (s *scanner) scanWord() {
+	start := s.pos
+	for s.pos < len(s.input) {
+		r, size := utf8.DecodeRuneInString(s.input[s.pos:])
+		if r == utf8.RuneError || !util.IsIdentifierPart(r) {
+			break
+		}
+		s.pos += size
+	}
 
-			if pos < len(input) && (input[pos] == '\'' || input[pos] == '"') {
-				value := input[start:pos]
-				tokens = append(tokens, Token{Type: LITERAL, Value: value})
-				pos++
-			} else {
-				return nil, fmt.Errorf("unclosed string literal")
-			}
-			continue
+	value := s.input[start:s.pos]
+	word := strings.ToUpper(value)
+	switch {
+	case IsConditionalOperator(word):
+		s.emit(OPERATOR, word, start)
+	case IsBooleanLiteral(word):
+		s.emit(LITERAL, word, start)
+	case IsTypedLiteralPrefix(word) && nextIsQuote(s.input, s.pos):
+		// DATE '2024-01-01' is the string that follows, its column gives
+		// it the type
+	case GetKeywordOrIdentifier(word) == KEYWORD:
+		s.emit(KEYWORD, word, start)
+	default:
+		s.emit(IDENTIFIER, s.identifierCase.Fold(value, false), start)
+	}
+}
+
+// scanNumber reads digits with an optional fraction and exponent. A minus
+// in front is part of the number.
+func (s *scanner) scanNumber() error {
+	start := s.pos
+	if s.at(0) == '-' {
+		s.pos++
+	}
+	s.skipDigits()
+	if s.at(0) == '.' {
+		s.pos++
+		s.skipDigits()
+	}
+	if e := s.at(0); e == 'e' || e == 'E' {
+		sign := 0
+		if s.at(1) == '+' || s.at(1) == '-' {
+			sign = 1
+		}
+		if util.IsDigit(s.at(1 + sign)) {
+			s.pos += 1 + sign
+			s.skipDigits()
 		}
+	}
 
-		if util.IsOperator(char) {
-			operator := string(char)
-			if util.IsOperator(input[pos+1]) {
-				operator = operator + string(input[pos+1])
-				pos++
-			}
+	if err := s.expectSeparated(start, "invalid number"); err != nil {
+		return err
+	}
+	s.emit(LITERAL, s.input[start:s.pos], start)
+	return nil
+}
+
+func (s *scanner) skipDigits() {
+	for util.IsDigit(s.at(0)) {
+		s.pos++
+	}
+}
+
+// expectSeparated fails if the literal that started at start runs into an
+// identifier, as in 12abc.
+func (s *scanner) expectSeparated(start int, message string) error {
+	r, _ := utf8.DecodeRuneInString(s.input[s.pos:])
+	if s.pos < len(s.input) && r != utf8.RuneError && util.IsIdentifierPart(r) {
+		return s.errorAt(start, message)
+	}
+	return nil
+}
+
+// scanString reads a string in single quotes. A quote inside is doubled or
+// escaped with a backslash, which also escapes \0, \b, \n, \r, \t, \Z and
+// itself. It stays in front of % and _, which LIKE needs escaped, and is
+// dropped in front of any other character.
+func (s *scanner) scanString() error {
+	start := s.pos
+	s.pos++
 
-			tokens = append(tokens, Token{Type: OPERATOR, Value: operator})
-			pos++
-			continue
+	var value strings.Builder
+	for {
+		if s.pos >= len(s.input) {
+			return s.errorAt(start, "unclosed string literal")
 		}
 
-		if util.IsDelimiter(char) {
-			tokens = append(tokens, Token{Type: DELIMITER, Value: string(char)})
-			pos++
-			continue
+		char := s.at(0)
+		switch {
+		case char == '\'' && s.at(1) == '\'':
+			value.WriteByte('\'')
+			s.pos += 2
+		case char == '\'':
+			s.pos++
+			return s.emitLiteral(value.String(), start)
+		case char == '\\' && s.pos+1 < len(s.input):
+			value.WriteString(unescape(s.at(1)))
+			s.pos += 2
+		default:
+			value.WriteByte(char)
+			s.pos++
 		}
+	}
+}
 
-		if util.IsSymbol(char) {
-			tokens = append(tokens, Token{Type: SYMBOL, Value: string(char)})
-			pos++
-			continue
+func unescape(char byte) string {
+	switch char {
+	case '0':
+		return "\x00"
+	case 'b':
+		return "\b"
+	case 'n':
+		return "\n"
+	case 'r':
+		return "\r"
+	case 't':
+		return "\t"
+	case 'Z':
+		return "\x1a"
+	case '%', '_':
+		return "\\" + string(char)
+	}
+	return string(char)
+}
+
+// scanQuotedIdentifier reads an identifier in double quotes or backticks, a
+// doubled quote stands for itself. Quoted identifiers may be keywords.
+func (s *scanner) scanQuotedIdentifier() error {
+	start := s.pos
+	quote := s.at(0)
+	s.pos++
+
+	var name strings.Builder
+	for {
+		if s.pos >= len(s.input) {
+			return s.errorAt(start, "unclosed quoted identifier")
 		}
 
+		char := s.at(0)
+		switch {
+		case char == quote && s.at(1) == quote:
+			name.WriteByte(quote)
+			s.pos += 2
+		case char == quote:
+			s.pos++
+			if name.Len() == 0 {
+				return s.errorAt(start, "empty quoted identifier")
+			}
+			identifier := s.identifierCase.Fold(name.String(), true)
+			s.tokens = append(s.tokens, Token{Type: IDENTIFIER, Value: identifier, Pos: s.position(start), Quoted: true})
+			return nil
+		default:
+			name.WriteByte(char)
+			s.pos++
+		}
+	}
+}
+
+// scanHexString reads X'1F', a string of bytes given by pairs of hex digits.
+func (s *scanner) scanHexString() error {
+	start := s.pos
+	end := strings.IndexByte(s.input[s.pos+2:], '\'')
+	if end < 0 {
+		return s.errorAt(start, "unclosed string literal")
+	}
+	digits := s.input[s.pos+2 : s.pos+2+end]
+	s.pos += 2 + end + 1
+
+	value, err := hex.DecodeString(digits)
+	if err != nil {
+		return s.errorAt(start, "invalid hex literal")
+	}
+	return s.emitBytes(string(value), start)
+}
+
+// scanHexNumber reads 0x1F, an odd number of digits is padded with a zero
+// in front.
+func (s *scanner) scanHexNumber() error {
+	start := s.pos
+	s.pos += 2
+	for util.IsHexDigit(s.at(0)) {
+		s.pos++
+	}
+	if err := s.expectSeparated(start, "invalid hex literal"); err != nil {
+		return err
+	}
+
+	digits := s.input[start+2 : s.pos]
+	if len(digits)%2 == 1 {
+		digits = "0" + digits
+	}
+	value, _ := hex.DecodeString(digits)
+	return s.emitBytes(string(value), start)
+}
+
+// scanBinaryString reads B'1010', a string of bytes given bit by bit.
+func (s *scanner) scanBinaryString() error {
+	start := s.pos
+	end := strings.IndexByte(s.input[s.pos+2:], '\'')
+	if end < 0 {
+		return s.errorAt(start, "unclosed string literal")
+	}
+	digits := s.input[s.pos+2 : s.pos+2+end]
+	s.pos += 2 + end + 1
+
+	if strings.Trim(digits, "01") != "" {
+		return s.errorAt(start, "invalid binary literal")
+	}
+	return s.emitBytes(bitsToBytes(digits), start)
+}
+
+// scanBinaryNumber reads 0b1010.
+func (s *scanner) scanBinaryNumber() error {
+	start := s.pos
+	s.pos += 2
+	for util.IsBinaryDigit(s.at(0)) {
+		s.pos++
+	}
+	if err := s.expectSeparated(start, "invalid binary literal"); err != nil {
+		return err
+	}
+	return s.emitBytes(bitsToBytes(s.input[start+2:s.pos]), start)
+}
+
+// bitsToBytes packs the bits into bytes, padding them with zeros in front
+// to whole bytes.
+func bitsToBytes(bits string) string {
+	if pad := len(bits) % 8; pad != 0 {
+		bits = strings.Repeat("0", 8-pad) + bits
+	}
+
+	value := make([]byte, len(bits)/8)
+	for i := range value {
+		b, _ := strconv.ParseUint(bits[i*8:i*8+8], 2, 8)
+		value[i] = byte(b)
+	}
+	return string(value)
+}
+
+// emitLiteral adds a string literal. A string of raw bytes could spell the
+// value that stands for NULL, it is refused rather than read as NULL.
+func (s *scanner) emitLiteral(value string, start int) error {
+	if value == engine.Null {
+		return s.errorAt(start, "invalid string literal")
+	}
+	s.emit(LITERAL, value, start)
+	return nil
+}
+
+// emitBytes adds the literal of a hex or bit string.
+func (s *scanner) emitBytes(value string, start int) error {
+	if err := s.emitLiteral(value, start); err != nil {
+		return err
+	}
+	s.tokens[len(s.tokens)-1].Binary = true
+	return nil
+}
+
+// positionOf returns the line and column of the byte at offset. Columns count
+// characters, not bytes.
+func positionOf(input string, offset int) Position {
+	line := 1 + strings.Count(input[:offset], "\n")
+	lineStart := strings.LastIndexByte(input[:offset], '\n') + 1
+	return Position{Offset: offset, Line: line, Column: utf8.RuneCountInString(input[lineStart:offset]) + 1}
+}
+
+func nextIsQuote(input string, pos int) bool {
+	for pos < len(input) && util.IsWhitespace(input[pos]) {
 		pos++
 	}
+	return pos < len(input) && input[pos] == '\''
+}
+
+// isNegativeNumber tells a minus sign in front of a number from a
+// subtraction: it has to follow an operator, a delimiter, an opening
+// parenthesis or a keyword.
+func (s *scanner) isNegativeNumber() bool {
+	if !util.IsDigit(s.at(1)) && !(s.at(1) == '.' && util.IsDigit(s.at(2))) {
+		return false
+	}
+	if len(s.tokens) == 0 {
+		return true
+	}
 
-	return tokens, nil
+	last := s.tokens[len(s.tokens)-1]
+	switch last.Type {
+	case OPERATOR, DELIMITER, KEYWORD:
+		return true
+	case SYMBOL:
+		return last.Value == "("
+	}
+	return false
 }
@@ -1,6 +1,10 @@
 package parser
 
-import "testing"
+import (
+	"dbngin3/engine"
+	"reflect"
+	"testing"
+)
 
 type TokenTest struct {
 	name     string
@@ -226,7 +230,7 @@ func TestLexer_Tokenize_SelectQueryWithWhereClauseMoreThanEqualsOperator(t *test
 }
 
 func TestLexer_Tokenize_SelectQueryWithMultipleAndWhereClauses(t *testing.T) {
-	lexer := NewLexer("SELECT id FROM users WHERE name = \"marty\" AND age = 18")
+	lexer := NewLexer("SELECT id FROM users WHERE name = 'marty' AND age = 18")
 	tokens, _ := lexer.Tokenize()
 
 	t.Run("Check tokens generated correctly", func(t *testing.T) {
@@ -253,7 +257,7 @@ func TestLexer_Tokenize_SelectQueryWithMultipleAndWhereClauses(t *testing.T) {
 }
 
 func TestLexer_Tokenize_SelectQueryWithMultipleAndWhereClausesAndOrOperator(t *testing.T) {
-	lexer := NewLexer("SELECT id FROM users WHERE name = \"marty\" OR age = 18")
+	lexer := NewLexer("SELECT id FROM users WHERE name = 'marty' OR age = 18")
 	tokens, _ := lexer.Tokenize()
 
 	t.Run("Check tokens generated correctly", func(t *testing.T) {
@@ -280,7 +284,7 @@ func TestLexer_Tokenize_SelectQueryWithMultipleAndWhereClausesAndOrOperator(t *t
 }
 
 func TestLexer_Tokenize_SimpleInsertQuery(t *testing.T) {
-	lexer := NewLexer("INSERT INTO users (id, name, age) VALUES (1, \"marty\", 18)")
+	lexer := NewLexer("INSERT INTO users (id, name, age) VALUES (1, 'marty', 18)")
 	tokens, _ := lexer.Tokenize()
 
 	t.Run("Check tokens generated correctly", func(t *testing.T) {
@@ -313,7 +317,7 @@ func TestLexer_Tokenize_SimpleInsertQuery(t *testing.T) {
 }
 
 func TestLexer_Tokenize_SimpleUpdateQuery(t *testing.T) {
-	lexer := NewLexer("UPDATE users SET name = \"marty\" WHERE id = 1")
+	lexer := NewLexer("UPDATE users SET name = 'marty' WHERE id = 1")
 	tokens, _ := lexer.Tokenize()
 
 	t.Run("Check tokens generated correctly", func(t *testing.T) {
@@ -338,7 +342,7 @@ func TestLexer_Tokenize_SimpleUpdateQuery(t *testing.T) {
 }
 
 func TestLexer_Tokenize_UpdateQueryMultiColumnChanges(t *testing.T) {
-	lexer := NewLexer("UPDATE users SET name = \"marty\", age = 18, email = \"marty.mcfly@thefuture.com\" WHERE id = 1")
+	lexer := NewLexer("UPDATE users SET name = 'marty', age = 18, email = 'marty.mcfly@thefuture.com' WHERE id = 1")
 	tokens, _ := lexer.Tokenize()
 
 	t.Run("Check tokens generated correctly", func(t *testing.T) {
@@ -371,7 +375,7 @@ func TestLexer_Tokenize_UpdateQueryMultiColumnChanges(t *testing.T) {
 }
 
 func TestLexer_Tokenize_UpdateQueryMultiColumnWhereClauses(t *testing.T) {
-	lexer := NewLexer("UPDATE users SET name = \"marty\", age = 18 WHERE id = 1 AND email = \"marty.mcfly@thefuture.com\"")
+	lexer := NewLexer("UPDATE users SET name = 'marty', age = 18 WHERE id = 1 AND email = 'marty.mcfly@thefuture.com'")
 	tokens, _ := lexer.Tokenize()
 
 	t.Run("Check tokens generated correctly", func(t *testing.T) {
@@ -426,7 +430,7 @@ func TestLexer_Tokenize_SimpleDeleteQuery(t *testing.T) {
 }
 
 func TestLexer_Tokenize_DeleteQueryMultipleWhereClauses(t *testing.T) {
-	lexer := NewLexer("DELETE FROM users WHERE id = 1 AND email = \"marty.mcfly@thefuture.com\"")
+	lexer := NewLexer("DELETE FROM users WHERE id = 1 AND email = 'marty.mcfly@thefuture.com'")
 	tokens, _ := lexer.Tokenize()
 
 	t.Run("Check tokens generated correctly", func(t *testing.T) {
@@ -464,3 +468,321 @@ func validateTokenDetail(t *testing.T, tests []TokenTest) {
 		})
 	}
 }
+
+func TestLexer_Tokenize_TypedLiterals(t *testing.T) {
+	lexer := NewLexer("INSERT INTO t VALUES (-1.25, TRUE, DATE '2024-01-31', 3-2)")
+	tokens, _ := lexer.Tokenize()
+
+	t.Run("Check tokens generated correctly", func(t *testing.T) {
+		if len(tokens) != 15 {
+			t.Fatalf("expected 15 tokens, got %v", len(tokens))
+		}
+	})
+
+	tests := []TokenTest{
+		{"Negative decimal is one literal", tokens[5], Token{Type: LITERAL, Value: "-1.25"}},
+		{"Boolean is a literal", tokens[7], Token{Type: LITERAL, Value: TRUE}},
+		{"Typed string keeps its value", tokens[9], Token{Type: LITERAL, Value: "2024-01-31"}},
+		{"Subtraction is an operator", tokens[12], Token{Type: OPERATOR, Value: "-"}},
+	}
+	validateTokenDetail(t, tests)
+}
+
+func TestLexer_Tokenize_ArithmeticOperators(t *testing.T) {
+	lexer := NewLexer("a*-1 % b<>c>=")
+	tokens, _ := lexer.Tokenize()
+
+	expected := []Token{
+		{Type: IDENTIFIER, Value: "a", Pos: Position{Offset: 0, Line: 1, Column: 1}},
+		{Type: OPERATOR, Value: MULTIPLY, Pos: Position{Offset: 1, Line: 1, Column: 2}},
+		{Type: LITERAL, Value: "-1", Pos: Position{Offset: 2, Line: 1, Column: 3}},
+		{Type: OPERATOR, Value: MODULO, Pos: Position{Offset: 5, Line: 1, Column: 6}},
+		{Type: IDENTIFIER, Value: "b", Pos: Position{Offset: 7, Line: 1, Column: 8}},
+		{Type: OPERATOR, Value: LESS_MORE_THAN, Pos: Position{Offset: 8, Line: 1, Column: 9}},
+		{Type: IDENTIFIER, Value: "c", Pos: Position{Offset: 10, Line: 1, Column: 11}},
+		{Type: OPERATOR, Value: MORE_THAN_EQUALS, Pos: Position{Offset: 11, Line: 1, Column: 12}},
+	}
+	if !reflect.DeepEqual(tokens, expected) {
+		t.Errorf("expected %v, got %v", expected, tokens)
+	}
+}
+
+func TestLexer_Tokenize_Positions(t *testing.T) {
+	tokens, err := NewLexer("SELECT name\n  FROM users\nWHERE name = 'ü' AND id = 1").Tokenize()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		token    int
+		expected Position
+	}{
+		{0, Position{Offset: 0, Line: 1, Column: 1}},
+		{2, Position{Offset: 14, Line: 2, Column: 3}},
+		{3, Position{Offset: 19, Line: 2, Column: 8}},
+		{7, Position{Offset: 38, Line: 3, Column: 14}},
+		{8, Position{Offset: 43, Line: 3, Column: 18}},
+	}
+	for _, test := range tests {
+		if got := tokens[test.token].Pos; got != test.expected {
+			t.Errorf("expected token %q at %+v, got %+v", tokens[test.token].Value, test.expected, got)
+		}
+	}
+}
+
+func TestLexer_Tokenize_Errors(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected Position
+	}{
+		{"SELECT name FROM users WHERE name = 'marty", Position{Offset: 36, Line: 1, Column: 37}},
+		{"SELECT name FROM users\nWHERE name # 1", Position{Offset: 34, Line: 2, Column: 12}},
+		{"SELECT 'üü' § FROM users", Position{Offset: 14, Line: 1, Column: 13}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			_, err := NewLexer(test.query).Tokenize()
+			syntaxErr, ok := err.(*SyntaxError)
+			if !ok {
+				t.Fatalf("expected a syntax error, got %v", err)
+			}
+			if syntaxErr.Pos != test.expected {
+				t.Errorf("expected the error at %+v, got %+v", test.expected, syntaxErr.Pos)
+			}
+		})
+	}
+}
+
+func TestLexer_Tokenize_Words(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected []Token
+	}{
+		{"t_users col2 _x a$b", []Token{
+			{Type: IDENTIFIER, Value: "t_users"},
+			{Type: IDENTIFIER, Value: "col2"},
+			{Type: IDENTIFIER, Value: "_x"},
+			{Type: IDENTIFIER, Value: "a$b"},
+		}},
+		{"straße 名前 ÄÖ", []Token{
+			{Type: IDENTIFIER, Value: "straße"},
+			{Type: IDENTIFIER, Value: "名前"},
+			{Type: IDENTIFIER, Value: "ÄÖ"},
+		}},
+		{`"SELECT" "my ""name""" ` + "`a``b`", []Token{
+			{Type: IDENTIFIER, Value: "SELECT", Quoted: true},
+			{Type: IDENTIFIER, Value: `my "name"`, Quoted: true},
+			{Type: IDENTIFIER, Value: "a`b", Quoted: true},
+		}},
+		{"t.id", []Token{
+			{Type: IDENTIFIER, Value: "t"},
+			{Type: SYMBOL, Value: "."},
+			{Type: IDENTIFIER, Value: "id"},
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			validateTokens(t, test.query, test.expected)
+		})
+	}
+}
+
+func TestLexer_Tokenize_Numbers(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected []Token
+	}{
+		{"42 1.5 .5 7.", []Token{
+			{Type: LITERAL, Value: "42"},
+			{Type: LITERAL, Value: "1.5"},
+			{Type: LITERAL, Value: ".5"},
+			{Type: LITERAL, Value: "7."},
+		}},
+		{"6.02e23 1E-3 2e+2", []Token{
+			{Type: LITERAL, Value: "6.02e23"},
+			{Type: LITERAL, Value: "1E-3"},
+			{Type: LITERAL, Value: "2e+2"},
+		}},
+		{"= -1.5e3", []Token{
+			{Type: OPERATOR, Value: EQUALS},
+			{Type: LITERAL, Value: "-1.5e3"},
+		}},
+		{"a-1", []Token{
+			{Type: IDENTIFIER, Value: "a"},
+			{Type: OPERATOR, Value: MINUS},
+			{Type: LITERAL, Value: "1"},
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			validateTokens(t, test.query, test.expected)
+		})
+	}
+}
+
+func TestLexer_Tokenize_Strings(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected string
+		binary   bool
+	}{
+		{`'it''s'`, "it's", false},
+		{`'it\'s'`, "it's", false},
+		{`'say "hi"'`, `say "hi"`, false},
+		{`'a\nb\tc\\d'`, "a\nb\tc\\d", false},
+		{`'50\% \_ \q'`, `50\% \_ q`, false},
+		{`''`, "", false},
+		{`X'48690A'`, "Hi\n", true},
+		{`0x4869`, "Hi", true},
+		{`0xA`, "\n", true},
+		{`B'01001000'`, "H", true},
+		{`0b1`, "\x01", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			validateTokens(t, test.query, []Token{{Type: LITERAL, Value: test.expected, Binary: test.binary}})
+		})
+	}
+}
+
+func TestLexer_Tokenize_Comments(t *testing.T) {
+	query := "SELECT -- the name\n name /* of\n all */ FROM users -- at the end"
+	validateTokens(t, query, []Token{
+		{Type: KEYWORD, Value: SELECT},
+		{Type: IDENTIFIER, Value: "name"},
+		{Type: KEYWORD, Value: FROM},
+		{Type: IDENTIFIER, Value: "users"},
+	})
+
+	tokens, _ := NewLexer(query).Tokenize()
+	if expected := (Position{Offset: 39, Line: 3, Column: 9}); tokens[2].Pos != expected {
+		t.Errorf("expected FROM at %+v, got %+v", expected, tokens[2].Pos)
+	}
+}
+
+func TestLexer_Tokenize_InvalidTokens(t *testing.T) {
+	tests := []struct {
+		query   string
+		message string
+	}{
+		{"SELECT /* open", "unclosed comment"},
+		{`SELECT "name`, "unclosed quoted identifier"},
+		{"SELECT ``", "empty quoted identifier"},
+		{"SELECT 12abc", "invalid number"},
+		{"SELECT X'ABC'", "invalid hex literal"},
+		{"SELECT 0x1G", "invalid hex literal"},
+		{"SELECT B'102'", "invalid binary literal"},
+		{"SELECT X'FF4E554C4C'", "invalid string literal"},
+		{"SELECT \xff", "invalid UTF-8 in query"},
+		{"SELECT 'it\\'", "unclosed string literal"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			_, err := NewLexer(test.query).Tokenize()
+			syntaxErr, ok := err.(*SyntaxError)
+			if !ok {
+				t.Fatalf("expected a syntax error, got %v", err)
+			}
+			if syntaxErr.Message != test.message {
+				t.Errorf("expected %q, got %q", test.message, syntaxErr.Message)
+			}
+		})
+	}
+}
+
+func FuzzLexer_Tokenize(f *testing.F) {
+	for _, query := range []string{
+		"SELECT id, name FROM users WHERE id = 1",
+		"INSERT INTO t (a, b) VALUES (-1.5e3, 'it''s')",
+		"UPDATE t SET a = a*-1 WHERE \"b\" <> `c` -- done",
+		"SELECT /* x */ X'4869', 0b101, B'1' FROM straße",
+		"SELECT 'a\\'b' FROM t WHERE x >= .5 AND y IS NOT NULL",
+		"DATE '2024-01-01'",
+	} {
+		f.Add(query)
+	}
+
+	f.Fuzz(func(t *testing.T, query string) {
+		tokens, err := NewLexer(query).Tokenize()
+		if err != nil {
+			if _, ok := err.(*SyntaxError); !ok {
+				t.Fatalf("expected a syntax error, got %v", err)
+			}
+			return
+		}
+
+		offset := -1
+		for _, token := range tokens {
+			if token.Pos.Offset <= offset || token.Pos.Offset >= len(query) {
+				t.Fatalf("token %+v out of order or outside the query", token)
+			}
+			if token.Pos != positionOf(query, token.Pos.Offset) {
+				t.Fatalf("token %+v has the wrong position", token)
+			}
+			offset = token.Pos.Offset
+		}
+
+		parser := NewParser(tokens)
+		parser.SetInput(query)
+		parser.Parse()
+	})
+}
+
+// validateTokens compares the tokens of the query to the expected ones,
+// ignoring their positions.
+func validateTokens(t *testing.T, query string, expected []Token) {
+	t.Helper()
+
+	tokens, err := NewLexer(query).Tokenize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range tokens {
+		tokens[i].Pos = Position{}
+	}
+	if !reflect.DeepEqual(tokens, expected) {
+		t.Errorf("expected %+v, got %+v", expected, tokens)
+	}
+}
+
+func TestLexer_Tokenize_Case(t *testing.T) {
+	query := `select Name, "Id" from Users where a is not null And b = true`
+	expected := []Token{
+		{Type: KEYWORD, Value: SELECT},
+		{Type: IDENTIFIER, Value: "Name"},
+		{Type: DELIMITER, Value: ","},
+		{Type: IDENTIFIER, Value: "Id", Quoted: true},
+		{Type: KEYWORD, Value: FROM},
+		{Type: IDENTIFIER, Value: "Users"},
+		{Type: KEYWORD, Value: WHERE},
+		{Type: IDENTIFIER, Value: "a"},
+		{Type: KEYWORD, Value: IS},
+		{Type: KEYWORD, Value: NOT},
+		{Type: KEYWORD, Value: NULL},
+		{Type: OPERATOR, Value: AND},
+		{Type: IDENTIFIER, Value: "b"},
+		{Type: OPERATOR, Value: EQUALS},
+		{Type: LITERAL, Value: TRUE},
+	}
+	validateTokens(t, query, expected)
+
+	lexer := NewLexer(query)
+	lexer.IdentifierCase = engine.LowerCase
+	tokens, err := lexer.Tokenize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tokens[1].Value != "name" || tokens[3].Value != "Id" || tokens[5].Value != "users" {
+		t.Errorf("expected unquoted names in lower case, got %v", tokens)
+	}
+
+	validateTokens(t, "values Date '2024-01-01'", []Token{
+		{Type: KEYWORD, Value: VALUES},
+		{Type: LITERAL, Value: "2024-01-01"},
+	})
+}
@@ -1,7 +1,10 @@
 package parser
 
 import (
-	"errors"
+	"dbngin3/engine"
+	"strconv"
+	"strings"
+	"unicode/utf8"
 )
 
 type TokenValidatorParam struct {
@@ -10,6 +13,8 @@ type TokenValidatorParam struct {
 
 type Parser struct {
 	Tokens []Token
+	// Input is the query the tokens were read from, syntax errors quote it.
+	Input string
 }
 
 func NewParser(Tokens []Token) *Parser {
@@ -23,9 +28,14 @@ func (p *Parser) SetToken(tokens []Token) error {
 	return nil
 }
 
+func (p *Parser) SetInput(input string) error {
+	p.Input = input
+	return nil
+}
+
 func (p *Parser) Parse() (ASTNode, error) {
-	if p.Tokens[0].Type != KEYWORD {
-		return nil, errors.New("expected KEYWORD")
+	if len(p.Tokens) == 0 || p.Tokens[0].Type != KEYWORD {
+		return nil, p.expected(0, "statement")
 	}
 
 	var node ASTNode
@@ -33,10 +43,30 @@ func (p *Parser) Parse() (ASTNode, error) {
 
 	if p.Tokens[0].Value == SELECT {
 		node, err = p.parseSelect(p.Tokens)
-	} else if p.Tokens[0].Value == INSERT {
+	} else if p.Tokens[0].Value == INSERT || p.Tokens[0].Value == REPLACE {
 		node, err = p.parseInsert(p.Tokens)
 	} else if p.Tokens[0].Value == UPDATE {
 		node, err = p.parseUpdate(p.Tokens)
+	} else if p.Tokens[0].Value == DELETE {
+		node, err = p.parseDelete()
+	} else if p.Tokens[0].Value == BEGIN || p.Tokens[0].Value == START {
+		node, err = p.parseBegin()
+	} else if p.Tokens[0].Value == COMMIT {
+		node, err = p.parseCommit()
+	} else if p.Tokens[0].Value == ROLLBACK {
+		node, err = p.parseRollback()
+	} else if p.Tokens[0].Value == SAVEPOINT {
+		node, err = p.parseSavepoint()
+	} else if p.Tokens[0].Value == SET {
+		node, err = p.parseSetTransaction()
+	} else if p.Tokens[0].Value == CREATE {
+		node, err = p.parseCreateTable()
+	} else if p.Tokens[0].Value == DROP {
+		node, err = p.parseDropTable()
+	} else if p.Tokens[0].Value == ALTER {
+		node, err = p.parseAlterTable()
+	} else {
+		err = p.errorAt(0, "unsupported statement "+p.Tokens[0].Value)
 	}
 
 	if err != nil {
@@ -53,73 +83,330 @@ func (p *Parser) parseSelect(tokens []Token) (*SelectStatement, error) {
 		return &SelectStatement{}, nil
 	}
 
+	node, err := p.parseQuery(&param)
+	if err != nil {
+		return node, err
+	}
+	return node, p.expectEnd(&param)
+}
+
+// parseQuery parses a SELECT from the token at param, up to the first token
+// that cannot continue it. Subqueries end that way at their parenthesis.
+func (p *Parser) parseQuery(param *TokenValidatorParam) (*SelectStatement, error) {
 	node := &SelectStatement{}
 
 	param.pos++
-	nextShouldDelimiter := false
-	for param.pos < len(p.Tokens) {
+	if p.isOperator(param.pos, WILDCARD) {
+		node.Columns = append(node.Columns, WILDCARD)
+		param.pos++
+	} else {
+		for {
+			expr, err := p.parseExpression(param, lowestPrecedence)
+			if err != nil {
+				return node, err
+			}
 
-		if p.Tokens[param.pos].Type == OPERATOR && p.Tokens[param.pos].Value == WILDCARD {
-			node.Columns = append(node.Columns, p.Tokens[param.pos].Value)
+			name := expr.String()
+			if p.isKeyword(param.pos, AS) {
+				param.pos++
+				if name, err = p.expectIdentifier(param, "alias"); err != nil {
+					return node, err
+				}
+			} else if param.pos < len(p.Tokens) && p.Tokens[param.pos].Type == IDENTIFIER {
+				name = p.Tokens[param.pos].Value
+				param.pos++
+			}
+
+			node.Columns = append(node.Columns, name)
+			node.Expressions = append(node.Expressions, expr)
+
+			if param.pos >= len(p.Tokens) || p.Tokens[param.pos].Type != DELIMITER {
+				break
+			}
 			param.pos++
-			break
 		}
+	}
 
-		if !nextShouldDelimiter && p.Tokens[param.pos].Type != IDENTIFIER {
-			return node, errors.New("expected IDENTIFIER")
+	if p.isKeyword(param.pos, FROM) {
+		param.pos++
+		table, subquery, alias, err := p.parseTableReference(param)
+		if err != nil {
+			return node, err
 		}
+		node.Table, node.Subquery, node.Alias = table, subquery, alias
 
-		if p.Tokens[param.pos].Type != IDENTIFIER && p.Tokens[param.pos].Type != DELIMITER {
-			break
+		if node.Joins, err = p.parseJoins(param); err != nil {
+			return node, err
 		}
+	}
 
-		if p.Tokens[param.pos].Type == IDENTIFIER {
-			if nextShouldDelimiter {
-				return node, errors.New("expected IDENTIFIER")
+	where, err := p.ParseWhere(param)
+	node.Where = where
+	if err != nil {
+		return node, err
+	}
+
+	if p.isKeyword(param.pos, GROUP) {
+		if node.GroupBy, err = p.parseGroupBy(param); err != nil {
+			return node, err
+		}
+	}
+	if p.isKeyword(param.pos, HAVING) {
+		param.pos++
+		if node.Having, err = p.parseExpression(param, lowestPrecedence); err != nil {
+			return node, err
+		}
+	}
+	if p.isKeyword(param.pos, ORDER) {
+		if node.OrderBy, err = p.parseOrderBy(param); err != nil {
+			return node, err
+		}
+	}
+	if p.isKeyword(param.pos, LIMIT) {
+		if err = p.parseLimit(param, node); err != nil {
+			return node, err
+		}
+	}
+
+	return node, nil
+}
+
+// parseTableReference parses table [[AS] alias] or a derived table,
+// (SELECT ...) [AS] alias.
+func (p *Parser) parseTableReference(param *TokenValidatorParam) (string, *SelectStatement, string, error) {
+	if p.isSymbol(param.pos, "(") {
+		subquery, err := p.parseSubquery(param)
+		if err != nil {
+			return "", nil, "", err
+		}
+		if p.isKeyword(param.pos, AS) {
+			param.pos++
+		}
+		alias, err := p.expectIdentifier(param, "alias")
+		return "", subquery, alias, err
+	}
+
+	table, err := p.expectIdentifier(param, "table name")
+	if err != nil {
+		return "", nil, "", err
+	}
+
+	if p.isKeyword(param.pos, AS) {
+		param.pos++
+		alias, err := p.expectIdentifier(param, "alias")
+		return table, nil, alias, err
+	}
+	if param.pos < len(p.Tokens) && p.Tokens[param.pos].Type == IDENTIFIER {
+		param.pos++
+		return table, nil, p.Tokens[param.pos-1].Value, nil
+	}
+	return table, nil, "", nil
+}
+
+// parseSubquery parses (SELECT ...).
+func (p *Parser) parseSubquery(param *TokenValidatorParam) (*SelectStatement, error) {
+	if !p.isSymbol(param.pos, "(") {
+		return nil, p.expected(param.pos, "(")
+	}
+	param.pos++
+	if !p.isKeyword(param.pos, SELECT) {
+		return nil, p.expected(param.pos, SELECT)
+	}
+
+	query, err := p.parseQuery(param)
+	if err != nil {
+		return nil, err
+	}
+	if !p.isSymbol(param.pos, ")") {
+		return nil, p.expected(param.pos, ")")
+	}
+	param.pos++
+	return query, nil
+}
+
+// parseJoins parses the tables joined to the first one of the FROM clause.
+func (p *Parser) parseJoins(param *TokenValidatorParam) ([]Join, error) {
+	var joins []Join
+	for {
+		var join Join
+		comma := param.pos < len(p.Tokens) && p.Tokens[param.pos].Type == DELIMITER
+		switch {
+		case comma:
+			join.Kind = CROSS
+		case p.isKeyword(param.pos, JOIN):
+			join.Kind = INNER
+		case p.isKeyword(param.pos, INNER), p.isKeyword(param.pos, CROSS):
+			join.Kind = p.Tokens[param.pos].Value
+			param.pos++
+		case p.isKeyword(param.pos, LEFT), p.isKeyword(param.pos, RIGHT), p.isKeyword(param.pos, FULL):
+			join.Kind = p.Tokens[param.pos].Value
+			param.pos++
+			if p.isKeyword(param.pos, OUTER) {
+				param.pos++
 			}
+		default:
+			return joins, nil
+		}
 
-			node.Columns = append(node.Columns, p.Tokens[param.pos].Value)
-			nextShouldDelimiter = true
-		} else {
-			if !nextShouldDelimiter {
-				return node, errors.New("expected DELIMITER")
+		if !comma && !p.isKeyword(param.pos, JOIN) {
+			return nil, p.expected(param.pos, JOIN)
+		}
+		param.pos++
+
+		var err error
+		if join.Table, join.Subquery, join.Alias, err = p.parseTableReference(param); err != nil {
+			return nil, err
+		}
+
+		if p.isKeyword(param.pos, ON) {
+			param.pos++
+			if join.On, err = p.parseExpression(param, lowestPrecedence); err != nil {
+				return nil, err
+			}
+		} else if p.isKeyword(param.pos, USING) {
+			if join.Using, err = p.parseUsing(param); err != nil {
+				return nil, err
 			}
+		} else if join.Kind != INNER && join.Kind != CROSS {
+			return nil, p.expected(param.pos, "ON or USING")
+		}
+		joins = append(joins, join)
+	}
+}
 
-			nextShouldDelimiter = false
+// parseUsing parses USING (column, ...).
+func (p *Parser) parseUsing(param *TokenValidatorParam) ([]string, error) {
+	param.pos++
+	if !p.isSymbol(param.pos, "(") {
+		return nil, p.expected(param.pos, "(")
+	}
+	param.pos++
+
+	var columns []string
+	for {
+		column, err := p.expectIdentifier(param, "column name")
+		if err != nil {
+			return nil, err
 		}
+		columns = append(columns, column)
 
+		if param.pos >= len(p.Tokens) || p.Tokens[param.pos].Type != DELIMITER {
+			break
+		}
 		param.pos++
 	}
 
-	if p.Tokens[param.pos].Type == KEYWORD && p.Tokens[param.pos].Value == FROM {
+	if !p.isSymbol(param.pos, ")") {
+		return nil, p.expected(param.pos, ")")
+	}
+	param.pos++
+	return columns, nil
+}
+
+func (p *Parser) parseGroupBy(param *TokenValidatorParam) ([]Expression, error) {
+	param.pos++
+	if !p.isKeyword(param.pos, BY) {
+		return nil, p.expected(param.pos, BY)
+	}
+	param.pos++
+
+	var expressions []Expression
+	for {
+		expr, err := p.parseExpression(param, lowestPrecedence)
+		if err != nil {
+			return nil, err
+		}
+		expressions = append(expressions, expr)
+
+		if param.pos >= len(p.Tokens) || p.Tokens[param.pos].Type != DELIMITER {
+			return expressions, nil
+		}
 		param.pos++
-		if p.Tokens[param.pos].Type != IDENTIFIER {
-			return node, errors.New("expected Table Name")
+	}
+}
+
+func (p *Parser) parseOrderBy(param *TokenValidatorParam) ([]OrderByItem, error) {
+	param.pos++
+	if !p.isKeyword(param.pos, BY) {
+		return nil, p.expected(param.pos, BY)
+	}
+	param.pos++
+
+	var items []OrderByItem
+	for {
+		expr, err := p.parseExpression(param, lowestPrecedence)
+		if err != nil {
+			return nil, err
+		}
+		item := OrderByItem{Expression: expr}
+
+		if p.isKeyword(param.pos, DESC) {
+			item.Desc = true
+			param.pos++
+		} else if p.isKeyword(param.pos, ASC) {
+			param.pos++
+		}
+		item.NullsFirst = !item.Desc
+
+		if p.isKeyword(param.pos, NULLS) {
+			param.pos++
+			switch {
+			case p.isWord(param.pos, FIRST):
+				item.NullsFirst = true
+			case p.isWord(param.pos, LAST):
+				item.NullsFirst = false
+			default:
+				return nil, p.expected(param.pos, "FIRST or LAST")
+			}
+			param.pos++
 		}
+		items = append(items, item)
 
-		node.Table = p.Tokens[param.pos].Value
+		if param.pos >= len(p.Tokens) || p.Tokens[param.pos].Type != DELIMITER {
+			return items, nil
+		}
 		param.pos++
 	}
+}
 
-	whereClause, err := p.ParseWhere(&param)
-	node.WhereClause = whereClause
+// parseLimit parses LIMIT count [OFFSET skip] or LIMIT skip, count.
+func (p *Parser) parseLimit(param *TokenValidatorParam, node *SelectStatement) error {
+	param.pos++
+	count, err := p.expectCount(param, "LIMIT")
 	if err != nil {
-		return node, err
+		return err
 	}
 
-	if param.pos < len(p.Tokens) {
-		if p.Tokens[param.pos].Type != SYMBOL && p.Tokens[param.pos].Value != ";" {
-			return node, errors.New("expected SYMBOL")
+	if param.pos < len(p.Tokens) && p.Tokens[param.pos].Type == DELIMITER {
+		param.pos++
+		node.Offset = count
+		if count, err = p.expectCount(param, "LIMIT"); err != nil {
+			return err
 		}
-
+	} else if p.isKeyword(param.pos, OFFSET) {
 		param.pos++
+		if node.Offset, err = p.expectCount(param, "OFFSET"); err != nil {
+			return err
+		}
 	}
 
-	if param.pos == len(p.Tokens) {
-		return node, nil
+	node.Limit = &count
+	return nil
+}
+
+// expectCount reads the number of rows a LIMIT or OFFSET takes.
+func (p *Parser) expectCount(param *TokenValidatorParam, clause string) (int, error) {
+	if param.pos >= len(p.Tokens) || p.Tokens[param.pos].Type != LITERAL {
+		return 0, p.expected(param.pos, "row count")
 	}
 
-	return node, errors.New("expected EOF")
+	value := p.Tokens[param.pos].Value
+	count, err := strconv.Atoi(value)
+	if err != nil || count < 0 || strings.Trim(value, "0123456789") != "" {
+		return 0, p.errorAt(param.pos, "invalid "+clause+" "+value)
+	}
+	param.pos++
+	return count, nil
 }
 
 func (p *Parser) parseInsert(tokens []Token) (ASTNode, error) {
@@ -127,88 +414,130 @@ func (p *Parser) parseInsert(tokens []Token) (ASTNode, error) {
 
 	param.pos++
 
-	node := &InsertStatement{}
+	node := &InsertStatement{Replace: tokens[0].Value == REPLACE}
 
-	if tokens[param.pos].Type != KEYWORD && tokens[param.pos].Value != INTO {
-		return node, errors.New("expected INTO")
+	if !p.isKeyword(param.pos, INTO) {
+		return node, p.expected(param.pos, INTO)
 	}
 
 	param.pos++
 
-	if tokens[param.pos].Type != IDENTIFIER {
-		return node, errors.New("expected IDENTIFIER")
+	table, err := p.expectIdentifier(&param, "table name")
+	if err != nil {
+		return node, err
 	}
+	node.Table = table
 
-	node.Table = p.Tokens[param.pos].Value
-	param.pos++
-
-	if tokens[param.pos].Type == SYMBOL && tokens[param.pos].Value == "(" {
+	if p.isSymbol(param.pos, "(") {
 		param.pos++
-		nextShouldDelimiter := false
-		for param.pos < len(tokens) {
-			if tokens[param.pos].Type == IDENTIFIER {
-				if nextShouldDelimiter {
-					return node, errors.New("expected IDENTIFIER")
-				}
-
-				node.Columns = append(node.Columns, p.Tokens[param.pos].Value)
-				nextShouldDelimiter = true
-				param.pos++
-			} else if tokens[param.pos].Type == DELIMITER {
-				if !nextShouldDelimiter {
-					return node, errors.New("expected DELIMITER")
-				}
+		for {
+			column, err := p.expectIdentifier(&param, "column name")
+			if err != nil {
+				return node, err
+			}
+			node.Columns = append(node.Columns, column)
 
-				nextShouldDelimiter = false
-				param.pos++
-			} else if tokens[param.pos].Value == ")" {
-				param.pos++
+			if param.pos >= len(tokens) || tokens[param.pos].Type != DELIMITER {
 				break
-			} else {
-				return node, errors.New("expected DELIMITER or SYMBOL")
 			}
+			param.pos++
+		}
+
+		if !p.isSymbol(param.pos, ")") {
+			return node, p.expected(param.pos, ")")
+		}
+		param.pos++
+	}
+
+	if p.isKeyword(param.pos, SELECT) {
+		if node.Select, err = p.parseQuery(&param); err != nil {
+			return node, err
 		}
+		return node, p.parseOnDuplicate(&param, node)
+	}
+
+	if !p.isKeyword(param.pos, VALUES) {
+		return node, p.expected(param.pos, VALUES)
+	}
+	param.pos++
 
-		if tokens[param.pos].Type != KEYWORD && tokens[param.pos].Value != VALUES {
-			return node, errors.New("expected VALUES")
+	for {
+		row, err := p.parseValues(&param)
+		if err != nil {
+			return node, err
 		}
+		node.Rows = append(node.Rows, row)
 
+		if param.pos >= len(tokens) || tokens[param.pos].Type != DELIMITER {
+			break
+		}
 		param.pos++
+	}
 
-		if tokens[param.pos].Type == SYMBOL && tokens[param.pos].Value == "(" {
-			param.pos++
-			nextShouldDelimiter := false
-			for param.pos < len(tokens) {
-				if tokens[param.pos].Type == LITERAL {
-					if nextShouldDelimiter {
-						return node, errors.New("expected LITERAL")
-					}
+	return node, p.parseOnDuplicate(&param, node)
+}
 
-					node.Values = append(node.Values, p.Tokens[param.pos].Value)
-					nextShouldDelimiter = true
-					param.pos++
-				} else if tokens[param.pos].Type == DELIMITER {
-					if !nextShouldDelimiter {
-						return node, errors.New("expected DELIMITER")
-					}
+// parseOnDuplicate parses the optional ON DUPLICATE KEY UPDATE column =
+// expression, ... that ends an INSERT. REPLACE has none.
+func (p *Parser) parseOnDuplicate(param *TokenValidatorParam, node *InsertStatement) error {
+	if node.Replace || !p.isKeyword(param.pos, ON) {
+		return p.expectEnd(param)
+	}
+	param.pos++
 
-					nextShouldDelimiter = false
-					param.pos++
-				} else if tokens[param.pos].Value == ")" {
-					param.pos++
-					break
-				} else {
-					return node, errors.New("expected DELIMITER or SYMBOL")
-				}
-			}
+	if !p.isWord(param.pos, DUPLICATE) {
+		return p.expected(param.pos, DUPLICATE)
+	}
+	param.pos++
+	if !p.isKeyword(param.pos, KEY) {
+		return p.expected(param.pos, KEY)
+	}
+	param.pos++
+	if !p.isKeyword(param.pos, UPDATE) {
+		return p.expected(param.pos, UPDATE)
+	}
+	param.pos++
+
+	sets, err := p.parseAssignments(param)
+	if err != nil {
+		return err
+	}
+	node.OnDuplicate = sets
+	return p.expectEnd(param)
+}
 
-			if param.pos == len(tokens) {
-				return node, nil
+// parseValues parses a row of VALUES, (value, ...), where a value is an
+// expression or DEFAULT, which leaves the expression nil.
+func (p *Parser) parseValues(param *TokenValidatorParam) ([]Expression, error) {
+	if !p.isSymbol(param.pos, "(") {
+		return nil, p.expected(param.pos, "(")
+	}
+	param.pos++
+
+	var row []Expression
+	for {
+		if p.isKeyword(param.pos, DEFAULT) {
+			param.pos++
+			row = append(row, nil)
+		} else {
+			value, err := p.parseExpression(param, lowestPrecedence)
+			if err != nil {
+				return nil, err
 			}
+			row = append(row, value)
+		}
+
+		if param.pos >= len(p.Tokens) || p.Tokens[param.pos].Type != DELIMITER {
+			break
 		}
+		param.pos++
 	}
 
-	return node, errors.New("expected EOF")
+	if !p.isSymbol(param.pos, ")") {
+		return nil, p.expected(param.pos, ")")
+	}
+	param.pos++
+	return row, nil
 }
 
 func (p *Parser) parseUpdate(tokens []Token) (ASTNode, error) {
@@ -217,43 +546,59 @@ func (p *Parser) parseUpdate(tokens []Token) (ASTNode, error) {
 
 	node := &UpdateStatement{}
 
-	if tokens[param.pos].Type != IDENTIFIER {
-		return node, errors.New("expected IDENTIFIER")
+	if param.pos >= len(tokens) || tokens[param.pos].Type != IDENTIFIER {
+		return node, p.expected(param.pos, "table name")
 	}
 
 	node.Table = p.Tokens[param.pos].Value
 	param.pos++
 
-	if tokens[param.pos].Type != KEYWORD && tokens[param.pos].Value != SET {
-		return node, errors.New("expected SET")
+	if !p.isKeyword(param.pos, SET) {
+		return node, p.expected(param.pos, SET)
 	}
 
-	sets := map[string]string{}
-
 	param.pos++
-	for param.pos < len(tokens) {
-		if tokens[param.pos].Type != IDENTIFIER {
-			return node, errors.New("expected IDENTIFIER")
+	sets, err := p.parseAssignments(&param)
+	if err != nil {
+		return node, err
+	}
+	node.Set = sets
+
+	where, err := p.ParseWhere(&param)
+	node.Where = where
+	if err != nil {
+		return node, err
+	}
+
+	return node, p.expectEnd(&param)
+}
+
+// parseAssignments parses column = expression, ... as in UPDATE ... SET.
+func (p *Parser) parseAssignments(param *TokenValidatorParam) (map[string]Expression, error) {
+	sets := map[string]Expression{}
+
+	for param.pos < len(p.Tokens) {
+		if p.Tokens[param.pos].Type != IDENTIFIER {
+			return nil, p.expected(param.pos, "column name")
 		}
 
-		column := tokens[param.pos].Value
+		column := p.Tokens[param.pos].Value
 		param.pos++
 
-		if tokens[param.pos].Type == OPERATOR && tokens[param.pos].Value != EQUALS {
-			return node, errors.New("expected EQUALS")
+		if !p.isOperator(param.pos, EQUALS) {
+			return nil, p.expected(param.pos, EQUALS)
 		}
 
 		param.pos++
 
-		if tokens[param.pos].Type != LITERAL {
-			return node, errors.New("expected LITERAL")
+		expr, err := p.parseExpression(param, lowestPrecedence)
+		if err != nil {
+			return nil, err
 		}
 
-		value := tokens[param.pos].Value
-		sets[column] = value
-		param.pos++
+		sets[column] = expr
 
-		if tokens[param.pos].Type == DELIMITER && tokens[param.pos].Value == "," {
+		if param.pos < len(p.Tokens) && p.Tokens[param.pos].Type == DELIMITER && p.Tokens[param.pos].Value == "," {
 			param.pos++
 		} else {
 			break
@@ -261,126 +606,691 @@ func (p *Parser) parseUpdate(tokens []Token) (ASTNode, error) {
 	}
 
 	if len(sets) == 0 {
-		return node, errors.New("expected SET")
+		return nil, p.expected(param.pos, "column name")
 	}
+	return sets, nil
+}
 
-	node.Set = sets
+func (p *Parser) parseDelete() (ASTNode, error) {
+	param := TokenValidatorParam{pos: 1}
+	node := &DeleteStatement{}
 
-	whereClause, err := p.ParseWhere(&param)
-	node.WhereClause = whereClause
+	if !p.isKeyword(param.pos, FROM) {
+		return node, p.expected(param.pos, FROM)
+	}
+	param.pos++
+
+	table, err := p.expectIdentifier(&param, "table name")
 	if err != nil {
 		return node, err
 	}
+	node.Table = table
 
-	if param.pos < len(p.Tokens) {
-		if p.Tokens[param.pos].Type != SYMBOL && p.Tokens[param.pos].Value != ";" {
-			return node, errors.New("expected SYMBOL")
-		}
+	where, err := p.ParseWhere(&param)
+	node.Where = where
+	if err != nil {
+		return node, err
+	}
+
+	return node, p.expectEnd(&param)
+}
 
+func (p *Parser) parseBegin() (ASTNode, error) {
+	param := TokenValidatorParam{pos: 0}
+
+	if p.Tokens[param.pos].Value == START {
+		param.pos++
+		if !p.isKeyword(param.pos, TRANSACTION) {
+			return nil, p.expected(param.pos, TRANSACTION)
+		}
+		param.pos++
+	} else {
 		param.pos++
+		if p.isKeyword(param.pos, TRANSACTION) || p.isKeyword(param.pos, WORK) {
+			param.pos++
+		}
 	}
 
-	if param.pos == len(p.Tokens) {
-		return node, nil
+	return &BeginStatement{}, p.expectEnd(&param)
+}
+
+func (p *Parser) parseCommit() (ASTNode, error) {
+	param := TokenValidatorParam{pos: 1}
+
+	if p.isKeyword(param.pos, TRANSACTION) || p.isKeyword(param.pos, WORK) {
+		param.pos++
 	}
 
-	return node, errors.New("expected EOF")
+	return &CommitStatement{}, p.expectEnd(&param)
 }
 
-func (p *Parser) ParseWhere(param *TokenValidatorParam) (*WhereClause, error) {
-	var root *WhereClause
+func (p *Parser) parseRollback() (ASTNode, error) {
+	param := TokenValidatorParam{pos: 1}
+	node := &RollbackStatement{}
 
-	if param.pos >= len(p.Tokens) {
-		return root, nil
+	if p.isKeyword(param.pos, TRANSACTION) || p.isKeyword(param.pos, WORK) {
+		param.pos++
 	}
 
-	if p.Tokens[param.pos].Type != KEYWORD && p.Tokens[param.pos].Value != WHERE {
-		return root, nil
+	if p.isKeyword(param.pos, TO) {
+		param.pos++
+		if p.isKeyword(param.pos, SAVEPOINT) {
+			param.pos++
+		}
+
+		if param.pos >= len(p.Tokens) || p.Tokens[param.pos].Type != IDENTIFIER {
+			return node, p.expected(param.pos, "savepoint name")
+		}
+
+		node.Savepoint = p.Tokens[param.pos].Value
+		param.pos++
 	}
 
+	return node, p.expectEnd(&param)
+}
+
+func (p *Parser) parseSavepoint() (ASTNode, error) {
+	param := TokenValidatorParam{pos: 1}
+	node := &SavepointStatement{}
+
+	if param.pos >= len(p.Tokens) || p.Tokens[param.pos].Type != IDENTIFIER {
+		return node, p.expected(param.pos, "savepoint name")
+	}
+
+	node.Name = p.Tokens[param.pos].Value
 	param.pos++
 
-	root = p.parseWhereByToken(p.Tokens[param.pos:])
+	return node, p.expectEnd(&param)
+}
 
-	for param.pos < len(p.Tokens) {
-		if p.Tokens[param.pos].Type == KEYWORD {
-			break
+func (p *Parser) parseSetTransaction() (ASTNode, error) {
+	param := TokenValidatorParam{pos: 1}
+	node := &SetTransactionStatement{}
+
+	if p.isKeyword(param.pos, SESSION) {
+		node.Session = true
+		param.pos++
+	}
+
+	for _, keyword := range []string{TRANSACTION, ISOLATION, LEVEL} {
+		if !p.isKeyword(param.pos, keyword) {
+			return node, p.expected(param.pos, keyword)
 		}
 		param.pos++
 	}
 
-	return root, nil
+	switch {
+	case p.isKeyword(param.pos, READ) && p.isKeyword(param.pos+1, COMMITTED):
+		node.Isolation = READ + " " + COMMITTED
+		param.pos += 2
+	case p.isKeyword(param.pos, REPEATABLE) && p.isKeyword(param.pos+1, READ):
+		node.Isolation = REPEATABLE + " " + READ
+		param.pos += 2
+	case p.isKeyword(param.pos, SNAPSHOT):
+		node.Isolation = SNAPSHOT
+		param.pos++
+	default:
+		return node, p.expected(param.pos, "isolation level")
+	}
+
+	return node, p.expectEnd(&param)
 }
 
-func (p *Parser) parseWhereByToken(tokens []Token) *WhereClause {
-	if len(tokens) == 3 {
-		return &WhereClause{
-			Left:  &WhereClause{Name: tokens[0].Value},
-			Type:  tokens[1].Value,
-			Right: &WhereClause{Value: tokens[2].Value},
+func (p *Parser) parseCreateTable() (ASTNode, error) {
+	param := TokenValidatorParam{pos: 1}
+	node := &CreateTableStatement{}
+
+	if !p.isKeyword(param.pos, TABLE) {
+		return node, p.expected(param.pos, TABLE)
+	}
+	param.pos++
+
+	if p.isKeyword(param.pos, IF) {
+		if !p.isKeyword(param.pos+1, NOT) {
+			return node, p.expected(param.pos+1, NOT)
 		}
+		if !p.isKeyword(param.pos+2, EXISTS) {
+			return node, p.expected(param.pos+2, EXISTS)
+		}
+		node.IfNotExists = true
+		param.pos += 3
+	}
+
+	table, err := p.expectIdentifier(&param, "table name")
+	if err != nil {
+		return node, err
+	}
+	node.Table = table
+
+	if !p.isSymbol(param.pos, "(") {
+		return node, p.expected(param.pos, "(")
 	}
+	param.pos++
 
-	for i := len(tokens) - 2; i > 0; i -= 2 {
-		if tokens[i].Value == AND || tokens[i].Value == OR {
-			return &WhereClause{
-				Type:  tokens[i].Value,
-				Left:  p.parseWhereByToken(tokens[:i]),
-				Right: p.parseWhereByToken(tokens[i+1:]),
+	for {
+		if p.isKeyword(param.pos, PRIMARY) || p.isKeyword(param.pos, UNIQUE) {
+			if err = p.parseTableConstraint(&param, node); err != nil {
+				return node, err
 			}
+		} else {
+			column, err := p.parseColumnDefinition(&param)
+			if err != nil {
+				return node, err
+			}
+			node.Columns = append(node.Columns, column)
+		}
+
+		if param.pos < len(p.Tokens) && p.Tokens[param.pos].Type == DELIMITER {
+			param.pos++
+			continue
 		}
+		break
 	}
 
-	return nil
+	if !p.isSymbol(param.pos, ")") {
+		return node, p.expected(param.pos, ")")
+	}
+	param.pos++
+
+	return node, p.expectEnd(&param)
 }
 
-func (p *Parser) parseWhere(param *TokenValidatorParam) (WhereClause, error) {
-	root := WhereClause{}
+func (p *Parser) parseColumnDefinition(param *TokenValidatorParam) (ColumnDefinition, error) {
+	name, err := p.expectIdentifier(param, "column name")
+	if err != nil {
+		return ColumnDefinition{}, err
+	}
+	column := ColumnDefinition{Name: name}
 
-	if param.pos < len(p.Tokens) && p.Tokens[param.pos].Type == KEYWORD && p.Tokens[param.pos].Value == WHERE {
-		param.pos++
+	if param.pos >= len(p.Tokens) || p.Tokens[param.pos].Type != IDENTIFIER {
+		return column, p.expected(param.pos, "data type of column "+name)
+	}
+	column.Type = p.Tokens[param.pos].Value
+	param.pos++
 
-		var currentClause *WhereClause
-		for param.pos < len(p.Tokens) {
-			if p.Tokens[param.pos].Type != IDENTIFIER {
-				return root, errors.New("expected IDENTIFIER")
+	if p.isSymbol(param.pos, "(") {
+		if param.pos+1 >= len(p.Tokens) || p.Tokens[param.pos+1].Type != LITERAL {
+			return column, p.expected(param.pos+1, "length of column "+name)
+		}
+		if column.Length, err = strconv.Atoi(p.Tokens[param.pos+1].Value); err != nil || column.Length <= 0 {
+			return column, p.errorAt(param.pos+1, "invalid length of column "+name)
+		}
+		param.pos += 2
+
+		if param.pos+1 < len(p.Tokens) && p.Tokens[param.pos].Type == DELIMITER && p.Tokens[param.pos+1].Type == LITERAL {
+			if column.Scale, err = strconv.Atoi(p.Tokens[param.pos+1].Value); err != nil || column.Scale < 0 {
+				return column, p.errorAt(param.pos+1, "invalid scale of column "+name)
 			}
+			param.pos += 2
+		}
+		if !p.isSymbol(param.pos, ")") {
+			return column, p.expected(param.pos, ")")
+		}
+		param.pos++
+	}
 
-			newClause := &WhereClause{Name: p.Tokens[param.pos].Value}
-			if currentClause == nil {
-				currentClause.Right = newClause
+	for {
+		switch {
+		case p.isKeyword(param.pos, PRIMARY):
+			if !p.isKeyword(param.pos+1, KEY) {
+				return column, p.expected(param.pos+1, KEY)
+			}
+			column.PrimaryKey = true
+			param.pos += 2
+		case p.isKeyword(param.pos, UNIQUE):
+			column.Unique = true
+			param.pos++
+			if p.isKeyword(param.pos, KEY) {
+				param.pos++
+			}
+		case p.isKeyword(param.pos, NOT):
+			if !p.isKeyword(param.pos+1, NULL) {
+				return column, p.expected(param.pos+1, NULL)
+			}
+			column.NotNull = true
+			param.pos += 2
+		case p.isKeyword(param.pos, NULL):
+			param.pos++
+		case p.isKeyword(param.pos, DEFAULT):
+			param.pos++
+			if !p.isValue(param.pos) {
+				return column, p.expected(param.pos, "default value of column "+name)
 			}
+			value := p.value(param.pos)
 			param.pos++
+			column.Default = &value
+		default:
+			return column, nil
+		}
+	}
+}
 
-			if p.Tokens[param.pos].Type != OPERATOR && p.Tokens[param.pos].Value != EQUALS {
-				return root, errors.New("expected EQUALS")
+// parseTableConstraint parses PRIMARY KEY (column) or UNIQUE [KEY] (column)
+// and marks the column, which has to be defined before.
+func (p *Parser) parseTableConstraint(param *TokenValidatorParam, node *CreateTableStatement) error {
+	primaryKey := p.isKeyword(param.pos, PRIMARY)
+	if primaryKey && !p.isKeyword(param.pos+1, KEY) {
+		return p.expected(param.pos+1, KEY)
+	}
+	param.pos++
+	if p.isKeyword(param.pos, KEY) {
+		param.pos++
+	}
+
+	if !p.isSymbol(param.pos, "(") {
+		return p.expected(param.pos, "(")
+	}
+	param.pos++
+
+	namePos := param.pos
+	name, err := p.expectIdentifier(param, "column name")
+	if err != nil {
+		return err
+	}
+	if param.pos < len(p.Tokens) && p.Tokens[param.pos].Type == DELIMITER {
+		return p.errorAt(param.pos, "constraints over several columns are not supported")
+	}
+	if !p.isSymbol(param.pos, ")") {
+		return p.expected(param.pos, ")")
+	}
+	param.pos++
+
+	for i := range node.Columns {
+		if strings.EqualFold(node.Columns[i].Name, name) {
+			if primaryKey {
+				node.Columns[i].PrimaryKey = true
+			} else {
+				node.Columns[i].Unique = true
 			}
+			return nil
+		}
+	}
+	return p.errorAt(namePos, "column "+name+" of constraint is not defined")
+}
+
+func (p *Parser) parseAlterTable() (ASTNode, error) {
+	param := TokenValidatorParam{pos: 1}
+	node := &AlterTableStatement{}
 
-			root.Type = p.Tokens[param.pos].Value
+	if !p.isKeyword(param.pos, TABLE) {
+		return node, p.expected(param.pos, TABLE)
+	}
+	param.pos++
+
+	table, err := p.expectIdentifier(&param, "table name")
+	if err != nil {
+		return node, err
+	}
+	node.Table = table
+
+	for {
+		action, err := p.parseAlterAction(&param)
+		if err != nil {
+			return node, err
+		}
+		node.Actions = append(node.Actions, action)
+
+		if param.pos < len(p.Tokens) && p.Tokens[param.pos].Type == DELIMITER {
 			param.pos++
+			continue
+		}
+		break
+	}
 
-			if p.Tokens[param.pos].Type != LITERAL {
-				return root, errors.New("expected LITERAL")
+	return node, p.expectEnd(&param)
+}
+
+func (p *Parser) parseAlterAction(param *TokenValidatorParam) (ASTNode, error) {
+	switch {
+	case p.isKeyword(param.pos, ADD):
+		param.pos++
+		if p.isKeyword(param.pos, COLUMN) {
+			param.pos++
+		}
+		column, err := p.parseColumnDefinition(param)
+		return &AddColumnAction{Column: column}, err
+	case p.isKeyword(param.pos, DROP):
+		param.pos++
+		if p.isKeyword(param.pos, COLUMN) {
+			param.pos++
+		}
+		column, err := p.expectIdentifier(param, "column name")
+		return &DropColumnAction{Column: column}, err
+	case p.isKeyword(param.pos, MODIFY):
+		param.pos++
+		if p.isKeyword(param.pos, COLUMN) {
+			param.pos++
+		}
+		column, err := p.parseColumnDefinition(param)
+		return &ModifyColumnAction{Column: column}, err
+	case p.isKeyword(param.pos, RENAME) && p.isKeyword(param.pos+1, COLUMN):
+		param.pos += 2
+		action := &RenameColumnAction{}
+		var err error
+		if action.Column, err = p.expectIdentifier(param, "column name"); err != nil {
+			return action, err
+		}
+		if !p.isKeyword(param.pos, TO) {
+			return action, p.expected(param.pos, TO)
+		}
+		param.pos++
+		action.NewName, err = p.expectIdentifier(param, "new column name")
+		return action, err
+	case p.isKeyword(param.pos, RENAME):
+		param.pos++
+		if p.isKeyword(param.pos, TO) || p.isKeyword(param.pos, AS) {
+			param.pos++
+		}
+		name, err := p.expectIdentifier(param, "new table name")
+		return &RenameTableAction{NewName: name}, err
+	}
+	return nil, p.expected(param.pos, "ADD, DROP, MODIFY or RENAME")
+}
+
+func (p *Parser) parseDropTable() (ASTNode, error) {
+	param := TokenValidatorParam{pos: 1}
+	node := &DropTableStatement{}
+
+	if !p.isKeyword(param.pos, TABLE) {
+		return node, p.expected(param.pos, TABLE)
+	}
+	param.pos++
+
+	if p.isKeyword(param.pos, IF) {
+		if !p.isKeyword(param.pos+1, EXISTS) {
+			return node, p.expected(param.pos+1, EXISTS)
+		}
+		node.IfExists = true
+		param.pos += 2
+	}
+
+	table, err := p.expectIdentifier(&param, "table name")
+	if err != nil {
+		return node, err
+	}
+	node.Table = table
+
+	return node, p.expectEnd(&param)
+}
+
+func (p *Parser) expectIdentifier(param *TokenValidatorParam, what string) (string, error) {
+	if param.pos >= len(p.Tokens) || p.Tokens[param.pos].Type != IDENTIFIER {
+		return "", p.expected(param.pos, what)
+	}
+	param.pos++
+	return p.Tokens[param.pos-1].Value, nil
+}
+
+// expected returns the syntax error for the token at pos, or for the end of
+// the query, not being what the statement needs there.
+func (p *Parser) expected(pos int, what string) error {
+	err := &SyntaxError{Query: p.Input, Pos: p.position(pos), Expected: what}
+	if pos < len(p.Tokens) {
+		err.Found = p.Tokens[pos].Value
+	}
+	return err
+}
+
+func (p *Parser) errorAt(pos int, message string) error {
+	return &SyntaxError{Query: p.Input, Pos: p.position(pos), Message: message}
+}
+
+// position is where the token at pos starts. Past the last token it is the
+// end of the query.
+func (p *Parser) position(pos int) Position {
+	if pos < len(p.Tokens) {
+		return p.Tokens[pos].Pos
+	}
+	if p.Input != "" {
+		return positionOf(p.Input, len(strings.TrimRight(p.Input, " \t\r\n")))
+	}
+	if len(p.Tokens) == 0 {
+		return Position{Line: 1, Column: 1}
+	}
+
+	last := p.Tokens[len(p.Tokens)-1]
+	return Position{
+		Offset: last.Pos.Offset + len(last.Value),
+		Line:   last.Pos.Line,
+		Column: last.Pos.Column + utf8.RuneCountInString(last.Value),
+	}
+}
+
+func (p *Parser) isSymbol(pos int, symbol string) bool {
+	return pos < len(p.Tokens) && p.Tokens[pos].Type == SYMBOL && p.Tokens[pos].Value == symbol
+}
+
+func (p *Parser) isOperator(pos int, operator string) bool {
+	return pos < len(p.Tokens) && p.Tokens[pos].Type == OPERATOR && p.Tokens[pos].Value == operator
+}
+
+// isValue reports whether the token is a literal or NULL.
+func (p *Parser) isValue(pos int) bool {
+	return pos < len(p.Tokens) && (p.Tokens[pos].Type == LITERAL || p.isKeyword(pos, NULL))
+}
+
+// value returns the value of a literal or NULL token.
+func (p *Parser) value(pos int) string {
+	if p.isKeyword(pos, NULL) {
+		return engine.Null
+	}
+	return p.Tokens[pos].Value
+}
+
+// isWord reports whether the token is the unquoted word, in any case. Words
+// that are no keywords only mean something in one place and can be names
+// everywhere else.
+func (p *Parser) isWord(pos int, word string) bool {
+	return pos < len(p.Tokens) && p.Tokens[pos].Type == IDENTIFIER && !p.Tokens[pos].Quoted && strings.EqualFold(p.Tokens[pos].Value, word)
+}
+
+func (p *Parser) isKeyword(pos int, keyword string) bool {
+	return pos < len(p.Tokens) && p.Tokens[pos].Type == KEYWORD && p.Tokens[pos].Value == keyword
+}
+
+// expectEnd accepts an optional trailing semicolon and fails if any other
+// token is left.
+func (p *Parser) expectEnd(param *TokenValidatorParam) error {
+	if param.pos < len(p.Tokens) && p.Tokens[param.pos].Type == SYMBOL && p.Tokens[param.pos].Value == ";" {
+		param.pos++
+	}
+
+	if param.pos != len(p.Tokens) {
+		return p.expected(param.pos, "end of query")
+	}
+	return nil
+}
+
+// ParseWhere parses an optional WHERE clause.
+func (p *Parser) ParseWhere(param *TokenValidatorParam) (Expression, error) {
+	if !p.isKeyword(param.pos, WHERE) {
+		return nil, nil
+	}
+	param.pos++
+
+	return p.parseExpression(param, lowestPrecedence)
+}
+
+// parseExpression parses an expression by precedence climbing: it reads an
+// operand, then folds the operators that follow into it for as long as they
+// bind tighter than the given precedence. The right operand of an operator
+// is parsed with the precedence of the operator, which makes all of them
+// left associative.
+func (p *Parser) parseExpression(param *TokenValidatorParam, precedence int) (Expression, error) {
+	left, err := p.parseOperand(param)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		if p.isKeyword(param.pos, IS) {
+			if comparisonPrecedence <= precedence {
+				return left, nil
 			}
+			param.pos++
 
-			root.Right = &WhereClause{Name: p.Tokens[param.pos].Value}
+			expr := &IsNullExpression{Operand: left}
+			if p.isKeyword(param.pos, NOT) {
+				expr.Not = true
+				param.pos++
+			}
+			if !p.isKeyword(param.pos, NULL) {
+				return nil, p.expected(param.pos, NULL)
+			}
 			param.pos++
+			left = expr
+			continue
+		}
 
-			if param.pos < len(p.Tokens) {
-				if p.Tokens[param.pos].Type == OPERATOR {
-					if p.Tokens[param.pos].Value != AND && p.Tokens[param.pos].Value != OR {
-						break
-					}
+		if p.isKeyword(param.pos, IN) || p.isKeyword(param.pos, NOT) && p.isKeyword(param.pos+1, IN) {
+			if comparisonPrecedence <= precedence {
+				return left, nil
+			}
 
-					param.pos++
-				} else {
-					break
-				}
+			expr := &InExpression{Operand: left, Not: p.isKeyword(param.pos, NOT)}
+			if expr.Not {
+				param.pos++
+			}
+			param.pos++
+			if expr.Select, err = p.parseSubquery(param); err != nil {
+				return nil, err
 			}
+			left = expr
+			continue
+		}
+
+		if param.pos >= len(p.Tokens) || p.Tokens[param.pos].Type != OPERATOR {
+			return left, nil
+		}
+		operator := p.Tokens[param.pos].Value
+		next := binaryPrecedence(operator)
+		if next <= precedence {
+			return left, nil
+		}
+		param.pos++
+
+		right, err := p.parseExpression(param, next)
+		if err != nil {
+			return nil, err
+		}
+		if operator == LESS_MORE_THAN {
+			operator = NOT_EQUALS
+		}
+		left = &BinaryExpression{Operator: operator, Left: left, Right: right}
+	}
+}
+
+// parseOperand parses NOT or unary minus with their operand, a parenthesized
+// expression, a subquery, EXISTS, a literal, NULL, a function call or a
+// column.
+func (p *Parser) parseOperand(param *TokenValidatorParam) (Expression, error) {
+	switch {
+	case p.isSymbol(param.pos, "(") && p.isKeyword(param.pos+1, SELECT):
+		query, err := p.parseSubquery(param)
+		if err != nil {
+			return nil, err
+		}
+		return &SubqueryExpression{Select: query}, nil
+	case p.isKeyword(param.pos, EXISTS):
+		param.pos++
+		query, err := p.parseSubquery(param)
+		if err != nil {
+			return nil, err
+		}
+		return &ExistsExpression{Select: query}, nil
+	case p.isKeyword(param.pos, VALUES) && p.isSymbol(param.pos+1, "("):
+		param.pos += 2
+		column, err := p.expectIdentifier(param, "column name")
+		if err != nil {
+			return nil, err
+		}
+		if !p.isSymbol(param.pos, ")") {
+			return nil, p.expected(param.pos, ")")
+		}
+		param.pos++
+		return &InsertValue{Column: column}, nil
+	case p.isKeyword(param.pos, NOT), p.isOperator(param.pos, MINUS):
+		operator, precedence := NOT, notPrecedence
+		if p.Tokens[param.pos].Value == MINUS {
+			operator, precedence = MINUS, unaryPrecedence
+		}
+		param.pos++
+
+		operand, err := p.parseExpression(param, precedence)
+		if err != nil {
+			return nil, err
+		}
+		return &UnaryExpression{Operator: operator, Operand: operand}, nil
+	case p.isSymbol(param.pos, "("):
+		param.pos++
+		expr, err := p.parseExpression(param, lowestPrecedence)
+		if err != nil {
+			return nil, err
+		}
+		if !p.isSymbol(param.pos, ")") {
+			return nil, p.expected(param.pos, ")")
+		}
+		param.pos++
+		return expr, nil
+	case p.isValue(param.pos):
+		param.pos++
+		return &Literal{Value: p.value(param.pos - 1), Binary: p.Tokens[param.pos-1].Binary}, nil
+	case param.pos < len(p.Tokens) && p.Tokens[param.pos].Type == IDENTIFIER:
+		if !p.Tokens[param.pos].Quoted && p.isSymbol(param.pos+1, "(") {
+			return p.parseFunction(param)
+		}
+		param.pos++
+		if !p.isSymbol(param.pos, ".") {
+			return &ColumnRef{Name: p.Tokens[param.pos-1].Value}, nil
 		}
+
+		ref := &ColumnRef{Table: p.Tokens[param.pos-1].Value}
+		param.pos++
+		name, err := p.expectIdentifier(param, "column name")
+		ref.Name = name
+		return ref, err
 	}
+	return nil, p.expected(param.pos, "expression")
+}
 
-	return root, nil
+// parseFunction parses name([DISTINCT] expression) or COUNT(*). The only
+// functions are the aggregate ones.
+func (p *Parser) parseFunction(param *TokenValidatorParam) (Expression, error) {
+	name := strings.ToUpper(p.Tokens[param.pos].Value)
+	if !IsAggregateFunction(name) {
+		return nil, p.errorAt(param.pos, "unknown function "+p.Tokens[param.pos].Value)
+	}
+	param.pos += 2
+
+	expr := &AggregateExpression{Function: name}
+	if p.isKeyword(param.pos, DISTINCT) {
+		expr.Distinct = true
+		param.pos++
+	}
+
+	if name == COUNT && !expr.Distinct && p.isOperator(param.pos, WILDCARD) {
+		param.pos++
+	} else {
+		argument, err := p.parseExpression(param, lowestPrecedence)
+		if err != nil {
+			return nil, err
+		}
+		expr.Argument = argument
+	}
+
+	if !p.isSymbol(param.pos, ")") {
+		return nil, p.expected(param.pos, ")")
+	}
+	param.pos++
+	return expr, nil
+}
+
+func isComparison(operator string) bool {
+	switch operator {
+	case EQUALS, NOT_EQUALS, LESS_MORE_THAN, LESS_THAN, LESS_THAN_EQUALS, MORE_THAN, MORE_THAN_EQUALS:
+		return true
+	}
+	return false
 }
 
 func (p *Parser) ValidateTokens() bool {
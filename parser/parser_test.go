@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"dbngin3/engine"
 	"reflect"
 	"testing"
 )
@@ -218,13 +219,13 @@ func TestParser_Parse_SelectQueryWithWhereClause(t *testing.T) {
 			t.Errorf("expected table %v, got %v", expectedTable, selectStmt.Table)
 		}
 
-		whereClauseTest := WhereClause{
-			Type:  EQUALS,
-			Left:  &WhereClause{Name: "id"},
-			Right: &WhereClause{Value: "1"},
+		whereClauseTest := &BinaryExpression{
+			Operator: EQUALS,
+			Left:     &ColumnRef{Name: "id"},
+			Right:    &Literal{Value: "1"},
 		}
 
-		validateWhereNode(whereClauseTest, *selectStmt.WhereClause, t)
+		validateWhereNode(whereClauseTest, selectStmt.Where, t)
 	})
 }
 
@@ -267,21 +268,21 @@ func TestParser_Parse_SelectQueryWithMultipleAndWhereClauses(t *testing.T) {
 			t.Errorf("expected table %v, got %v", expectedTable, selectStmt.Table)
 		}
 
-		whereClauseTests := WhereClause{
-			Type: AND,
-			Left: &WhereClause{
-				Type:  EQUALS,
-				Left:  &WhereClause{Name: "id"},
-				Right: &WhereClause{Name: "1"},
+		whereClauseTests := &BinaryExpression{
+			Operator: AND,
+			Left: &BinaryExpression{
+				Operator: EQUALS,
+				Left:     &ColumnRef{Name: "name"},
+				Right:    &Literal{Value: "marty"},
 			},
-			Right: &WhereClause{
-				Type:  EQUALS,
-				Left:  &WhereClause{Name: "age"},
-				Right: &WhereClause{Name: "18"},
+			Right: &BinaryExpression{
+				Operator: EQUALS,
+				Left:     &ColumnRef{Name: "age"},
+				Right:    &Literal{Value: "18"},
 			},
 		}
 
-		validateWhereNode(whereClauseTests, *selectStmt.WhereClause, t)
+		validateWhereNode(whereClauseTests, selectStmt.Where, t)
 	})
 }
 
@@ -324,21 +325,21 @@ func TestParser_Parse_SelectQueryWithWhereClauseOrOperator(t *testing.T) {
 			t.Errorf("expected table %v, got %v", expectedTable, selectStmt.Table)
 		}
 
-		whereClauseTests := WhereClause{
-			Type: OR,
-			Left: &WhereClause{
-				Type:  EQUALS,
-				Left:  &WhereClause{Name: "name"},
-				Right: &WhereClause{Name: "marty"},
+		whereClauseTests := &BinaryExpression{
+			Operator: OR,
+			Left: &BinaryExpression{
+				Operator: EQUALS,
+				Left:     &ColumnRef{Name: "name"},
+				Right:    &Literal{Value: "marty"},
 			},
-			Right: &WhereClause{
-				Type:  EQUALS,
-				Left:  &WhereClause{Name: "age"},
-				Right: &WhereClause{Name: "18"},
+			Right: &BinaryExpression{
+				Operator: EQUALS,
+				Left:     &ColumnRef{Name: "age"},
+				Right:    &Literal{Value: "18"},
 			},
 		}
 
-		validateWhereNode(whereClauseTests, *selectStmt.WhereClause, t)
+		validateWhereNode(whereClauseTests, selectStmt.Where, t)
 	})
 }
 
@@ -386,13 +387,90 @@ func TestParser_Parse_SimpleInsertQuery(t *testing.T) {
 			t.Errorf("expected table %v, got %v", expectedTable, insertStmt.Table)
 		}
 
-		expectedValues := []string{"1", "marty", "18"}
-		if !reflect.DeepEqual(insertStmt.Values, expectedValues) {
-			t.Errorf("expected column %v, got %v", expectedValues, insertStmt.Values)
+		expectedRows := [][]Expression{{&Literal{Value: "1"}, &Literal{Value: "marty"}, &Literal{Value: "18"}}}
+		if !reflect.DeepEqual(insertStmt.Rows, expectedRows) {
+			t.Errorf("expected rows %v, got %v", expectedRows, insertStmt.Rows)
 		}
 	})
 }
 
+func TestParser_Parse_Inserts(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected *InsertStatement
+	}{
+		{
+			"INSERT INTO users VALUES (1, 'a', 2 * 3), (2, DEFAULT, NULL);",
+			&InsertStatement{
+				Table: "users",
+				Rows: [][]Expression{
+					{&Literal{Value: "1"}, &Literal{Value: "a"}, &BinaryExpression{Operator: MULTIPLY, Left: &Literal{Value: "2"}, Right: &Literal{Value: "3"}}},
+					{&Literal{Value: "2"}, nil, &Literal{Value: engine.Null}},
+				},
+			},
+		},
+		{
+			"INSERT INTO users (id, name) SELECT id, name FROM people WHERE id > 1",
+			&InsertStatement{
+				Table:   "users",
+				Columns: []string{"id", "name"},
+				Select: &SelectStatement{
+					Columns:     []string{"id", "name"},
+					Expressions: []Expression{&ColumnRef{Name: "id"}, &ColumnRef{Name: "name"}},
+					Table:       "people",
+					Where:       &BinaryExpression{Operator: MORE_THAN, Left: &ColumnRef{Name: "id"}, Right: &Literal{Value: "1"}},
+				},
+			},
+		},
+		{
+			"REPLACE INTO users (id, name) VALUES (1, 'a')",
+			&InsertStatement{
+				Table:   "users",
+				Columns: []string{"id", "name"},
+				Rows:    [][]Expression{{&Literal{Value: "1"}, &Literal{Value: "a"}}},
+				Replace: true,
+			},
+		},
+		{
+			"INSERT INTO users VALUES (1, 'a') ON DUPLICATE KEY UPDATE name = VALUES(name), visits = visits + 1",
+			&InsertStatement{
+				Table: "users",
+				Rows:  [][]Expression{{&Literal{Value: "1"}, &Literal{Value: "a"}}},
+				OnDuplicate: map[string]Expression{
+					"name":   &InsertValue{Column: "name"},
+					"visits": &BinaryExpression{Operator: PLUS, Left: &ColumnRef{Name: "visits"}, Right: &Literal{Value: "1"}},
+				},
+			},
+		},
+		{
+			"INSERT INTO users SELECT * FROM people on duplicate key update name = 'x';",
+			&InsertStatement{
+				Table:       "users",
+				Select:      &SelectStatement{Columns: []string{"*"}, Table: "people"},
+				OnDuplicate: map[string]Expression{"name": &Literal{Value: "x"}},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			tokens, err := NewLexer(test.query).Tokenize()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			node, err := NewParser(tokens).Parse()
+			if err != nil {
+				t.Fatalf("parser parse failed: %v", err)
+			}
+
+			if !reflect.DeepEqual(node, test.expected) {
+				t.Errorf("expected %#v, got %#v", test.expected, node)
+			}
+		})
+	}
+}
+
 func TestParser_ValidateTokens_SimpleUpdateQuery(t *testing.T) {
 	tokens := []Token{
 		{Type: KEYWORD, Value: UPDATE},
@@ -424,20 +502,20 @@ func TestParser_ValidateTokens_SimpleUpdateQuery(t *testing.T) {
 			t.Errorf("expected table %v, got %v", expectedTable, updateStmt.Table)
 		}
 
-		expectedSets := map[string]string{
-			"name": "marty",
+		expectedSets := map[string]Expression{
+			"name": &Literal{Value: "marty"},
 		}
 		if !reflect.DeepEqual(updateStmt.Set, expectedSets) {
 			t.Errorf("expected column %v, got %v", expectedSets, updateStmt.Set)
 		}
 
-		whereClauseTests := WhereClause{
-			Type:  EQUALS,
-			Left:  &WhereClause{Name: "id"},
-			Right: &WhereClause{Value: "1"},
+		whereClauseTests := &BinaryExpression{
+			Operator: EQUALS,
+			Left:     &ColumnRef{Name: "id"},
+			Right:    &Literal{Value: "1"},
 		}
 
-		validateWhereNode(whereClauseTests, *updateStmt.WhereClause, t)
+		validateWhereNode(whereClauseTests, updateStmt.Where, t)
 	})
 }
 
@@ -480,29 +558,29 @@ func TestParser_ValidateTokens_UpdateQueryMultiColumnChanges(t *testing.T) {
 			t.Errorf("expected table %v, got %v", expectedTable, updateStmt.Table)
 		}
 
-		expectedSets := map[string]string{
-			"name":  "marty",
-			"email": "marty.mcfly@thefuture.com",
+		expectedSets := map[string]Expression{
+			"name":  &Literal{Value: "marty"},
+			"email": &Literal{Value: "marty.mcfly@thefuture.com"},
 		}
 		if !reflect.DeepEqual(updateStmt.Set, expectedSets) {
 			t.Errorf("expected column %v, got %v", expectedSets, updateStmt.Set)
 		}
 
-		whereClauseTests := WhereClause{
-			Type: AND,
-			Left: &WhereClause{
-				Type:  EQUALS,
-				Left:  &WhereClause{Name: "id"},
-				Right: &WhereClause{Name: "1"},
+		whereClauseTests := &BinaryExpression{
+			Operator: AND,
+			Left: &BinaryExpression{
+				Operator: EQUALS,
+				Left:     &ColumnRef{Name: "id"},
+				Right:    &Literal{Value: "1"},
 			},
-			Right: &WhereClause{
-				Type:  EQUALS,
-				Left:  &WhereClause{Name: "age"},
-				Right: &WhereClause{Name: "18"},
+			Right: &BinaryExpression{
+				Operator: EQUALS,
+				Left:     &ColumnRef{Name: "age"},
+				Right:    &Literal{Value: "18"},
 			},
 		}
 
-		validateWhereNode(whereClauseTests, *updateStmt.WhereClause, t)
+		validateWhereNode(whereClauseTests, updateStmt.Where, t)
 	})
 }
 
@@ -578,6 +656,308 @@ func TestParser_ValidateTokens_DeleteQueryMultipleWhereClauses(t *testing.T) {
 	})
 }
 
+func TestParser_Parse_DeleteStatements(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected ASTNode
+	}{
+		{"DELETE FROM users", &DeleteStatement{Table: "users"}},
+		{"delete from users;", &DeleteStatement{Table: "users"}},
+		{
+			"DELETE FROM users WHERE id = 1 AND email IS NULL",
+			&DeleteStatement{Table: "users", Where: &BinaryExpression{
+				Operator: AND,
+				Left:     &BinaryExpression{Operator: EQUALS, Left: &ColumnRef{Name: "id"}, Right: &Literal{Value: "1"}},
+				Right:    &IsNullExpression{Operand: &ColumnRef{Name: "email"}},
+			}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			tokens, err := NewLexer(test.query).Tokenize()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			node, err := NewParser(tokens).Parse()
+			if err != nil {
+				t.Fatalf("parser parse failed: %v", err)
+			}
+
+			if !reflect.DeepEqual(node, test.expected) {
+				t.Errorf("expected %#v, got %#v", test.expected, node)
+			}
+		})
+	}
+}
+
+func TestParser_Parse_OrderByLimit(t *testing.T) {
+	limit := func(n int) *int { return &n }
+
+	tests := []struct {
+		query    string
+		expected *SelectStatement
+	}{
+		{
+			"SELECT id FROM users ORDER BY name, age DESC NULLS FIRST, id ASC NULLS LAST LIMIT 10 OFFSET 5",
+			&SelectStatement{
+				Columns:     []string{"id"},
+				Expressions: []Expression{&ColumnRef{Name: "id"}},
+				Table:       "users",
+				OrderBy: []OrderByItem{
+					{Expression: &ColumnRef{Name: "name"}, NullsFirst: true},
+					{Expression: &ColumnRef{Name: "age"}, Desc: true, NullsFirst: true},
+					{Expression: &ColumnRef{Name: "id"}},
+				},
+				Limit:  limit(10),
+				Offset: 5,
+			},
+		},
+		{
+			"select id from users where id > 1 order by id * -1 desc limit 5, 10;",
+			&SelectStatement{
+				Columns:     []string{"id"},
+				Expressions: []Expression{&ColumnRef{Name: "id"}},
+				Table:       "users",
+				Where:       &BinaryExpression{Operator: MORE_THAN, Left: &ColumnRef{Name: "id"}, Right: &Literal{Value: "1"}},
+				OrderBy: []OrderByItem{{
+					Expression: &BinaryExpression{Operator: MULTIPLY, Left: &ColumnRef{Name: "id"}, Right: &Literal{Value: "-1"}},
+					Desc:       true,
+				}},
+				Limit:  limit(10),
+				Offset: 5,
+			},
+		},
+		{
+			"SELECT first, last FROM users LIMIT 0",
+			&SelectStatement{
+				Columns:     []string{"first", "last"},
+				Expressions: []Expression{&ColumnRef{Name: "first"}, &ColumnRef{Name: "last"}},
+				Table:       "users",
+				Limit:       limit(0),
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			tokens, err := NewLexer(test.query).Tokenize()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			node, err := NewParser(tokens).Parse()
+			if err != nil {
+				t.Fatalf("parser parse failed: %v", err)
+			}
+
+			if !reflect.DeepEqual(node, test.expected) {
+				t.Errorf("expected %#v, got %#v", test.expected, node)
+			}
+		})
+	}
+}
+
+func TestParser_Parse_GroupBy(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected *SelectStatement
+	}{
+		{
+			"SELECT age, COUNT(*), count(DISTINCT name) AS names FROM users GROUP BY age HAVING Sum(id) > 10 ORDER BY 2",
+			&SelectStatement{
+				Columns: []string{"age", "COUNT(*)", "names"},
+				Expressions: []Expression{
+					&ColumnRef{Name: "age"},
+					&AggregateExpression{Function: COUNT},
+					&AggregateExpression{Function: COUNT, Argument: &ColumnRef{Name: "name"}, Distinct: true},
+				},
+				Table:   "users",
+				GroupBy: []Expression{&ColumnRef{Name: "age"}},
+				Having: &BinaryExpression{
+					Operator: MORE_THAN,
+					Left:     &AggregateExpression{Function: SUM, Argument: &ColumnRef{Name: "id"}},
+					Right:    &Literal{Value: "10"},
+				},
+				OrderBy: []OrderByItem{{Expression: &Literal{Value: "2"}, NullsFirst: true}},
+			},
+		},
+		{
+			"SELECT MAX(age) - MIN(age) FROM users WHERE id > 1 GROUP BY name, age % 10",
+			&SelectStatement{
+				Columns: []string{"MAX(age) - MIN(age)"},
+				Expressions: []Expression{&BinaryExpression{
+					Operator: MINUS,
+					Left:     &AggregateExpression{Function: MAX, Argument: &ColumnRef{Name: "age"}},
+					Right:    &AggregateExpression{Function: MIN, Argument: &ColumnRef{Name: "age"}},
+				}},
+				Table: "users",
+				Where: &BinaryExpression{Operator: MORE_THAN, Left: &ColumnRef{Name: "id"}, Right: &Literal{Value: "1"}},
+				GroupBy: []Expression{
+					&ColumnRef{Name: "name"},
+					&BinaryExpression{Operator: MODULO, Left: &ColumnRef{Name: "age"}, Right: &Literal{Value: "10"}},
+				},
+			},
+		},
+		{
+			"SELECT \"count\", avg FROM users",
+			&SelectStatement{
+				Columns:     []string{"count", "avg"},
+				Expressions: []Expression{&ColumnRef{Name: "count"}, &ColumnRef{Name: "avg"}},
+				Table:       "users",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			tokens, err := NewLexer(test.query).Tokenize()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			node, err := NewParser(tokens).Parse()
+			if err != nil {
+				t.Fatalf("parser parse failed: %v", err)
+			}
+
+			if !reflect.DeepEqual(node, test.expected) {
+				t.Errorf("expected %#v, got %#v", test.expected, node)
+			}
+		})
+	}
+}
+
+func TestParser_Parse_Joins(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected *SelectStatement
+	}{
+		{
+			"SELECT u.name, o.id FROM users AS u JOIN orders o ON u.id = o.user_id",
+			&SelectStatement{
+				Columns:     []string{"u.name", "o.id"},
+				Expressions: []Expression{&ColumnRef{Table: "u", Name: "name"}, &ColumnRef{Table: "o", Name: "id"}},
+				Table:       "users",
+				Alias:       "u",
+				Joins: []Join{{
+					Kind:  INNER,
+					Table: "orders",
+					Alias: "o",
+					On:    &BinaryExpression{Operator: EQUALS, Left: &ColumnRef{Table: "u", Name: "id"}, Right: &ColumnRef{Table: "o", Name: "user_id"}},
+				}},
+			},
+		},
+		{
+			"SELECT * FROM users LEFT OUTER JOIN orders USING (id, name) RIGHT JOIN items ON id = 1 FULL JOIN tags USING (tag)",
+			&SelectStatement{
+				Columns: []string{"*"},
+				Table:   "users",
+				Joins: []Join{
+					{Kind: LEFT, Table: "orders", Using: []string{"id", "name"}},
+					{Kind: RIGHT, Table: "items", On: &BinaryExpression{Operator: EQUALS, Left: &ColumnRef{Name: "id"}, Right: &Literal{Value: "1"}}},
+					{Kind: FULL, Table: "tags", Using: []string{"tag"}},
+				},
+			},
+		},
+		{
+			"SELECT id FROM users, orders o CROSS JOIN items INNER JOIN tags",
+			&SelectStatement{
+				Columns:     []string{"id"},
+				Expressions: []Expression{&ColumnRef{Name: "id"}},
+				Table:       "users",
+				Joins: []Join{
+					{Kind: CROSS, Table: "orders", Alias: "o"},
+					{Kind: CROSS, Table: "items"},
+					{Kind: INNER, Table: "tags"},
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			tokens, err := NewLexer(test.query).Tokenize()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			node, err := NewParser(tokens).Parse()
+			if err != nil {
+				t.Fatalf("parser parse failed: %v", err)
+			}
+
+			if !reflect.DeepEqual(node, test.expected) {
+				t.Errorf("expected %#v, got %#v", test.expected, node)
+			}
+		})
+	}
+}
+
+func TestParser_Parse_Subqueries(t *testing.T) {
+	orders := &SelectStatement{
+		Columns:     []string{"user_id"},
+		Expressions: []Expression{&ColumnRef{Name: "user_id"}},
+		Table:       "orders",
+	}
+
+	tests := []struct {
+		query    string
+		expected *SelectStatement
+	}{
+		{
+			"SELECT name, (SELECT user_id FROM orders) AS o FROM users WHERE id IN (SELECT user_id FROM orders)",
+			&SelectStatement{
+				Columns:     []string{"name", "o"},
+				Expressions: []Expression{&ColumnRef{Name: "name"}, &SubqueryExpression{Select: orders}},
+				Table:       "users",
+				Where:       &InExpression{Operand: &ColumnRef{Name: "id"}, Select: orders},
+			},
+		},
+		{
+			"SELECT id FROM users WHERE NOT EXISTS (SELECT user_id FROM orders) AND id NOT IN (SELECT user_id FROM orders)",
+			&SelectStatement{
+				Columns:     []string{"id"},
+				Expressions: []Expression{&ColumnRef{Name: "id"}},
+				Table:       "users",
+				Where: &BinaryExpression{
+					Operator: AND,
+					Left:     &UnaryExpression{Operator: NOT, Operand: &ExistsExpression{Select: orders}},
+					Right:    &InExpression{Operand: &ColumnRef{Name: "id"}, Select: orders, Not: true},
+				},
+			},
+		},
+		{
+			"SELECT * FROM (SELECT user_id FROM orders) AS a JOIN (SELECT user_id FROM orders) b USING (user_id);",
+			&SelectStatement{
+				Columns:  []string{"*"},
+				Subquery: orders,
+				Alias:    "a",
+				Joins:    []Join{{Kind: INNER, Subquery: orders, Alias: "b", Using: []string{"user_id"}}},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			tokens, err := NewLexer(test.query).Tokenize()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			node, err := NewParser(tokens).Parse()
+			if err != nil {
+				t.Fatalf("parser parse failed: %v", err)
+			}
+
+			if !reflect.DeepEqual(node, test.expected) {
+				t.Errorf("expected %#v, got %#v", test.expected, node)
+			}
+		})
+	}
+}
+
 func TestParser_ParseWhere_Equals(t *testing.T) {
 	tokens := []Token{
 		{Type: KEYWORD, Value: WHERE},
@@ -590,13 +970,13 @@ func TestParser_ParseWhere_Equals(t *testing.T) {
 	node, _ := parser.ParseWhere(&TokenValidatorParam{pos: 0})
 
 	t.Run("Check generated AST Nodes", func(t *testing.T) {
-		whereClauseTest := WhereClause{
-			Type:  EQUALS,
-			Left:  &WhereClause{Name: "id"},
-			Right: &WhereClause{Value: "1"},
+		whereClauseTest := &BinaryExpression{
+			Operator: EQUALS,
+			Left:     &ColumnRef{Name: "id"},
+			Right:    &Literal{Value: "1"},
 		}
 
-		validateWhereNode(whereClauseTest, *node, t)
+		validateWhereNode(whereClauseTest, node, t)
 	})
 }
 
@@ -616,21 +996,21 @@ func TestParser_ParseWhere_MultipleEqualsWithAndOperator(t *testing.T) {
 	node, _ := parser.ParseWhere(&TokenValidatorParam{pos: 0})
 
 	t.Run("Check generated AST Nodes", func(t *testing.T) {
-		whereClauseTest := WhereClause{
-			Type: AND,
-			Left: &WhereClause{
-				Type:  EQUALS,
-				Left:  &WhereClause{Name: "id"},
-				Right: &WhereClause{Value: "1"},
+		whereClauseTest := &BinaryExpression{
+			Operator: AND,
+			Left: &BinaryExpression{
+				Operator: EQUALS,
+				Left:     &ColumnRef{Name: "id"},
+				Right:    &Literal{Value: "1"},
 			},
-			Right: &WhereClause{
-				Type:  EQUALS,
-				Left:  &WhereClause{Name: "age"},
-				Right: &WhereClause{Value: "18"},
+			Right: &BinaryExpression{
+				Operator: EQUALS,
+				Left:     &ColumnRef{Name: "age"},
+				Right:    &Literal{Value: "18"},
 			},
 		}
 
-		validateWhereNode(whereClauseTest, *node, t)
+		validateWhereNode(whereClauseTest, node, t)
 	})
 }
 
@@ -650,21 +1030,21 @@ func TestParser_ParseWhere_MultipleEqualsWithOrOperator(t *testing.T) {
 	node, _ := parser.ParseWhere(&TokenValidatorParam{pos: 0})
 
 	t.Run("Check generated AST Nodes", func(t *testing.T) {
-		whereClauseTest := WhereClause{
-			Type: OR,
-			Left: &WhereClause{
-				Type:  EQUALS,
-				Left:  &WhereClause{Name: "id"},
-				Right: &WhereClause{Value: "1"},
+		whereClauseTest := &BinaryExpression{
+			Operator: OR,
+			Left: &BinaryExpression{
+				Operator: EQUALS,
+				Left:     &ColumnRef{Name: "id"},
+				Right:    &Literal{Value: "1"},
 			},
-			Right: &WhereClause{
-				Type:  EQUALS,
-				Left:  &WhereClause{Name: "age"},
-				Right: &WhereClause{Value: "18"},
+			Right: &BinaryExpression{
+				Operator: EQUALS,
+				Left:     &ColumnRef{Name: "age"},
+				Right:    &Literal{Value: "18"},
 			},
 		}
 
-		validateWhereNode(whereClauseTest, *node, t)
+		validateWhereNode(whereClauseTest, node, t)
 	})
 }
 
@@ -688,29 +1068,29 @@ func TestParser_ParseWhere_WithMultipleAndOperator(t *testing.T) {
 	node, _ := parser.ParseWhere(&TokenValidatorParam{pos: 0})
 
 	t.Run("Check generated AST Nodes", func(t *testing.T) {
-		whereClauseTest := WhereClause{
-			Type: AND,
-			Left: &WhereClause{
-				Type:  EQUALS,
-				Left:  &WhereClause{Name: "id"},
-				Right: &WhereClause{Value: "1"},
-			},
-			Right: &WhereClause{
-				Type: AND,
-				Left: &WhereClause{
-					Type:  EQUALS,
-					Left:  &WhereClause{Name: "age"},
-					Right: &WhereClause{Value: "18"},
+		whereClauseTest := &BinaryExpression{
+			Operator: AND,
+			Left: &BinaryExpression{
+				Operator: AND,
+				Left: &BinaryExpression{
+					Operator: EQUALS,
+					Left:     &ColumnRef{Name: "id"},
+					Right:    &Literal{Value: "1"},
 				},
-				Right: &WhereClause{
-					Type:  EQUALS,
-					Left:  &WhereClause{Name: "email"},
-					Right: &WhereClause{Value: "marty.mcfly@thefuture.com"},
+				Right: &BinaryExpression{
+					Operator: EQUALS,
+					Left:     &ColumnRef{Name: "age"},
+					Right:    &Literal{Value: "18"},
 				},
 			},
+			Right: &BinaryExpression{
+				Operator: EQUALS,
+				Left:     &ColumnRef{Name: "email"},
+				Right:    &Literal{Value: "marty.mcfly@thefuture.com"},
+			},
 		}
 
-		validateWhereNode(whereClauseTest, *node, t)
+		validateWhereNode(whereClauseTest, node, t)
 	})
 }
 
@@ -734,29 +1114,29 @@ func TestParser_ParseWhere_WithMultipleOrOperator(t *testing.T) {
 	node, _ := parser.ParseWhere(&TokenValidatorParam{pos: 0})
 
 	t.Run("Check generated AST Nodes", func(t *testing.T) {
-		whereClauseTest := WhereClause{
-			Type: OR,
-			Left: &WhereClause{
-				Type:  EQUALS,
-				Left:  &WhereClause{Name: "id"},
-				Right: &WhereClause{Value: "1"},
-			},
-			Right: &WhereClause{
-				Type: OR,
-				Left: &WhereClause{
-					Type:  EQUALS,
-					Left:  &WhereClause{Name: "age"},
-					Right: &WhereClause{Value: "18"},
+		whereClauseTest := &BinaryExpression{
+			Operator: OR,
+			Left: &BinaryExpression{
+				Operator: OR,
+				Left: &BinaryExpression{
+					Operator: EQUALS,
+					Left:     &ColumnRef{Name: "id"},
+					Right:    &Literal{Value: "1"},
 				},
-				Right: &WhereClause{
-					Type:  EQUALS,
-					Left:  &WhereClause{Name: "email"},
-					Right: &WhereClause{Value: "marty.mcfly@thefuture.com"},
+				Right: &BinaryExpression{
+					Operator: EQUALS,
+					Left:     &ColumnRef{Name: "age"},
+					Right:    &Literal{Value: "18"},
 				},
 			},
+			Right: &BinaryExpression{
+				Operator: EQUALS,
+				Left:     &ColumnRef{Name: "email"},
+				Right:    &Literal{Value: "marty.mcfly@thefuture.com"},
+			},
 		}
 
-		validateWhereNode(whereClauseTest, *node, t)
+		validateWhereNode(whereClauseTest, node, t)
 	})
 }
 
@@ -780,43 +1160,329 @@ func TestParser_ParseWhere_WithAndOperatorBeforeOrOperator(t *testing.T) {
 	node, _ := parser.ParseWhere(&TokenValidatorParam{pos: 0})
 
 	t.Run("Check generated AST Nodes", func(t *testing.T) {
-		whereClauseTest := WhereClause{
-			Type: OR,
-			Left: &WhereClause{
-				Type: AND,
-				Left: &WhereClause{
-					Type:  EQUALS,
-					Left:  &WhereClause{Name: "id"},
-					Right: &WhereClause{Value: "1"},
+		whereClauseTest := &BinaryExpression{
+			Operator: OR,
+			Left: &BinaryExpression{
+				Operator: AND,
+				Left: &BinaryExpression{
+					Operator: EQUALS,
+					Left:     &ColumnRef{Name: "id"},
+					Right:    &Literal{Value: "1"},
 				},
-				Right: &WhereClause{
-					Type:  EQUALS,
-					Left:  &WhereClause{Name: "age"},
-					Right: &WhereClause{Value: "18"},
+				Right: &BinaryExpression{
+					Operator: EQUALS,
+					Left:     &ColumnRef{Name: "age"},
+					Right:    &Literal{Value: "18"},
 				},
 			},
-			Right: &WhereClause{
-				Type:  EQUALS,
-				Left:  &WhereClause{Name: "email"},
-				Right: &WhereClause{Value: "marty.mcfly@thefuture.com"},
+			Right: &BinaryExpression{
+				Operator: EQUALS,
+				Left:     &ColumnRef{Name: "email"},
+				Right:    &Literal{Value: "marty.mcfly@thefuture.com"},
 			},
 		}
 
-		validateWhereNode(whereClauseTest, *node, t)
+		validateWhereNode(whereClauseTest, node, t)
 	})
 }
 
-func validateWhereNode(expected WhereClause, current WhereClause, t *testing.T) {
-	if current.Left.Name != expected.Left.Name {
-		t.Errorf("Check where clauses Column: expected %v, got %v", expected.Left.Name, current.Left.Name)
+func validateWhereNode(expected Expression, current Expression, t *testing.T) {
+	if !reflect.DeepEqual(current, expected) {
+		t.Errorf("Check where clause: expected %v, got %v", expected, current)
+	}
+}
+
+func TestParser_Parse_TransactionStatements(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected ASTNode
+	}{
+		{"BEGIN", &BeginStatement{}},
+		{"BEGIN TRANSACTION;", &BeginStatement{}},
+		{"START TRANSACTION", &BeginStatement{}},
+		{"COMMIT", &CommitStatement{}},
+		{"COMMIT WORK;", &CommitStatement{}},
+		{"ROLLBACK", &RollbackStatement{}},
+		{"ROLLBACK TO checkpoint", &RollbackStatement{Savepoint: "checkpoint"}},
+		{"ROLLBACK WORK TO SAVEPOINT sp;", &RollbackStatement{Savepoint: "sp"}},
+		{"SAVEPOINT sp", &SavepointStatement{Name: "sp"}},
+		{"SET TRANSACTION ISOLATION LEVEL READ COMMITTED", &SetTransactionStatement{Isolation: "READ COMMITTED"}},
+		{"SET SESSION TRANSACTION ISOLATION LEVEL REPEATABLE READ;", &SetTransactionStatement{Session: true, Isolation: "REPEATABLE READ"}},
+		{"SET SESSION TRANSACTION ISOLATION LEVEL SNAPSHOT", &SetTransactionStatement{Session: true, Isolation: "SNAPSHOT"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			tokens, err := NewLexer(test.query).Tokenize()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			node, err := NewParser(tokens).Parse()
+			if err != nil {
+				t.Fatalf("parser parse failed: %v", err)
+			}
+
+			if !reflect.DeepEqual(node, test.expected) {
+				t.Errorf("expected %#v, got %#v", test.expected, node)
+			}
+		})
+	}
+}
+
+func TestParser_Parse_InvalidTransactionStatements(t *testing.T) {
+	for _, query := range []string{"START", "SAVEPOINT", "ROLLBACK TO", "COMMIT users", "BEGIN WORK WORK", "SET TRANSACTION ISOLATION LEVEL READ", "SET SESSION ISOLATION LEVEL SNAPSHOT"} {
+		t.Run(query, func(t *testing.T) {
+			tokens, _ := NewLexer(query).Tokenize()
+			if _, err := NewParser(tokens).Parse(); err == nil {
+				t.Errorf("expected %q to be rejected", query)
+			}
+		})
+	}
+}
+
+func TestParser_Parse_TableStatements(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected ASTNode
+	}{
+		{
+			"CREATE TABLE users (id INT PRIMARY KEY, name VARCHAR(50) NOT NULL, email TEXT UNIQUE)",
+			&CreateTableStatement{Table: "users", Columns: []ColumnDefinition{
+				{Name: "id", Type: "INT", PrimaryKey: true},
+				{Name: "name", Type: "VARCHAR", Length: 50, NotNull: true},
+				{Name: "email", Type: "TEXT", Unique: true},
+			}},
+		},
+		{
+			"CREATE TABLE IF NOT EXISTS users (id INT, email TEXT NULL, PRIMARY KEY (id), UNIQUE KEY (email));",
+			&CreateTableStatement{Table: "users", IfNotExists: true, Columns: []ColumnDefinition{
+				{Name: "id", Type: "INT", PrimaryKey: true},
+				{Name: "email", Type: "TEXT", Unique: true},
+			}},
+		},
+		{
+			"CREATE TABLE prices (day DATE, amount DECIMAL(8, 2) DEFAULT 0.5, paid BOOLEAN DEFAULT FALSE)",
+			&CreateTableStatement{Table: "prices", Columns: []ColumnDefinition{
+				{Name: "day", Type: "DATE"},
+				{Name: "amount", Type: "DECIMAL", Length: 8, Scale: 2, Default: stringPtr("0.5")},
+				{Name: "paid", Type: "BOOLEAN", Default: stringPtr(FALSE)},
+			}},
+		},
+		{"DROP TABLE users", &DropTableStatement{Table: "users"}},
+		{"DROP TABLE IF EXISTS users;", &DropTableStatement{Table: "users", IfExists: true}},
+		{
+			"ALTER TABLE users ADD COLUMN age INT NOT NULL DEFAULT -1, DROP email, RENAME COLUMN name TO fullname",
+			&AlterTableStatement{Table: "users", Actions: []ASTNode{
+				&AddColumnAction{Column: ColumnDefinition{Name: "age", Type: "INT", NotNull: true, Default: stringPtr("-1")}},
+				&DropColumnAction{Column: "email"},
+				&RenameColumnAction{Column: "name", NewName: "fullname"},
+			}},
+		},
+		{
+			"ALTER TABLE users MODIFY name VARCHAR(100) DEFAULT 'none';",
+			&AlterTableStatement{Table: "users", Actions: []ASTNode{
+				&ModifyColumnAction{Column: ColumnDefinition{Name: "name", Type: "VARCHAR", Length: 100, Default: stringPtr("none")}},
+			}},
+		},
+		{
+			"ALTER TABLE users RENAME TO people",
+			&AlterTableStatement{Table: "users", Actions: []ASTNode{&RenameTableAction{NewName: "people"}}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			tokens, err := NewLexer(test.query).Tokenize()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			node, err := NewParser(tokens).Parse()
+			if err != nil {
+				t.Fatalf("parser parse failed: %v", err)
+			}
+
+			if !reflect.DeepEqual(node, test.expected) {
+				t.Errorf("expected %#v, got %#v", test.expected, node)
+			}
+		})
+	}
+}
+
+func TestParser_Parse_InvalidTableStatements(t *testing.T) {
+	queries := []string{
+		"CREATE users (id INT)",
+		"CREATE TABLE users",
+		"CREATE TABLE users (id)",
+		"CREATE TABLE users (id INT,)",
+		"CREATE TABLE users (id INT",
+		"CREATE TABLE users (name VARCHAR(0))",
+		"CREATE TABLE users (price DECIMAL(8,))",
+		"CREATE TABLE users (id INT PRIMARY)",
+		"CREATE TABLE users (id INT NOT)",
+		"CREATE TABLE users (id INT, PRIMARY KEY (email))",
+		"CREATE TABLE users (id INT, name TEXT, PRIMARY KEY (id, name))",
+		"CREATE TABLE IF EXISTS users (id INT)",
+		"DROP users",
+		"DROP TABLE IF users",
+		"DROP TABLE users users",
+		"ALTER users ADD age INT",
+		"ALTER TABLE users",
+		"ALTER TABLE users ADD age",
+		"ALTER TABLE users CHANGE age",
+		"ALTER TABLE users RENAME COLUMN age",
+		"ALTER TABLE users DROP age,",
+		"ALTER TABLE users ADD age INT DEFAULT",
+	}
+
+	for _, query := range queries {
+		t.Run(query, func(t *testing.T) {
+			tokens, _ := NewLexer(query).Tokenize()
+			if _, err := NewParser(tokens).Parse(); err == nil {
+				t.Errorf("expected %q to be rejected", query)
+			}
+		})
+	}
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+func TestParser_ParseWhere_NullsAndPrecedence(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected Expression
+	}{
+		{
+			"WHERE name IS NULL OR age IS NOT NULL",
+			&BinaryExpression{
+				Operator: OR,
+				Left:     &IsNullExpression{Operand: &ColumnRef{Name: "name"}},
+				Right:    &IsNullExpression{Operand: &ColumnRef{Name: "age"}, Not: true},
+			},
+		},
+		{
+			"WHERE id = 1 OR id = 2 AND NOT age <> NULL",
+			&BinaryExpression{
+				Operator: OR,
+				Left:     &BinaryExpression{Operator: EQUALS, Left: &ColumnRef{Name: "id"}, Right: &Literal{Value: "1"}},
+				Right: &BinaryExpression{
+					Operator: AND,
+					Left:     &BinaryExpression{Operator: EQUALS, Left: &ColumnRef{Name: "id"}, Right: &Literal{Value: "2"}},
+					Right: &UnaryExpression{Operator: NOT, Operand: &BinaryExpression{
+						Operator: NOT_EQUALS, Left: &ColumnRef{Name: "age"}, Right: &Literal{Value: engine.Null},
+					}},
+				},
+			},
+		},
+		{
+			"WHERE (id = 1 OR id = 2) AND age > 3",
+			&BinaryExpression{
+				Operator: AND,
+				Left: &BinaryExpression{
+					Operator: OR,
+					Left:     &BinaryExpression{Operator: EQUALS, Left: &ColumnRef{Name: "id"}, Right: &Literal{Value: "1"}},
+					Right:    &BinaryExpression{Operator: EQUALS, Left: &ColumnRef{Name: "id"}, Right: &Literal{Value: "2"}},
+				},
+				Right: &BinaryExpression{Operator: MORE_THAN, Left: &ColumnRef{Name: "age"}, Right: &Literal{Value: "3"}},
+			},
+		},
 	}
 
-	if current.Type != expected.Type {
-		t.Errorf("Check where clauses Operator: expected %v, got %v", expected.Type, current.Type)
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			tokens, err := NewLexer(test.query).Tokenize()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			node, err := NewParser(tokens).ParseWhere(&TokenValidatorParam{pos: 0})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(node, test.expected) {
+				t.Errorf("expected %+v, got %+v", test.expected, node)
+			}
+		})
 	}
 
-	if current.Right.Value != expected.Right.Value {
-		t.Errorf("Check where clauses Value: expected %v, got %v", expected.Right.Value, current.Right.Value)
+	for _, query := range []string{"WHERE name IS", "WHERE name IS NOT 1", "WHERE (id = 1", "WHERE NOT", "WHERE id =", "WHERE id + * 2"} {
+		t.Run(query, func(t *testing.T) {
+			tokens, _ := NewLexer(query).Tokenize()
+			if _, err := NewParser(tokens).ParseWhere(&TokenValidatorParam{pos: 0}); err == nil {
+				t.Errorf("expected %q to be rejected", query)
+			}
+		})
 	}
+}
+
+func TestParser_Parse_Expressions(t *testing.T) {
+	t.Run("Select list", func(t *testing.T) {
+		tokens, _ := NewLexer("SELECT id, price * quantity AS total, -price discount FROM orders WHERE price > cost").Tokenize()
+		node, err := NewParser(tokens).Parse()
+		if err != nil {
+			t.Fatal(err)
+		}
 
+		expected := &SelectStatement{
+			Columns: []string{"id", "total", "discount"},
+			Expressions: []Expression{
+				&ColumnRef{Name: "id"},
+				&BinaryExpression{Operator: MULTIPLY, Left: &ColumnRef{Name: "price"}, Right: &ColumnRef{Name: "quantity"}},
+				&UnaryExpression{Operator: MINUS, Operand: &ColumnRef{Name: "price"}},
+			},
+			Table: "orders",
+			Where: &BinaryExpression{Operator: MORE_THAN, Left: &ColumnRef{Name: "price"}, Right: &ColumnRef{Name: "cost"}},
+		}
+		if !reflect.DeepEqual(node, expected) {
+			t.Errorf("expected %+v, got %+v", expected, node)
+		}
+	})
+
+	t.Run("Select list names", func(t *testing.T) {
+		tokens, _ := NewLexer("SELECT (price + 1) * 2, NOT active FROM orders").Tokenize()
+		node, err := NewParser(tokens).Parse()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := []string{"(price + 1) * 2", "NOT active"}
+		if columns := node.(*SelectStatement).Columns; !reflect.DeepEqual(columns, expected) {
+			t.Errorf("expected %v, got %v", expected, columns)
+		}
+	})
+
+	t.Run("Update set", func(t *testing.T) {
+		tokens, _ := NewLexer("UPDATE orders SET quantity = quantity - 1, price = price * 2 WHERE id = 1").Tokenize()
+		node, err := NewParser(tokens).Parse()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := map[string]Expression{
+			"quantity": &BinaryExpression{Operator: MINUS, Left: &ColumnRef{Name: "quantity"}, Right: &Literal{Value: "1"}},
+			"price":    &BinaryExpression{Operator: MULTIPLY, Left: &ColumnRef{Name: "price"}, Right: &Literal{Value: "2"}},
+		}
+		if set := node.(*UpdateStatement).Set; !reflect.DeepEqual(set, expected) {
+			t.Errorf("expected %v, got %v", expected, set)
+		}
+	})
+
+	for _, query := range []string{
+		"SELECT id + FROM orders",
+		"SELECT (id FROM orders",
+		"SELECT id AS FROM orders",
+		"SELECT id, FROM orders",
+		"UPDATE orders SET quantity = WHERE id = 1",
+		"UPDATE orders SET quantity = 1 + WHERE id = 1",
+	} {
+		t.Run(query, func(t *testing.T) {
+			tokens, _ := NewLexer(query).Tokenize()
+			if _, err := NewParser(tokens).Parse(); err == nil {
+				t.Errorf("expected %q to be rejected", query)
+			}
+		})
+	}
 }
@@ -1,6 +1,10 @@
 package parser
 
-import "dbngin3/engine"
+import (
+	"dbngin3/engine"
+	"strconv"
+	"strings"
+)
 
 type QueryOptimizer interface {
 	Optimize(node *ASTNode)
@@ -10,17 +14,472 @@ type SelectQueryOptimizer struct {
 	Schema *engine.SchemaManager
 }
 
+// Optimize expands SELECT *, replaces the positions and aliases of result
+// columns, qualifies the columns of a query with joins, turns the EXISTS and
+// IN subqueries it can into joins and looks for a primary key lookup in a
+// query on a single table.
 func (s *SelectQueryOptimizer) Optimize(selectStmt *SelectStatement) error {
-	table, err := s.Schema.GetTable(selectStmt.Table)
+	_, err := optimizeSelect(s.Schema, selectStmt, nil)
+	return err
+}
+
+// optimizeSelect optimizes a query, which is a subquery if it has an outer
+// scope, and returns its result columns.
+func optimizeSelect(schema *engine.SchemaManager, selectStmt *SelectStatement, outer *scope) ([]engine.Column, error) {
+	base, err := newScope(schema, selectStmt.Table, selectStmt.Subquery, selectStmt.Alias, selectStmt.Joins, outer)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	decorrelate(selectStmt, base)
+
+	scope, err := newScope(schema, selectStmt.Table, selectStmt.Subquery, selectStmt.Alias, selectStmt.Joins, outer)
+	if err != nil {
+		return nil, err
 	}
 
 	if selectStmt.Columns[0] == WILDCARD {
 		selectStmt.Columns = []string{}
+		selectStmt.Expressions = []Expression{}
+		for _, ref := range scope.columns() {
+			selectStmt.Columns = append(selectStmt.Columns, ref.Name)
+			selectStmt.Expressions = append(selectStmt.Expressions, ref)
+		}
+	}
+
+	selectStmt.Subquery = scope.tables[0].query
+	for i := range selectStmt.Joins {
+		selectStmt.Joins[i].Subquery = scope.tables[i+1].query
+		selectStmt.Joins[i].On, selectStmt.Joins[i].Using = scope.conditions[i], nil
+	}
+	if selectStmt.Where, err = scope.qualify(selectStmt.Where, "where"); err != nil {
+		return nil, err
+	}
+	// positions and aliases are replaced by the result columns before they
+	// are qualified, the subqueries in them are prepared once
+	for i, expr := range selectStmt.GroupBy {
+		if selectStmt.GroupBy[i], err = scope.qualify(resolveResultColumn(selectStmt, expr), "group"); err != nil {
+			return nil, err
+		}
+	}
+	if selectStmt.Having, err = scope.qualify(resolveAliases(selectStmt, selectStmt.Having), "having"); err != nil {
+		return nil, err
+	}
+	for i, item := range selectStmt.OrderBy {
+		if selectStmt.OrderBy[i].Expression, err = scope.qualify(resolveResultColumn(selectStmt, item.Expression), "order"); err != nil {
+			return nil, err
+		}
+	}
+	for i, expr := range selectStmt.Expressions {
+		if selectStmt.Expressions[i], err = scope.qualify(expr, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	if pk := scope.tables[0].table.PrimaryKey(); pk != nil && len(selectStmt.Joins) == 0 {
+		selectStmt.IndexLookup = findIndexLookup(selectStmt.Where, pk)
+	}
+
+	columns := make([]engine.Column, len(selectStmt.Expressions))
+	for i, expr := range selectStmt.Expressions {
+		dataType, err := TypeOf(expr, scope.qualifiedColumns())
+		if err != nil {
+			return nil, err
+		}
+		columns[i] = engine.Column{Name: selectStmt.Columns[i], Type: dataType}
+	}
+	return columns, nil
+}
+
+// decorrelate turns the EXISTS, NOT EXISTS, IN and NOT IN subqueries that
+// must hold for every row of the query into SEMI and ANTI joins, which read
+// the table of the subquery once instead of once per row. Only subqueries
+// on a single table whose rows are not grouped or limited qualify. NOT IN
+// is only NOT EXISTS of an equal row when neither side can be NULL.
+func decorrelate(selectStmt *SelectStatement, s *scope) {
+	var where Expression
+	for _, condition := range conjuncts(selectStmt.Where) {
+		if join, ok := s.semiJoin(selectStmt, condition); ok {
+			selectStmt.Joins = append(selectStmt.Joins, join)
+		} else {
+			where = and(where, condition)
+		}
+	}
+	selectStmt.Where = where
+}
+
+func (s *scope) semiJoin(selectStmt *SelectStatement, condition Expression) (Join, bool) {
+	kind := SEMI
+	if not, ok := condition.(*UnaryExpression); ok && not.Operator == NOT {
+		kind, condition = ANTI, not.Operand
+	}
+
+	var on Expression
+	var sub *SelectStatement
+	switch e := condition.(type) {
+	case *ExistsExpression:
+		sub = e.Select
+	case *InExpression:
+		if e.Not {
+			if kind == ANTI {
+				return Join{}, false
+			}
+			kind = ANTI
+		}
+		if containsSubquery(e.Operand) || len(e.Select.Expressions) != 1 {
+			return Join{}, false
+		}
+		sub = e.Select
+		on = &BinaryExpression{Operator: EQUALS, Left: e.Operand, Right: sub.Expressions[0]}
+	default:
+		return Join{}, false
+	}
+	if sub.Table == "" || sub.Subquery != nil || len(sub.Joins) > 0 || IsAggregateQuery(sub) ||
+		sub.Limit != nil || sub.Offset > 0 || containsSubquery(append([]Expression{sub.Where}, sub.Expressions...)...) {
+		return Join{}, false
+	}
+
+	inner, err := newScope(s.schema, sub.Table, nil, sub.Alias, nil, s)
+	if err != nil {
+		return Join{}, false
+	}
+	name := s.uniqueName(inner.tables[0].name, selectStmt.Joins)
+
+	// the columns of the subquery are qualified by the name of its table
+	// in the query, those of the query by their tables
+	var refs []*OuterRef
+	convert := func(e Expression) Expression {
+		switch e := e.(type) {
+		case *ColumnRef:
+			return &ColumnRef{Table: name, Name: e.Name}
+		case *OuterRef:
+			refs = append(refs, e)
+			if e.Column.Table == "" {
+				return &ColumnRef{Table: s.tables[0].name, Name: e.Column.Name}
+			}
+			return e.Column
+		}
+		return nil
+	}
+
+	var operand Expression
+	if in, ok := on.(*BinaryExpression); ok {
+		if operand, err = s.qualify(in.Left, "where"); err != nil {
+			return Join{}, false
+		}
+		right, err := inner.qualify(in.Right, "")
+		if err != nil {
+			return Join{}, false
+		}
+		if kind == ANTI && (!s.notNull(operand) || !inner.notNull(right)) {
+			return Join{}, false
+		}
+		operand = Transform(operand, func(e Expression) Expression {
+			if ref, ok := e.(*ColumnRef); ok && ref.Table == "" {
+				return &ColumnRef{Table: s.tables[0].name, Name: ref.Name}
+			}
+			return nil
+		})
+		on = &BinaryExpression{Operator: EQUALS, Left: operand, Right: Transform(right, convert)}
+	}
+
+	where, err := inner.qualify(sub.Where, "where")
+	if err != nil {
+		return Join{}, false
+	}
+	on = and(on, Transform(where, convert))
+	for _, ref := range refs {
+		if ref.Depth > 1 {
+			return Join{}, false
+		}
+	}
+	if len(refs) == 0 && operand == nil {
+		// an uncorrelated EXISTS is run once
+		return Join{}, false
+	}
+	if kind == ANTI && operand != nil {
+		for _, join := range selectStmt.Joins {
+			if join.Kind == LEFT || join.Kind == RIGHT || join.Kind == FULL {
+				return Join{}, false
+			}
+		}
+	}
+
+	join := Join{Kind: kind, Table: sub.Table, On: on}
+	if name != sub.Table {
+		join.Alias = name
+	}
+	return join, true
+}
+
+// uniqueName returns the name, or if a table of the scope or one of the
+// joins has it, the name with the first number that makes it unique.
+func (s *scope) uniqueName(name string, joins []Join) string {
+	taken := func(name string) bool {
+		for _, t := range s.tables {
+			if t.name == name {
+				return true
+			}
+		}
+		for _, join := range joins {
+			if join.Alias == name || join.Alias == "" && join.Table == name {
+				return true
+			}
+		}
+		return false
+	}
+
+	unique := name
+	for i := 1; taken(unique); i++ {
+		unique = name + "_" + strconv.Itoa(i)
+	}
+	return unique
+}
+
+// notNull reports whether the qualified expression is a column of the scope
+// that cannot be NULL.
+func (s *scope) notNull(expr Expression) bool {
+	ref, ok := expr.(*ColumnRef)
+	if !ok {
+		return false
+	}
+	for _, t := range s.tables {
+		if ref.Table != "" && ref.Table != t.name {
+			continue
+		}
+		if i := t.table.ColumnIndex(ref.Name); i >= 0 {
+			return t.table.Columns[i].NotNull || t.table.Columns[i].PrimaryKey
+		}
+	}
+	return false
+}
+
+// conjuncts splits a condition into the conditions it ANDs.
+func conjuncts(condition Expression) []Expression {
+	if b, ok := condition.(*BinaryExpression); ok && b.Operator == AND {
+		return append(conjuncts(b.Left), conjuncts(b.Right)...)
+	}
+	if condition == nil {
+		return nil
+	}
+	return []Expression{condition}
+}
+
+// resultPosition returns the result column GROUP BY 2 or ORDER BY 2 refers
+// to, counted from 1.
+func resultPosition(expr Expression) (int, bool) {
+	literal, ok := expr.(*Literal)
+	if !ok || strings.Trim(literal.Value, "0123456789") != "" {
+		return 0, false
+	}
+	position, err := strconv.Atoi(literal.Value)
+	return position, err == nil
+}
+
+// resultAlias returns the expression of the result column a GROUP BY,
+// HAVING or ORDER BY names by its alias. Like in MySQL an alias wins over a
+// column of the table with the same name.
+func resultAlias(selectStmt *SelectStatement, expr Expression) (Expression, bool) {
+	ref, ok := expr.(*ColumnRef)
+	if !ok || ref.Table != "" {
+		return nil, false
+	}
+	for i, column := range selectStmt.Columns {
+		if column == ref.Name && i < len(selectStmt.Expressions) {
+			return selectStmt.Expressions[i], true
+		}
+	}
+	return nil, false
+}
+
+// resolveResultColumn replaces a position or an alias by the expression of
+// the result column. The analyzer checked the positions.
+func resolveResultColumn(selectStmt *SelectStatement, expr Expression) Expression {
+	if position, ok := resultPosition(expr); ok {
+		return selectStmt.Expressions[position-1]
+	}
+	if alias, ok := resultAlias(selectStmt, expr); ok {
+		return alias
+	}
+	return expr
+}
+
+// resolveAliases replaces the aliases anywhere in a HAVING condition by the
+// expressions of their result columns.
+func resolveAliases(selectStmt *SelectStatement, expr Expression) Expression {
+	return Transform(expr, func(e Expression) Expression {
+		alias, _ := resultAlias(selectStmt, e)
+		return alias
+	})
+}
+
+type DeleteQueryOptimizer struct {
+	Schema *engine.SchemaManager
+}
+
+// Optimize deletes through the primary key index when the where clause
+// fixes the key.
+func (s *DeleteQueryOptimizer) Optimize(deleteStmt *DeleteStatement) error {
+	scope, err := newScope(s.Schema, deleteStmt.Table, nil, "", nil, nil)
+	if err != nil {
+		return err
+	}
+
+	if deleteStmt.Where, err = scope.qualify(deleteStmt.Where, "where"); err != nil {
+		return err
+	}
+	if pk := scope.tables[0].table.PrimaryKey(); pk != nil {
+		deleteStmt.IndexLookup = findIndexLookup(deleteStmt.Where, pk)
+	}
+	return nil
+}
+
+type UpdateQueryOptimizer struct {
+	Schema *engine.SchemaManager
+}
+
+// Optimize prepares the subqueries of the SET and WHERE clauses and casts
+// the literals to the types of the columns they are assigned to.
+func (s *UpdateQueryOptimizer) Optimize(updateStmt *UpdateStatement) error {
+	scope, err := newScope(s.Schema, updateStmt.Table, nil, "", nil, nil)
+	if err != nil {
+		return err
+	}
+
+	table := scope.tables[0].table
+	for column, expr := range updateStmt.Set {
+		if updateStmt.Set[column], err = assignColumn(scope, table, column, expr, "set"); err != nil {
+			return err
+		}
+	}
+	updateStmt.Where, err = scope.qualify(updateStmt.Where, "where")
+	return err
+}
+
+type InsertQueryOptimizer struct {
+	Schema *engine.SchemaManager
+}
+
+// Optimize prepares the query of an INSERT ... SELECT and the subqueries in
+// the values, which cannot refer to any column, and casts the literals to
+// the types of their columns. The expressions of ON DUPLICATE KEY UPDATE
+// are qualified in the scope of the table.
+func (s *InsertQueryOptimizer) Optimize(insertStmt *InsertStatement) error {
+	if insertStmt.Select != nil {
+		query, _, err := prepareSelect(s.Schema, insertStmt.Select, nil)
+		if err != nil {
+			return err
+		}
+		insertStmt.Select = query
+	}
+
+	// the planner reports unknown columns and rows of the wrong length
+	var columns []*engine.Column
+	if table, err := s.Schema.GetTable(insertStmt.Table); err == nil {
+		columns = insertTargets(table, insertStmt.Columns)
+	}
+
+	values := &scope{schema: s.Schema}
+	for _, row := range insertStmt.Rows {
+		for i, expr := range row {
+			if expr == nil {
+				continue
+			}
+			var err error
+			if row[i], err = values.qualify(expr, ""); err != nil {
+				return err
+			}
+			if len(row) != len(columns) || columns[i] == nil {
+				continue
+			}
+			if row[i], err = assign(*columns[i], row[i]); err != nil {
+				return err
+			}
+		}
+	}
+
+	if insertStmt.OnDuplicate == nil {
+		return nil
+	}
+	scope, err := newScope(s.Schema, insertStmt.Table, nil, "", nil, nil)
+	if err != nil {
+		return err
+	}
+	scope.inserted = scope.tables[0].table
+	for column, expr := range insertStmt.OnDuplicate {
+		if insertStmt.OnDuplicate[column], err = assignColumn(scope, scope.inserted, column, expr, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// insertTargets returns the columns the values of an insert go to, nil for
+// the names the table has no column for.
+func insertTargets(table *engine.Table, names []string) []*engine.Column {
+	if len(names) == 0 {
+		columns := make([]*engine.Column, len(table.Columns))
 		for i := range table.Columns {
-			selectStmt.Columns = append(selectStmt.Columns, table.Columns[i].Name)
+			columns[i] = &table.Columns[i]
+		}
+		return columns
+	}
+
+	columns := make([]*engine.Column, len(names))
+	for i, name := range names {
+		if idx := table.ColumnIndex(name); idx >= 0 {
+			columns[i] = &table.Columns[idx]
+		}
+	}
+	return columns
+}
+
+// assignColumn qualifies the value a statement assigns to a column of the
+// table and casts it to the type of the column. An unknown column is left
+// to the planner.
+func assignColumn(scope *scope, table *engine.Table, column string, expr Expression, clause string) (Expression, error) {
+	qualified, err := scope.qualify(expr, clause)
+	if err != nil {
+		return nil, err
+	}
+	if idx := table.ColumnIndex(column); idx >= 0 {
+		return assign(table.Columns[idx], qualified)
+	}
+	return qualified, nil
+}
+
+// findIndexLookup looks for a `pk = literal` condition, either way round,
+// that must hold for every returned row, i.e. one that is not below an OR.
+// The literal has to be a key of the index: cast to the type of the key, or
+// a string compared with a string key. A number that is none, like 1.5 for
+// an INT key, matches no row and is left to the scan.
+func findIndexLookup(expr Expression, pk *engine.Column) *IndexLookup {
+	b, ok := expr.(*BinaryExpression)
+	if !ok {
+		return nil
+	}
+
+	if b.Operator == EQUALS {
+		for _, operands := range [][2]Expression{{b.Left, b.Right}, {b.Right, b.Left}} {
+			ref, isRef := operands[0].(*ColumnRef)
+			if !isRef || ref.Name != pk.Name {
+				continue
+			}
+			switch value := operands[1].(type) {
+			case *Literal:
+				if value.Value != engine.Null && isString(pk.Type) {
+					return &IndexLookup{Column: pk.Name, Value: value.Value}
+				}
+			case *Cast:
+				return &IndexLookup{Column: pk.Name, Value: value.Value}
+			}
+		}
+	}
+
+	if b.Operator == AND {
+		if lookup := findIndexLookup(b.Left, pk); lookup != nil {
+			return lookup
 		}
+		return findIndexLookup(b.Right, pk)
 	}
 
 	return nil
@@ -42,3 +42,286 @@ func TestSelectStatement_Optimize_WildcardSelect(t *testing.T) {
 		}
 	})
 }
+
+func TestSelectStatement_Optimize_PrimaryKeyLookup(t *testing.T) {
+	schema := engine.NewSchemaManager()
+	schema.AddTable("users", &engine.Table{
+		Name: "users",
+		Columns: []engine.Column{
+			{Name: "id", Type: engine.Int, PrimaryKey: true},
+			{Name: "name", Type: engine.Varchar},
+		},
+	})
+
+	selectQueryOptimizer := SelectQueryOptimizer{
+		Schema: schema,
+	}
+
+	t.Run("Equality on primary key uses the index, either way round", func(t *testing.T) {
+		selectStmt := &SelectStatement{
+			Table:   "users",
+			Columns: []string{"*"},
+			Where: &BinaryExpression{
+				Operator: AND,
+				Left: &BinaryExpression{
+					Operator: EQUALS,
+					Left:     &ColumnRef{Name: "name"},
+					Right:    &Literal{Value: "john"},
+				},
+				Right: &BinaryExpression{
+					Operator: EQUALS,
+					Left:     &Literal{Value: "1"},
+					Right:    &ColumnRef{Name: "id"},
+				},
+			},
+		}
+
+		if err := selectQueryOptimizer.Optimize(selectStmt); err != nil {
+			t.Fatal(err)
+		}
+
+		expected := &IndexLookup{Column: "id", Value: "1"}
+		if !reflect.DeepEqual(selectStmt.IndexLookup, expected) {
+			t.Errorf("expected: %v, got: %v", expected, selectStmt.IndexLookup)
+		}
+	})
+
+	t.Run("Primary key below OR falls back to a scan", func(t *testing.T) {
+		selectStmt := &SelectStatement{
+			Table:   "users",
+			Columns: []string{"*"},
+			Where: &BinaryExpression{
+				Operator: OR,
+				Left: &BinaryExpression{
+					Operator: EQUALS,
+					Left:     &ColumnRef{Name: "id"},
+					Right:    &Literal{Value: "1"},
+				},
+				Right: &BinaryExpression{
+					Operator: EQUALS,
+					Left:     &ColumnRef{Name: "id"},
+					Right:    &Literal{Value: "2"},
+				},
+			},
+		}
+
+		if err := selectQueryOptimizer.Optimize(selectStmt); err != nil {
+			t.Fatal(err)
+		}
+
+		if selectStmt.IndexLookup != nil {
+			t.Errorf("expected no index lookup, got: %v", selectStmt.IndexLookup)
+		}
+	})
+}
+
+func TestSelectStatement_Optimize_Subqueries(t *testing.T) {
+	schema := engine.NewSchemaManager()
+	schema.AddTable("users", &engine.Table{
+		Name: "users",
+		Columns: []engine.Column{
+			{Name: "id", Type: engine.Int, PrimaryKey: true},
+			{Name: "name", Type: engine.Varchar},
+		},
+	})
+	schema.AddTable("orders", &engine.Table{
+		Name: "orders",
+		Columns: []engine.Column{
+			{Name: "id", Type: engine.Int, PrimaryKey: true},
+			{Name: "user_id", Type: engine.Int},
+			{Name: "total", Type: engine.Decimal},
+		},
+	})
+
+	selectQueryOptimizer := SelectQueryOptimizer{
+		Schema: schema,
+	}
+
+	tests := []struct {
+		query string
+		joins []string
+		where string
+	}{
+		{"SELECT name FROM users u WHERE EXISTS (SELECT * FROM orders o WHERE o.user_id = u.id) AND u.id > 1",
+			[]string{"SEMI JOIN orders AS o ON o.user_id = u.id"}, "u.id > 1"},
+		{"SELECT name FROM users WHERE NOT EXISTS (SELECT 1 FROM orders WHERE user_id = users.id AND total > 10)",
+			[]string{"ANTI JOIN orders ON orders.user_id = users.id AND orders.total > 10"}, ""},
+		{"SELECT id FROM orders WHERE user_id IN (SELECT id FROM users WHERE name = 'a')",
+			[]string{"SEMI JOIN users ON orders.user_id = users.id AND users.name = 'a'"}, ""},
+		{"SELECT id FROM users WHERE id NOT IN (SELECT id FROM users WHERE name = 'a')",
+			[]string{"ANTI JOIN users AS users_1 ON users.id = users_1.id AND users_1.name = 'a'"}, ""},
+		{"SELECT id FROM users WHERE id NOT IN (SELECT user_id FROM orders)",
+			nil, "id NOT IN (SELECT user_id FROM orders)"},
+		{"SELECT id FROM users WHERE EXISTS (SELECT * FROM orders)",
+			nil, "EXISTS (SELECT id, user_id, total FROM orders)"},
+		{"SELECT id FROM users WHERE id IN (SELECT MAX(user_id) FROM orders)",
+			nil, "id IN (SELECT MAX(user_id) FROM orders)"},
+		{"SELECT id FROM users WHERE id = 1 OR EXISTS (SELECT * FROM orders WHERE user_id = users.id)",
+			nil, "id = 1 OR EXISTS (SELECT id, user_id, total FROM orders WHERE user_id = id)"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			tokens, err := NewLexer(test.query).Tokenize()
+			if err != nil {
+				t.Fatal(err)
+			}
+			node, err := NewParser(tokens).Parse()
+			if err != nil {
+				t.Fatal(err)
+			}
+			selectStmt := node.(*SelectStatement)
+			if err := selectQueryOptimizer.Optimize(selectStmt); err != nil {
+				t.Fatal(err)
+			}
+
+			var joins []string
+			for _, join := range selectStmt.Joins {
+				joins = append(joins, join.Kind+" "+JOIN+" "+tableReference(join.Table, join.Subquery, join.Alias)+" "+ON+" "+join.On.String())
+			}
+			if !reflect.DeepEqual(joins, test.joins) {
+				t.Errorf("expected joins %v, got %v", test.joins, joins)
+			}
+			where := ""
+			if selectStmt.Where != nil {
+				where = selectStmt.Where.String()
+			}
+			if where != test.where {
+				t.Errorf("expected where %q, got %q", test.where, where)
+			}
+		})
+	}
+}
+
+func TestInsertStatement_Optimize_OnDuplicate(t *testing.T) {
+	schema := engine.NewSchemaManager()
+	schema.AddTable("counters", &engine.Table{
+		Name: "counters",
+		Columns: []engine.Column{
+			{Name: "id", Type: engine.Int},
+			{Name: "hits", Type: engine.Int},
+		},
+	})
+
+	insertQueryOptimizer := InsertQueryOptimizer{
+		Schema: schema,
+	}
+
+	tests := []struct {
+		query string
+		err   string
+	}{
+		{"INSERT INTO counters VALUES (1, 1) ON DUPLICATE KEY UPDATE hits = hits + VALUES(hits)", ""},
+		{"INSERT INTO counters SELECT id, hits FROM counters ON DUPLICATE KEY UPDATE hits = (SELECT MAX(hits) FROM counters)", ""},
+		{"INSERT INTO counters VALUES (1, 1) ON DUPLICATE KEY UPDATE hits = total", "column total not found in table counters"},
+		{"INSERT INTO counters VALUES (1, 1) ON DUPLICATE KEY UPDATE hits = VALUES(total)", "column total not found in table counters"},
+		{"INSERT INTO counters VALUES (1, VALUES(hits))", "invalid use of VALUES(hits)"},
+		{"REPLACE INTO counters SELECT VALUES(id), hits FROM counters", "invalid use of VALUES(id)"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			tokens, err := NewLexer(test.query).Tokenize()
+			if err != nil {
+				t.Fatal(err)
+			}
+			node, err := NewParser(tokens).Parse()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = insertQueryOptimizer.Optimize(node.(*InsertStatement))
+			if test.err == "" && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if test.err != "" && (err == nil || err.Error() != test.err) {
+				t.Errorf("expected error %q, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestSelectStatement_Optimize_Coercion(t *testing.T) {
+	schema := engine.NewSchemaManager()
+	schema.AddTable("events", &engine.Table{
+		Name: "events",
+		Columns: []engine.Column{
+			{Name: "day", Type: engine.Date, PrimaryKey: true},
+			{Name: "public", Type: engine.Boolean},
+			{Name: "seats", Type: engine.Int},
+		},
+	})
+
+	selectQueryOptimizer := SelectQueryOptimizer{
+		Schema: schema,
+	}
+
+	tokens, err := NewLexer("SELECT * FROM events WHERE '2024-01-05' = day AND public = 'TRUE' AND seats > '10'").Tokenize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	node, err := NewParser(tokens).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	selectStmt := node.(*SelectStatement)
+	if err := selectQueryOptimizer.Optimize(selectStmt); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Literals are cast to the type of the column they meet", func(t *testing.T) {
+		where := selectStmt.Where.(*BinaryExpression)
+		day := where.Left.(*BinaryExpression).Left.(*BinaryExpression).Left
+		expected := &Cast{Literal: &Literal{Value: "2024-01-05"}, Type: engine.Date, Value: "2024-01-05"}
+		if !reflect.DeepEqual(day, expected) {
+			t.Errorf("expected: %#v, got: %#v", expected, day)
+		}
+
+		public := where.Left.(*BinaryExpression).Right.(*BinaryExpression).Right
+		if cast, ok := public.(*Cast); !ok || cast.Type != engine.Boolean {
+			t.Errorf("expected a BOOLEAN cast, got: %#v", public)
+		}
+
+		seats := where.Right.(*BinaryExpression).Right
+		if cast, ok := seats.(*Cast); !ok || cast.Type != engine.Int || cast.Value != "10" {
+			t.Errorf("expected the INT 10, got: %#v", seats)
+		}
+	})
+
+	t.Run("Index lookup uses the cast value", func(t *testing.T) {
+		expected := &IndexLookup{Column: "day", Value: "2024-01-05"}
+		if !reflect.DeepEqual(selectStmt.IndexLookup, expected) {
+			t.Errorf("expected: %v, got: %v", expected, selectStmt.IndexLookup)
+		}
+	})
+
+	t.Run("Only numbers that are keys are looked up", func(t *testing.T) {
+		schema.AddTable("seats", &engine.Table{
+			Name:    "seats",
+			Columns: []engine.Column{{Name: "id", Type: engine.Int, PrimaryKey: true}},
+		})
+
+		for query, expected := range map[string]*IndexLookup{
+			"SELECT * FROM seats WHERE id = 2.0":  {Column: "id", Value: "2"},
+			"SELECT * FROM seats WHERE id = 0x0A": {Column: "id", Value: "10"},
+			"SELECT * FROM seats WHERE id = 1.5":  nil,
+			"SELECT * FROM seats WHERE id = 1e99": nil,
+		} {
+			tokens, err := NewLexer(query).Tokenize()
+			if err != nil {
+				t.Fatal(err)
+			}
+			node, err := NewParser(tokens).Parse()
+			if err != nil {
+				t.Fatal(err)
+			}
+			selectStmt := node.(*SelectStatement)
+			if err := selectQueryOptimizer.Optimize(selectStmt); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(selectStmt.IndexLookup, expected) {
+				t.Errorf("%s: expected: %v, got: %v", query, expected, selectStmt.IndexLookup)
+			}
+		}
+	})
+}
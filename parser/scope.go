@@ -0,0 +1,315 @@
+package parser
+
+import (
+	"dbngin3/engine"
+	"errors"
+	"strings"
+)
+
+// scope is the tables a statement reads from, each known by its alias or
+// else its name. In a statement on a single table columns keep their plain
+// names; with joins every column is qualified by its table, which is how
+// the planner names the columns of joined rows. The scope of a subquery has
+// the scope of the enclosing query as its outer one, columns that are none
+// of its own are looked up there.
+type scope struct {
+	schema *engine.SchemaManager
+	outer  *scope
+	tables []scopeTable
+	// using maps a column that USING joined, in lower case, to the table an
+	// unqualified reference to it means: the right one of a RIGHT join, else
+	// the left
+	using map[string]string
+	// conditions are the qualified ON conditions of the joins
+	conditions []Expression
+	// inserted is the table of an INSERT ... ON DUPLICATE KEY UPDATE, whose
+	// columns VALUES(column) may name
+	inserted *engine.Table
+}
+
+// scopeTable is a table of the scope. A derived table has the prepared
+// query that it reads. The table of a SEMI or ANTI join is hidden: only its
+// join condition, which qualifies all its columns, sees it.
+type scopeTable struct {
+	name   string
+	table  *engine.Table
+	query  *SelectStatement
+	hidden bool
+}
+
+// newScope looks up the tables and checks the conditions of the joins,
+// which only see the tables joined so far. Derived tables are prepared in
+// the outer scope, they cannot see the tables next to them.
+func newScope(schema *engine.SchemaManager, table string, subquery *SelectStatement, alias string, joins []Join, outer *scope) (*scope, error) {
+	s := &scope{schema: schema, outer: outer, using: make(map[string]string)}
+	if err := s.add(table, subquery, alias); err != nil {
+		return nil, err
+	}
+
+	for _, join := range joins {
+		if err := s.add(join.Table, join.Subquery, join.Alias); err != nil {
+			return nil, err
+		}
+		right := &s.tables[len(s.tables)-1]
+		right.hidden = join.Kind == SEMI || join.Kind == ANTI
+
+		var condition Expression
+		for _, column := range join.Using {
+			if !containsColumn(right.table.Columns, column) {
+				return nil, errors.New("column " + column + " not found in table " + right.table.Name + " for using clause")
+			}
+			var left *ColumnRef
+			for _, t := range s.tables[:len(s.tables)-1] {
+				if !containsColumn(t.table.Columns, column) {
+					continue
+				}
+				if left != nil {
+					return nil, errors.New("column " + column + " is ambiguous for using clause")
+				}
+				left = &ColumnRef{Table: t.name, Name: column}
+			}
+			if left == nil {
+				return nil, errors.New("unknown column " + column + " for using clause")
+			}
+
+			s.using[strings.ToLower(column)] = left.Table
+			if join.Kind == RIGHT {
+				s.using[strings.ToLower(column)] = right.name
+			}
+			condition = and(condition, &BinaryExpression{Operator: EQUALS, Left: left, Right: &ColumnRef{Table: right.name, Name: column}})
+		}
+
+		if join.On != nil {
+			on, err := s.qualify(join.On, "on")
+			if err != nil {
+				return nil, err
+			}
+			if aggregates := Aggregates(on); len(aggregates) > 0 {
+				return nil, errors.New("invalid use of aggregate function " + aggregates[0].String() + " in on clause")
+			}
+			condition = and(condition, on)
+		}
+		s.conditions = append(s.conditions, condition)
+	}
+	return s, nil
+}
+
+func (s *scope) add(name string, subquery *SelectStatement, alias string) error {
+	var t scopeTable
+	if subquery != nil {
+		query, columns, err := prepareSelect(s.schema, subquery, s.outer)
+		if err != nil {
+			return err
+		}
+		for i, column := range columns {
+			if containsColumn(columns[:i], column.Name) {
+				return errors.New("duplicate column name " + column.Name + " in derived table " + alias)
+			}
+		}
+		t = scopeTable{name: alias, table: &engine.Table{Name: alias, Columns: columns}, query: query}
+	} else {
+		table, err := s.schema.GetTable(name)
+		if err != nil {
+			return errors.New("table " + name + " not found in schema")
+		}
+		if alias == "" {
+			alias = name
+		}
+		t = scopeTable{name: alias, table: table}
+	}
+
+	for _, other := range s.tables {
+		if other.name == t.name {
+			return errors.New("not unique table/alias " + t.name)
+		}
+	}
+	s.tables = append(s.tables, t)
+	return nil
+}
+
+// columns returns every column of every table, for SELECT *.
+func (s *scope) columns() []*ColumnRef {
+	var refs []*ColumnRef
+	for _, t := range s.tables {
+		if t.hidden {
+			continue
+		}
+		for _, column := range t.table.Columns {
+			ref := &ColumnRef{Name: column.Name}
+			if len(s.tables) > 1 {
+				ref.Table = t.name
+			}
+			refs = append(refs, ref)
+		}
+	}
+	return refs
+}
+
+// qualifiedColumns returns the columns of the tables by the names the
+// planner gives them.
+func (s *scope) qualifiedColumns() []engine.Column {
+	var columns []engine.Column
+	for _, t := range s.tables {
+		if t.hidden {
+			continue
+		}
+		for _, column := range t.table.Columns {
+			if len(s.tables) > 1 {
+				column.Name = t.name + "." + column.Name
+			}
+			columns = append(columns, column)
+		}
+	}
+	return columns
+}
+
+// qualify returns the expression with every column resolved, qualified if
+// the scope has several tables, the queries of its subqueries prepared and
+// its literals coerced to the types they meet. It fails for the first
+// unknown or ambiguous column, naming the clause it is in, and for operands
+// of mismatching types.
+func (s *scope) qualify(expr Expression, clause string) (Expression, error) {
+	var err error
+	qualified := Transform(expr, func(e Expression) Expression {
+		if err != nil {
+			return nil
+		}
+
+		switch e := e.(type) {
+		case *ColumnRef:
+			var resolved Expression
+			if resolved, _, err = s.resolve(e, clause); err != nil {
+				return e
+			}
+			return resolved
+		case *InsertValue:
+			if s.inserted == nil {
+				err = errors.New("invalid use of " + e.String())
+				return e
+			}
+			idx := s.inserted.ColumnIndex(e.Column)
+			if idx < 0 {
+				err = errors.New("column " + e.Column + " not found in table " + s.inserted.Name)
+				return e
+			}
+			return &InsertValue{Column: s.inserted.Columns[idx].Name}
+		case *SubqueryExpression:
+			prepared := &SubqueryExpression{}
+			prepared.Select, prepared.Type, err = s.prepareSubquery(e.Select, true)
+			return prepared
+		case *InExpression:
+			prepared := &InExpression{Not: e.Not}
+			if prepared.Operand, err = s.qualify(e.Operand, clause); err == nil {
+				prepared.Select, prepared.Type, err = s.prepareSubquery(e.Select, true)
+			}
+			return prepared
+		case *ExistsExpression:
+			prepared := &ExistsExpression{}
+			prepared.Select, _, err = s.prepareSubquery(e.Select, false)
+			return prepared
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return coerce(qualified, s.typedColumns())
+}
+
+// typedColumns returns the columns of all tables, hidden ones included, by
+// the names qualified expressions give them, followed by those VALUES(column)
+// reads.
+func (s *scope) typedColumns() []engine.Column {
+	var columns []engine.Column
+	for _, t := range s.tables {
+		for _, column := range t.table.Columns {
+			if len(s.tables) > 1 {
+				column.Name = t.name + "." + column.Name
+			}
+			columns = append(columns, column)
+		}
+	}
+	if s.inserted != nil {
+		for _, column := range s.inserted.Columns {
+			column.Name = (&InsertValue{Column: column.Name}).String()
+			columns = append(columns, column)
+		}
+	}
+	return columns
+}
+
+// resolve returns the column the reference means and its type: a column of
+// the scope, or an OuterRef to one of an outer scope if no table of the
+// scope has the column or the name it is qualified with.
+func (s *scope) resolve(ref *ColumnRef, clause string) (Expression, engine.DataType, error) {
+	suffix := ""
+	if clause != "" {
+		suffix = " for " + clause + " clause"
+	}
+
+	var matches []scopeTable
+	known := ref.Table == ""
+	for _, t := range s.tables {
+		if ref.Table != "" && ref.Table != t.name || ref.Table == "" && t.hidden {
+			continue
+		}
+		known = true
+		if containsColumn(t.table.Columns, ref.Name) {
+			matches = append(matches, t)
+		}
+	}
+
+	if s.outer != nil && (!known || len(matches) == 0 && ref.Table == "") {
+		if outer, dataType, err := s.outer.resolve(ref, clause); err == nil {
+			if o, ok := outer.(*OuterRef); ok {
+				deeper := *o
+				deeper.Depth++
+				return &deeper, dataType, nil
+			}
+			return &OuterRef{Column: outer.(*ColumnRef), Depth: 1, Type: dataType}, dataType, nil
+		}
+	}
+
+	switch {
+	case !known:
+		return nil, 0, errors.New("unknown table " + ref.Table + suffix)
+	case len(matches) == 0 && (ref.Table != "" || len(s.tables) == 1):
+		table := s.tables[0].table.Name
+		for _, t := range s.tables {
+			if t.name == ref.Table {
+				table = t.table.Name
+			}
+		}
+		return nil, 0, errors.New("column " + ref.Name + " not found in table " + table + suffix)
+	case len(matches) == 0:
+		return nil, 0, errors.New("unknown column " + ref.Name + suffix)
+	case len(matches) > 1:
+		name, ok := s.using[strings.ToLower(ref.Name)]
+		if !ok {
+			return nil, 0, errors.New("column " + ref.Name + " is ambiguous" + suffix)
+		}
+		for _, t := range matches {
+			if t.name == name {
+				matches[0] = t
+			}
+		}
+	}
+
+	// the column by the name the catalog gives it
+	column := matches[0].table.Columns[matches[0].table.ColumnIndex(ref.Name)]
+	if len(s.tables) == 1 {
+		return &ColumnRef{Name: column.Name}, column.Type, nil
+	}
+	return &ColumnRef{Table: matches[0].name, Name: column.Name}, column.Type, nil
+}
+
+// and joins two conditions, either of which may be missing.
+func and(left, right Expression) Expression {
+	switch {
+	case left == nil:
+		return right
+	case right == nil:
+		return left
+	}
+	return &BinaryExpression{Operator: AND, Left: left, Right: right}
+}
@@ -3,45 +3,382 @@ package parser
 import (
 	"dbngin3/engine"
 	"errors"
+	"strconv"
+	"strings"
 )
 
-type SemanticAnalyzer interface {
-	Analyze(node *ASTNode) error
+// SemanticAnalyzer checks a statement of any kind with the analyzer for its
+// kind. Statements that have none, like transaction control and schema
+// changes, are checked as they run.
+type SemanticAnalyzer struct {
+	Schema *engine.SchemaManager
+}
+
+func (s *SemanticAnalyzer) Analyze(node ASTNode) error {
+	switch node := node.(type) {
+	case *SelectStatement:
+		return (&SelectSemanticAnalyzer{Schema: s.Schema}).Analyze(node)
+	case *InsertStatement:
+		return (&InsertSemanticAnalyzer{Schema: s.Schema}).Analyze(node)
+	case *UpdateStatement:
+		return (&UpdateSemanticAnalyzer{Schema: s.Schema}).Analyze(node)
+	case *DeleteStatement:
+		return (&DeleteSemanticAnalyzer{Schema: s.Schema}).Analyze(node)
+	}
+	return nil
 }
 
 type SelectSemanticAnalyzer struct {
 	Schema *engine.SchemaManager
 }
 
+// Analyze checks that the tables and columns exist and that no column is
+// ambiguous. Table names are compared as the lexer folded them, the way the
+// catalog stores them, column names in any case. A query with a GROUP BY or
+// an aggregate function may only use the columns outside of aggregate
+// functions that it groups by.
 func (s *SelectSemanticAnalyzer) Analyze(selectStmt *SelectStatement) error {
-	table, err := s.Schema.GetTable(selectStmt.Table)
+	return analyzeSelect(s.Schema, selectStmt, nil)
+}
+
+// analyzeSelect analyzes a query, which is a subquery if it has an outer
+// scope. Subqueries are analyzed as the columns they are in are qualified.
+func analyzeSelect(schema *engine.SchemaManager, selectStmt *SelectStatement, outer *scope) error {
+	scope, err := newScope(schema, selectStmt.Table, selectStmt.Subquery, selectStmt.Alias, selectStmt.Joins, outer)
+	if err != nil {
+		return err
+	}
+
+	// the result columns as the optimizer will expand and qualify them
+	var expressions []Expression
+	if selectStmt.Columns[0] == WILDCARD {
+		for _, ref := range scope.columns() {
+			expressions = append(expressions, ref)
+		}
+	}
+	for _, expr := range selectStmt.Expressions {
+		qualified, err := scope.qualify(expr, "")
+		if err != nil {
+			return err
+		}
+		expressions = append(expressions, qualified)
+	}
+
+	where, err := scope.qualify(selectStmt.Where, "where")
+	if err != nil {
+		return err
+	}
+	if aggregates := Aggregates(where); len(aggregates) > 0 {
+		return errors.New("invalid use of aggregate function " + aggregates[0].String() + " in where clause")
+	}
+
+	resolve := func(expr Expression, clause string) (Expression, error) {
+		if position, ok := resultPosition(expr); ok {
+			if position < 1 || position > len(expressions) {
+				return nil, errors.New("unknown column " + strconv.Itoa(position) + " in " + clause + " clause")
+			}
+			return expressions[position-1], nil
+		}
+		if alias, ok := resultAlias(selectStmt, expr); ok {
+			return scope.qualify(alias, "")
+		}
+		return scope.qualify(expr, clause)
+	}
+
+	groupBy := make([]Expression, len(selectStmt.GroupBy))
+	for i, expr := range selectStmt.GroupBy {
+		if groupBy[i], err = resolve(expr, "group"); err != nil {
+			return err
+		}
+		if aggregates := Aggregates(groupBy[i]); len(aggregates) > 0 {
+			return errors.New("invalid use of aggregate function " + aggregates[0].String() + " in group clause")
+		}
+	}
+
+	having, err := scope.qualify(resolveAliases(selectStmt, selectStmt.Having), "having")
+	if err != nil {
+		return err
+	}
+
+	orderBy := make([]Expression, len(selectStmt.OrderBy))
+	for i, item := range selectStmt.OrderBy {
+		if orderBy[i], err = resolve(item.Expression, "order"); err != nil {
+			return err
+		}
+	}
+
+	results := append(append(append([]Expression{}, expressions...), having), orderBy...)
+	aggregates := Aggregates(results...)
+	for _, aggregate := range aggregates {
+		if nested := Aggregates(aggregate.Argument); len(nested) > 0 {
+			return errors.New("invalid use of aggregate function " + nested[0].String() + " in " + aggregate.String())
+		}
+		if _, err := TypeOf(aggregate, scope.qualifiedColumns()); err != nil {
+			return err
+		}
+	}
+
+	if len(groupBy) == 0 && len(aggregates) == 0 && having == nil {
+		return nil
+	}
+	for _, expr := range results {
+		if column := ungroupedColumn(expr, groupBy); column != "" {
+			return errors.New("column " + column + " must appear in the group by clause or be used in an aggregate function")
+		}
+	}
+	return nil
+}
+
+// IsAggregateQuery reports whether the query returns a row per group instead
+// of one per row of the table: it has a GROUP BY or HAVING clause or uses an
+// aggregate function. Without a GROUP BY all rows form one group.
+func IsAggregateQuery(selectStmt *SelectStatement) bool {
+	if len(selectStmt.GroupBy) > 0 || selectStmt.Having != nil {
+		return true
+	}
+	for _, item := range selectStmt.OrderBy {
+		if len(Aggregates(item.Expression)) > 0 {
+			return true
+		}
+	}
+	return len(Aggregates(selectStmt.Expressions...)) > 0
+}
+
+// ungroupedColumn returns a column the expression uses outside of the GROUP
+// BY expressions and of aggregate functions, or "" if there is none.
+func ungroupedColumn(expr Expression, groupBy []Expression) string {
+	if expr == nil {
+		return ""
+	}
+	for _, group := range groupBy {
+		if expr.String() == group.String() {
+			return ""
+		}
+	}
+
+	switch expr := expr.(type) {
+	case *ColumnRef:
+		return expr.String()
+	case *UnaryExpression:
+		return ungroupedColumn(expr.Operand, groupBy)
+	case *BinaryExpression:
+		if column := ungroupedColumn(expr.Left, groupBy); column != "" {
+			return column
+		}
+		return ungroupedColumn(expr.Right, groupBy)
+	case *IsNullExpression:
+		return ungroupedColumn(expr.Operand, groupBy)
+	case *InExpression:
+		if column := ungroupedColumn(expr.Operand, groupBy); column != "" {
+			return column
+		}
+	}
+
+	// a subquery sees the columns of the group, not those of its rows
+	if query := subqueryOf(expr); query != nil {
+		for _, ref := range outerRefs(query) {
+			if ref.Depth > 1 {
+				continue
+			}
+			if column := ungroupedColumn(ref.Column, groupBy); column != "" {
+				return column
+			}
+		}
+	}
+	return ""
+}
+
+type DeleteSemanticAnalyzer struct {
+	Schema *engine.SchemaManager
+}
+
+// Analyze checks that the table and the columns of the where clause exist.
+func (s *DeleteSemanticAnalyzer) Analyze(deleteStmt *DeleteStatement) error {
+	scope, err := newScope(s.Schema, deleteStmt.Table, nil, "", nil, nil)
 	if err != nil {
-		return errors.New("table not found in schema ")
+		return err
 	}
 
-	for i := range selectStmt.Columns {
-		if selectStmt.Columns[i] == WILDCARD {
-			break
+	_, err = scope.qualify(deleteStmt.Where, "where")
+	return err
+}
+
+type InsertSemanticAnalyzer struct {
+	Schema *engine.SchemaManager
+}
+
+// Analyze checks that the table and the named columns exist, that every row
+// or the query has a value for each of the columns and that the values fit
+// them. The expressions of ON DUPLICATE KEY UPDATE are checked like those
+// of an UPDATE, VALUES(column) has the type of the column.
+func (s *InsertSemanticAnalyzer) Analyze(insertStmt *InsertStatement) error {
+	target, err := newScope(s.Schema, insertStmt.Table, nil, "", nil, nil)
+	if err != nil {
+		return err
+	}
+	table := target.tables[0].table
+
+	columns := table.Columns
+	if len(insertStmt.Columns) > 0 {
+		columns = make([]engine.Column, len(insertStmt.Columns))
+		for i, name := range insertStmt.Columns {
+			idx := table.ColumnIndex(name)
+			if idx < 0 {
+				return errors.New("column " + name + " not found in table " + table.Name)
+			}
+			if containsColumn(columns[:i], name) {
+				return errors.New("column " + name + " specified twice")
+			}
+			columns[i] = table.Columns[idx]
 		}
-		
-		if !containsColumn(table.Columns, selectStmt.Columns[i]) {
-			return errors.New("column not found in table ")
+	}
+
+	if insertStmt.Select != nil {
+		_, results, err := prepareSelect(s.Schema, insertStmt.Select, nil)
+		if err != nil {
+			return err
+		}
+		if len(results) != len(columns) {
+			return errors.New("column count doesn't match value count at row 1")
+		}
+		for i, result := range results {
+			if err := checkAssignment(table, columns[i], result.Type); err != nil {
+				return err
+			}
+		}
+	}
+
+	values := &scope{schema: s.Schema}
+	for n, row := range insertStmt.Rows {
+		if len(row) != len(columns) {
+			return errors.New("column count doesn't match value count at row " + strconv.Itoa(n+1))
+		}
+		for i, expr := range row {
+			if expr == nil {
+				continue
+			}
+			qualified, err := values.qualify(expr, "")
+			if err != nil {
+				return err
+			}
+			if err := checkValue(table, columns[i], qualified, nil); err != nil {
+				return err
+			}
 		}
 	}
 
-	whereColumns := selectStmt.WhereClause.GetColumnNames()
-	for i := range whereColumns {
-		if !containsColumn(table.Columns, whereColumns[i]) {
-			return errors.New("column not found in table for where clause")
+	target.inserted = table
+	for name, expr := range insertStmt.OnDuplicate {
+		if err := checkSet(target, name, expr, "on duplicate key update"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type UpdateSemanticAnalyzer struct {
+	Schema *engine.SchemaManager
+}
+
+// Analyze checks that the table and the columns exist and that the values
+// of the SET clause fit their columns.
+func (s *UpdateSemanticAnalyzer) Analyze(updateStmt *UpdateStatement) error {
+	scope, err := newScope(s.Schema, updateStmt.Table, nil, "", nil, nil)
+	if err != nil {
+		return err
+	}
+
+	for name, expr := range updateStmt.Set {
+		if err := checkSet(scope, name, expr, "set"); err != nil {
+			return err
 		}
 	}
 
+	where, err := scope.qualify(updateStmt.Where, "where")
+	if err != nil {
+		return err
+	}
+	if aggregates := Aggregates(where); len(aggregates) > 0 {
+		return errors.New("invalid use of aggregate function " + aggregates[0].String() + " in where clause")
+	}
 	return nil
 }
 
+// checkSet checks an assignment column = expression of the clause to a
+// column of the single table of the scope.
+func checkSet(scope *scope, name string, expr Expression, clause string) error {
+	table := scope.tables[0].table
+	idx := table.ColumnIndex(name)
+	if idx < 0 {
+		return errors.New("column " + name + " not found in table " + table.Name)
+	}
+
+	qualified, err := scope.qualify(expr, clause)
+	if err != nil {
+		return err
+	}
+	if aggregates := Aggregates(qualified); len(aggregates) > 0 {
+		return errors.New("invalid use of aggregate function " + aggregates[0].String() + " in " + clause + " clause")
+	}
+
+	// VALUES(column) has the type of the column
+	qualified = Transform(qualified, func(e Expression) Expression {
+		if value, ok := e.(*InsertValue); ok {
+			return &ColumnRef{Name: value.Column}
+		}
+		return nil
+	})
+	return checkValue(table, table.Columns[idx], qualified, table.Columns)
+}
+
+// checkValue checks that the values of the expression, for rows with the
+// given columns, fit the column of the table. A literal also has to be a
+// value of the column, it is cast to its type.
+func checkValue(table *engine.Table, column engine.Column, expr Expression, columns []engine.Column) error {
+	literal, isLiteral := expr.(*Literal)
+	if isLiteral && literal.Value == engine.Null {
+		if column.NotNull || column.PrimaryKey {
+			return errors.New("column " + column.Name + " of table " + table.Name + " cannot be null")
+		}
+		return nil
+	}
+
+	dataType, err := TypeOf(expr, columns)
+	if err != nil {
+		return err
+	}
+	if err := checkAssignment(table, column, dataType); err != nil {
+		return err
+	}
+	if isLiteral {
+		_, err = castLiteral(literal, column)
+	}
+	return err
+}
+
+// checkAssignment checks that a value of the type can be stored in the
+// column. Numbers and booleans, which are TINYINT(1) in MySQL, go into
+// numeric and boolean columns, points in time into DATE and TIMESTAMP
+// columns, anything into a string column and strings into any column, which
+// has to parse them.
+func checkAssignment(table *engine.Table, column engine.Column, dataType engine.DataType) error {
+	numeric := func(dataType engine.DataType) bool {
+		return dataType.IsNumeric() || dataType == engine.Boolean
+	}
+	switch {
+	case dataType == column.Type, isString(column.Type), isString(dataType):
+		return nil
+	case numeric(dataType) && numeric(column.Type):
+		return nil
+	case isTemporal(column.Type) && isTemporal(dataType):
+		return nil
+	}
+	return errors.New("cannot store " + dataType.String() + " in column " + column.Name + " of table " + table.Name)
+}
+
 func containsColumn(columns []engine.Column, col string) bool {
 	for _, c := range columns {
-		if c.Name == col {
+		if strings.EqualFold(c.Name, col) {
 			return true
 		}
 	}
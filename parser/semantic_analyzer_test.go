@@ -16,8 +16,9 @@ func TestSelectStatement_Analyze_SimpleSelectQuery(t *testing.T) {
 	})
 
 	selectStmt := &SelectStatement{
-		Table:   "users",
-		Columns: []string{"id", "name"},
+		Table:       "users",
+		Columns:     []string{"id", "name"},
+		Expressions: []Expression{&ColumnRef{Name: "id"}, &ColumnRef{Name: "name"}},
 	}
 
 	selectSemanticAnalyzer := SelectSemanticAnalyzer{
@@ -43,16 +44,13 @@ func TestSelectStatement_Analyze_SelectQueryWithWhereClause(t *testing.T) {
 	})
 
 	selectStmt := &SelectStatement{
-		Table:   "users",
-		Columns: []string{"id", "name"},
-		WhereClause: &WhereClause{
-			Type: EQUALS,
-			Left: &WhereClause{
-				Name: "id",
-			},
-			Right: &WhereClause{
-				Value: "12",
-			},
+		Table:       "users",
+		Columns:     []string{"id", "name"},
+		Expressions: []Expression{&ColumnRef{Name: "id"}, &ColumnRef{Name: "name"}},
+		Where: &BinaryExpression{
+			Operator: EQUALS,
+			Left:     &ColumnRef{Name: "id"},
+			Right:    &Literal{Value: "12"},
 		},
 	}
 
@@ -67,3 +65,465 @@ func TestSelectStatement_Analyze_SelectQueryWithWhereClause(t *testing.T) {
 		}
 	})
 }
+
+func TestSelectStatement_Analyze_UnknownColumnInExpression(t *testing.T) {
+	schema := engine.NewSchemaManager()
+	schema.AddTable("users", &engine.Table{
+		Name: "users",
+		Columns: []engine.Column{
+			{Name: "id", Type: engine.Int},
+			{Name: "name", Type: engine.Varchar},
+		},
+	})
+
+	selectSemanticAnalyzer := SelectSemanticAnalyzer{
+		Schema: schema,
+	}
+
+	selectStmt := &SelectStatement{
+		Table:   "users",
+		Columns: []string{"id + age"},
+		Expressions: []Expression{
+			&BinaryExpression{Operator: PLUS, Left: &ColumnRef{Name: "id"}, Right: &ColumnRef{Name: "age"}},
+		},
+	}
+	if err := selectSemanticAnalyzer.Analyze(selectStmt); err == nil {
+		t.Error("expected an error for the unknown column age")
+	}
+
+	selectStmt = &SelectStatement{
+		Table:       "users",
+		Columns:     []string{"id"},
+		Expressions: []Expression{&ColumnRef{Name: "id"}},
+		Where:       &BinaryExpression{Operator: EQUALS, Left: &ColumnRef{Name: "id"}, Right: &ColumnRef{Name: "age"}},
+	}
+	if err := selectSemanticAnalyzer.Analyze(selectStmt); err == nil {
+		t.Error("expected an error for the unknown column age in the where clause")
+	}
+}
+
+func TestSelectStatement_Analyze_OrderBy(t *testing.T) {
+	schema := engine.NewSchemaManager()
+	schema.AddTable("users", &engine.Table{
+		Name: "users",
+		Columns: []engine.Column{
+			{Name: "id", Type: engine.Int},
+			{Name: "name", Type: engine.Varchar},
+		},
+	})
+
+	selectSemanticAnalyzer := SelectSemanticAnalyzer{
+		Schema: schema,
+	}
+
+	tests := []struct {
+		orderBy Expression
+		columns []string
+		valid   bool
+	}{
+		{&ColumnRef{Name: "name"}, []string{"id"}, true},
+		{&ColumnRef{Name: "label"}, []string{"label"}, true},
+		{&ColumnRef{Name: "age"}, []string{"id"}, false},
+		{&Literal{Value: "1"}, []string{"id"}, true},
+		{&Literal{Value: "2"}, []string{"id"}, false},
+		{&Literal{Value: "2"}, []string{"*"}, true},
+		{&Literal{Value: "0"}, []string{"*"}, false},
+	}
+
+	for _, test := range tests {
+		selectStmt := &SelectStatement{
+			Table:   "users",
+			Columns: test.columns,
+			OrderBy: []OrderByItem{{Expression: test.orderBy}},
+		}
+		if test.columns[0] != WILDCARD {
+			selectStmt.Expressions = []Expression{&ColumnRef{Name: "id"}}
+		}
+
+		err := selectSemanticAnalyzer.Analyze(selectStmt)
+		if test.valid && err != nil {
+			t.Errorf("ORDER BY %s: %v", test.orderBy, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("ORDER BY %s: expected an error", test.orderBy)
+		}
+	}
+}
+
+func TestSelectStatement_Analyze_GroupBy(t *testing.T) {
+	schema := engine.NewSchemaManager()
+	schema.AddTable("users", &engine.Table{
+		Name: "users",
+		Columns: []engine.Column{
+			{Name: "id", Type: engine.Int},
+			{Name: "name", Type: engine.Varchar},
+			{Name: "age", Type: engine.Int},
+		},
+	})
+
+	selectSemanticAnalyzer := SelectSemanticAnalyzer{
+		Schema: schema,
+	}
+
+	tests := []struct {
+		query string
+		valid bool
+	}{
+		{"SELECT COUNT(*), SUM(age), AVG(age), MIN(name), MAX(id) FROM users", true},
+		{"SELECT name, COUNT(DISTINCT age) FROM users GROUP BY name", true},
+		{"SELECT name AS n, COUNT(*) AS c FROM users GROUP BY n HAVING c > 1 ORDER BY c", true},
+		{"SELECT age + 1, COUNT(*) FROM users GROUP BY age + 1 ORDER BY 1", true},
+		{"SELECT age * 2 FROM users GROUP BY age", true},
+		{"SELECT name, age FROM users GROUP BY 1, 2", true},
+		{"SELECT * FROM users GROUP BY id, name, age", true},
+		{"SELECT COUNT(*) FROM users HAVING MAX(age) > 30", true},
+		{"SELECT name, age FROM users GROUP BY name", false},
+		{"SELECT name, COUNT(*) FROM users", false},
+		{"SELECT * FROM users GROUP BY id", false},
+		{"SELECT age + 1 FROM users GROUP BY age + 2", false},
+		{"SELECT name FROM users GROUP BY name HAVING age > 1", false},
+		{"SELECT name FROM users GROUP BY name ORDER BY age", false},
+		{"SELECT name FROM users HAVING name = 'a'", false},
+		{"SELECT COUNT(*) FROM users WHERE COUNT(*) > 1", false},
+		{"SELECT COUNT(*) FROM users GROUP BY COUNT(*)", false},
+		{"SELECT COUNT(*) AS c FROM users GROUP BY c", false},
+		{"SELECT COUNT(*) FROM users GROUP BY 2", false},
+		{"SELECT SUM(MAX(age)) FROM users", false},
+		{"SELECT SUM(name) FROM users", false},
+		{"SELECT MAX(height) FROM users", false},
+		{"SELECT COUNT(*) FROM users GROUP BY height", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			tokens, err := NewLexer(test.query).Tokenize()
+			if err != nil {
+				t.Fatal(err)
+			}
+			node, err := NewParser(tokens).Parse()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = selectSemanticAnalyzer.Analyze(node.(*SelectStatement))
+			if test.valid && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if !test.valid && err == nil {
+				t.Errorf("expected an error")
+			}
+		})
+	}
+}
+
+func TestSelectStatement_Analyze_Joins(t *testing.T) {
+	schema := engine.NewSchemaManager()
+	schema.AddTable("users", &engine.Table{
+		Name: "users",
+		Columns: []engine.Column{
+			{Name: "id", Type: engine.Int},
+			{Name: "name", Type: engine.Varchar},
+		},
+	})
+	schema.AddTable("orders", &engine.Table{
+		Name: "orders",
+		Columns: []engine.Column{
+			{Name: "id", Type: engine.Int},
+			{Name: "user_id", Type: engine.Int},
+			{Name: "total", Type: engine.Decimal},
+		},
+	})
+
+	selectSemanticAnalyzer := SelectSemanticAnalyzer{
+		Schema: schema,
+	}
+
+	tests := []struct {
+		query string
+		err   string
+	}{
+		{"SELECT u.name, total FROM users u JOIN orders o ON u.id = o.user_id", ""},
+		{"SELECT users.name FROM users LEFT JOIN orders ON users.id = orders.user_id WHERE orders.id IS NULL", ""},
+		{"SELECT id FROM users a JOIN users b USING (id, name)", ""},
+		{"SELECT name, SUM(total) FROM users JOIN orders ON users.id = user_id GROUP BY users.name", ""},
+		{"SELECT u.id FROM users AS u, orders", ""},
+		{"SELECT id FROM users JOIN orders ON users.id = orders.user_id", "column id is ambiguous"},
+		{"SELECT u.id FROM users JOIN orders ON users.id = user_id", "unknown table u"},
+		{"SELECT users.id FROM users u", "unknown table users"},
+		{"SELECT u.age FROM users u JOIN orders ON true", "column age not found in table users"},
+		{"SELECT age FROM users JOIN orders ON true", "unknown column age"},
+		{"SELECT 1 FROM users JOIN users ON true", "not unique table/alias users"},
+		{"SELECT 1 FROM users u JOIN orders u ON true", "not unique table/alias u"},
+		{"SELECT 1 FROM users JOIN items ON true", "table items not found in schema"},
+		{"SELECT 1 FROM users u JOIN orders o ON u.id = x.id", "unknown table x for on clause"},
+		{"SELECT 1 FROM users JOIN orders ON COUNT(*) > 1", "invalid use of aggregate function COUNT(*) in on clause"},
+		{"SELECT 1 FROM users JOIN orders USING (name)", "column name not found in table orders for using clause"},
+		{"SELECT 1 FROM users JOIN orders ON true JOIN users p USING (id)", "column id is ambiguous for using clause"},
+		{"SELECT name, SUM(total) FROM users JOIN orders ON users.id = user_id GROUP BY users.id", "column users.name must appear in the group by clause or be used in an aggregate function"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			tokens, err := NewLexer(test.query).Tokenize()
+			if err != nil {
+				t.Fatal(err)
+			}
+			node, err := NewParser(tokens).Parse()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = selectSemanticAnalyzer.Analyze(node.(*SelectStatement))
+			if test.err == "" && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if test.err != "" && (err == nil || err.Error() != test.err) {
+				t.Errorf("expected error %q, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestSelectStatement_Analyze_Subqueries(t *testing.T) {
+	schema := engine.NewSchemaManager()
+	schema.AddTable("users", &engine.Table{
+		Name: "users",
+		Columns: []engine.Column{
+			{Name: "id", Type: engine.Int},
+			{Name: "name", Type: engine.Varchar},
+		},
+	})
+	schema.AddTable("orders", &engine.Table{
+		Name: "orders",
+		Columns: []engine.Column{
+			{Name: "id", Type: engine.Int},
+			{Name: "user_id", Type: engine.Int},
+			{Name: "total", Type: engine.Decimal},
+		},
+	})
+
+	selectSemanticAnalyzer := SelectSemanticAnalyzer{
+		Schema: schema,
+	}
+
+	tests := []struct {
+		query string
+		err   string
+	}{
+		{"SELECT name, (SELECT COUNT(*) FROM orders WHERE user_id = users.id) FROM users", ""},
+		{"SELECT name FROM users u WHERE EXISTS (SELECT * FROM orders o WHERE o.user_id = u.id AND name = 'a')", ""},
+		{"SELECT id FROM users WHERE id IN (SELECT user_id FROM orders WHERE total > (SELECT AVG(total) FROM orders))", ""},
+		{"SELECT d.n FROM (SELECT user_id, COUNT(*) AS n FROM orders GROUP BY user_id) d JOIN users ON users.id = d.user_id", ""},
+		{"SELECT name, (SELECT MAX(total) FROM orders WHERE user_id = users.id) FROM users GROUP BY id, name", ""},
+		{"SELECT id FROM users WHERE id IN (SELECT id, user_id FROM orders)", "operand should contain 1 column"},
+		{"SELECT (SELECT * FROM orders) FROM users", "operand should contain 1 column"},
+		{"SELECT id FROM users WHERE EXISTS (SELECT * FROM orders WHERE price > 1)", "column price not found in table orders for where clause"},
+		{"SELECT id FROM users WHERE EXISTS (SELECT * FROM orders WHERE x.id = 1)", "unknown table x for where clause"},
+		{"SELECT id FROM users WHERE EXISTS (SELECT * FROM orders o WHERE o.name = 'a')", "column name not found in table orders for where clause"},
+		{"SELECT id FROM users WHERE EXISTS (SELECT * FROM orders JOIN users u ON true WHERE id = 1)", "column id is ambiguous for where clause"},
+		{"SELECT d.id FROM (SELECT id, user_id AS id FROM orders) d", "duplicate column name id in derived table d"},
+		{"SELECT id FROM (SELECT id FROM orders WHERE user_id = users.id) d, users", "unknown table users for where clause"},
+		{"SELECT d.id FROM (SELECT id FROM orders) d WHERE d.total > 1", "column total not found in table d for where clause"},
+		{"SELECT COUNT(*), (SELECT total FROM orders WHERE user_id = users.id) FROM users", "column id must appear in the group by clause or be used in an aggregate function"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			tokens, err := NewLexer(test.query).Tokenize()
+			if err != nil {
+				t.Fatal(err)
+			}
+			node, err := NewParser(tokens).Parse()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = selectSemanticAnalyzer.Analyze(node.(*SelectStatement))
+			if test.err == "" && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if test.err != "" && (err == nil || err.Error() != test.err) {
+				t.Errorf("expected error %q, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestDeleteStatement_Analyze(t *testing.T) {
+	schema := engine.NewSchemaManager()
+	schema.AddTable("users", &engine.Table{
+		Name: "users",
+		Columns: []engine.Column{
+			{Name: "id", Type: engine.Int},
+			{Name: "name", Type: engine.Varchar},
+		},
+	})
+
+	deleteSemanticAnalyzer := DeleteSemanticAnalyzer{
+		Schema: schema,
+	}
+
+	deleteStmt := &DeleteStatement{
+		Table: "users",
+		Where: &BinaryExpression{Operator: EQUALS, Left: &ColumnRef{Name: "id"}, Right: &Literal{Value: "12"}},
+	}
+	if err := deleteSemanticAnalyzer.Analyze(deleteStmt); err != nil {
+		t.Error(err)
+	}
+
+	deleteStmt = &DeleteStatement{Table: "people"}
+	if err := deleteSemanticAnalyzer.Analyze(deleteStmt); err == nil {
+		t.Error("expected an error for the unknown table people")
+	}
+
+	deleteStmt = &DeleteStatement{
+		Table: "users",
+		Where: &IsNullExpression{Operand: &ColumnRef{Name: "age"}},
+	}
+	if err := deleteSemanticAnalyzer.Analyze(deleteStmt); err == nil {
+		t.Error("expected an error for the unknown column age in the where clause")
+	}
+}
+
+func TestSemanticAnalyzer_Analyze_Changes(t *testing.T) {
+	schema := engine.NewSchemaManager()
+	schema.AddTable("users", &engine.Table{
+		Name: "users",
+		Columns: []engine.Column{
+			{Name: "id", Type: engine.Int, PrimaryKey: true, NotNull: true},
+			{Name: "name", Type: engine.Varchar},
+			{Name: "active", Type: engine.Boolean},
+			{Name: "born", Type: engine.Date},
+		},
+	})
+	schema.AddTable("orders", &engine.Table{
+		Name: "orders",
+		Columns: []engine.Column{
+			{Name: "id", Type: engine.Int},
+			{Name: "user_id", Type: engine.Int},
+			{Name: "placed", Type: engine.Timestamp},
+		},
+	})
+
+	semanticAnalyzer := SemanticAnalyzer{
+		Schema: schema,
+	}
+
+	tests := []struct {
+		query string
+		err   string
+	}{
+		{"INSERT INTO users VALUES (1, 'John', 1, '2000-01-01'), (2, NULL, TRUE, DEFAULT)", ""},
+		{"INSERT INTO users (id, born) SELECT id, placed FROM orders", ""},
+		{"INSERT INTO users (id, name) VALUES (1, 'a') ON DUPLICATE KEY UPDATE name = email", "column email not found in table users for on duplicate key update clause"},
+		{"INSERT INTO users (id, name) VALUES (1, 'a') ON DUPLICATE KEY UPDATE born = VALUES(born), id = id + 1", ""},
+		{"INSERT INTO people VALUES (1)", "table people not found in schema"},
+		{"INSERT INTO users (id, email) VALUES (1, 'a')", "column email not found in table users"},
+		{"INSERT INTO users (id, id) VALUES (1, 2)", "column id specified twice"},
+		{"INSERT INTO users (id, name) VALUES (1, 'a'), (2)", "column count doesn't match value count at row 2"},
+		{"INSERT INTO users (id) SELECT id, user_id FROM orders", "column count doesn't match value count at row 1"},
+		{"INSERT INTO users (id) VALUES (NULL)", "column id of table users cannot be null"},
+		{"INSERT INTO users (id, born) VALUES (1, 20000101)", "cannot store BIGINT in column born of table users"},
+		{"INSERT INTO users (id, active) SELECT id, placed FROM orders", "cannot store TIMESTAMP in column active of table users"},
+		{"INSERT INTO users (id) VALUES (user_id)", "unknown column user_id"},
+		{"INSERT INTO users (id, name) VALUES (1, 'a') ON DUPLICATE KEY UPDATE born = id", "cannot store INT in column born of table users"},
+		{"UPDATE users SET name = name, active = id > 1 WHERE born < '2000-01-01'", ""},
+		{"UPDATE users SET name = (SELECT MAX(id) FROM orders WHERE user_id = users.id)", ""},
+		{"UPDATE people SET name = 'a'", "table people not found in schema"},
+		{"UPDATE users SET email = 'a'", "column email not found in table users"},
+		{"UPDATE users SET name = email", "column email not found in table users for set clause"},
+		{"UPDATE users SET name = 'a' WHERE email = 'b'", "column email not found in table users for where clause"},
+		{"UPDATE users SET id = NULL", "column id of table users cannot be null"},
+		{"UPDATE users SET born = active", "cannot store BOOLEAN in column born of table users"},
+		{"UPDATE users SET id = COUNT(*)", "invalid use of aggregate function COUNT(*) in set clause"},
+		{"UPDATE users SET name = VALUES(name)", "invalid use of VALUES(name)"},
+		{"DELETE FROM users WHERE email = 'a'", "column email not found in table users for where clause"},
+		{"SELECT email FROM users", "column email not found in table users"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			tokens, err := NewLexer(test.query).Tokenize()
+			if err != nil {
+				t.Fatal(err)
+			}
+			node, err := NewParser(tokens).Parse()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = semanticAnalyzer.Analyze(node)
+			if test.err == "" && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if test.err != "" && (err == nil || err.Error() != test.err) {
+				t.Errorf("expected error %q, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestSemanticAnalyzer_Analyze_Types(t *testing.T) {
+	schema := engine.NewSchemaManager()
+	schema.AddTable("users", &engine.Table{
+		Name: "users",
+		Columns: []engine.Column{
+			{Name: "id", Type: engine.Int, PrimaryKey: true, NotNull: true},
+			{Name: "name", Type: engine.Varchar, Length: 5},
+			{Name: "active", Type: engine.Boolean},
+			{Name: "born", Type: engine.Date},
+		},
+	})
+
+	semanticAnalyzer := SemanticAnalyzer{
+		Schema: schema,
+	}
+
+	tests := []struct {
+		query string
+		err   string
+	}{
+		{"SELECT id FROM users WHERE id = '1' AND '2000-01-01' < born AND active = 'true'", ""},
+		{"SELECT id FROM users WHERE id + 1.5 > 2 AND -id < 0 AND id IN (SELECT id FROM users)", ""},
+		{"SELECT id FROM users WHERE id = 'abc'", "invalid number \"abc\" for column id"},
+		{"SELECT id FROM users WHERE born > 'yesterday'", "invalid date \"yesterday\" for column born"},
+		{"SELECT id FROM users WHERE active = 'maybe'", "invalid boolean \"maybe\" for column active"},
+		{"SELECT id FROM users WHERE born IN (SELECT born FROM users WHERE id = 1) AND 'x' IN (SELECT born FROM users)", "invalid date \"x\" for column born"},
+		{"SELECT id FROM users WHERE born = active", "cannot compare DATE with BOOLEAN"},
+		{"SELECT name + 1 FROM users", "operator + needs numbers, got VARCHAR"},
+		{"SELECT -born FROM users", "operator - needs a number, got DATE"},
+		{"SELECT id FROM users WHERE 'a' = 'b' AND id = NULL AND name = 'Johnny'", ""},
+		{"INSERT INTO users VALUES (1, 'John', 'yes', '2000-02-30')", "invalid boolean \"yes\" for column active"},
+		{"INSERT INTO users (id, born) VALUES (1, '2000-02-30')", "invalid date \"2000-02-30\" for column born"},
+		{"INSERT INTO users (id, name) VALUES ('one', 'John')", "invalid integer \"one\" for column id"},
+		{"INSERT INTO users (id, name) VALUES (1, 'Johnny')", "value too long for column name"},
+		{"UPDATE users SET born = '2000-01-01', active = 0 WHERE id = '7'", ""},
+		{"UPDATE users SET born = 'soon'", "invalid date \"soon\" for column born"},
+		{"UPDATE users SET id = 0x0F, active = b'1' WHERE id = x'01' OR id = 2.0 OR id = 1.5", ""},
+		{"UPDATE users SET id = TRUE WHERE active", ""},
+		{"INSERT INTO users (id, name) SELECT active, name FROM users", ""},
+		{"UPDATE users SET id = X'FFFFFFFFFF'", "value 1099511627775 out of range for column id"},
+		{"UPDATE users SET id = 1 WHERE name > 'a' - 1", "operator - needs numbers, got VARCHAR"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			tokens, err := NewLexer(test.query).Tokenize()
+			if err != nil {
+				t.Fatal(err)
+			}
+			node, err := NewParser(tokens).Parse()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = semanticAnalyzer.Analyze(node)
+			if test.err == "" && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if test.err != "" && (err == nil || err.Error() != test.err) {
+				t.Errorf("expected error %q, got %v", test.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,222 @@
+package parser
+
+import (
+	"dbngin3/engine"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// String renders the statement as SQL, it names the result column of a
+// subquery.
+func (s *SelectStatement) String() string {
+	var b strings.Builder
+	b.WriteString(SELECT + " ")
+	if len(s.Expressions) == 0 {
+		b.WriteString(strings.Join(s.Columns, ", "))
+	}
+	for i, expr := range s.Expressions {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(expr.String())
+		if i < len(s.Columns) && s.Columns[i] != expr.String() {
+			b.WriteString(" " + AS + " " + s.Columns[i])
+		}
+	}
+
+	if s.Table != "" || s.Subquery != nil {
+		b.WriteString(" " + FROM + " " + tableReference(s.Table, s.Subquery, s.Alias))
+	}
+	for _, join := range s.Joins {
+		b.WriteString(" " + join.Kind + " " + JOIN + " " + tableReference(join.Table, join.Subquery, join.Alias))
+		if join.On != nil {
+			b.WriteString(" " + ON + " " + join.On.String())
+		} else if len(join.Using) > 0 {
+			b.WriteString(" " + USING + " (" + strings.Join(join.Using, ", ") + ")")
+		}
+	}
+
+	if s.Where != nil {
+		b.WriteString(" " + WHERE + " " + s.Where.String())
+	}
+	for i, expr := range s.GroupBy {
+		if i == 0 {
+			b.WriteString(" " + GROUP + " " + BY + " ")
+		} else {
+			b.WriteString(", ")
+		}
+		b.WriteString(expr.String())
+	}
+	if s.Having != nil {
+		b.WriteString(" " + HAVING + " " + s.Having.String())
+	}
+	for i, item := range s.OrderBy {
+		if i == 0 {
+			b.WriteString(" " + ORDER + " " + BY + " ")
+		} else {
+			b.WriteString(", ")
+		}
+		b.WriteString(item.Expression.String())
+		if item.Desc {
+			b.WriteString(" " + DESC)
+		}
+		// NULL comes first in ascending order unless NULLS says otherwise
+		if item.NullsFirst && item.Desc {
+			b.WriteString(" " + NULLS + " " + FIRST)
+		} else if !item.NullsFirst && !item.Desc {
+			b.WriteString(" " + NULLS + " " + LAST)
+		}
+	}
+	if s.Limit != nil {
+		b.WriteString(" " + LIMIT + " " + strconv.Itoa(*s.Limit))
+		if s.Offset > 0 {
+			b.WriteString(" " + OFFSET + " " + strconv.Itoa(s.Offset))
+		}
+	}
+	return b.String()
+}
+
+func tableReference(table string, subquery *SelectStatement, alias string) string {
+	if subquery != nil {
+		table = "(" + subquery.String() + ")"
+	}
+	if alias != "" {
+		return table + " " + AS + " " + alias
+	}
+	return table
+}
+
+// copySelect copies the parts of the statement the optimizer changes, so
+// that a subquery can be prepared without changing the statement.
+func copySelect(stmt *SelectStatement) *SelectStatement {
+	c := *stmt
+	c.Columns = append([]string{}, stmt.Columns...)
+	c.Expressions = append([]Expression{}, stmt.Expressions...)
+	c.Joins = append([]Join{}, stmt.Joins...)
+	c.GroupBy = append([]Expression{}, stmt.GroupBy...)
+	c.OrderBy = append([]OrderByItem{}, stmt.OrderBy...)
+	return &c
+}
+
+// prepareSelect analyzes and optimizes a copy of a query nested in another
+// one, whose scope is outer, and returns it with its result columns.
+func prepareSelect(schema *engine.SchemaManager, stmt *SelectStatement, outer *scope) (*SelectStatement, []engine.Column, error) {
+	prepared := copySelect(stmt)
+	if err := analyzeSelect(schema, prepared, outer); err != nil {
+		return nil, nil, err
+	}
+	columns, err := optimizeSelect(schema, prepared, outer)
+	if err != nil {
+		return nil, nil, err
+	}
+	return prepared, columns, nil
+}
+
+// prepareSubquery prepares the query of a subquery in the scope, which has
+// to return a single column unless all it is asked is whether it returns a
+// row.
+func (s *scope) prepareSubquery(stmt *SelectStatement, single bool) (*SelectStatement, engine.DataType, error) {
+	prepared, columns, err := prepareSelect(s.schema, stmt, s)
+	if err != nil {
+		return nil, 0, err
+	}
+	if !single {
+		return prepared, 0, nil
+	}
+	if len(columns) != 1 {
+		return nil, 0, errors.New("operand should contain 1 column")
+	}
+	return prepared, columns[0].Type, nil
+}
+
+// IsCorrelated reports whether a prepared query refers to columns of
+// enclosing queries, which makes its result depend on their current rows.
+func IsCorrelated(stmt *SelectStatement) bool {
+	return len(outerRefs(stmt)) > 0
+}
+
+// outerRefs returns the columns of enclosing queries that a prepared query
+// or the subqueries in it refer to, with their depth counted from the query.
+func outerRefs(stmt *SelectStatement) []*OuterRef {
+	expressions := append(append([]Expression{stmt.Where, stmt.Having}, stmt.Expressions...), stmt.GroupBy...)
+	expressions = append(expressions, orderByExpressions(stmt.OrderBy)...)
+	for _, join := range stmt.Joins {
+		expressions = append(expressions, join.On)
+	}
+
+	// derived tables see the queries the query sees
+	var refs []*OuterRef
+	for _, subquery := range derivedTables(stmt) {
+		refs = append(refs, outerRefs(subquery)...)
+	}
+
+	for _, expr := range expressions {
+		Transform(expr, func(e Expression) Expression {
+			if ref, ok := e.(*OuterRef); ok {
+				refs = append(refs, ref)
+				return e
+			}
+			if query := subqueryOf(e); query != nil {
+				for _, ref := range outerRefs(query) {
+					if ref.Depth > 1 {
+						outer := *ref
+						outer.Depth--
+						refs = append(refs, &outer)
+					}
+				}
+			}
+			return nil
+		})
+	}
+	return refs
+}
+
+func derivedTables(stmt *SelectStatement) []*SelectStatement {
+	var tables []*SelectStatement
+	if stmt.Subquery != nil {
+		tables = append(tables, stmt.Subquery)
+	}
+	for _, join := range stmt.Joins {
+		if join.Subquery != nil {
+			tables = append(tables, join.Subquery)
+		}
+	}
+	return tables
+}
+
+// subqueryOf returns the query of a subquery node, or nil for other nodes.
+func subqueryOf(expr Expression) *SelectStatement {
+	switch expr := expr.(type) {
+	case *SubqueryExpression:
+		return expr.Select
+	case *InExpression:
+		return expr.Select
+	case *ExistsExpression:
+		return expr.Select
+	}
+	return nil
+}
+
+// containsSubquery reports whether there is a subquery in the expressions.
+func containsSubquery(expressions ...Expression) bool {
+	found := false
+	for _, expr := range expressions {
+		Transform(expr, func(e Expression) Expression {
+			if subqueryOf(e) != nil {
+				found = true
+				return e
+			}
+			return nil
+		})
+	}
+	return found
+}
+
+func orderByExpressions(items []OrderByItem) []Expression {
+	expressions := make([]Expression, len(items))
+	for i, item := range items {
+		expressions[i] = item.Expression
+	}
+	return expressions
+}
@@ -0,0 +1,64 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SyntaxError is a statement that cannot be read. Expected says what should
+// have come at Pos and Found is the token that came instead, empty at the
+// end of the query. Errors that are not about a missing token have a
+// Message instead.
+type SyntaxError struct {
+	Query    string
+	Pos      Position
+	Expected string
+	Found    string
+	Message  string
+}
+
+// Error describes the error and, if the query is known, shows its line with
+// a caret under the offending token:
+//
+//	syntax error at line 1, column 12: expected expression, found "FROM"
+//	SELECT id, FROM users
+//	           ^
+func (e *SyntaxError) Error() string {
+	message := e.Message
+	if message == "" {
+		found := "end of query"
+		if e.Found != "" {
+			found = strconv.Quote(e.Found)
+		}
+		message = "expected " + e.Expected + ", found " + found
+	}
+
+	text := fmt.Sprintf("syntax error at line %d, column %d: %s", e.Pos.Line, e.Pos.Column, message)
+	if e.Query == "" {
+		return text
+	}
+
+	lines := strings.Split(e.Query, "\n")
+	if e.Pos.Line < 1 || e.Pos.Line > len(lines) {
+		return text
+	}
+	line := strings.TrimRight(lines[e.Pos.Line-1], "\r")
+
+	// keep the tabs of the line so that the caret lines up with it
+	var caret strings.Builder
+	for i, r := range []rune(line) {
+		if i >= e.Pos.Column-1 {
+			break
+		}
+		if r == '\t' {
+			caret.WriteRune('\t')
+		} else {
+			caret.WriteRune(' ')
+		}
+	}
+	for i := len([]rune(line)); i < e.Pos.Column-1; i++ {
+		caret.WriteRune(' ')
+	}
+	return text + "\n" + line + "\n" + caret.String() + "^"
+}
@@ -0,0 +1,148 @@
+package parser
+
+import "testing"
+
+func parseQuery(query string) error {
+	tokens, err := NewLexer(query).Tokenize()
+	if err != nil {
+		return err
+	}
+	parser := NewParser(tokens)
+	if err := parser.SetInput(query); err != nil {
+		return err
+	}
+	_, err = parser.Parse()
+	return err
+}
+
+func TestSyntaxError_Error(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected string
+	}{
+		{
+			"SELECT id, FROM users",
+			"syntax error at line 1, column 12: expected expression, found \"FROM\"\n" +
+				"SELECT id, FROM users\n" +
+				"           ^",
+		},
+		{
+			"SELECT id\nFROM users\nWHERE id = ",
+			"syntax error at line 3, column 11: expected expression, found end of query\n" +
+				"WHERE id = \n" +
+				"          ^",
+		},
+		{
+			"UPDATE users SET\tname 'marty'",
+			"syntax error at line 1, column 23: expected =, found \"marty\"\n" +
+				"UPDATE users SET\tname 'marty'\n" +
+				"                \t     ^",
+		},
+		{
+			"CREATE TABLE t (id INT, PRIMARY KEY (name))",
+			"syntax error at line 1, column 38: column name of constraint is not defined\n" +
+				"CREATE TABLE t (id INT, PRIMARY KEY (name))\n" +
+				"                                     ^",
+		},
+		{
+			"SELECT * FROM users WHERE name = 'ä' ; 1",
+			"syntax error at line 1, column 40: expected end of query, found \"1\"\n" +
+				"SELECT * FROM users WHERE name = 'ä' ; 1\n" +
+				"                                       ^",
+		},
+		{
+			"SELECT name FROM users WHERE name # 1",
+			"syntax error at line 1, column 35: unexpected character '#'\n" +
+				"SELECT name FROM users WHERE name # 1\n" +
+				"                                  ^",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			err := parseQuery(test.query)
+			if _, ok := err.(*SyntaxError); !ok {
+				t.Fatalf("expected a syntax error, got %v", err)
+			}
+			if err.Error() != test.expected {
+				t.Errorf("expected\n%s\ngot\n%s", test.expected, err.Error())
+			}
+		})
+	}
+}
+
+func TestParser_Parse_SyntaxErrors(t *testing.T) {
+	for _, query := range []string{
+		"",
+		"users",
+		"DELETE users",
+		"DELETE FROM",
+		"DELETE FROM users WHERE",
+		"DELETE FROM users id = 1",
+		"INSERT",
+		"INSERT INTO",
+		"INSERT INTO users (id",
+		"INSERT INTO users (id) VALUES (1",
+		"INSERT INTO users (id) VALUES (1) 2",
+		"INSERT INTO users VALUES",
+		"INSERT INTO users VALUES (1),",
+		"INSERT INTO users VALUES (1) (2)",
+		"INSERT INTO users VALUES ()",
+		"INSERT INTO users () VALUES (1)",
+		"INSERT INTO users SELECT",
+		"INSERT INTO users (id) SELECT id FROM people;;",
+		"REPLACE users VALUES (1)",
+		"REPLACE INTO users VALUES (1) ON DUPLICATE KEY UPDATE id = 2",
+		"INSERT INTO users VALUES (1) ON DUPLICATE UPDATE id = 2",
+		"INSERT INTO users VALUES (1) ON KEY UPDATE id = 2",
+		"INSERT INTO users VALUES (1) ON DUPLICATE KEY UPDATE",
+		"INSERT INTO users VALUES (1) ON DUPLICATE KEY UPDATE id = VALUES()",
+		"UPDATE users",
+		"UPDATE users SET",
+		"UPDATE users SET name",
+		"SELECT",
+		"SELECT id FROM",
+		"SELECT id FROM users ORDER id",
+		"SELECT id FROM users ORDER BY",
+		"SELECT id FROM users ORDER BY id NULLS",
+		"SELECT id FROM users ORDER BY id NULLS \"FIRST\"",
+		"SELECT id FROM users LIMIT",
+		"SELECT id FROM users LIMIT -1",
+		"SELECT id FROM users LIMIT 1.5",
+		"SELECT id FROM users LIMIT 1 OFFSET",
+		"SELECT id FROM users LIMIT 1 ORDER BY id",
+		"SELECT id FROM users GROUP id",
+		"SELECT id FROM users GROUP BY",
+		"SELECT id FROM users GROUP BY id HAVING",
+		"SELECT id FROM users ORDER BY id GROUP BY id",
+		"SELECT COUNT( FROM users",
+		"SELECT COUNT(id FROM users",
+		"SELECT COUNT(DISTINCT *) FROM users",
+		"SELECT SUM(*) FROM users",
+		"SELECT LENGTH(name) FROM users",
+		"SELECT id FROM users JOIN",
+		"SELECT id FROM users JOIN orders ON",
+		"SELECT id FROM users LEFT orders ON id = user_id",
+		"SELECT id FROM users LEFT JOIN orders",
+		"SELECT id FROM users FULL OUTER JOIN orders USING id",
+		"SELECT id FROM users JOIN orders USING ()",
+		"SELECT id FROM users CROSS JOIN orders ON",
+		"SELECT id FROM (SELECT id FROM users)",
+		"SELECT id FROM (SELECT id FROM users AS u",
+		"SELECT id FROM users WHERE id IN (1, 2)",
+		"SELECT id FROM users WHERE id NOT (SELECT id FROM orders)",
+		"SELECT id FROM users WHERE EXISTS SELECT id FROM orders",
+		"SELECT (SELECT id FROM orders;) FROM users",
+		"SELECT id FROM users AS",
+		"SELECT id FROM users,",
+		"SELECT u. FROM users u",
+		"CREATE TABLE t (id DECIMAL(",
+		"CREATE TABLE IF NOT t (id INT)",
+	} {
+		t.Run(query, func(t *testing.T) {
+			if _, ok := parseQuery(query).(*SyntaxError); !ok {
+				t.Errorf("expected a syntax error for %q", query)
+			}
+		})
+	}
+}
@@ -14,33 +14,151 @@ const (
 type KeywordType string
 
 const (
-	SELECT = "SELECT"
-	FROM   = "FROM"
-	WHERE  = "WHERE"
-	INSERT = "INSERT"
-	INTO   = "INTO"
-	VALUES = "VALUES"
-	UPDATE = "UPDATE"
-	SET    = "SET"
-	DELETE = "DELETE"
+	SELECT  = "SELECT"
+	FROM    = "FROM"
+	WHERE   = "WHERE"
+	INSERT  = "INSERT"
+	INTO    = "INTO"
+	VALUES  = "VALUES"
+	UPDATE  = "UPDATE"
+	SET     = "SET"
+	DELETE  = "DELETE"
+	REPLACE = "REPLACE"
+
+	BEGIN       = "BEGIN"
+	START       = "START"
+	TRANSACTION = "TRANSACTION"
+	WORK        = "WORK"
+	COMMIT      = "COMMIT"
+	ROLLBACK    = "ROLLBACK"
+	SAVEPOINT   = "SAVEPOINT"
+	TO          = "TO"
+
+	SESSION    = "SESSION"
+	ISOLATION  = "ISOLATION"
+	LEVEL      = "LEVEL"
+	READ       = "READ"
+	COMMITTED  = "COMMITTED"
+	REPEATABLE = "REPEATABLE"
+	SNAPSHOT   = "SNAPSHOT"
+
+	CREATE  = "CREATE"
+	DROP    = "DROP"
+	TABLE   = "TABLE"
+	IF      = "IF"
+	EXISTS  = "EXISTS"
+	PRIMARY = "PRIMARY"
+	KEY     = "KEY"
+	UNIQUE  = "UNIQUE"
+	NOT     = "NOT"
+	NULL    = "NULL"
+	DEFAULT = "DEFAULT"
+
+	ALTER  = "ALTER"
+	ADD    = "ADD"
+	COLUMN = "COLUMN"
+	RENAME = "RENAME"
+	MODIFY = "MODIFY"
+	AS     = "AS"
+
+	IS = "IS"
+
+	ORDER  = "ORDER"
+	BY     = "BY"
+	ASC    = "ASC"
+	DESC   = "DESC"
+	NULLS  = "NULLS"
+	LIMIT  = "LIMIT"
+	OFFSET = "OFFSET"
+
+	GROUP    = "GROUP"
+	HAVING   = "HAVING"
+	DISTINCT = "DISTINCT"
+
+	JOIN  = "JOIN"
+	INNER = "INNER"
+	LEFT  = "LEFT"
+	RIGHT = "RIGHT"
+	FULL  = "FULL"
+	OUTER = "OUTER"
+	CROSS = "CROSS"
+	ON    = "ON"
+	USING = "USING"
+
+	IN = "IN"
+)
+
+// SEMI and ANTI joins cannot be written, the optimizer turns EXISTS and IN
+// subqueries into them.
+const (
+	SEMI = "SEMI"
+	ANTI = "ANTI"
+)
+
+// FIRST and LAST are only words after NULLS, they stay usable as names.
+const (
+	FIRST = "FIRST"
+	LAST  = "LAST"
+)
+
+// DUPLICATE is only a word after ON in ON DUPLICATE KEY UPDATE.
+const DUPLICATE = "DUPLICATE"
+
+// The aggregate functions are no keywords either, a name followed by a
+// parenthesis calls one.
+const (
+	COUNT = "COUNT"
+	SUM   = "SUM"
+	AVG   = "AVG"
+	MIN   = "MIN"
+	MAX   = "MAX"
+)
+
+const (
+	TRUE  = "TRUE"
+	FALSE = "FALSE"
 )
 
 type OperatorType string
 
 const (
 	WILDCARD         = "*"
+	PLUS             = "+"
+	MINUS            = "-"
+	MULTIPLY         = "*"
+	DIVIDE           = "/"
+	MODULO           = "%"
 	EQUALS           = "="
 	LESS_THAN        = "<"
 	LESS_THAN_EQUALS = "<="
 	MORE_THAN        = ">"
 	MORE_THAN_EQUALS = ">="
+	NOT_EQUALS       = "!="
+	LESS_MORE_THAN   = "<>"
 	AND              = "AND"
 	OR               = "OR"
+
+	IS_NULL     = "IS NULL"
+	IS_NOT_NULL = "IS NOT NULL"
 )
 
 func GetKeywordOrIdentifier(value string) TokenType {
 	switch value {
-	case SELECT, FROM, WHERE, INSERT, INTO, VALUES, UPDATE, SET, DELETE:
+	case SELECT, FROM, WHERE, INSERT, INTO, VALUES, UPDATE, SET, DELETE, REPLACE:
+		return KEYWORD
+	case BEGIN, START, TRANSACTION, WORK, COMMIT, ROLLBACK, SAVEPOINT, TO:
+		return KEYWORD
+	case SESSION, ISOLATION, LEVEL, READ, COMMITTED, REPEATABLE, SNAPSHOT:
+		return KEYWORD
+	case CREATE, DROP, TABLE, IF, EXISTS, PRIMARY, KEY, UNIQUE, NOT, NULL, DEFAULT:
+		return KEYWORD
+	case ALTER, ADD, COLUMN, RENAME, MODIFY, AS, IS:
+		return KEYWORD
+	case ORDER, BY, ASC, DESC, NULLS, LIMIT, OFFSET:
+		return KEYWORD
+	case GROUP, HAVING, DISTINCT:
+		return KEYWORD
+	case JOIN, INNER, LEFT, RIGHT, FULL, OUTER, CROSS, ON, USING, IN:
 		return KEYWORD
 	}
 
@@ -55,7 +173,45 @@ func IsConditionalOperator(str string) bool {
 	return false
 }
 
+// IsAggregateFunction reports whether the upper-cased name is one of the
+// aggregate functions.
+func IsAggregateFunction(name string) bool {
+	switch name {
+	case COUNT, SUM, AVG, MIN, MAX:
+		return true
+	}
+	return false
+}
+
+func IsBooleanLiteral(str string) bool {
+	return str == TRUE || str == FALSE
+}
+
+// IsTypedLiteralPrefix reports whether the word may precede a string to
+// give it a type, as in DATE '2024-01-01'.
+func IsTypedLiteralPrefix(str string) bool {
+	switch str {
+	case "DATE", "TIME", "TIMESTAMP":
+		return true
+	}
+	return false
+}
+
+// Token is a word, literal or sign of the query. Quoted is set for an
+// identifier written in double quotes or backticks, Binary for a hex or bit
+// literal, whose value is its bytes.
 type Token struct {
-	Type  TokenType
-	Value string
+	Type   TokenType
+	Value  string
+	Pos    Position
+	Quoted bool
+	Binary bool
+}
+
+// Position is where a token starts in the query: the byte offset, and the
+// line and column counted from 1.
+type Position struct {
+	Offset int
+	Line   int
+	Column int
 }
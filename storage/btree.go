@@ -0,0 +1,742 @@
+package storage
+
+import (
+	"encoding/binary"
+	"errors"
+	"path/filepath"
+	"sync"
+)
+
+// B+ tree meta page (page 0) layout:
+//
+//	| magic (4) | root page id (4) | free list head (4) |
+//
+// Pages released by merges are chained into the free list and reused before
+// the file grows.
+const (
+	btreeMagic      = 0x42505431 // "BPT1"
+	btreeMetaPageID = PageID(0)
+)
+
+var (
+	ErrKeyNotFound  = errors.New("key not found")
+	ErrDuplicateKey = errors.New("duplicate key")
+	ErrKeyTooLarge  = errors.New("key too large")
+)
+
+// BTree is a disk-backed B+ tree mapping unique keys to heap record ids.
+//
+// With a log manager attached the pages changed by one insert or delete are
+// logged together as a single redo-only page write, so recovery never sees
+// half of a split or merge. Every insert and delete is additionally logged as
+// a logical record so that a transaction rollback can reverse it.
+type BTree struct {
+	mu       sync.RWMutex
+	pool     *BufferPool
+	log      *LogManager
+	root     PageID
+	freeHead PageID
+	dirty    []*Page
+}
+
+func OpenBTree(filename string) (*BTree, error) {
+	disk, err := OpenDiskManager(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	tree, err := NewBTree(NewBufferPool(disk, DefaultBufferPoolSize))
+	if err != nil {
+		_ = disk.Close()
+		return nil, err
+	}
+	return tree, nil
+}
+
+func NewBTree(pool *BufferPool) (*BTree, error) {
+	t := &BTree{pool: pool, freeHead: InvalidPageID}
+
+	if pool.NumPages() == 0 {
+		meta, err := pool.NewPage()
+		if err != nil {
+			return nil, err
+		}
+		if err := pool.UnpinPage(meta.ID(), true); err != nil {
+			return nil, err
+		}
+
+		root, err := t.newNode(true)
+		if err != nil {
+			return nil, err
+		}
+
+		t.root = root.id
+		if err := t.writeNode(root); err != nil {
+			return nil, err
+		}
+		if err := t.writeMeta(); err != nil {
+			return nil, err
+		}
+
+		// the empty tree is created before logging is attached, so it has to
+		// reach the disk right away
+		return t, pool.FlushAll()
+	}
+
+	return t, t.readMeta()
+}
+
+// Name identifies the index file in log records.
+func (t *BTree) Name() string {
+	return filepath.Base(t.pool.Disk().Name())
+}
+
+func (t *BTree) Pool() *BufferPool {
+	return t.pool
+}
+
+// SetLogManager turns on write-ahead logging for the index.
+func (t *BTree) SetLogManager(log *LogManager) {
+	t.log = log
+	t.pool.SetLogManager(log)
+	log.RegisterIndex(t)
+}
+
+func (t *BTree) readMeta() error {
+	page, err := t.pool.FetchPage(btreeMetaPageID)
+	if err != nil {
+		return err
+	}
+	defer t.pool.UnpinPage(btreeMetaPageID, false)
+
+	data := page.Data()
+	if binary.LittleEndian.Uint32(data[0:]) != btreeMagic {
+		return errCorruptNode
+	}
+	t.root = PageID(binary.LittleEndian.Uint32(data[4:]))
+	t.freeHead = PageID(binary.LittleEndian.Uint32(data[8:]))
+	return nil
+}
+
+func (t *BTree) writeMeta() error {
+	return t.modifyPage(btreeMetaPageID, func(data []byte) {
+		binary.LittleEndian.PutUint32(data[0:], btreeMagic)
+		binary.LittleEndian.PutUint32(data[4:], uint32(t.root))
+		binary.LittleEndian.PutUint32(data[8:], uint32(t.freeHead))
+	})
+}
+
+// modifyPage applies fn to the page. With logging turned on the page stays
+// pinned until the running operation logs it in apply, so it cannot reach the
+// disk before its image is in the log.
+func (t *BTree) modifyPage(id PageID, fn func(data []byte)) error {
+	page, err := t.pool.FetchPage(id)
+	if err != nil {
+		return err
+	}
+
+	fn(page.Data())
+	if t.log == nil {
+		return t.pool.UnpinPage(id, true)
+	}
+
+	for _, dirty := range t.dirty {
+		if dirty.ID() == id {
+			return t.pool.UnpinPage(id, true)
+		}
+	}
+	t.dirty = append(t.dirty, page)
+	return nil
+}
+
+// apply runs a tree modification and logs the images of every page it
+// changed as one record:
+//
+//	| page id (4) | page image | page id (4) | page image | ...
+func (t *BTree) apply(fn func() error) error {
+	err := fn()
+	if len(t.dirty) == 0 {
+		return err
+	}
+
+	after := make([]byte, 0, len(t.dirty)*(4+PageSize))
+	for _, page := range t.dirty {
+		after = binary.LittleEndian.AppendUint32(after, uint32(page.ID()))
+		after = append(after, page.Data()...)
+	}
+
+	lsn, logErr := t.log.Append(&LogRecord{Txn: SystemTxn, Type: LogPageWrite, File: t.Name(), After: after})
+	for _, page := range t.dirty {
+		if logErr == nil {
+			page.SetLSN(lsn)
+		}
+		if unpinErr := t.pool.UnpinPage(page.ID(), true); logErr == nil {
+			logErr = unpinErr
+		}
+	}
+	t.dirty = nil
+
+	if err == nil {
+		err = logErr
+	}
+	return err
+}
+
+// logChange writes the logical record of an index change.
+func (t *BTree) logChange(rec *LogRecord) error {
+	if t.log == nil {
+		return nil
+	}
+
+	rec.File = t.Name()
+	_, err := t.log.Append(rec)
+	return err
+}
+
+// change writes the logical records of an index change and then makes it.
+// The records go first so that the pages never hold a change the log cannot
+// undo.
+func (t *BTree) change(fn func() error, recs ...*LogRecord) error {
+	for i, rec := range recs {
+		if err := t.logChange(rec); err != nil {
+			return t.compensate(recs[:i], err)
+		}
+	}
+	if err := t.apply(fn); err != nil {
+		return t.compensate(recs, err)
+	}
+	return nil
+}
+
+// compensate writes a compensation record for each logged record of a change
+// that failed, so that neither a rollback nor recovery reverts a change that
+// never happened. It returns the error the change failed with.
+func (t *BTree) compensate(recs []*LogRecord, err error) error {
+	if t.log == nil {
+		return err
+	}
+
+	for i := len(recs) - 1; i >= 0; i-- {
+		rec := recs[i]
+		clr := &LogRecord{Txn: rec.Txn, Type: LogIndexInsert, Compensation: true, UndoNext: rec.PrevLSN, Key: rec.Key, RID: rec.RID}
+		if rec.Type == LogIndexInsert {
+			clr.Type = LogIndexDelete
+		}
+		if logErr := t.logChange(clr); logErr != nil {
+			return logErr
+		}
+	}
+	return err
+}
+
+func (t *BTree) readNode(id PageID) (*btreeNode, error) {
+	page, err := t.pool.FetchPage(id)
+	if err != nil {
+		return nil, err
+	}
+	defer t.pool.UnpinPage(id, false)
+
+	return decodeNode(id, page.Data())
+}
+
+func (t *BTree) writeNode(n *btreeNode) error {
+	return t.modifyPage(n.id, n.encode)
+}
+
+func (t *BTree) newNode(leaf bool) (*btreeNode, error) {
+	n := &btreeNode{id: t.freeHead, leaf: leaf, next: InvalidPageID}
+	if n.id != InvalidPageID {
+		page, err := t.pool.FetchPage(n.id)
+		if err != nil {
+			return nil, err
+		}
+
+		t.freeHead = PageID(binary.LittleEndian.Uint32(page.Data()[3:]))
+		if err := t.pool.UnpinPage(n.id, false); err != nil {
+			return nil, err
+		}
+		return n, t.writeMeta()
+	}
+
+	page, err := t.pool.NewPage()
+	if err != nil {
+		return nil, err
+	}
+
+	n.id = page.ID()
+	return n, t.pool.UnpinPage(n.id, true)
+}
+
+func (t *BTree) freeNode(id PageID) error {
+	err := t.modifyPage(id, func(data []byte) {
+		for i := range data {
+			data[i] = 0
+		}
+		data[0] = nodeTypeFree
+		binary.LittleEndian.PutUint32(data[3:], uint32(t.freeHead))
+	})
+	if err != nil {
+		return err
+	}
+
+	t.freeHead = id
+	return t.writeMeta()
+}
+
+func (t *BTree) findLeaf(key Key) (*btreeNode, error) {
+	n, err := t.readNode(t.root)
+	for err == nil && !n.leaf {
+		child := n.children[0]
+		if key != nil {
+			child = n.children[n.childIndex(key)]
+		}
+		n, err = t.readNode(child)
+	}
+	return n, err
+}
+
+func (t *BTree) Get(key Key) (RID, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return t.get(key)
+}
+
+func (t *BTree) get(key Key) (RID, error) {
+	leaf, err := t.findLeaf(key)
+	if err != nil {
+		return RID{}, err
+	}
+
+	i, found := leaf.search(key)
+	if !found {
+		return RID{}, ErrKeyNotFound
+	}
+	return leaf.rids[i], nil
+}
+
+// Update points an existing key at a new record id.
+func (t *BTree) Update(txn TxnID, key Key, rid RID) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	leaf, err := t.findLeaf(key)
+	if err != nil {
+		return err
+	}
+
+	i, found := leaf.search(key)
+	if !found {
+		return ErrKeyNotFound
+	}
+
+	old := leaf.rids[i]
+	leaf.rids[i] = rid
+	return t.change(func() error { return t.writeNode(leaf) },
+		&LogRecord{Txn: txn, Type: LogIndexDelete, Key: key, RID: old},
+		&LogRecord{Txn: txn, Type: LogIndexInsert, Key: key, RID: rid})
+}
+
+func (t *BTree) Insert(txn TxnID, key Key, rid RID) error {
+	if len(key) > MaxKeySize {
+		return ErrKeyTooLarge
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.log != nil {
+		if _, err := t.get(key); err != ErrKeyNotFound {
+			if err == nil {
+				err = ErrDuplicateKey
+			}
+			return err
+		}
+
+	}
+
+	return t.change(func() error { return t.insertKey(key, rid) },
+		&LogRecord{Txn: txn, Type: LogIndexInsert, Key: key, RID: rid})
+}
+
+func (t *BTree) insertKey(key Key, rid RID) error {
+	sep, right, err := t.insert(t.root, key, rid)
+	if err != nil || right == nil {
+		return err
+	}
+
+	root, err := t.newNode(false)
+	if err != nil {
+		return err
+	}
+
+	root.keys = []Key{sep}
+	root.children = []PageID{t.root, right.id}
+	if err := t.writeNode(root); err != nil {
+		return err
+	}
+
+	t.root = root.id
+	return t.writeMeta()
+}
+
+// insert adds the key below the node and returns the separator and new right
+// sibling when the node had to be split.
+func (t *BTree) insert(id PageID, key Key, rid RID) (Key, *btreeNode, error) {
+	n, err := t.readNode(id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if n.leaf {
+		i, found := n.search(key)
+		if found {
+			return nil, nil, ErrDuplicateKey
+		}
+
+		n.keys = insertAt(n.keys, i, key)
+		n.rids = append(n.rids, RID{})
+		copy(n.rids[i+1:], n.rids[i:])
+		n.rids[i] = rid
+	} else {
+		i := n.childIndex(key)
+		sep, right, err := t.insert(n.children[i], key, rid)
+		if err != nil || right == nil {
+			return nil, nil, err
+		}
+
+		n.keys = insertAt(n.keys, i, sep)
+		n.children = append(n.children, InvalidPageID)
+		copy(n.children[i+2:], n.children[i+1:])
+		n.children[i+1] = right.id
+	}
+
+	if n.size() <= PageSize {
+		return nil, nil, t.writeNode(n)
+	}
+
+	left, sep, right := n.divide(n.splitPoint())
+	newNode, err := t.newNode(n.leaf)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	left.id, right.id = n.id, newNode.id
+	if n.leaf {
+		right.next = n.next
+		left.next = right.id
+	}
+
+	if err := t.writeNode(left); err != nil {
+		return nil, nil, err
+	}
+	return sep, right, t.writeNode(right)
+}
+
+func (t *BTree) Delete(txn TxnID, key Key) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var recs []*LogRecord
+	if t.log != nil {
+		rid, err := t.get(key)
+		if err != nil {
+			return err
+		}
+		recs = append(recs, &LogRecord{Txn: txn, Type: LogIndexDelete, Key: key, RID: rid})
+	}
+
+	return t.change(func() error { return t.deleteKey(key) }, recs...)
+}
+
+func (t *BTree) deleteKey(key Key) error {
+	if _, err := t.delete(t.root, key); err != nil {
+		return err
+	}
+
+	root, err := t.readNode(t.root)
+	if err != nil {
+		return err
+	}
+
+	// collapse a root that was left with a single child
+	if !root.leaf && len(root.keys) == 0 {
+		t.root = root.children[0]
+		if err := t.writeMeta(); err != nil {
+			return err
+		}
+		return t.freeNode(root.id)
+	}
+	return nil
+}
+
+// delete removes the key below the node and reports whether the node fell
+// below the minimum fill afterwards.
+func (t *BTree) delete(id PageID, key Key) (bool, error) {
+	n, err := t.readNode(id)
+	if err != nil {
+		return false, err
+	}
+
+	if n.leaf {
+		i, found := n.search(key)
+		if !found {
+			return false, ErrKeyNotFound
+		}
+
+		n.keys = append(n.keys[:i], n.keys[i+1:]...)
+		n.rids = append(n.rids[:i], n.rids[i+1:]...)
+		return n.size() < minNodeSize, t.writeNode(n)
+	}
+
+	i := n.childIndex(key)
+	underflow, err := t.delete(n.children[i], key)
+	if err != nil || !underflow {
+		return false, err
+	}
+
+	if err := t.rebalance(n, i); err != nil {
+		return false, err
+	}
+	return n.size() < minNodeSize, t.writeNode(n)
+}
+
+// rebalance fixes the underflowing child at index i of parent by merging it
+// with a sibling, or by redistributing entries when both do not fit in one
+// page.
+func (t *BTree) rebalance(parent *btreeNode, i int) error {
+	li := i - 1
+	if li < 0 {
+		li = 0
+	}
+	ri := li + 1
+	if ri >= len(parent.children) {
+		return nil
+	}
+
+	left, err := t.readNode(parent.children[li])
+	if err != nil {
+		return err
+	}
+	right, err := t.readNode(parent.children[ri])
+	if err != nil {
+		return err
+	}
+
+	merged := left.merge(parent.keys[li], right)
+	if merged.size() <= PageSize {
+		merged.id = left.id
+		merged.next = right.next
+		if err := t.writeNode(merged); err != nil {
+			return err
+		}
+
+		parent.keys = append(parent.keys[:li], parent.keys[li+1:]...)
+		parent.children = append(parent.children[:ri], parent.children[ri+1:]...)
+		return t.freeNode(right.id)
+	}
+
+	newLeft, sep, newRight := merged.divide(merged.splitPoint())
+	newLeft.id, newRight.id = left.id, right.id
+	if left.leaf {
+		newLeft.next = right.id
+		newRight.next = right.next
+	}
+
+	parent.keys[li] = sep
+	if err := t.writeNode(newLeft); err != nil {
+		return err
+	}
+	return t.writeNode(newRight)
+}
+
+// divide splits the entries at mid into two nodes and returns the separator
+// that has to be stored in the parent. Internal nodes hand the middle key up
+// to the parent, leaves keep it as the first key of the right node.
+func (n *btreeNode) divide(mid int) (*btreeNode, Key, *btreeNode) {
+	left := &btreeNode{leaf: n.leaf, next: InvalidPageID}
+	right := &btreeNode{leaf: n.leaf, next: InvalidPageID}
+
+	if n.leaf {
+		left.keys = append(left.keys, n.keys[:mid]...)
+		left.rids = append(left.rids, n.rids[:mid]...)
+		right.keys = append(right.keys, n.keys[mid:]...)
+		right.rids = append(right.rids, n.rids[mid:]...)
+		return left, right.keys[0], right
+	}
+
+	left.keys = append(left.keys, n.keys[:mid]...)
+	left.children = append(left.children, n.children[:mid+1]...)
+	right.keys = append(right.keys, n.keys[mid+1:]...)
+	right.children = append(right.children, n.children[mid+1:]...)
+	return left, n.keys[mid], right
+}
+
+// merge concatenates the node with its right sibling. For internal nodes the
+// separator from the parent is pulled down between the two halves.
+func (n *btreeNode) merge(sep Key, right *btreeNode) *btreeNode {
+	res := &btreeNode{leaf: n.leaf, next: InvalidPageID}
+	res.keys = append(res.keys, n.keys...)
+
+	if n.leaf {
+		res.keys = append(res.keys, right.keys...)
+		res.rids = append(append(res.rids, n.rids...), right.rids...)
+		return res
+	}
+
+	res.keys = append(append(res.keys, sep), right.keys...)
+	res.children = append(append(res.children, n.children...), right.children...)
+	return res
+}
+
+func insertAt(keys []Key, i int, key Key) []Key {
+	keys = append(keys, nil)
+	copy(keys[i+1:], keys[i:])
+	keys[i] = key
+	return keys
+}
+
+// redoPage installs the page images of a logged page write.
+func (t *BTree) redoPage(rec *LogRecord) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	meta := false
+	for off := 0; off+4+PageSize <= len(rec.After); off += 4 + PageSize {
+		id := PageID(binary.LittleEndian.Uint32(rec.After[off:]))
+		for t.pool.NumPages() <= uint32(id) {
+			page, err := t.pool.NewPage()
+			if err != nil {
+				return err
+			}
+			if err := t.pool.UnpinPage(page.ID(), true); err != nil {
+				return err
+			}
+		}
+
+		page, err := t.pool.FetchPage(id)
+		if err != nil {
+			return err
+		}
+
+		copy(page.Data(), rec.After[off+4:off+4+PageSize])
+		page.SetLSN(rec.LSN)
+		if err := t.pool.UnpinPage(id, true); err != nil {
+			return err
+		}
+		meta = meta || id == btreeMetaPageID
+	}
+
+	if meta {
+		return t.readMeta()
+	}
+	return nil
+}
+
+// undo reverses a logical index change and writes its compensation record.
+// The change may never have reached the tree when the crash happened between
+// logging it and applying it, so a missing or already present key is fine.
+// The compensation record follows the page write of the inverse change: a
+// crash in between only makes recovery undo the change once more.
+func (t *BTree) undo(rec *LogRecord) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	clr := &LogRecord{Txn: rec.Txn, Compensation: true, UndoNext: rec.PrevLSN, Key: rec.Key, RID: rec.RID}
+	switch rec.Type {
+	case LogIndexInsert:
+		clr.Type = LogIndexDelete
+		err := t.apply(func() error { return t.deleteKey(rec.Key) })
+		if err != nil && err != ErrKeyNotFound {
+			return err
+		}
+	case LogIndexDelete:
+		clr.Type = LogIndexInsert
+		err := t.apply(func() error { return t.insertKey(rec.Key, rec.RID) })
+		if err != nil && err != ErrDuplicateKey {
+			return err
+		}
+	default:
+		return nil
+	}
+	return t.logChange(clr)
+}
+
+func (t *BTree) Flush() error {
+	return t.pool.FlushAll()
+}
+
+func (t *BTree) Close() error {
+	return t.pool.Close()
+}
+
+type IndexEntry struct {
+	Key Key
+	RID RID
+}
+
+// BTreeIterator walks the leaf chain in key order. Entries are copied out
+// one leaf at a time so no page stays pinned between calls.
+type BTreeIterator struct {
+	tree    *BTree
+	low     Key
+	high    Key
+	next    PageID
+	started bool
+	entries []IndexEntry
+}
+
+// Range iterates over the keys between low and high, both inclusive. A nil
+// bound leaves that side of the range open.
+func (t *BTree) Range(low, high Key) *BTreeIterator {
+	return &BTreeIterator{tree: t, low: low, high: high, next: InvalidPageID}
+}
+
+// Next returns the next entry, or nil once the range is exhausted.
+func (it *BTreeIterator) Next() (*IndexEntry, error) {
+	for len(it.entries) == 0 {
+		if it.started && it.next == InvalidPageID {
+			return nil, nil
+		}
+
+		if err := it.load(); err != nil {
+			return nil, err
+		}
+	}
+
+	entry := it.entries[0]
+	if it.high != nil && CompareKeys(entry.Key, it.high) > 0 {
+		it.entries = nil
+		it.next = InvalidPageID
+		return nil, nil
+	}
+
+	it.entries = it.entries[1:]
+	return &entry, nil
+}
+
+func (it *BTreeIterator) load() error {
+	it.tree.mu.RLock()
+	defer it.tree.mu.RUnlock()
+
+	var leaf *btreeNode
+	var err error
+	if !it.started {
+		leaf, err = it.tree.findLeaf(it.low)
+		it.started = true
+	} else {
+		leaf, err = it.tree.readNode(it.next)
+	}
+	if err != nil {
+		return err
+	}
+
+	for i, key := range leaf.keys {
+		if it.low != nil && CompareKeys(key, it.low) < 0 {
+			continue
+		}
+		it.entries = append(it.entries, IndexEntry{Key: key, RID: leaf.rids[i]})
+	}
+	it.next = leaf.next
+	return nil
+}
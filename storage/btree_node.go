@@ -0,0 +1,179 @@
+package storage
+
+import (
+	"encoding/binary"
+	"errors"
+	"sort"
+)
+
+// B+ tree node layout:
+//
+//	| type (1) | key count (2) | next (4) | entries ... |
+//
+// A leaf entry is | key length (2) | key | page id (4) | slot (2) |, pointing
+// at a heap record. An internal node starts with its leftmost child id (4)
+// followed by | key length (2) | key | child id (4) | entries, where every key
+// is the smallest key reachable through the child on its right. Leaves are
+// chained through next so range scans never go back up the tree.
+const (
+	nodeTypeLeaf     = 1
+	nodeTypeInternal = 2
+	nodeTypeFree     = 3
+
+	nodeHeaderSize     = 7
+	leafEntryExtra     = 2 + 6
+	internalEntryExtra = 2 + 4
+
+	// nodes that shrink below this size borrow from or merge with a sibling
+	minNodeSize = PageSize / 4
+)
+
+var errCorruptNode = errors.New("corrupt b+ tree node")
+
+type btreeNode struct {
+	id       PageID
+	leaf     bool
+	keys     []Key
+	rids     []RID
+	children []PageID
+	next     PageID
+}
+
+func (n *btreeNode) size() int {
+	size := nodeHeaderSize
+	if n.leaf {
+		for _, key := range n.keys {
+			size += len(key) + leafEntryExtra
+		}
+		return size
+	}
+
+	size += 4
+	for _, key := range n.keys {
+		size += len(key) + internalEntryExtra
+	}
+	return size
+}
+
+// search returns the position of the first key >= key and whether it matches.
+func (n *btreeNode) search(key Key) (int, bool) {
+	i := sort.Search(len(n.keys), func(i int) bool {
+		return CompareKeys(n.keys[i], key) >= 0
+	})
+	return i, i < len(n.keys) && CompareKeys(n.keys[i], key) == 0
+}
+
+// childIndex returns the child that covers key.
+func (n *btreeNode) childIndex(key Key) int {
+	return sort.Search(len(n.keys), func(i int) bool {
+		return CompareKeys(n.keys[i], key) > 0
+	})
+}
+
+func (n *btreeNode) encode(buf []byte) {
+	for i := range buf[:PageSize] {
+		buf[i] = 0
+	}
+
+	if n.leaf {
+		buf[0] = nodeTypeLeaf
+	} else {
+		buf[0] = nodeTypeInternal
+	}
+	binary.LittleEndian.PutUint16(buf[1:], uint16(len(n.keys)))
+	binary.LittleEndian.PutUint32(buf[3:], uint32(n.next))
+
+	off := nodeHeaderSize
+	if !n.leaf {
+		binary.LittleEndian.PutUint32(buf[off:], uint32(n.children[0]))
+		off += 4
+	}
+
+	for i, key := range n.keys {
+		binary.LittleEndian.PutUint16(buf[off:], uint16(len(key)))
+		off += 2
+		off += copy(buf[off:], key)
+
+		if n.leaf {
+			binary.LittleEndian.PutUint32(buf[off:], uint32(n.rids[i].PageID))
+			binary.LittleEndian.PutUint16(buf[off+4:], n.rids[i].Slot)
+			off += 6
+		} else {
+			binary.LittleEndian.PutUint32(buf[off:], uint32(n.children[i+1]))
+			off += 4
+		}
+	}
+}
+
+func decodeNode(id PageID, buf []byte) (*btreeNode, error) {
+	n := &btreeNode{id: id}
+	switch buf[0] {
+	case nodeTypeLeaf:
+		n.leaf = true
+	case nodeTypeInternal:
+	default:
+		return nil, errCorruptNode
+	}
+
+	count := int(binary.LittleEndian.Uint16(buf[1:]))
+	n.next = PageID(binary.LittleEndian.Uint32(buf[3:]))
+
+	off := nodeHeaderSize
+	extra := leafEntryExtra
+	if !n.leaf {
+		n.children = append(n.children, PageID(binary.LittleEndian.Uint32(buf[off:])))
+		off += 4
+		extra = internalEntryExtra
+	}
+
+	for i := 0; i < count; i++ {
+		if off+2 > PageSize {
+			return nil, errCorruptNode
+		}
+		length := int(binary.LittleEndian.Uint16(buf[off:]))
+		if off+length+extra > PageSize {
+			return nil, errCorruptNode
+		}
+		off += 2
+
+		key := make(Key, length)
+		copy(key, buf[off:off+length])
+		off += length
+		n.keys = append(n.keys, key)
+
+		if n.leaf {
+			n.rids = append(n.rids, RID{
+				PageID: PageID(binary.LittleEndian.Uint32(buf[off:])),
+				Slot:   binary.LittleEndian.Uint16(buf[off+4:]),
+			})
+			off += 6
+		} else {
+			n.children = append(n.children, PageID(binary.LittleEndian.Uint32(buf[off:])))
+			off += 4
+		}
+	}
+
+	return n, nil
+}
+
+// splitPoint returns the index at which the entries should be divided so both
+// halves end up with roughly the same number of bytes.
+func (n *btreeNode) splitPoint() int {
+	extra := leafEntryExtra
+	if !n.leaf {
+		extra = internalEntryExtra
+	}
+
+	half := (n.size() - nodeHeaderSize) / 2
+	acc := 0
+	for i, key := range n.keys {
+		acc += len(key) + extra
+		if acc >= half {
+			if i+1 >= len(n.keys) {
+				return len(n.keys) - 1
+			}
+			return i + 1
+		}
+	}
+	return len(n.keys) / 2
+}
@@ -0,0 +1,248 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"path/filepath"
+	"testing"
+)
+
+func openTestBTree(t *testing.T) (*BTree, string) {
+	filename := filepath.Join(t.TempDir(), "users_id.idx")
+	tree, err := OpenBTree(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return tree, filename
+}
+
+func collectKeys(t *testing.T, it *BTreeIterator) []Key {
+	var keys []Key
+	for {
+		entry, err := it.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if entry == nil {
+			return keys
+		}
+		keys = append(keys, entry.Key)
+	}
+}
+
+func TestIntKey_Order(t *testing.T) {
+	values := []int64{-1 << 40, -5, -1, 0, 1, 7, 1 << 40}
+	for i := 1; i < len(values); i++ {
+		if CompareKeys(IntKey(values[i-1]), IntKey(values[i])) >= 0 {
+			t.Errorf("expected key %d to sort before %d", values[i-1], values[i])
+		}
+	}
+}
+
+func TestBTree_InsertAndGet(t *testing.T) {
+	tree, _ := openTestBTree(t)
+	defer tree.Close()
+
+	perm := rand.New(rand.NewSource(1)).Perm(5000)
+	for _, v := range perm {
+		if err := tree.Insert(SystemTxn, IntKey(int64(v)), RID{PageID: PageID(v), Slot: uint16(v % 100)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("Point lookup returns the mapped rid", func(t *testing.T) {
+		for _, v := range []int{0, 1, 2500, 4999} {
+			rid, err := tree.Get(IntKey(int64(v)))
+			if err != nil {
+				t.Fatalf("expected no error, got %s", err)
+			}
+
+			if rid != (RID{PageID: PageID(v), Slot: uint16(v % 100)}) {
+				t.Errorf("expected rid of %d, got %v", v, rid)
+			}
+		}
+	})
+
+	t.Run("Missing key", func(t *testing.T) {
+		if _, err := tree.Get(IntKey(5000)); err != ErrKeyNotFound {
+			t.Errorf("expected %v, got %v", ErrKeyNotFound, err)
+		}
+	})
+
+	t.Run("Duplicate key is rejected", func(t *testing.T) {
+		if err := tree.Insert(SystemTxn, IntKey(42), RID{}); err != ErrDuplicateKey {
+			t.Errorf("expected %v, got %v", ErrDuplicateKey, err)
+		}
+	})
+
+	t.Run("Full scan is ordered", func(t *testing.T) {
+		keys := collectKeys(t, tree.Range(nil, nil))
+		if len(keys) != 5000 {
+			t.Fatalf("expected 5000 keys, got %d", len(keys))
+		}
+
+		for i := 1; i < len(keys); i++ {
+			if CompareKeys(keys[i-1], keys[i]) >= 0 {
+				t.Fatalf("keys out of order at %d", i)
+			}
+		}
+	})
+}
+
+func TestBTree_Range(t *testing.T) {
+	tree, _ := openTestBTree(t)
+	defer tree.Close()
+
+	for i := 0; i < 1000; i++ {
+		_ = tree.Insert(SystemTxn, IntKey(int64(i*2)), RID{PageID: PageID(i)})
+	}
+
+	keys := collectKeys(t, tree.Range(IntKey(101), IntKey(200)))
+	t.Run("Range is inclusive on both ends", func(t *testing.T) {
+		if len(keys) != 50 {
+			t.Fatalf("expected 50 keys, got %d", len(keys))
+		}
+
+		if CompareKeys(keys[0], IntKey(102)) != 0 || CompareKeys(keys[49], IntKey(200)) != 0 {
+			t.Errorf("expected range 102..200")
+		}
+	})
+}
+
+func TestBTree_DeleteMergesNodes(t *testing.T) {
+	tree, _ := openTestBTree(t)
+	defer tree.Close()
+
+	for i := 0; i < 3000; i++ {
+		_ = tree.Insert(SystemTxn, StringKey(fmt.Sprintf("user-%05d", i)), RID{PageID: PageID(i)})
+	}
+	pagesBefore := tree.Pool().NumPages()
+
+	for i := 0; i < 3000; i++ {
+		if i%10 == 0 {
+			continue
+		}
+		if err := tree.Delete(SystemTxn, StringKey(fmt.Sprintf("user-%05d", i))); err != nil {
+			t.Fatalf("delete %d: %s", i, err)
+		}
+	}
+
+	t.Run("Remaining keys are still reachable", func(t *testing.T) {
+		keys := collectKeys(t, tree.Range(nil, nil))
+		if len(keys) != 300 {
+			t.Fatalf("expected 300 keys, got %d", len(keys))
+		}
+
+		for i := 0; i < 3000; i += 10 {
+			if _, err := tree.Get(StringKey(fmt.Sprintf("user-%05d", i))); err != nil {
+				t.Fatalf("expected key %d, got %s", i, err)
+			}
+		}
+	})
+
+	t.Run("Deleting a missing key fails", func(t *testing.T) {
+		if err := tree.Delete(SystemTxn, StringKey("user-00001")); err != ErrKeyNotFound {
+			t.Errorf("expected %v, got %v", ErrKeyNotFound, err)
+		}
+	})
+
+	t.Run("Freed pages are reused", func(t *testing.T) {
+		for i := 0; i < 3000; i++ {
+			if i%10 == 0 {
+				continue
+			}
+			_ = tree.Insert(SystemTxn, StringKey(fmt.Sprintf("user-%05d", i)), RID{PageID: PageID(i)})
+		}
+
+		if pages := tree.Pool().NumPages(); pages > pagesBefore+2 {
+			t.Errorf("expected about %d pages, got %d", pagesBefore, pages)
+		}
+	})
+}
+
+func TestBTree_Reopen(t *testing.T) {
+	tree, filename := openTestBTree(t)
+	for i := 0; i < 2000; i++ {
+		_ = tree.Insert(SystemTxn, IntKey(int64(i)), RID{PageID: PageID(i)})
+	}
+	if err := tree.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	tree, err := OpenBTree(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tree.Close()
+
+	rid, err := tree.Get(IntKey(1234))
+	t.Run("Index survives reopening", func(t *testing.T) {
+		if err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+
+		if rid.PageID != 1234 {
+			t.Errorf("expected page 1234, got %d", rid.PageID)
+		}
+	})
+}
+
+func TestBTree_FailedChangeIsNotUndone(t *testing.T) {
+	f := openWalFixture(t, t.TempDir())
+	defer f.close()
+	tree := f.index
+
+	// pin every frame, keeping the meta page and the root leaf in the pool,
+	// so that the first split cannot allocate its new node
+	pinned := []PageID{btreeMetaPageID, tree.root}
+	for _, id := range pinned {
+		if _, err := tree.pool.FetchPage(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for {
+		page, err := tree.pool.NewPage()
+		if err != nil {
+			break
+		}
+		pinned = append(pinned, page.ID())
+	}
+
+	_, _ = f.log.Begin(1)
+	var failed int64
+	for id := int64(1); failed == 0; id++ {
+		err := tree.Insert(1, IntKey(id), RID{PageID: 1, Slot: uint16(id)})
+		if errors.Is(err, ErrBufferPoolFull) {
+			failed = id
+		} else if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, id := range pinned {
+		if err := tree.pool.UnpinPage(id, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// another transaction takes the key the failed insert was logged for
+	_, _ = f.log.Begin(2)
+	if err := tree.Insert(2, IntKey(failed), RID{PageID: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.log.Commit(2); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := f.log.Abort(1); err != nil {
+		t.Fatal(err)
+	}
+	if rid, err := tree.Get(IntKey(failed)); err != nil || rid.PageID != 2 {
+		t.Errorf("expected the key of the committed insert to stay, got %v (%v)", rid, err)
+	}
+	if keys := collectKeys(t, tree.Range(nil, nil)); len(keys) != 1 {
+		t.Errorf("expected only the committed key, got %d keys", len(keys))
+	}
+}
@@ -0,0 +1,258 @@
+package storage
+
+import (
+	"errors"
+	"sync"
+)
+
+const DefaultBufferPoolSize = 64
+
+var (
+	ErrBufferPoolFull = errors.New("buffer pool is full: every frame is pinned")
+	ErrPageNotPinned  = errors.New("page is not pinned")
+)
+
+// Page is a frame of the buffer pool holding one disk page.
+type Page struct {
+	id       PageID
+	data     []byte
+	pinCount int
+	dirty    bool
+	lsn      LSN
+}
+
+func (p *Page) ID() PageID {
+	return p.id
+}
+
+func (p *Page) Data() []byte {
+	return p.data
+}
+
+// SetLSN records the latest log record describing a change to the page. The
+// log has to be flushed up to this LSN before the page can be written back.
+func (p *Page) SetLSN(lsn LSN) {
+	if lsn > p.lsn {
+		p.lsn = lsn
+	}
+}
+
+type BufferPoolStats struct {
+	Hits   uint64
+	Misses uint64
+}
+
+// BufferPool caches pages of a disk file in a fixed number of frames. Pages
+// must be pinned (FetchPage/NewPage) while in use and unpinned afterwards;
+// only unpinned pages can be evicted, dirty ones are written back first.
+type BufferPool struct {
+	mu        sync.Mutex
+	disk      *DiskManager
+	log       *LogManager
+	frames    []*Page
+	pageTable map[PageID]int
+	freeList  []int
+	replacer  *ClockReplacer
+	stats     BufferPoolStats
+}
+
+func NewBufferPool(disk *DiskManager, size int) *BufferPool {
+	if size <= 0 {
+		size = DefaultBufferPoolSize
+	}
+
+	frames := make([]*Page, size)
+	freeList := make([]int, size)
+	for i := range frames {
+		frames[i] = &Page{id: InvalidPageID, data: make([]byte, PageSize)}
+		freeList[i] = i
+	}
+
+	return &BufferPool{
+		disk:      disk,
+		frames:    frames,
+		pageTable: make(map[PageID]int),
+		freeList:  freeList,
+		replacer:  NewClockReplacer(size),
+	}
+}
+
+func (bp *BufferPool) Disk() *DiskManager {
+	return bp.disk
+}
+
+// SetLogManager enforces the write-ahead rule: before a dirty page goes to
+// disk the log is flushed up to the page LSN.
+func (bp *BufferPool) SetLogManager(log *LogManager) {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	bp.log = log
+}
+
+func (bp *BufferPool) Size() int {
+	return len(bp.frames)
+}
+
+func (bp *BufferPool) NumPages() uint32 {
+	return bp.disk.NumPages()
+}
+
+func (bp *BufferPool) Stats() BufferPoolStats {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	return bp.stats
+}
+
+func (bp *BufferPool) FetchPage(id PageID) (*Page, error) {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+
+	if frame, ok := bp.pageTable[id]; ok {
+		bp.stats.Hits++
+		page := bp.frames[frame]
+		page.pinCount++
+		bp.replacer.Pin(frame)
+		return page, nil
+	}
+
+	bp.stats.Misses++
+	frame, err := bp.allocateFrame()
+	if err != nil {
+		return nil, err
+	}
+
+	page := bp.frames[frame]
+	if err := bp.disk.ReadPage(id, page.data); err != nil {
+		bp.freeList = append(bp.freeList, frame)
+		return nil, err
+	}
+
+	bp.install(frame, id)
+	return page, nil
+}
+
+// NewPage allocates a zeroed page at the end of the file and returns it pinned.
+func (bp *BufferPool) NewPage() (*Page, error) {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+
+	frame, err := bp.allocateFrame()
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := bp.disk.AllocatePage()
+	if err != nil {
+		bp.freeList = append(bp.freeList, frame)
+		return nil, err
+	}
+
+	page := bp.frames[frame]
+	for i := range page.data {
+		page.data[i] = 0
+	}
+
+	bp.install(frame, id)
+	return page, nil
+}
+
+func (bp *BufferPool) UnpinPage(id PageID, dirty bool) error {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+
+	frame, ok := bp.pageTable[id]
+	if !ok || bp.frames[frame].pinCount == 0 {
+		return ErrPageNotPinned
+	}
+
+	page := bp.frames[frame]
+	page.dirty = page.dirty || dirty
+	page.pinCount--
+	if page.pinCount == 0 {
+		bp.replacer.Unpin(frame)
+	}
+	return nil
+}
+
+func (bp *BufferPool) FlushPage(id PageID) error {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+
+	frame, ok := bp.pageTable[id]
+	if !ok {
+		return nil
+	}
+	return bp.flush(bp.frames[frame])
+}
+
+func (bp *BufferPool) FlushAll() error {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+
+	for _, frame := range bp.pageTable {
+		if err := bp.flush(bp.frames[frame]); err != nil {
+			return err
+		}
+	}
+	return bp.disk.Sync()
+}
+
+func (bp *BufferPool) Close() error {
+	if err := bp.FlushAll(); err != nil {
+		return err
+	}
+	return bp.disk.Close()
+}
+
+func (bp *BufferPool) flush(page *Page) error {
+	if !page.dirty {
+		return nil
+	}
+
+	if bp.log != nil && page.lsn != InvalidLSN {
+		if err := bp.log.Flush(page.lsn); err != nil {
+			return err
+		}
+	}
+
+	if err := bp.disk.WritePage(page.id, page.data); err != nil {
+		return err
+	}
+	page.dirty = false
+	return nil
+}
+
+// allocateFrame returns a frame from the free list or evicts a victim,
+// writing it back to disk first if it is dirty.
+func (bp *BufferPool) allocateFrame() (int, error) {
+	if n := len(bp.freeList); n > 0 {
+		frame := bp.freeList[n-1]
+		bp.freeList = bp.freeList[:n-1]
+		return frame, nil
+	}
+
+	frame, ok := bp.replacer.Victim()
+	if !ok {
+		return 0, ErrBufferPoolFull
+	}
+
+	victim := bp.frames[frame]
+	if err := bp.flush(victim); err != nil {
+		bp.replacer.Unpin(frame)
+		return 0, err
+	}
+
+	delete(bp.pageTable, victim.id)
+	victim.id = InvalidPageID
+	return frame, nil
+}
+
+func (bp *BufferPool) install(frame int, id PageID) {
+	page := bp.frames[frame]
+	page.id = id
+	page.pinCount = 1
+	page.dirty = false
+	page.lsn = InvalidLSN
+	bp.pageTable[id] = frame
+	bp.replacer.Pin(frame)
+}
@@ -0,0 +1,204 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func openTestBufferPool(t *testing.T, size int) *BufferPool {
+	disk, err := OpenDiskManager(filepath.Join(t.TempDir(), "pool.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return NewBufferPool(disk, size)
+}
+
+func TestClockReplacer_SecondChance(t *testing.T) {
+	replacer := NewClockReplacer(3)
+	replacer.Unpin(0)
+	replacer.Unpin(1)
+	replacer.Unpin(2)
+
+	// the first sweep clears every reference bit, so frame 0 goes first
+	first, _ := replacer.Victim()
+
+	// touching frame 1 again gives it a second chance over frame 2
+	replacer.Pin(1)
+	replacer.Unpin(1)
+	second, _ := replacer.Victim()
+
+	t.Run("Victims follow the clock hand", func(t *testing.T) {
+		if first != 0 {
+			t.Errorf("expected frame 0, got %d", first)
+		}
+
+		if second != 2 {
+			t.Errorf("expected frame 2, got %d", second)
+		}
+
+		if replacer.Size() != 1 {
+			t.Errorf("expected 1 evictable frame, got %d", replacer.Size())
+		}
+	})
+
+	t.Run("Pinned frames are never victims", func(t *testing.T) {
+		replacer.Pin(1)
+		if _, ok := replacer.Victim(); ok {
+			t.Errorf("expected no victim when every frame is pinned")
+		}
+	})
+}
+
+func TestBufferPool_FetchCountsHitsAndMisses(t *testing.T) {
+	pool := openTestBufferPool(t, 2)
+	defer pool.Close()
+
+	page, err := pool.NewPage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := page.ID()
+	_ = pool.UnpinPage(id, false)
+
+	for i := 0; i < 3; i++ {
+		if _, err := pool.FetchPage(id); err != nil {
+			t.Fatal(err)
+		}
+		_ = pool.UnpinPage(id, false)
+	}
+
+	t.Run("Cached page is a hit", func(t *testing.T) {
+		stats := pool.Stats()
+		if stats.Hits != 3 || stats.Misses != 0 {
+			t.Errorf("expected 3 hits and 0 misses, got %+v", stats)
+		}
+	})
+}
+
+func TestBufferPool_EvictionWritesBackDirtyPages(t *testing.T) {
+	pool := openTestBufferPool(t, 2)
+	defer pool.Close()
+
+	var ids []PageID
+	for i := 0; i < 3; i++ {
+		page, err := pool.NewPage()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		page.Data()[0] = byte(i + 1)
+		ids = append(ids, page.ID())
+		if err := pool.UnpinPage(page.ID(), true); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("Evicted dirty pages are read back from disk", func(t *testing.T) {
+		for i, id := range ids {
+			page, err := pool.FetchPage(id)
+			if err != nil {
+				t.Fatalf("expected no error, got %s", err)
+			}
+
+			if page.Data()[0] != byte(i+1) {
+				t.Errorf("expected byte %d, got %d", i+1, page.Data()[0])
+			}
+			_ = pool.UnpinPage(id, false)
+		}
+
+		if stats := pool.Stats(); stats.Misses == 0 {
+			t.Errorf("expected misses after eviction, got %+v", stats)
+		}
+	})
+}
+
+func TestBufferPool_AllFramesPinned(t *testing.T) {
+	pool := openTestBufferPool(t, 2)
+	defer pool.Close()
+
+	for i := 0; i < 2; i++ {
+		if _, err := pool.NewPage(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err := pool.NewPage()
+	t.Run("Pool refuses to evict pinned pages", func(t *testing.T) {
+		if err != ErrBufferPoolFull {
+			t.Errorf("expected %v, got %v", ErrBufferPoolFull, err)
+		}
+	})
+
+	t.Run("Unpinning a page twice fails", func(t *testing.T) {
+		if err := pool.UnpinPage(0, false); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if err := pool.UnpinPage(0, false); err != ErrPageNotPinned {
+			t.Errorf("expected %v, got %v", ErrPageNotPinned, err)
+		}
+	})
+}
+
+func TestBufferPool_FlushAll(t *testing.T) {
+	pool := openTestBufferPool(t, 4)
+	defer pool.Close()
+
+	page, _ := pool.NewPage()
+	copy(page.Data(), "hello world")
+	_ = pool.UnpinPage(page.ID(), true)
+
+	if err := pool.FlushAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, PageSize)
+	err := pool.Disk().ReadPage(page.ID(), buf)
+	t.Run("Dirty page reaches the disk", func(t *testing.T) {
+		if err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+
+		if string(buf[:11]) != "hello world" {
+			t.Errorf("expected hello world, got %s", buf[:11])
+		}
+	})
+}
+
+func TestHeapFile_SmallBufferPool(t *testing.T) {
+	disk, err := OpenDiskManager(filepath.Join(t.TempDir(), "users.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	heap, err := NewHeapFile(NewBufferPool(disk, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer heap.Close()
+
+	record := make([]byte, 1000)
+	var rids []RID
+	for i := 0; i < 20; i++ {
+		record[0] = byte(i)
+		rid, err := heap.Insert(SystemTxn, record)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rids = append(rids, rid)
+	}
+
+	t.Run("Records survive eviction", func(t *testing.T) {
+		for i, rid := range rids {
+			res, err := heap.Get(rid)
+			if err != nil {
+				t.Fatalf("expected no error, got %s", err)
+			}
+
+			if res[0] != byte(i) {
+				t.Errorf("expected record %d, got %d", i, res[0])
+			}
+		}
+	})
+}
@@ -0,0 +1,64 @@
+package storage
+
+// ClockReplacer picks eviction victims among unpinned frames using the clock
+// (second chance) policy: a frame that was used since the hand last passed
+// it gets its reference bit cleared instead of being evicted.
+type ClockReplacer struct {
+	referenced []bool
+	evictable  []bool
+	hand       int
+	size       int
+}
+
+func NewClockReplacer(frames int) *ClockReplacer {
+	return &ClockReplacer{
+		referenced: make([]bool, frames),
+		evictable:  make([]bool, frames),
+	}
+}
+
+// Victim returns the frame to evict, or false when every frame is pinned.
+func (c *ClockReplacer) Victim() (int, bool) {
+	if c.size == 0 {
+		return 0, false
+	}
+
+	for {
+		frame := c.hand
+		c.hand = (c.hand + 1) % len(c.evictable)
+
+		if !c.evictable[frame] {
+			continue
+		}
+
+		if c.referenced[frame] {
+			c.referenced[frame] = false
+			continue
+		}
+
+		c.evictable[frame] = false
+		c.size--
+		return frame, true
+	}
+}
+
+// Pin removes the frame from the eviction candidates.
+func (c *ClockReplacer) Pin(frame int) {
+	if c.evictable[frame] {
+		c.evictable[frame] = false
+		c.size--
+	}
+}
+
+// Unpin makes the frame an eviction candidate and marks it as recently used.
+func (c *ClockReplacer) Unpin(frame int) {
+	if !c.evictable[frame] {
+		c.evictable[frame] = true
+		c.size++
+	}
+	c.referenced[frame] = true
+}
+
+func (c *ClockReplacer) Size() int {
+	return c.size
+}
@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"os"
+	"sync"
+)
+
+const PageSize = 4096
+
+type PageID uint32
+
+const InvalidPageID PageID = 0xFFFFFFFF
+
+var ErrPageNotFound = errors.New("page not found")
+
+// DiskManager reads and writes fixed-size pages of a single file.
+type DiskManager struct {
+	mu       sync.Mutex
+	file     *os.File
+	numPages uint32
+}
+
+func OpenDiskManager(filename string) (*DiskManager, error) {
+	f, err := os.OpenFile(filename, os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+
+	return &DiskManager{
+		file:     f,
+		numPages: uint32(info.Size() / PageSize),
+	}, nil
+}
+
+func (d *DiskManager) Name() string {
+	return d.file.Name()
+}
+
+func (d *DiskManager) NumPages() uint32 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.numPages
+}
+
+func (d *DiskManager) ReadPage(id PageID, buf []byte) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if uint32(id) >= d.numPages {
+		return ErrPageNotFound
+	}
+
+	_, err := d.file.ReadAt(buf[:PageSize], int64(id)*PageSize)
+	if err == io.EOF {
+		return ErrPageNotFound
+	}
+	return err
+}
+
+func (d *DiskManager) WritePage(id PageID, buf []byte) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, err := d.file.WriteAt(buf[:PageSize], int64(id)*PageSize); err != nil {
+		return err
+	}
+
+	if uint32(id) >= d.numPages {
+		d.numPages = uint32(id) + 1
+	}
+	return nil
+}
+
+// AllocatePage extends the file by one zeroed page and returns its id.
+func (d *DiskManager) AllocatePage() (PageID, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	id := PageID(d.numPages)
+	if _, err := d.file.WriteAt(make([]byte, PageSize), int64(id)*PageSize); err != nil {
+		return InvalidPageID, err
+	}
+
+	d.numPages++
+	return id, nil
+}
+
+func (d *DiskManager) Sync() error {
+	return d.file.Sync()
+}
+
+func (d *DiskManager) Close() error {
+	return d.file.Close()
+}
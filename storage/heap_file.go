@@ -0,0 +1,369 @@
+package storage
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+)
+
+// RID identifies a record inside a heap file.
+type RID struct {
+	PageID PageID
+	Slot   uint16
+}
+
+func (r RID) String() string {
+	return fmt.Sprintf("(%d,%d)", r.PageID, r.Slot)
+}
+
+// HeapFile stores variable-length records in slotted pages read through a
+// buffer pool. The free space of every page is tracked in memory so inserts
+// do not need to probe pages that are already full.
+//
+// Once a log manager is attached every change is written to the log under
+// the given transaction before the page is unpinned.
+type HeapFile struct {
+	mu        sync.RWMutex
+	pool      *BufferPool
+	log       *LogManager
+	freeSpace []int
+}
+
+func OpenHeapFile(filename string) (*HeapFile, error) {
+	disk, err := OpenDiskManager(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	heap, err := NewHeapFile(NewBufferPool(disk, DefaultBufferPoolSize))
+	if err != nil {
+		_ = disk.Close()
+		return nil, err
+	}
+	return heap, nil
+}
+
+func NewHeapFile(pool *BufferPool) (*HeapFile, error) {
+	h := &HeapFile{pool: pool}
+	for id := PageID(0); uint32(id) < pool.NumPages(); id++ {
+		page, err := pool.FetchPage(id)
+		if err != nil {
+			return nil, err
+		}
+
+		h.freeSpace = append(h.freeSpace, NewSlottedPage(page.Data()).FreeSpace())
+		if err := pool.UnpinPage(id, false); err != nil {
+			return nil, err
+		}
+	}
+
+	return h, nil
+}
+
+// Name identifies the heap file in log records.
+func (h *HeapFile) Name() string {
+	return filepath.Base(h.pool.Disk().Name())
+}
+
+func (h *HeapFile) Pool() *BufferPool {
+	return h.pool
+}
+
+// SetLogManager turns on write-ahead logging for the heap file.
+func (h *HeapFile) SetLogManager(log *LogManager) {
+	h.log = log
+	h.pool.SetLogManager(log)
+	log.RegisterHeap(h)
+}
+
+func (h *HeapFile) NumPages() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.freeSpace)
+}
+
+// withPage pins the page, runs fn on its slotted view and unpins it again,
+// refreshing the free space entry when fn modified the page.
+func (h *HeapFile) withPage(id PageID, dirty bool, fn func(page *Page, sp *SlottedPage) error) error {
+	if int(id) >= len(h.freeSpace) {
+		return ErrRecordNotFound
+	}
+
+	page, err := h.pool.FetchPage(id)
+	if err != nil {
+		return err
+	}
+
+	sp := NewSlottedPage(page.Data())
+	err = fn(page, sp)
+	if dirty {
+		h.freeSpace[id] = sp.FreeSpace()
+	}
+
+	if unpinErr := h.pool.UnpinPage(id, dirty); err == nil {
+		err = unpinErr
+	}
+	return err
+}
+
+// logChange appends the record and stamps its LSN on the page it describes.
+func (h *HeapFile) logChange(page *Page, sp *SlottedPage, rec *LogRecord) error {
+	if h.log == nil {
+		return nil
+	}
+
+	rec.File = h.Name()
+	lsn, err := h.log.Append(rec)
+	if err != nil {
+		return err
+	}
+
+	sp.SetLSN(lsn)
+	page.SetLSN(lsn)
+	return nil
+}
+
+func (h *HeapFile) Insert(txn TxnID, record []byte) (RID, error) {
+	if len(record) > MaxRecordSize {
+		return RID{}, ErrRecordTooLarge
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.insert(txn, record)
+}
+
+func (h *HeapFile) insert(txn TxnID, record []byte) (RID, error) {
+	id := InvalidPageID
+	for i, free := range h.freeSpace {
+		if free >= len(record) {
+			id = PageID(i)
+			break
+		}
+	}
+
+	if id == InvalidPageID {
+		page, err := h.pool.NewPage()
+		if err != nil {
+			return RID{}, err
+		}
+
+		id = page.ID()
+		h.freeSpace = append(h.freeSpace, NewSlottedPage(page.Data()).FreeSpace())
+		if err := h.pool.UnpinPage(id, true); err != nil {
+			return RID{}, err
+		}
+	}
+
+	rid := RID{PageID: id}
+	err := h.withPage(id, true, func(page *Page, sp *SlottedPage) error {
+		var err error
+		if rid.Slot, err = sp.Insert(record); err != nil {
+			return err
+		}
+		return h.logChange(page, sp, &LogRecord{Txn: txn, Type: LogInsert, RID: rid, After: record})
+	})
+	return rid, err
+}
+
+func (h *HeapFile) Get(rid RID) ([]byte, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	var res []byte
+	err := h.withPage(rid.PageID, false, func(page *Page, sp *SlottedPage) error {
+		var err error
+		res, err = sp.Get(rid.Slot)
+		return err
+	})
+	return res, err
+}
+
+// Update replaces the record stored under rid. When the new record no longer
+// fits in its page it is moved and the returned RID differs from rid.
+func (h *HeapFile) Update(txn TxnID, rid RID, record []byte) (RID, error) {
+	if len(record) > MaxRecordSize {
+		return RID{}, ErrRecordTooLarge
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	moved := false
+	err := h.withPage(rid.PageID, true, func(page *Page, sp *SlottedPage) error {
+		before, err := sp.Get(rid.Slot)
+		if err != nil {
+			return err
+		}
+
+		err = sp.Update(rid.Slot, record)
+		if err == nil {
+			return h.logChange(page, sp, &LogRecord{Txn: txn, Type: LogUpdate, RID: rid, Before: before, After: record})
+		}
+		if err != ErrPageFull {
+			return err
+		}
+
+		moved = true
+		if err := sp.Delete(rid.Slot); err != nil {
+			return err
+		}
+		return h.logChange(page, sp, &LogRecord{Txn: txn, Type: LogDelete, RID: rid, Before: before})
+	})
+	if err != nil {
+		return RID{}, err
+	}
+
+	if moved {
+		return h.insert(txn, record)
+	}
+	return rid, nil
+}
+
+func (h *HeapFile) Delete(txn TxnID, rid RID) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.withPage(rid.PageID, true, func(page *Page, sp *SlottedPage) error {
+		before, err := sp.Get(rid.Slot)
+		if err != nil {
+			return err
+		}
+
+		if err := sp.Delete(rid.Slot); err != nil {
+			return err
+		}
+		return h.logChange(page, sp, &LogRecord{Txn: txn, Type: LogDelete, RID: rid, Before: before})
+	})
+}
+
+// redo reapplies a logged change unless the page already contains it.
+func (h *HeapFile) redo(rec *LogRecord) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for len(h.freeSpace) <= int(rec.RID.PageID) {
+		page, err := h.pool.NewPage()
+		if err != nil {
+			return err
+		}
+
+		h.freeSpace = append(h.freeSpace, NewSlottedPage(page.Data()).FreeSpace())
+		if err := h.pool.UnpinPage(page.ID(), true); err != nil {
+			return err
+		}
+	}
+
+	return h.withPage(rec.RID.PageID, true, func(page *Page, sp *SlottedPage) error {
+		if sp.LSN() >= rec.LSN {
+			return nil
+		}
+
+		var err error
+		switch rec.Type {
+		case LogInsert:
+			err = sp.InsertAt(rec.RID.Slot, rec.After)
+		case LogUpdate:
+			err = sp.Update(rec.RID.Slot, rec.After)
+		case LogDelete:
+			err = sp.Delete(rec.RID.Slot)
+		}
+		if err != nil {
+			return err
+		}
+
+		sp.SetLSN(rec.LSN)
+		page.SetLSN(rec.LSN)
+		return nil
+	})
+}
+
+// undo reverts a logged change and writes the compensation record for it.
+func (h *HeapFile) undo(rec *LogRecord) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.withPage(rec.RID.PageID, true, func(page *Page, sp *SlottedPage) error {
+		clr := &LogRecord{Txn: rec.Txn, Compensation: true, UndoNext: rec.PrevLSN, RID: rec.RID}
+
+		var err error
+		switch rec.Type {
+		case LogInsert:
+			clr.Type, clr.Before = LogDelete, rec.After
+			err = sp.Delete(rec.RID.Slot)
+		case LogUpdate:
+			clr.Type, clr.Before, clr.After = LogUpdate, rec.After, rec.Before
+			err = sp.Update(rec.RID.Slot, rec.Before)
+		case LogDelete:
+			clr.Type, clr.After = LogInsert, rec.Before
+			err = sp.InsertAt(rec.RID.Slot, rec.Before)
+		}
+		if err != nil {
+			return err
+		}
+
+		return h.logChange(page, sp, clr)
+	})
+}
+
+func (h *HeapFile) Flush() error {
+	return h.pool.FlushAll()
+}
+
+func (h *HeapFile) Close() error {
+	return h.pool.Close()
+}
+
+type Record struct {
+	RID  RID
+	Data []byte
+}
+
+// HeapIterator walks every live record of a heap file in RID order. Records
+// are copied out one page at a time so no page stays pinned between calls.
+type HeapIterator struct {
+	heap    *HeapFile
+	pageID  PageID
+	records []*Record
+}
+
+func (h *HeapFile) Scan() *HeapIterator {
+	return &HeapIterator{heap: h}
+}
+
+// Next returns the next record, or nil once the scan is exhausted.
+func (it *HeapIterator) Next() (*Record, error) {
+	for len(it.records) == 0 {
+		it.heap.mu.RLock()
+		if int(it.pageID) >= len(it.heap.freeSpace) {
+			it.heap.mu.RUnlock()
+			return nil, nil
+		}
+
+		id := it.pageID
+		err := it.heap.withPage(id, false, func(page *Page, sp *SlottedPage) error {
+			for slot := uint16(0); slot < sp.NumSlots(); slot++ {
+				data, err := sp.Get(slot)
+				if err == ErrRecordNotFound {
+					continue
+				}
+				if err != nil {
+					return err
+				}
+				it.records = append(it.records, &Record{RID: RID{PageID: id, Slot: slot}, Data: data})
+			}
+			return nil
+		})
+		it.heap.mu.RUnlock()
+		if err != nil {
+			return nil, err
+		}
+
+		it.pageID++
+	}
+
+	rec := it.records[0]
+	it.records = it.records[1:]
+	return rec, nil
+}
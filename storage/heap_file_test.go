@@ -0,0 +1,268 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func openTestHeapFile(t *testing.T) (*HeapFile, string) {
+	filename := filepath.Join(t.TempDir(), "users.db")
+	heap, err := OpenHeapFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return heap, filename
+}
+
+func TestSlottedPage_InsertAndGet(t *testing.T) {
+	page := NewSlottedPage(make([]byte, PageSize))
+
+	slot, err := page.Insert([]byte("hello world"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := page.Get(slot)
+	t.Run("Get inserted record", func(t *testing.T) {
+		if err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+
+		if !bytes.Equal(res, []byte("hello world")) {
+			t.Errorf("expected %s, got %s", "hello world", res)
+		}
+	})
+}
+
+func TestSlottedPage_ReuseDeletedSlot(t *testing.T) {
+	page := NewSlottedPage(make([]byte, PageSize))
+
+	first, _ := page.Insert([]byte("first"))
+	_, _ = page.Insert([]byte("second"))
+
+	if err := page.Delete(first); err != nil {
+		t.Fatal(err)
+	}
+
+	slot, err := page.Insert([]byte("third"))
+	t.Run("Deleted slot is reused", func(t *testing.T) {
+		if err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+
+		if slot != first {
+			t.Errorf("expected slot %d, got %d", first, slot)
+		}
+	})
+
+	t.Run("Other records are untouched", func(t *testing.T) {
+		res, err := page.Get(1)
+		if err != nil || string(res) != "second" {
+			t.Errorf("expected second, got %s (%v)", res, err)
+		}
+	})
+}
+
+func TestSlottedPage_CompactOnFragmentation(t *testing.T) {
+	page := NewSlottedPage(make([]byte, PageSize))
+	record := bytes.Repeat([]byte("a"), 1000)
+
+	var slots []uint16
+	for {
+		slot, err := page.Insert(record)
+		if err == ErrPageFull {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		slots = append(slots, slot)
+	}
+
+	if err := page.Delete(slots[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := page.Delete(slots[2]); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := page.Insert(bytes.Repeat([]byte("b"), 1500))
+	t.Run("Insert into fragmented free space", func(t *testing.T) {
+		if err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+
+		res, _ := page.Get(slots[1])
+		if !bytes.Equal(res, record) {
+			t.Errorf("record moved by compaction was corrupted")
+		}
+	})
+}
+
+func TestHeapFile_InsertAndGet(t *testing.T) {
+	heap, _ := openTestHeapFile(t)
+	defer heap.Close()
+
+	rid, err := heap.Insert(SystemTxn, []byte("John Doe"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := heap.Get(rid)
+	t.Run("Get inserted record", func(t *testing.T) {
+		if err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+
+		if string(res) != "John Doe" {
+			t.Errorf("expected %s, got %s", "John Doe", res)
+		}
+	})
+}
+
+func TestHeapFile_InsertSpillsToNewPage(t *testing.T) {
+	heap, _ := openTestHeapFile(t)
+	defer heap.Close()
+
+	record := bytes.Repeat([]byte("x"), 1000)
+	var rids []RID
+	for i := 0; i < 10; i++ {
+		rid, err := heap.Insert(SystemTxn, record)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rids = append(rids, rid)
+	}
+
+	t.Run("Records are spread over several pages", func(t *testing.T) {
+		if heap.NumPages() < 3 {
+			t.Errorf("expected at least 3 pages, got %d", heap.NumPages())
+		}
+
+		if rids[len(rids)-1].PageID == rids[0].PageID {
+			t.Errorf("expected last record on another page than %v", rids[0])
+		}
+	})
+
+	t.Run("Record too large is rejected", func(t *testing.T) {
+		if _, err := heap.Insert(SystemTxn, make([]byte, PageSize)); err != ErrRecordTooLarge {
+			t.Errorf("expected %v, got %v", ErrRecordTooLarge, err)
+		}
+	})
+}
+
+func TestHeapFile_Update(t *testing.T) {
+	heap, _ := openTestHeapFile(t)
+	defer heap.Close()
+
+	rid, _ := heap.Insert(SystemTxn, []byte("John Doe"))
+	for i := 0; i < 3; i++ {
+		_, _ = heap.Insert(SystemTxn, bytes.Repeat([]byte("y"), 1200))
+	}
+
+	t.Run("Update in place", func(t *testing.T) {
+		newRID, err := heap.Update(SystemTxn, rid, []byte("Jane Doe"))
+		if err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+
+		if newRID != rid {
+			t.Errorf("expected rid %v, got %v", rid, newRID)
+		}
+
+		res, _ := heap.Get(rid)
+		if string(res) != "Jane Doe" {
+			t.Errorf("expected %s, got %s", "Jane Doe", res)
+		}
+	})
+
+	t.Run("Update moves record that outgrows its page", func(t *testing.T) {
+		record := bytes.Repeat([]byte("z"), 2000)
+		newRID, err := heap.Update(SystemTxn, rid, record)
+		if err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+
+		if newRID == rid {
+			t.Errorf("expected record to move away from %v", rid)
+		}
+
+		if _, err := heap.Get(rid); err != ErrRecordNotFound {
+			t.Errorf("expected %v for old rid, got %v", ErrRecordNotFound, err)
+		}
+
+		res, _ := heap.Get(newRID)
+		if !bytes.Equal(res, record) {
+			t.Errorf("moved record was corrupted")
+		}
+	})
+}
+
+func TestHeapFile_Delete(t *testing.T) {
+	heap, _ := openTestHeapFile(t)
+	defer heap.Close()
+
+	rid, _ := heap.Insert(SystemTxn, []byte("John Doe"))
+	err := heap.Delete(SystemTxn, rid)
+
+	t.Run("Delete record", func(t *testing.T) {
+		if err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+
+		if _, err := heap.Get(rid); err != ErrRecordNotFound {
+			t.Errorf("expected %v, got %v", ErrRecordNotFound, err)
+		}
+
+		if err := heap.Delete(SystemTxn, rid); err != ErrRecordNotFound {
+			t.Errorf("expected %v, got %v", ErrRecordNotFound, err)
+		}
+	})
+}
+
+func TestHeapFile_ScanAfterReopen(t *testing.T) {
+	heap, filename := openTestHeapFile(t)
+
+	for i := 0; i < 500; i++ {
+		if _, err := heap.Insert(SystemTxn, []byte(fmt.Sprintf("row-%03d", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := heap.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	heap, err := OpenHeapFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer heap.Close()
+
+	var records []string
+	it := heap.Scan()
+	for {
+		rec, err := it.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rec == nil {
+			break
+		}
+		records = append(records, string(rec.Data))
+	}
+
+	t.Run("Scan returns every persisted record in order", func(t *testing.T) {
+		if len(records) != 500 {
+			t.Fatalf("expected 500 records, got %d", len(records))
+		}
+
+		for i, rec := range records {
+			if expected := fmt.Sprintf("row-%03d", i); rec != expected {
+				t.Fatalf("expected %s, got %s", expected, rec)
+			}
+		}
+	})
+}
@@ -0,0 +1,27 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// Key is an index key encoded so that comparing the raw bytes yields the
+// same order as comparing the original values.
+type Key []byte
+
+const MaxKeySize = 512
+
+func IntKey(v int64) Key {
+	key := make(Key, 8)
+	// flipping the sign bit makes negative numbers sort before positive ones
+	binary.BigEndian.PutUint64(key, uint64(v)^(1<<63))
+	return key
+}
+
+func StringKey(s string) Key {
+	return Key(s)
+}
+
+func CompareKeys(a, b Key) int {
+	return bytes.Compare(a, b)
+}
@@ -0,0 +1,308 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"os"
+	"sync"
+)
+
+const (
+	logFileMagic = "DBWAL001"
+
+	// anything larger than the page write of a deep B+ tree split is garbage
+	// from a torn write
+	maxLogRecordSize = 64 * PageSize
+)
+
+var ErrLogRecordNotFound = errors.New("log record not found")
+
+// LogManager appends log records to the write-ahead log. Records are kept in
+// memory until Flush forces them to disk; a page may only be written back once
+// the log covering its page LSN has been flushed, and a transaction is only
+// committed once its commit record is durable.
+type LogManager struct {
+	mu         sync.Mutex
+	file       *os.File
+	buf        []byte
+	nextLSN    LSN
+	flushedLSN LSN
+	lastLSN    map[TxnID]LSN
+
+	heaps   map[string]*HeapFile
+	indexes map[string]*BTree
+}
+
+// OpenLogManager opens or creates the log file. A torn record at the end of
+// the log, left behind by a crash in the middle of a write, is cut off.
+func OpenLogManager(filename string) (*LogManager, error) {
+	f, err := os.OpenFile(filename, os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &LogManager{
+		file:    f,
+		lastLSN: make(map[TxnID]LSN),
+		heaps:   make(map[string]*HeapFile),
+		indexes: make(map[string]*BTree),
+	}
+
+	end, err := l.validEnd()
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+
+	if end == 0 {
+		if _, err := f.WriteAt([]byte(logFileMagic), 0); err != nil {
+			_ = f.Close()
+			return nil, err
+		}
+		end = LSN(len(logFileMagic))
+	}
+
+	if err := f.Truncate(int64(end)); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+
+	l.nextLSN = end
+	l.flushedLSN = end
+	return l, nil
+}
+
+// validEnd returns the offset right after the last intact record.
+func (l *LogManager) validEnd() (LSN, error) {
+	magic := make([]byte, len(logFileMagic))
+	if _, err := io.ReadFull(io.NewSectionReader(l.file, 0, int64(len(magic))), magic); err != nil {
+		return 0, nil
+	}
+	if string(magic) != logFileMagic {
+		return 0, errors.New("not a write-ahead log file")
+	}
+
+	it := &LogIterator{reader: bufio.NewReader(io.NewSectionReader(l.file, int64(len(magic)), 1<<62)), lsn: LSN(len(magic))}
+	for {
+		rec, err := it.Next()
+		if err != nil {
+			return 0, err
+		}
+		if rec == nil {
+			return it.lsn, nil
+		}
+	}
+}
+
+// RegisterHeap makes the heap file known to recovery and rollback.
+func (l *LogManager) RegisterHeap(h *HeapFile) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.heaps[h.Name()] = h
+}
+
+// RegisterIndex makes the index known to recovery and rollback.
+func (l *LogManager) RegisterIndex(t *BTree) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.indexes[t.Name()] = t
+}
+
+func (l *LogManager) heap(name string) *HeapFile {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.heaps[name]
+}
+
+func (l *LogManager) index(name string) *BTree {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.indexes[name]
+}
+
+// Append assigns the next LSN to the record, links it to the previous record
+// of the same transaction and buffers it.
+func (l *LogManager) Append(rec *LogRecord) (LSN, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	rec.LSN = l.nextLSN
+	if rec.Txn != SystemTxn {
+		rec.PrevLSN = l.lastLSN[rec.Txn]
+		l.lastLSN[rec.Txn] = rec.LSN
+	}
+
+	buf := rec.encode()
+	l.buf = append(l.buf, buf...)
+	l.nextLSN += LSN(len(buf))
+
+	if rec.Type == LogCommit || rec.Type == LogAbort {
+		delete(l.lastLSN, rec.Txn)
+	}
+	return rec.LSN, nil
+}
+
+// Flush makes every record up to and including lsn durable.
+func (l *LogManager) Flush(lsn LSN) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if lsn < l.flushedLSN || len(l.buf) == 0 {
+		return nil
+	}
+	return l.flush()
+}
+
+func (l *LogManager) FlushAll() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.flush()
+}
+
+func (l *LogManager) flush() error {
+	if len(l.buf) > 0 {
+		if _, err := l.file.WriteAt(l.buf, int64(l.flushedLSN)); err != nil {
+			return err
+		}
+		l.buf = l.buf[:0]
+	}
+
+	if err := l.file.Sync(); err != nil {
+		return err
+	}
+	l.flushedLSN = l.nextLSN
+	return nil
+}
+
+func (l *LogManager) FlushedLSN() LSN {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.flushedLSN
+}
+
+// LastLSN returns the LSN of the latest record written by the transaction.
+func (l *LogManager) LastLSN(txn TxnID) LSN {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.lastLSN[txn]
+}
+
+func (l *LogManager) Begin(txn TxnID) (LSN, error) {
+	return l.Append(&LogRecord{Txn: txn, Type: LogBegin})
+}
+
+// Commit writes the commit record and forces the log to disk.
+func (l *LogManager) Commit(txn TxnID) (LSN, error) {
+	lsn, err := l.Append(&LogRecord{Txn: txn, Type: LogCommit})
+	if err != nil {
+		return lsn, err
+	}
+	return lsn, l.Flush(lsn)
+}
+
+// Abort undoes every change of the transaction and writes its abort record.
+func (l *LogManager) Abort(txn TxnID) error {
+	if err := l.RollbackTo(txn, InvalidLSN); err != nil {
+		return err
+	}
+
+	_, err := l.Append(&LogRecord{Txn: txn, Type: LogAbort})
+	return err
+}
+
+// Read returns the record stored at lsn.
+func (l *LogManager) Read(lsn LSN) (*LogRecord, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if lsn < LSN(len(logFileMagic)) || lsn >= l.nextLSN {
+		return nil, ErrLogRecordNotFound
+	}
+
+	var reader io.Reader
+	if lsn >= l.flushedLSN {
+		reader = bytes.NewReader(l.buf[lsn-l.flushedLSN:])
+	} else {
+		reader = io.NewSectionReader(l.file, int64(lsn), int64(l.flushedLSN-lsn))
+	}
+
+	it := &LogIterator{reader: reader, lsn: lsn}
+	rec, err := it.Next()
+	if err == nil && rec == nil {
+		err = ErrLogRecordNotFound
+	}
+	return rec, err
+}
+
+// Scan flushes the log and iterates over every record from the start.
+func (l *LogManager) Scan() (*LogIterator, error) {
+	if err := l.FlushAll(); err != nil {
+		return nil, err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	start := int64(len(logFileMagic))
+	section := io.NewSectionReader(l.file, start, int64(l.flushedLSN)-start)
+	return &LogIterator{reader: bufio.NewReader(section), lsn: LSN(start)}, nil
+}
+
+func (l *LogManager) Close() error {
+	if err := l.FlushAll(); err != nil {
+		return err
+	}
+	return l.file.Close()
+}
+
+// LogIterator decodes consecutive log records. It stops at the end of the log
+// or at the first torn or corrupted record.
+type LogIterator struct {
+	reader io.Reader
+	lsn    LSN
+}
+
+// Next returns the next record, or nil once no intact record is left.
+func (it *LogIterator) Next() (*LogRecord, error) {
+	header := make([]byte, logRecordHeaderSize)
+	if _, err := io.ReadFull(it.reader, header); err != nil {
+		return nil, ignoreTornRecord(err)
+	}
+
+	size := binary.LittleEndian.Uint32(header[0:])
+	if size > maxLogRecordSize {
+		return nil, nil
+	}
+
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(it.reader, payload); err != nil {
+		return nil, ignoreTornRecord(err)
+	}
+
+	if crc32.ChecksumIEEE(payload) != binary.LittleEndian.Uint32(header[4:]) {
+		return nil, nil
+	}
+
+	rec, err := decodeLogRecord(it.lsn, payload)
+	if err != nil {
+		return nil, nil
+	}
+
+	it.lsn += LSN(logRecordHeaderSize + len(payload))
+	return rec, nil
+}
+
+func ignoreTornRecord(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func openTestLogManager(t *testing.T) (*LogManager, string) {
+	filename := filepath.Join(t.TempDir(), "wal.log")
+	log, err := OpenLogManager(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return log, filename
+}
+
+func TestLogManager_AppendAndRead(t *testing.T) {
+	log, _ := openTestLogManager(t)
+	defer log.Close()
+
+	begin, _ := log.Begin(1)
+	insert, _ := log.Append(&LogRecord{Txn: 1, Type: LogInsert, File: "users.db", RID: RID{PageID: 3, Slot: 7}, After: []byte("John Doe")})
+
+	t.Run("Records of a transaction are chained", func(t *testing.T) {
+		rec, err := log.Read(insert)
+		if err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+
+		if rec.PrevLSN != begin {
+			t.Errorf("expected prev lsn %d, got %d", begin, rec.PrevLSN)
+		}
+
+		if rec.File != "users.db" || rec.RID != (RID{PageID: 3, Slot: 7}) || string(rec.After) != "John Doe" {
+			t.Errorf("record was not decoded correctly: %+v", rec)
+		}
+	})
+
+	t.Run("Commit forces the log to disk", func(t *testing.T) {
+		if log.FlushedLSN() > insert {
+			t.Fatalf("expected unflushed records before commit")
+		}
+
+		commit, err := log.Commit(1)
+		if err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+
+		if log.FlushedLSN() <= commit {
+			t.Errorf("expected commit record %d to be flushed, flushed up to %d", commit, log.FlushedLSN())
+		}
+
+		if log.LastLSN(1) != InvalidLSN {
+			t.Errorf("expected finished transaction to be forgotten")
+		}
+	})
+
+	t.Run("Read a flushed record", func(t *testing.T) {
+		rec, err := log.Read(begin)
+		if err != nil || rec.Type != LogBegin || rec.Txn != 1 {
+			t.Errorf("expected begin record of txn 1, got %+v (%v)", rec, err)
+		}
+	})
+}
+
+func TestLogManager_TornTailIsDiscarded(t *testing.T) {
+	log, filename := openTestLogManager(t)
+
+	var ends []int64
+	for i := 0; i < 5; i++ {
+		_, _ = log.Append(&LogRecord{Txn: 1, Type: LogInsert, File: "users.db", After: bytes.Repeat([]byte{byte(i)}, 100)})
+		_ = log.FlushAll()
+		ends = append(ends, int64(log.FlushedLSN()))
+	}
+	if err := log.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// cut the last record in half
+	if err := os.Truncate(filename, ends[4]-50); err != nil {
+		t.Fatal(err)
+	}
+
+	log, err := OpenLogManager(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer log.Close()
+
+	it, err := log.Scan()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	count := 0
+	for {
+		rec, err := it.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rec == nil {
+			break
+		}
+		count++
+	}
+
+	t.Run("Only intact records are replayed", func(t *testing.T) {
+		if count != 4 {
+			t.Errorf("expected 4 records, got %d", count)
+		}
+
+		if int64(log.FlushedLSN()) != ends[3] {
+			t.Errorf("expected log to end at %d, got %d", ends[3], log.FlushedLSN())
+		}
+	})
+
+	t.Run("New records follow the last intact one", func(t *testing.T) {
+		lsn, _ := log.Append(&LogRecord{Txn: 2, Type: LogBegin})
+		if int64(lsn) != ends[3] {
+			t.Errorf("expected lsn %d, got %d", ends[3], lsn)
+		}
+	})
+}
@@ -0,0 +1,188 @@
+package storage
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+)
+
+// LSN is the byte offset of a log record in the write-ahead log.
+type LSN uint64
+
+const InvalidLSN LSN = 0
+
+type TxnID uint64
+
+// SystemTxn is used for redo-only records that do not belong to any
+// transaction and are never undone.
+const SystemTxn TxnID = 0
+
+type LogRecordType uint8
+
+const (
+	LogBegin LogRecordType = iota + 1
+	LogCommit
+	LogAbort
+	LogInsert
+	LogUpdate
+	LogDelete
+	LogPageWrite
+	LogIndexInsert
+	LogIndexDelete
+)
+
+func (t LogRecordType) String() string {
+	switch t {
+	case LogBegin:
+		return "BEGIN"
+	case LogCommit:
+		return "COMMIT"
+	case LogAbort:
+		return "ABORT"
+	case LogInsert:
+		return "INSERT"
+	case LogUpdate:
+		return "UPDATE"
+	case LogDelete:
+		return "DELETE"
+	case LogPageWrite:
+		return "PAGE_WRITE"
+	case LogIndexInsert:
+		return "INDEX_INSERT"
+	case LogIndexDelete:
+		return "INDEX_DELETE"
+	}
+	return "UNKNOWN"
+}
+
+// LogRecord describes a single change. Row records (insert, update, delete)
+// are physiological: they name a heap file and RID and carry the before and
+// after images of the record. Page writes carry the full after image of a
+// page and are redo only. Index records are logical and only used for undo,
+// the index pages themselves are restored by their page writes.
+//
+// Records written while undoing a change are compensation log records (CLR).
+// They are redone like any other record but never undone; UndoNext points at
+// the next record of the transaction that still has to be undone.
+type LogRecord struct {
+	LSN          LSN
+	PrevLSN      LSN
+	Txn          TxnID
+	Type         LogRecordType
+	Compensation bool
+	UndoNext     LSN
+	File         string
+	RID          RID
+	Key          Key
+	Before       []byte
+	After        []byte
+}
+
+// Every record is framed as | payload length (4) | crc32 of payload (4) |
+// payload |, so a torn write at the tail of the log is detected on reading.
+const logRecordHeaderSize = 8
+
+var errCorruptLogRecord = errors.New("corrupt log record")
+
+func (r *LogRecord) encode() []byte {
+	size := 1 + 1 + 8 + 8 + 8 + 2 + len(r.File) + 4 + 2 + 2 + len(r.Key) + 4 + len(r.Before) + 4 + len(r.After)
+	buf := make([]byte, logRecordHeaderSize+size)
+	p := buf[logRecordHeaderSize:]
+
+	p[0] = byte(r.Type)
+	if r.Compensation {
+		p[1] = 1
+	}
+	binary.LittleEndian.PutUint64(p[2:], uint64(r.Txn))
+	binary.LittleEndian.PutUint64(p[10:], uint64(r.PrevLSN))
+	binary.LittleEndian.PutUint64(p[18:], uint64(r.UndoNext))
+	off := 26
+
+	binary.LittleEndian.PutUint16(p[off:], uint16(len(r.File)))
+	off += 2
+	off += copy(p[off:], r.File)
+
+	binary.LittleEndian.PutUint32(p[off:], uint32(r.RID.PageID))
+	binary.LittleEndian.PutUint16(p[off+4:], r.RID.Slot)
+	off += 6
+
+	binary.LittleEndian.PutUint16(p[off:], uint16(len(r.Key)))
+	off += 2
+	off += copy(p[off:], r.Key)
+
+	binary.LittleEndian.PutUint32(p[off:], uint32(len(r.Before)))
+	off += 4
+	off += copy(p[off:], r.Before)
+
+	binary.LittleEndian.PutUint32(p[off:], uint32(len(r.After)))
+	off += 4
+	copy(p[off:], r.After)
+
+	binary.LittleEndian.PutUint32(buf[0:], uint32(size))
+	binary.LittleEndian.PutUint32(buf[4:], crc32.ChecksumIEEE(p))
+	return buf
+}
+
+func decodeLogRecord(lsn LSN, p []byte) (*LogRecord, error) {
+	r := &LogRecord{LSN: lsn}
+	if len(p) < 26 {
+		return nil, errCorruptLogRecord
+	}
+
+	r.Type = LogRecordType(p[0])
+	r.Compensation = p[1] == 1
+	r.Txn = TxnID(binary.LittleEndian.Uint64(p[2:]))
+	r.PrevLSN = LSN(binary.LittleEndian.Uint64(p[10:]))
+	r.UndoNext = LSN(binary.LittleEndian.Uint64(p[18:]))
+	off := 26
+
+	field := func(lenSize int) ([]byte, bool) {
+		if off+lenSize > len(p) {
+			return nil, false
+		}
+
+		var n int
+		if lenSize == 2 {
+			n = int(binary.LittleEndian.Uint16(p[off:]))
+		} else {
+			n = int(binary.LittleEndian.Uint32(p[off:]))
+		}
+		off += lenSize
+
+		if off+n > len(p) {
+			return nil, false
+		}
+		res := make([]byte, n)
+		copy(res, p[off:off+n])
+		off += n
+		return res, true
+	}
+
+	file, ok := field(2)
+	if !ok || off+6 > len(p) {
+		return nil, errCorruptLogRecord
+	}
+	r.File = string(file)
+	r.RID = RID{
+		PageID: PageID(binary.LittleEndian.Uint32(p[off:])),
+		Slot:   binary.LittleEndian.Uint16(p[off+4:]),
+	}
+	off += 6
+
+	key, ok := field(2)
+	if !ok {
+		return nil, errCorruptLogRecord
+	}
+	if len(key) > 0 {
+		r.Key = key
+	}
+
+	if r.Before, ok = field(4); !ok {
+		return nil, errCorruptLogRecord
+	}
+	if r.After, ok = field(4); !ok {
+		return nil, errCorruptLogRecord
+	}
+
+	return r, nil
+}
@@ -0,0 +1,214 @@
+package storage
+
+import "container/heap"
+
+// Recover runs ARIES-style crash recovery over every registered heap file and
+// index and returns the highest transaction id found in the log.
+//
+//   - analysis finds the transactions without a commit or abort record
+//   - redo repeats history, reapplying every change the pages do not have yet
+//   - undo rolls back the losers found by the analysis in one backward pass,
+//     writing compensation records so an interrupted recovery can simply be
+//     started again
+func (l *LogManager) Recover() (TxnID, error) {
+	losers, maxTxn, err := l.analyze()
+	if err != nil {
+		return maxTxn, err
+	}
+
+	if err := l.redo(); err != nil {
+		return maxTxn, err
+	}
+
+	if err := l.undo(losers); err != nil {
+		return maxTxn, err
+	}
+
+	if err := l.FlushAll(); err != nil {
+		return maxTxn, err
+	}
+	return maxTxn, l.flushFiles()
+}
+
+func (l *LogManager) analyze() (map[TxnID]LSN, TxnID, error) {
+	active := make(map[TxnID]LSN)
+	maxTxn := SystemTxn
+
+	it, err := l.Scan()
+	if err != nil {
+		return nil, maxTxn, err
+	}
+
+	for {
+		rec, err := it.Next()
+		if err != nil {
+			return nil, maxTxn, err
+		}
+		if rec == nil {
+			return active, maxTxn, nil
+		}
+
+		if rec.Txn == SystemTxn {
+			continue
+		}
+		if rec.Txn > maxTxn {
+			maxTxn = rec.Txn
+		}
+
+		switch rec.Type {
+		case LogCommit, LogAbort:
+			delete(active, rec.Txn)
+		default:
+			active[rec.Txn] = rec.LSN
+		}
+	}
+}
+
+func (l *LogManager) redo() error {
+	it, err := l.Scan()
+	if err != nil {
+		return err
+	}
+
+	for {
+		rec, err := it.Next()
+		if err != nil || rec == nil {
+			return err
+		}
+
+		switch rec.Type {
+		case LogInsert, LogUpdate, LogDelete:
+			if heap := l.heap(rec.File); heap != nil {
+				err = heap.redo(rec)
+			}
+		case LogPageWrite:
+			if index := l.index(rec.File); index != nil {
+				err = index.redoPage(rec)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// undo rolls back all losers together, always undoing the record with the
+// highest LSN of any loser next, so the changes are reverted in the reverse
+// order they were made in and the compensation records are the same on every
+// run. A loser gets its abort record once its first record is undone.
+func (l *LogManager) undo(losers map[TxnID]LSN) error {
+	next := &undoQueue{}
+	for txn, lsn := range losers {
+		l.mu.Lock()
+		l.lastLSN[txn] = lsn
+		l.mu.Unlock()
+
+		heap.Push(next, undoEntry{txn: txn, lsn: lsn})
+	}
+
+	for next.Len() > 0 {
+		entry := heap.Pop(next).(undoEntry)
+		lsn, err := l.undoRecord(entry.lsn)
+		if err != nil {
+			return err
+		}
+
+		if lsn == InvalidLSN {
+			if _, err := l.Append(&LogRecord{Txn: entry.txn, Type: LogAbort}); err != nil {
+				return err
+			}
+			continue
+		}
+		heap.Push(next, undoEntry{txn: entry.txn, lsn: lsn})
+	}
+	return nil
+}
+
+// undoEntry is the next record of a loser that undo has to revert.
+type undoEntry struct {
+	txn TxnID
+	lsn LSN
+}
+
+// undoQueue is a max-heap of undo entries ordered by LSN.
+type undoQueue []undoEntry
+
+func (q undoQueue) Len() int           { return len(q) }
+func (q undoQueue) Less(i, j int) bool { return q[i].lsn > q[j].lsn }
+func (q undoQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+func (q *undoQueue) Push(x any)        { *q = append(*q, x.(undoEntry)) }
+
+func (q *undoQueue) Pop() any {
+	old := *q
+	entry := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return entry
+}
+
+// RollbackTo undoes every change the transaction made after lsn. Passing
+// InvalidLSN rolls back the whole transaction, passing the LSN returned by
+// LastLSN at some earlier point rolls back to that savepoint.
+func (l *LogManager) RollbackTo(txn TxnID, lsn LSN) error {
+	current := l.LastLSN(txn)
+	for current != InvalidLSN && current > lsn {
+		var err error
+		if current, err = l.undoRecord(current); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// undoRecord reverts the change logged at lsn and returns the LSN of the
+// record of the same transaction to undo next. A compensation record is
+// not undone, it skips the records it already compensated.
+func (l *LogManager) undoRecord(lsn LSN) (LSN, error) {
+	rec, err := l.Read(lsn)
+	if err != nil {
+		return InvalidLSN, err
+	}
+
+	if rec.Compensation {
+		return rec.UndoNext, nil
+	}
+
+	switch rec.Type {
+	case LogInsert, LogUpdate, LogDelete:
+		if heap := l.heap(rec.File); heap != nil {
+			err = heap.undo(rec)
+		}
+	case LogIndexInsert, LogIndexDelete:
+		if index := l.index(rec.File); index != nil {
+			err = index.undo(rec)
+		}
+	}
+	if err != nil {
+		return InvalidLSN, err
+	}
+	return rec.PrevLSN, nil
+}
+
+func (l *LogManager) flushFiles() error {
+	l.mu.Lock()
+	heaps := make([]*HeapFile, 0, len(l.heaps))
+	for _, heap := range l.heaps {
+		heaps = append(heaps, heap)
+	}
+	indexes := make([]*BTree, 0, len(l.indexes))
+	for _, index := range l.indexes {
+		indexes = append(indexes, index)
+	}
+	l.mu.Unlock()
+
+	for _, heap := range heaps {
+		if err := heap.Flush(); err != nil {
+			return err
+		}
+	}
+	for _, index := range indexes {
+		if err := index.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,362 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+type walFixture struct {
+	dir   string
+	log   *LogManager
+	heap  *HeapFile
+	index *BTree
+}
+
+func openWalFixture(t *testing.T, dir string) *walFixture {
+	log, err := OpenLogManager(filepath.Join(dir, "wal.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	heap, err := OpenHeapFile(filepath.Join(dir, "users.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	heap.SetLogManager(log)
+
+	index, err := OpenBTree(filepath.Join(dir, "users_id.idx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	index.SetLogManager(log)
+
+	return &walFixture{dir: dir, log: log, heap: heap, index: index}
+}
+
+func (f *walFixture) close() {
+	_ = f.heap.Close()
+	_ = f.index.Close()
+	_ = f.log.Close()
+}
+
+// crash copies the files as they are on disk right now, keeping only the
+// first logSize bytes of the log, and recovers from the copy.
+func (f *walFixture) crash(t *testing.T, logSize int64) *walFixture {
+	dir := t.TempDir()
+	for _, name := range []string{"users.db", "users_id.idx", "wal.log"} {
+		limit := int64(-1)
+		if name == "wal.log" {
+			limit = logSize
+		}
+		copyFile(t, filepath.Join(f.dir, name), filepath.Join(dir, name), limit)
+	}
+
+	recovered := openWalFixture(t, dir)
+	if _, err := recovered.log.Recover(); err != nil {
+		t.Fatalf("recovery failed: %s", err)
+	}
+	return recovered
+}
+
+func copyFile(t *testing.T, src, dst string, limit int64) {
+	in, err := os.Open(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+
+	var r io.Reader = in
+	if limit >= 0 {
+		r = io.LimitReader(in, limit)
+	}
+	if _, err := io.Copy(out, r); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (f *walFixture) insert(t *testing.T, txn TxnID, id int64, value string) RID {
+	rid, err := f.heap.Insert(txn, []byte(value))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.index.Insert(txn, IntKey(id), rid); err != nil {
+		t.Fatal(err)
+	}
+	return rid
+}
+
+func (f *walFixture) records(t *testing.T) []string {
+	var records []string
+	it := f.heap.Scan()
+	for {
+		rec, err := it.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rec == nil {
+			break
+		}
+		records = append(records, string(rec.Data))
+	}
+
+	sort.Strings(records)
+	return records
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func copyMap[V any](m map[int64]V) map[int64]V {
+	c := make(map[int64]V, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+func TestRecovery_LosersAreUndone(t *testing.T) {
+	f := openWalFixture(t, t.TempDir())
+	defer f.close()
+
+	_, _ = f.log.Begin(1)
+	rids := make(map[int64]RID)
+	for i := int64(1); i <= 200; i++ {
+		rids[i] = f.insert(t, 1, i, fmt.Sprintf("user-%03d", i))
+	}
+	if _, err := f.log.Commit(1); err != nil {
+		t.Fatal(err)
+	}
+
+	_, _ = f.log.Begin(2)
+	for i := int64(201); i <= 400; i++ {
+		f.insert(t, 2, i, fmt.Sprintf("user-%03d", i))
+	}
+	_, _ = f.heap.Update(2, rids[1], []byte("changed"))
+	_ = f.heap.Delete(2, rids[2])
+	_ = f.index.Delete(2, IntKey(2))
+
+	// steal: uncommitted changes reach the data files before the crash
+	if err := f.heap.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.index.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	recovered := f.crash(t, int64(f.log.FlushedLSN()))
+	defer recovered.close()
+
+	t.Run("Committed rows survive", func(t *testing.T) {
+		records := recovered.records(t)
+		if len(records) != 200 {
+			t.Fatalf("expected 200 records, got %d", len(records))
+		}
+
+		for i, rec := range records {
+			if expected := fmt.Sprintf("user-%03d", i+1); rec != expected {
+				t.Fatalf("expected %s, got %s", expected, rec)
+			}
+		}
+	})
+
+	t.Run("Index matches the heap", func(t *testing.T) {
+		keys := collectKeys(t, recovered.index.Range(nil, nil))
+		if len(keys) != 200 {
+			t.Fatalf("expected 200 keys, got %d", len(keys))
+		}
+
+		rid, err := recovered.index.Get(IntKey(2))
+		if err != nil {
+			t.Fatalf("expected deleted key to be restored, got %s", err)
+		}
+		if res, _ := recovered.heap.Get(rid); string(res) != "user-002" {
+			t.Errorf("expected user-002, got %s", res)
+		}
+	})
+
+	t.Run("Recovering twice is harmless", func(t *testing.T) {
+		again := recovered.crash(t, int64(recovered.log.FlushedLSN()))
+		defer again.close()
+
+		if records := again.records(t); len(records) != 200 {
+			t.Errorf("expected 200 records, got %d", len(records))
+		}
+	})
+}
+
+func TestRecovery_TruncatedLog(t *testing.T) {
+	f := openWalFixture(t, t.TempDir())
+	defer f.close()
+
+	type commit struct {
+		end  LSN
+		rows []string
+	}
+
+	rnd := rand.New(rand.NewSource(7))
+	live := make(map[int64]RID)
+	values := make(map[int64]string)
+	var commits []commit
+	var stolen LSN
+	nextID := int64(1)
+
+	for txn := TxnID(1); txn <= 30; txn++ {
+		savedLive, savedValues := copyMap(live), copyMap(values)
+
+		_, _ = f.log.Begin(txn)
+		for i := 0; i < 20; i++ {
+			id := nextID
+			nextID++
+			values[id] = fmt.Sprintf("txn-%02d-row-%d", txn, i)
+			live[id] = f.insert(t, txn, id, values[id])
+		}
+
+		for id, rid := range live {
+			switch rnd.Intn(6) {
+			case 0:
+				values[id] = fmt.Sprintf("%s-updated-by-%02d", values[id], txn)
+				newRID, err := f.heap.Update(txn, rid, []byte(values[id]))
+				if err != nil {
+					t.Fatal(err)
+				}
+				live[id] = newRID
+			case 1:
+				if err := f.heap.Delete(txn, rid); err != nil {
+					t.Fatal(err)
+				}
+				if err := f.index.Delete(txn, IntKey(id)); err != nil {
+					t.Fatal(err)
+				}
+				delete(live, id)
+				delete(values, id)
+			}
+		}
+
+		if txn == 15 {
+			// write dirty pages, including ones with uncommitted changes
+			_ = f.heap.Flush()
+			_ = f.index.Flush()
+			stolen = f.log.FlushedLSN()
+		}
+
+		if txn%4 == 0 {
+			// a transaction that is rolled back at runtime
+			if err := f.log.Abort(txn); err != nil {
+				t.Fatal(err)
+			}
+			live, values = savedLive, savedValues
+			continue
+		}
+
+		if _, err := f.log.Commit(txn); err != nil {
+			t.Fatal(err)
+		}
+
+		var rows []string
+		for _, v := range values {
+			rows = append(rows, v)
+		}
+		sort.Strings(rows)
+		commits = append(commits, commit{end: f.log.FlushedLSN(), rows: rows})
+	}
+
+	expected := func(size int64) []string {
+		var rows []string
+		for _, c := range commits {
+			if int64(c.end) <= size {
+				rows = c.rows
+			}
+		}
+		return rows
+	}
+
+	end := int64(f.log.FlushedLSN())
+	for i := 0; i < 40; i++ {
+		size := int64(stolen) + rnd.Int63n(end-int64(stolen)+1)
+		if i == 0 {
+			size = end
+		}
+
+		t.Run(fmt.Sprintf("Log cut at %d", size), func(t *testing.T) {
+			recovered := f.crash(t, size)
+			defer recovered.close()
+
+			want := expected(size)
+			if got := recovered.records(t); !equalStrings(got, want) {
+				t.Fatalf("expected %d committed rows, got %d", len(want), len(got))
+			}
+
+			if keys := collectKeys(t, recovered.index.Range(nil, nil)); len(keys) != len(want) {
+				t.Errorf("expected %d index keys, got %d", len(want), len(keys))
+			}
+		})
+	}
+}
+
+func TestRecovery_LosersAreUndoneNewestFirst(t *testing.T) {
+	f := openWalFixture(t, t.TempDir())
+	defer f.close()
+
+	// two losers whose changes interleave
+	_, _ = f.log.Begin(1)
+	_, _ = f.log.Begin(2)
+	for _, change := range []struct {
+		txn   TxnID
+		value string
+	}{{1, "a1"}, {2, "b1"}, {1, "a2"}, {2, "b2"}, {2, "b3"}} {
+		if _, err := f.heap.Insert(change.txn, []byte(change.value)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := f.log.FlushAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the order of the losers must not depend on how recovery finds them
+	for i := 0; i < 5; i++ {
+		recovered := f.crash(t, int64(f.log.FlushedLSN()))
+
+		var undone []string
+		it, err := recovered.log.Scan()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for {
+			rec, err := it.Next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rec == nil {
+				break
+			}
+			if rec.Compensation {
+				undone = append(undone, string(rec.Before))
+			}
+		}
+		recovered.close()
+
+		if expected := []string{"b3", "b2", "a2", "b1", "a1"}; !equalStrings(undone, expected) {
+			t.Fatalf("expected changes undone as %v, got %v", expected, undone)
+		}
+	}
+}
@@ -0,0 +1,262 @@
+package storage
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// Slotted page layout:
+//
+//	| page lsn (8) | slot count (2) | free end (2) | slot 0 | slot 1 | ... free ... | record 1 | record 0 |
+//
+// Every slot holds the offset and length of its record. Records grow from
+// the end of the page towards the slot directory. A slot with offset 0 is
+// free and may be reused by the next insert. The page LSN is the LSN of the
+// last log record applied to the page and makes redo idempotent.
+const (
+	slottedHeaderSize = 12
+	slotSize          = 4
+	MaxRecordSize     = PageSize - slottedHeaderSize - slotSize
+)
+
+var (
+	ErrRecordNotFound = errors.New("record not found")
+	ErrRecordTooLarge = errors.New("record too large")
+	ErrPageFull       = errors.New("not enough space in page")
+)
+
+type SlottedPage struct {
+	data []byte
+}
+
+func NewSlottedPage(data []byte) *SlottedPage {
+	p := &SlottedPage{data: data[:PageSize]}
+	if p.freeEnd() == 0 {
+		p.Init()
+	}
+	return p
+}
+
+func (p *SlottedPage) Init() {
+	for i := range p.data {
+		p.data[i] = 0
+	}
+	p.setNumSlots(0)
+	p.setFreeEnd(PageSize)
+}
+
+func (p *SlottedPage) LSN() LSN {
+	return LSN(binary.LittleEndian.Uint64(p.data[0:]))
+}
+
+func (p *SlottedPage) SetLSN(lsn LSN) {
+	binary.LittleEndian.PutUint64(p.data[0:], uint64(lsn))
+}
+
+func (p *SlottedPage) NumSlots() uint16 {
+	return binary.LittleEndian.Uint16(p.data[8:])
+}
+
+func (p *SlottedPage) setNumSlots(n uint16) {
+	binary.LittleEndian.PutUint16(p.data[8:], n)
+}
+
+func (p *SlottedPage) freeEnd() int {
+	return int(binary.LittleEndian.Uint16(p.data[10:]))
+}
+
+func (p *SlottedPage) setFreeEnd(end int) {
+	binary.LittleEndian.PutUint16(p.data[10:], uint16(end))
+}
+
+func (p *SlottedPage) slot(i uint16) (int, int) {
+	off := slottedHeaderSize + int(i)*slotSize
+	return int(binary.LittleEndian.Uint16(p.data[off:])), int(binary.LittleEndian.Uint16(p.data[off+2:]))
+}
+
+func (p *SlottedPage) setSlot(i uint16, offset, length int) {
+	off := slottedHeaderSize + int(i)*slotSize
+	binary.LittleEndian.PutUint16(p.data[off:], uint16(offset))
+	binary.LittleEndian.PutUint16(p.data[off+2:], uint16(length))
+}
+
+func (p *SlottedPage) slotDirEnd() int {
+	return slottedHeaderSize + int(p.NumSlots())*slotSize
+}
+
+func (p *SlottedPage) freeSlot() (uint16, bool) {
+	for i := uint16(0); i < p.NumSlots(); i++ {
+		if offset, _ := p.slot(i); offset == 0 {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func (p *SlottedPage) liveBytes() int {
+	total := 0
+	for i := uint16(0); i < p.NumSlots(); i++ {
+		if offset, length := p.slot(i); offset != 0 {
+			total += length
+		}
+	}
+	return total
+}
+
+// FreeSpace returns the largest record that can be inserted into the page,
+// counting space that is only reclaimable by compaction.
+func (p *SlottedPage) FreeSpace() int {
+	free := PageSize - p.slotDirEnd() - p.liveBytes()
+	if _, ok := p.freeSlot(); !ok {
+		free -= slotSize
+	}
+	if free < 0 {
+		return 0
+	}
+	return free
+}
+
+func (p *SlottedPage) Get(slot uint16) ([]byte, error) {
+	if slot >= p.NumSlots() {
+		return nil, ErrRecordNotFound
+	}
+
+	offset, length := p.slot(slot)
+	if offset == 0 {
+		return nil, ErrRecordNotFound
+	}
+
+	res := make([]byte, length)
+	copy(res, p.data[offset:offset+length])
+	return res, nil
+}
+
+func (p *SlottedPage) Insert(record []byte) (uint16, error) {
+	if len(record) > MaxRecordSize {
+		return 0, ErrRecordTooLarge
+	}
+
+	if len(record) > p.FreeSpace() {
+		return 0, ErrPageFull
+	}
+
+	slot, reuse := p.freeSlot()
+	if !reuse {
+		slot = p.NumSlots()
+	}
+
+	p.place(slot, record, reuse)
+	return slot, nil
+}
+
+// InsertAt stores the record under a specific slot number, growing the slot
+// directory when needed. It is used to replay an insert at its original RID.
+func (p *SlottedPage) InsertAt(slot uint16, record []byte) error {
+	if slot < p.NumSlots() {
+		if offset, _ := p.slot(slot); offset != 0 {
+			return errors.New("slot already in use")
+		}
+	}
+
+	need := len(record)
+	if slot >= p.NumSlots() {
+		need += int(slot-p.NumSlots()+1) * slotSize
+	}
+	if need > PageSize-p.slotDirEnd()-p.liveBytes() {
+		return ErrPageFull
+	}
+
+	p.place(slot, record, slot < p.NumSlots())
+	return nil
+}
+
+func (p *SlottedPage) place(slot uint16, record []byte, reuse bool) {
+	dirEnd := p.slotDirEnd()
+	if !reuse {
+		dirEnd = slottedHeaderSize + int(slot+1)*slotSize
+	}
+
+	if p.freeEnd()-dirEnd < len(record) {
+		p.compact()
+	}
+
+	if !reuse {
+		for i := p.NumSlots(); i < slot; i++ {
+			p.setSlot(i, 0, 0)
+		}
+		p.setNumSlots(slot + 1)
+	}
+
+	offset := p.freeEnd() - len(record)
+	copy(p.data[offset:], record)
+	p.setFreeEnd(offset)
+	p.setSlot(slot, offset, len(record))
+}
+
+func (p *SlottedPage) Update(slot uint16, record []byte) error {
+	if slot >= p.NumSlots() {
+		return ErrRecordNotFound
+	}
+
+	offset, length := p.slot(slot)
+	if offset == 0 {
+		return ErrRecordNotFound
+	}
+
+	if len(record) <= length {
+		copy(p.data[offset:], record)
+		p.setSlot(slot, offset, len(record))
+		return nil
+	}
+
+	if len(record)-length > PageSize-p.slotDirEnd()-p.liveBytes() {
+		return ErrPageFull
+	}
+
+	p.setSlot(slot, 0, 0)
+	p.place(slot, record, true)
+	return nil
+}
+
+func (p *SlottedPage) Delete(slot uint16) error {
+	if slot >= p.NumSlots() {
+		return ErrRecordNotFound
+	}
+
+	if offset, _ := p.slot(slot); offset == 0 {
+		return ErrRecordNotFound
+	}
+
+	p.setSlot(slot, 0, 0)
+
+	// trailing free slots can be dropped from the directory entirely
+	n := p.NumSlots()
+	for n > 0 {
+		if offset, _ := p.slot(n - 1); offset != 0 {
+			break
+		}
+		n--
+	}
+	p.setNumSlots(n)
+	return nil
+}
+
+// compact moves all live records to the end of the page so the free space
+// between the slot directory and the records is contiguous again.
+func (p *SlottedPage) compact() {
+	buf := make([]byte, PageSize)
+	end := PageSize
+	for i := uint16(0); i < p.NumSlots(); i++ {
+		offset, length := p.slot(i)
+		if offset == 0 {
+			continue
+		}
+
+		end -= length
+		copy(buf[end:], p.data[offset:offset+length])
+		p.setSlot(i, end, length)
+	}
+
+	copy(p.data[end:], buf[end:])
+	p.setFreeEnd(end)
+}
@@ -3,6 +3,7 @@ package storage
 import (
 	"log"
 	"os"
+	"path/filepath"
 )
 
 type Storage struct {
@@ -17,8 +18,47 @@ func Open(filename string) (*Storage, error) {
 	return &Storage{file: f}, nil
 }
 
+// Write replaces the file content atomically: the data goes to a temporary
+// file next to it which is synced and then renamed over the original, so a
+// crash leaves either the old or the new content behind.
 func (s *Storage) Write(val []byte) error {
-	return os.WriteFile(s.file.Name(), val, 0666)
+	name := s.file.Name()
+	tmp, err := os.CreateTemp(filepath.Dir(name), filepath.Base(name)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(val); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), name); err != nil {
+		return err
+	}
+
+	return syncDir(filepath.Dir(name))
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	// some platforms do not support syncing directories, the rename itself
+	// has already happened at this point
+	_ = d.Sync()
+	return nil
 }
 
 func (s *Storage) Read() []byte {
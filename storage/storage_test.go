@@ -3,6 +3,8 @@ package storage
 import (
 	"bytes"
 	"encoding/hex"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -67,3 +69,32 @@ func TestStorage_Read(t *testing.T) {
 	})
 
 }
+
+func TestStorage_WriteReplacesContent(t *testing.T) {
+	dir := t.TempDir()
+	storage, err := Open(filepath.Join(dir, "schema.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer storage.Close()
+
+	_ = storage.Write([]byte("a much longer first version"))
+	err = storage.Write([]byte("short"))
+
+	t.Run("Old content is replaced as a whole", func(t *testing.T) {
+		if err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+
+		if res := storage.Read(); string(res) != "short" {
+			t.Errorf("expected %s, got %s", "short", res)
+		}
+	})
+
+	t.Run("No temporary file is left behind", func(t *testing.T) {
+		entries, _ := os.ReadDir(dir)
+		if len(entries) != 1 {
+			t.Errorf("expected 1 file, got %d", len(entries))
+		}
+	})
+}
@@ -0,0 +1,350 @@
+package transaction
+
+import (
+	"dbngin3/storage"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const DefaultLockTimeout = 10 * time.Second
+
+// Error is a transaction failure reported to the client the way MySQL
+// reports it, with an error code and an SQLSTATE.
+type Error struct {
+	Code    int
+	State   string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("ERROR %d (%s): %s", e.Code, e.State, e.Message)
+}
+
+var (
+	ErrLockTimeout = &Error{Code: 1205, State: "HY000", Message: "Lock wait timeout exceeded; try restarting transaction"}
+	ErrDeadlock    = &Error{Code: 1213, State: "40001", Message: "Deadlock found when trying to get lock; try restarting transaction"}
+)
+
+type LockMode int
+
+const (
+	IntentionShared LockMode = iota
+	IntentionExclusive
+	Shared
+	Exclusive
+)
+
+func (m LockMode) String() string {
+	switch m {
+	case IntentionShared:
+		return "IS"
+	case IntentionExclusive:
+		return "IX"
+	case Shared:
+		return "S"
+	case Exclusive:
+		return "X"
+	}
+	return "UNKNOWN"
+}
+
+var lockCompatible = [4][4]bool{
+	IntentionShared:    {IntentionShared: true, IntentionExclusive: true, Shared: true},
+	IntentionExclusive: {IntentionShared: true, IntentionExclusive: true},
+	Shared:             {IntentionShared: true, Shared: true},
+	Exclusive:          {},
+}
+
+// covers reports whether holding m already grants other.
+func (m LockMode) covers(other LockMode) bool {
+	switch m {
+	case Exclusive:
+		return true
+	case Shared:
+		return other == Shared || other == IntentionShared
+	case IntentionExclusive:
+		return other == IntentionExclusive || other == IntentionShared
+	}
+	return other == IntentionShared
+}
+
+// upgrade returns the weakest mode granting both m and other. There is no
+// SIX mode, S combined with IX becomes X.
+func (m LockMode) upgrade(other LockMode) LockMode {
+	if m.covers(other) {
+		return m
+	}
+	if other.covers(m) {
+		return other
+	}
+	return Exclusive
+}
+
+// Resource names a lockable object: a whole table, or a row of it when Row
+// is set.
+type Resource struct {
+	Table string
+	Row   string
+}
+
+func (r Resource) String() string {
+	if r.Row == "" {
+		return r.Table
+	}
+	return r.Table + "/" + r.Row
+}
+
+type lockRequest struct {
+	txn  storage.TxnID
+	mode LockMode
+	done chan error
+}
+
+type lockQueue struct {
+	granted map[storage.TxnID]LockMode
+	waiting []*lockRequest
+}
+
+// compatible reports whether txn could hold mode next to the other holders.
+func (q *lockQueue) compatible(txn storage.TxnID, mode LockMode) bool {
+	for holder, held := range q.granted {
+		if holder != txn && !lockCompatible[held][mode] {
+			return false
+		}
+	}
+	return true
+}
+
+// LockManager grants shared, exclusive and intention locks. Transactions
+// follow strict two-phase locking: locks are only acquired while the
+// transaction runs and all of them are released together when it finishes.
+//
+// A request that has to wait is checked against the wait-for graph; when it
+// closes a cycle the youngest transaction of the cycle is chosen as the
+// victim and its request fails with ErrDeadlock. A request that waits longer
+// than the timeout fails with ErrLockTimeout.
+type LockManager struct {
+	mu      sync.Mutex
+	timeout time.Duration
+	queues  map[Resource]*lockQueue
+	held    map[storage.TxnID]map[Resource]bool
+	waits   map[storage.TxnID]Resource
+}
+
+func NewLockManager(timeout time.Duration) *LockManager {
+	return &LockManager{
+		timeout: timeout,
+		queues:  make(map[Resource]*lockQueue),
+		held:    make(map[storage.TxnID]map[Resource]bool),
+		waits:   make(map[storage.TxnID]Resource),
+	}
+}
+
+func (lm *LockManager) SetTimeout(timeout time.Duration) {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+	lm.timeout = timeout
+}
+
+func (lm *LockManager) Lock(txn storage.TxnID, res Resource, mode LockMode) error {
+	lm.mu.Lock()
+
+	q, ok := lm.queues[res]
+	if !ok {
+		q = &lockQueue{granted: make(map[storage.TxnID]LockMode)}
+		lm.queues[res] = q
+	}
+
+	held, holding := q.granted[txn]
+	if holding && held.covers(mode) {
+		lm.mu.Unlock()
+		return nil
+	}
+	if holding {
+		mode = held.upgrade(mode)
+	}
+
+	// upgrades may pass the queue, everybody else waits for their turn
+	if q.compatible(txn, mode) && (holding || len(q.waiting) == 0) {
+		lm.grant(q, txn, res, mode)
+		lm.mu.Unlock()
+		return nil
+	}
+
+	req := &lockRequest{txn: txn, mode: mode, done: make(chan error, 1)}
+	if holding {
+		q.waiting = append([]*lockRequest{req}, q.waiting...)
+	} else {
+		q.waiting = append(q.waiting, req)
+	}
+	lm.waits[txn] = res
+
+	if victim, found := lm.findDeadlock(txn); found {
+		lm.cancel(victim, ErrDeadlock)
+	}
+	timeout := lm.timeout
+	lm.mu.Unlock()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case err := <-req.done:
+		return err
+	case <-timer.C:
+	}
+
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+	if lm.waits[txn] == res {
+		lm.cancel(txn, ErrLockTimeout)
+	}
+	return <-req.done
+}
+
+// grant must be called with lm.mu held.
+func (lm *LockManager) grant(q *lockQueue, txn storage.TxnID, res Resource, mode LockMode) {
+	q.granted[txn] = mode
+	if lm.held[txn] == nil {
+		lm.held[txn] = make(map[Resource]bool)
+	}
+	lm.held[txn][res] = true
+}
+
+// cancel fails the pending request of txn. It must be called with lm.mu held.
+func (lm *LockManager) cancel(txn storage.TxnID, err error) {
+	res, ok := lm.waits[txn]
+	if !ok {
+		return
+	}
+	delete(lm.waits, txn)
+
+	q := lm.queues[res]
+	for i, req := range q.waiting {
+		if req.txn == txn {
+			q.waiting = append(q.waiting[:i], q.waiting[i+1:]...)
+			req.done <- err
+			break
+		}
+	}
+	lm.wake(q, res)
+}
+
+// wake grants waiting requests in queue order until one has to keep
+// waiting. It must be called with lm.mu held.
+func (lm *LockManager) wake(q *lockQueue, res Resource) {
+	for len(q.waiting) > 0 {
+		req := q.waiting[0]
+		if !q.compatible(req.txn, req.mode) {
+			return
+		}
+
+		q.waiting = q.waiting[1:]
+		delete(lm.waits, req.txn)
+		lm.grant(q, req.txn, res, req.mode)
+		req.done <- nil
+	}
+
+	if len(q.granted) == 0 {
+		delete(lm.queues, res)
+	}
+}
+
+// ReleaseAll drops every lock of the transaction and cancels a request it
+// may still be waiting for.
+func (lm *LockManager) ReleaseAll(txn storage.TxnID) {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+
+	lm.cancel(txn, ErrNotActive)
+	for res := range lm.held[txn] {
+		q := lm.queues[res]
+		delete(q.granted, txn)
+		lm.wake(q, res)
+	}
+	delete(lm.held, txn)
+}
+
+// Holds returns the mode txn holds on the resource.
+func (lm *LockManager) Holds(txn storage.TxnID, res Resource) (LockMode, bool) {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+
+	q, ok := lm.queues[res]
+	if !ok {
+		return 0, false
+	}
+	mode, ok := q.granted[txn]
+	return mode, ok
+}
+
+// blockers returns the transactions txn waits for: holders of incompatible
+// locks and incompatible requests queued before its own. It must be called
+// with lm.mu held.
+func (lm *LockManager) blockers(txn storage.TxnID) []storage.TxnID {
+	res, ok := lm.waits[txn]
+	if !ok {
+		return nil
+	}
+
+	q := lm.queues[res]
+	var mode LockMode
+	var ahead []*lockRequest
+	for i, req := range q.waiting {
+		if req.txn == txn {
+			mode, ahead = req.mode, q.waiting[:i]
+			break
+		}
+	}
+
+	var waitsFor []storage.TxnID
+	for holder, held := range q.granted {
+		if holder != txn && !lockCompatible[held][mode] {
+			waitsFor = append(waitsFor, holder)
+		}
+	}
+	for _, req := range ahead {
+		if !lockCompatible[req.mode][mode] {
+			waitsFor = append(waitsFor, req.txn)
+		}
+	}
+	return waitsFor
+}
+
+// findDeadlock looks for a cycle in the wait-for graph through txn and
+// returns the youngest transaction on it. It must be called with lm.mu held.
+func (lm *LockManager) findDeadlock(txn storage.TxnID) (storage.TxnID, bool) {
+	var path []storage.TxnID
+	visited := make(map[storage.TxnID]bool)
+
+	var visit func(current storage.TxnID) bool
+	visit = func(current storage.TxnID) bool {
+		path = append(path, current)
+		for _, next := range lm.blockers(current) {
+			if next == txn {
+				return true
+			}
+			if !visited[next] {
+				visited[next] = true
+				if visit(next) {
+					return true
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		return false
+	}
+
+	if !visit(txn) {
+		return 0, false
+	}
+
+	victim := path[0]
+	for _, id := range path[1:] {
+		if id > victim {
+			victim = id
+		}
+	}
+	return victim, true
+}
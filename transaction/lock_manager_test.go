@@ -0,0 +1,209 @@
+package transaction
+
+import (
+	"dbngin3/storage"
+	"testing"
+	"time"
+)
+
+var (
+	tableA = Resource{Table: "a"}
+	rowA1  = Resource{Table: "a", Row: "1"}
+	rowA2  = Resource{Table: "a", Row: "2"}
+)
+
+// lockAsync requests the lock in the background and waits until it is
+// granted or queued.
+func lockAsync(t *testing.T, lm *LockManager, txn storage.TxnID, res Resource, mode LockMode) <-chan error {
+	done := make(chan error, 1)
+	go func() {
+		done <- lm.Lock(txn, res, mode)
+	}()
+
+	for start := time.Now(); time.Since(start) < time.Second; time.Sleep(time.Millisecond) {
+		lm.mu.Lock()
+		_, waiting := lm.waits[txn]
+		granted := false
+		if q, ok := lm.queues[res]; ok {
+			_, granted = q.granted[txn]
+		}
+		lm.mu.Unlock()
+		if waiting || granted {
+			return done
+		}
+	}
+	t.Fatalf("lock request of %d on %s never arrived", txn, res)
+	return done
+}
+
+func expectBlocked(t *testing.T, done <-chan error) {
+	select {
+	case err := <-done:
+		t.Fatalf("expected the request to wait, got %v", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+}
+
+func TestLockManager_Compatibility(t *testing.T) {
+	tests := []struct {
+		held, requested LockMode
+		compatible      bool
+	}{
+		{IntentionShared, IntentionShared, true},
+		{IntentionShared, IntentionExclusive, true},
+		{IntentionShared, Shared, true},
+		{IntentionShared, Exclusive, false},
+		{IntentionExclusive, IntentionExclusive, true},
+		{IntentionExclusive, Shared, false},
+		{Shared, Shared, true},
+		{Shared, IntentionExclusive, false},
+		{Exclusive, IntentionShared, false},
+	}
+
+	for _, tt := range tests {
+		lm := NewLockManager(10 * time.Millisecond)
+		if err := lm.Lock(1, tableA, tt.held); err != nil {
+			t.Fatal(err)
+		}
+
+		err := lm.Lock(2, tableA, tt.requested)
+		if tt.compatible && err != nil {
+			t.Errorf("%s then %s: expected no error, got %v", tt.held, tt.requested, err)
+		}
+		if !tt.compatible && err != ErrLockTimeout {
+			t.Errorf("%s then %s: expected %v, got %v", tt.held, tt.requested, ErrLockTimeout, err)
+		}
+	}
+}
+
+func TestLockManager_Upgrade(t *testing.T) {
+	lm := NewLockManager(10 * time.Millisecond)
+
+	t.Run("Stronger lock covers weaker requests", func(t *testing.T) {
+		_ = lm.Lock(1, rowA1, Exclusive)
+		if err := lm.Lock(1, rowA1, Shared); err != nil {
+			t.Fatal(err)
+		}
+		if mode, _ := lm.Holds(1, rowA1); mode != Exclusive {
+			t.Errorf("expected %s, got %s", Exclusive, mode)
+		}
+	})
+
+	t.Run("Shared and intention exclusive combine to exclusive", func(t *testing.T) {
+		_ = lm.Lock(1, tableA, Shared)
+		if err := lm.Lock(1, tableA, IntentionExclusive); err != nil {
+			t.Fatal(err)
+		}
+		if mode, _ := lm.Holds(1, tableA); mode != Exclusive {
+			t.Errorf("expected %s, got %s", Exclusive, mode)
+		}
+	})
+
+	t.Run("Upgrade waits for other holders", func(t *testing.T) {
+		_ = lm.Lock(1, rowA2, Shared)
+		_ = lm.Lock(2, rowA2, Shared)
+
+		if err := lm.Lock(1, rowA2, Exclusive); err != ErrLockTimeout {
+			t.Errorf("expected %v, got %v", ErrLockTimeout, err)
+		}
+		if mode, _ := lm.Holds(1, rowA2); mode != Shared {
+			t.Errorf("expected the shared lock to stay, got %s", mode)
+		}
+	})
+}
+
+func TestLockManager_ReleaseAll(t *testing.T) {
+	lm := NewLockManager(time.Second)
+	_ = lm.Lock(1, tableA, IntentionExclusive)
+	_ = lm.Lock(1, rowA1, Exclusive)
+
+	shared := lockAsync(t, lm, 2, rowA1, Shared)
+	exclusive := lockAsync(t, lm, 3, rowA1, Exclusive)
+	alsoShared := lockAsync(t, lm, 4, rowA1, Shared)
+	expectBlocked(t, shared)
+
+	lm.ReleaseAll(1)
+
+	t.Run("Locks are released together", func(t *testing.T) {
+		if _, ok := lm.Holds(1, tableA); ok {
+			t.Errorf("expected the table lock to be released")
+		}
+		if _, ok := lm.Holds(1, rowA1); ok {
+			t.Errorf("expected the row lock to be released")
+		}
+	})
+
+	t.Run("Waiters are granted in queue order", func(t *testing.T) {
+		if err := <-shared; err != nil {
+			t.Fatal(err)
+		}
+		// the second shared request queued behind the exclusive one
+		expectBlocked(t, alsoShared)
+
+		lm.ReleaseAll(2)
+		if err := <-exclusive; err != nil {
+			t.Fatal(err)
+		}
+		lm.ReleaseAll(3)
+		if err := <-alsoShared; err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestLockManager_Deadlock(t *testing.T) {
+	t.Run("Requester closing the cycle is the youngest", func(t *testing.T) {
+		lm := NewLockManager(time.Second)
+		_ = lm.Lock(1, rowA1, Exclusive)
+		_ = lm.Lock(2, rowA2, Exclusive)
+
+		older := lockAsync(t, lm, 1, rowA2, Exclusive)
+		if err := lm.Lock(2, rowA1, Exclusive); err != ErrDeadlock {
+			t.Fatalf("expected %v, got %v", ErrDeadlock, err)
+		}
+
+		expectBlocked(t, older)
+		lm.ReleaseAll(2)
+		if err := <-older; err != nil {
+			t.Errorf("expected the older transaction to get the lock, got %v", err)
+		}
+	})
+
+	t.Run("Waiting younger transaction is the victim", func(t *testing.T) {
+		lm := NewLockManager(time.Second)
+		_ = lm.Lock(1, rowA1, Exclusive)
+		_ = lm.Lock(2, rowA2, Exclusive)
+
+		younger := lockAsync(t, lm, 2, rowA1, Exclusive)
+		older := lockAsync(t, lm, 1, rowA2, Exclusive)
+
+		if err := <-younger; err != ErrDeadlock {
+			t.Fatalf("expected %v, got %v", ErrDeadlock, err)
+		}
+		lm.ReleaseAll(2)
+		if err := <-older; err != nil {
+			t.Errorf("expected the older transaction to get the lock, got %v", err)
+		}
+	})
+
+	t.Run("Cycle through three transactions", func(t *testing.T) {
+		lm := NewLockManager(time.Second)
+		rowA3 := Resource{Table: "a", Row: "3"}
+		_ = lm.Lock(1, rowA1, Exclusive)
+		_ = lm.Lock(2, rowA2, Exclusive)
+		_ = lm.Lock(3, rowA3, Exclusive)
+
+		first := lockAsync(t, lm, 1, rowA2, Exclusive)
+		third := lockAsync(t, lm, 3, rowA1, Exclusive)
+		second := lockAsync(t, lm, 2, rowA3, Exclusive)
+
+		if err := <-third; err != ErrDeadlock {
+			t.Fatalf("expected %v, got %v", ErrDeadlock, err)
+		}
+		lm.ReleaseAll(3)
+		if err := <-second; err != nil {
+			t.Fatalf("expected no error after the victim released its locks, got %v", err)
+		}
+		expectBlocked(t, first)
+	})
+}
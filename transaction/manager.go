@@ -0,0 +1,188 @@
+package transaction
+
+import (
+	"dbngin3/storage"
+	"errors"
+	"sync"
+)
+
+var (
+	ErrNotActive         = errors.New("transaction is not active")
+	ErrSavepointNotFound = errors.New("savepoint does not exist")
+	// ErrWriteConflict is returned when a transaction tries to change a row
+	// that a concurrent transaction changed first. The later transaction is
+	// rolled back.
+	ErrWriteConflict = errors.New("could not serialize access due to concurrent update")
+)
+
+// Manager hands out transaction ids and drives commit and rollback through
+// the write-ahead log: a commit is durable once its record is flushed, a
+// rollback undoes the logged changes of the transaction in reverse order.
+type Manager struct {
+	mu     sync.Mutex
+	log    *storage.LogManager
+	nextID storage.TxnID
+	active map[storage.TxnID]*Transaction
+	locks  *LockManager
+}
+
+// NewManager continues numbering after lastID, the highest transaction id
+// returned by recovery.
+func NewManager(log *storage.LogManager, lastID storage.TxnID) *Manager {
+	return &Manager{
+		log:    log,
+		nextID: lastID + 1,
+		active: make(map[storage.TxnID]*Transaction),
+		locks:  NewLockManager(DefaultLockTimeout),
+	}
+}
+
+func (m *Manager) Locks() *LockManager {
+	return m.locks
+}
+
+// Lock acquires a lock for the transaction. It is held until the transaction
+// commits or rolls back.
+func (m *Manager) Lock(txn *Transaction, res Resource, mode LockMode) error {
+	if txn.state != Active {
+		return ErrNotActive
+	}
+	return m.locks.Lock(txn.id, res, mode)
+}
+
+func (m *Manager) Begin(isolation IsolationLevel) (*Transaction, error) {
+	m.mu.Lock()
+	txn := &Transaction{id: m.nextID, state: Active, isolation: isolation}
+	m.nextID++
+	m.active[txn.id] = txn
+	txn.snapshot = m.takeSnapshot()
+	m.mu.Unlock()
+
+	if _, err := m.log.Begin(txn.id); err != nil {
+		m.finish(txn, Aborted)
+		return nil, err
+	}
+	return txn, nil
+}
+
+func (m *Manager) Commit(txn *Transaction) error {
+	if txn.state != Active {
+		return ErrNotActive
+	}
+
+	if _, err := m.log.Commit(txn.id); err != nil {
+		return err
+	}
+
+	m.finish(txn, Committed)
+	return nil
+}
+
+func (m *Manager) Rollback(txn *Transaction) error {
+	if txn.state != Active {
+		return ErrNotActive
+	}
+
+	err := m.log.Abort(txn.id)
+	m.finish(txn, Aborted)
+	return err
+}
+
+// Savepoint sets a savepoint; an older savepoint with the same name is
+// replaced.
+func (m *Manager) Savepoint(txn *Transaction, name string) error {
+	if txn.state != Active {
+		return ErrNotActive
+	}
+
+	if i := txn.findSavepoint(name); i >= 0 {
+		txn.savepoints = append(txn.savepoints[:i], txn.savepoints[i+1:]...)
+	}
+	txn.savepoints = append(txn.savepoints, savepoint{name: name, lsn: m.log.LastLSN(txn.id)})
+	return nil
+}
+
+// RollbackTo undoes every change made after the savepoint. The savepoint
+// itself stays, savepoints set after it are discarded.
+func (m *Manager) RollbackTo(txn *Transaction, name string) error {
+	if txn.state != Active {
+		return ErrNotActive
+	}
+
+	i := txn.findSavepoint(name)
+	if i < 0 {
+		return ErrSavepointNotFound
+	}
+
+	txn.savepoints = txn.savepoints[:i+1]
+	return m.log.RollbackTo(txn.id, txn.savepoints[i].lsn)
+}
+
+// Run executes a single statement of the transaction. A failing statement
+// leaves no changes behind while the transaction itself stays active, unless
+// it lost a write conflict or was chosen as a deadlock victim: then the whole
+// transaction is rolled back.
+func (m *Manager) Run(txn *Transaction, fn func() error) error {
+	if txn.state != Active {
+		return ErrNotActive
+	}
+
+	if txn.isolation == ReadCommitted {
+		m.mu.Lock()
+		txn.snapshot = m.takeSnapshot()
+		m.mu.Unlock()
+	}
+
+	start := m.log.LastLSN(txn.id)
+	err := fn()
+	if errors.Is(err, ErrWriteConflict) || errors.Is(err, ErrDeadlock) {
+		if rollbackErr := m.Rollback(txn); rollbackErr != nil {
+			return rollbackErr
+		}
+		return err
+	}
+	if err != nil {
+		if undoErr := m.log.RollbackTo(txn.id, start); undoErr != nil {
+			return undoErr
+		}
+		return err
+	}
+	return nil
+}
+
+// takeSnapshot must be called with m.mu held.
+func (m *Manager) takeSnapshot() *Snapshot {
+	snapshot := &Snapshot{xmax: m.nextID, active: make(map[storage.TxnID]bool, len(m.active))}
+	for id := range m.active {
+		snapshot.active[id] = true
+	}
+	return snapshot
+}
+
+// IsActive reports whether txn has started and not finished yet. Changes of
+// a transaction that is no longer active are committed: a rollback removes
+// them before the transaction finishes.
+func (m *Manager) IsActive(txn storage.TxnID) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.active[txn] != nil
+}
+
+// finish releases the locks of the transaction only after its changes were
+// committed or undone.
+func (m *Manager) finish(txn *Transaction, state State) {
+	m.mu.Lock()
+	txn.state = state
+	txn.savepoints = nil
+	delete(m.active, txn.id)
+	m.mu.Unlock()
+
+	m.locks.ReleaseAll(txn.id)
+}
+
+// Active returns the number of transactions that have not finished yet.
+func (m *Manager) Active() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.active)
+}
@@ -0,0 +1,162 @@
+package transaction
+
+import (
+	"dbngin3/storage"
+	"path/filepath"
+	"testing"
+)
+
+func openTestManager(t *testing.T) (*Manager, *storage.HeapFile) {
+	dir := t.TempDir()
+	log, err := storage.OpenLogManager(filepath.Join(dir, "wal.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = log.Close() })
+
+	heap, err := storage.OpenHeapFile(filepath.Join(dir, "users.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = heap.Close() })
+	heap.SetLogManager(log)
+
+	return NewManager(log, storage.SystemTxn), heap
+}
+
+func countRecords(t *testing.T, heap *storage.HeapFile) int {
+	count := 0
+	it := heap.Scan()
+	for {
+		rec, err := it.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rec == nil {
+			return count
+		}
+		count++
+	}
+}
+
+func TestManager_CommitAndRollback(t *testing.T) {
+	m, heap := openTestManager(t)
+
+	committed, _ := m.Begin(RepeatableRead)
+	_, _ = heap.Insert(committed.ID(), []byte("John Doe"))
+	if err := m.Commit(committed); err != nil {
+		t.Fatal(err)
+	}
+
+	aborted, _ := m.Begin(RepeatableRead)
+	_, _ = heap.Insert(aborted.ID(), []byte("Jane Doe"))
+	_, _ = heap.Insert(aborted.ID(), []byte("Marty McFly"))
+	err := m.Rollback(aborted)
+
+	t.Run("Rollback undoes every change of the transaction", func(t *testing.T) {
+		if err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+
+		if count := countRecords(t, heap); count != 1 {
+			t.Errorf("expected 1 record, got %d", count)
+		}
+	})
+
+	t.Run("Transaction ids are increasing", func(t *testing.T) {
+		if aborted.ID() <= committed.ID() {
+			t.Errorf("expected id after %d, got %d", committed.ID(), aborted.ID())
+		}
+	})
+
+	t.Run("Finished transactions cannot be used again", func(t *testing.T) {
+		if committed.State() != Committed || aborted.State() != Aborted {
+			t.Errorf("expected COMMITTED and ABORTED, got %s and %s", committed.State(), aborted.State())
+		}
+
+		if err := m.Commit(aborted); err != ErrNotActive {
+			t.Errorf("expected %v, got %v", ErrNotActive, err)
+		}
+
+		if m.Active() != 0 {
+			t.Errorf("expected no active transactions, got %d", m.Active())
+		}
+	})
+}
+
+func TestManager_RollbackToSavepoint(t *testing.T) {
+	m, heap := openTestManager(t)
+
+	txn, _ := m.Begin(RepeatableRead)
+	_, _ = heap.Insert(txn.ID(), []byte("first"))
+	_ = m.Savepoint(txn, "one")
+	_, _ = heap.Insert(txn.ID(), []byte("second"))
+	_ = m.Savepoint(txn, "two")
+	_, _ = heap.Insert(txn.ID(), []byte("third"))
+
+	t.Run("Changes after the savepoint are undone", func(t *testing.T) {
+		if err := m.RollbackTo(txn, "one"); err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+
+		if count := countRecords(t, heap); count != 1 {
+			t.Errorf("expected 1 record, got %d", count)
+		}
+	})
+
+	t.Run("Later savepoints are discarded", func(t *testing.T) {
+		if err := m.RollbackTo(txn, "two"); err != ErrSavepointNotFound {
+			t.Errorf("expected %v, got %v", ErrSavepointNotFound, err)
+		}
+	})
+
+	t.Run("Savepoint can be used again", func(t *testing.T) {
+		_, _ = heap.Insert(txn.ID(), []byte("fourth"))
+		if err := m.RollbackTo(txn, "one"); err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+
+		if count := countRecords(t, heap); count != 1 {
+			t.Errorf("expected 1 record, got %d", count)
+		}
+	})
+
+	t.Run("Commit keeps the changes before the savepoint", func(t *testing.T) {
+		if err := m.Commit(txn); err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+
+		if count := countRecords(t, heap); count != 1 {
+			t.Errorf("expected 1 record, got %d", count)
+		}
+	})
+}
+
+func TestManager_RunUndoesFailedStatement(t *testing.T) {
+	m, heap := openTestManager(t)
+
+	txn, _ := m.Begin(RepeatableRead)
+	_, _ = heap.Insert(txn.ID(), []byte("first"))
+
+	err := m.Run(txn, func() error {
+		_, _ = heap.Insert(txn.ID(), []byte("second"))
+		_, err := heap.Insert(txn.ID(), make([]byte, storage.PageSize))
+		return err
+	})
+
+	t.Run("Statement error is returned", func(t *testing.T) {
+		if err != storage.ErrRecordTooLarge {
+			t.Errorf("expected %v, got %v", storage.ErrRecordTooLarge, err)
+		}
+	})
+
+	t.Run("Only the failed statement is undone", func(t *testing.T) {
+		if count := countRecords(t, heap); count != 1 {
+			t.Errorf("expected 1 record, got %d", count)
+		}
+
+		if txn.State() != Active {
+			t.Errorf("expected transaction to stay active, got %s", txn.State())
+		}
+	})
+}
@@ -0,0 +1,54 @@
+package transaction
+
+import (
+	"dbngin3/storage"
+	"errors"
+	"strings"
+)
+
+type IsolationLevel int
+
+const (
+	// ReadCommitted takes a new snapshot for every statement.
+	ReadCommitted IsolationLevel = iota
+	// RepeatableRead keeps the snapshot taken at BEGIN for the whole
+	// transaction, which makes it snapshot isolation.
+	RepeatableRead
+)
+
+const DefaultIsolationLevel = RepeatableRead
+
+func (l IsolationLevel) String() string {
+	switch l {
+	case ReadCommitted:
+		return "READ COMMITTED"
+	case RepeatableRead:
+		return "REPEATABLE READ"
+	}
+	return "UNKNOWN"
+}
+
+// ParseIsolationLevel accepts the names used in SET TRANSACTION ISOLATION
+// LEVEL. SNAPSHOT is another name for REPEATABLE READ.
+func ParseIsolationLevel(name string) (IsolationLevel, error) {
+	switch strings.ToUpper(name) {
+	case "READ COMMITTED":
+		return ReadCommitted, nil
+	case "REPEATABLE READ", "SNAPSHOT":
+		return RepeatableRead, nil
+	}
+	return 0, errors.New("unsupported isolation level " + name)
+}
+
+// Snapshot decides which transactions a reader can see: everything that
+// committed before the snapshot was taken and nothing else.
+type Snapshot struct {
+	// xmax is the first transaction id that had not been handed out yet
+	xmax   storage.TxnID
+	active map[storage.TxnID]bool
+}
+
+// Includes reports whether the changes of txn are part of the snapshot.
+func (s *Snapshot) Includes(txn storage.TxnID) bool {
+	return txn < s.xmax && !s.active[txn]
+}
@@ -0,0 +1,54 @@
+package transaction
+
+import (
+	"dbngin3/storage"
+	"testing"
+)
+
+func TestTransaction_Visible(t *testing.T) {
+	m, _ := openTestManager(t)
+
+	committed, _ := m.Begin(RepeatableRead)
+	_ = m.Commit(committed)
+	running, _ := m.Begin(RepeatableRead)
+	reader, _ := m.Begin(RepeatableRead)
+	later, _ := m.Begin(RepeatableRead)
+
+	tests := []struct {
+		name       string
+		xmin, xmax storage.TxnID
+		expected   bool
+	}{
+		{"Committed before the snapshot", committed.ID(), 0, true},
+		{"Created by a running transaction", running.ID(), 0, false},
+		{"Created by a later transaction", later.ID(), 0, false},
+		{"Own insert", reader.ID(), 0, true},
+		{"Own delete", committed.ID(), reader.ID(), false},
+		{"Deleted by a running transaction", committed.ID(), running.ID(), true},
+		{"Deleted before the snapshot", storage.SystemTxn + 1, committed.ID(), false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if res := reader.Visible(test.xmin, test.xmax); res != test.expected {
+				t.Errorf("expected %v, got %v", test.expected, res)
+			}
+		})
+	}
+}
+
+func TestParseIsolationLevel(t *testing.T) {
+	for name, expected := range map[string]IsolationLevel{
+		"READ COMMITTED":  ReadCommitted,
+		"REPEATABLE READ": RepeatableRead,
+		"SNAPSHOT":        RepeatableRead,
+	} {
+		if level, err := ParseIsolationLevel(name); err != nil || level != expected {
+			t.Errorf("%s: expected %s, got %s (%v)", name, expected, level, err)
+		}
+	}
+
+	if _, err := ParseIsolationLevel("SERIALIZABLE"); err == nil {
+		t.Errorf("expected SERIALIZABLE to be rejected")
+	}
+}
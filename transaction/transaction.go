@@ -0,0 +1,74 @@
+package transaction
+
+import (
+	"dbngin3/storage"
+)
+
+type State int
+
+const (
+	Active State = iota
+	Committed
+	Aborted
+)
+
+func (s State) String() string {
+	switch s {
+	case Active:
+		return "ACTIVE"
+	case Committed:
+		return "COMMITTED"
+	case Aborted:
+		return "ABORTED"
+	}
+	return "UNKNOWN"
+}
+
+// Transaction is the handle a session holds between BEGIN and COMMIT or
+// ROLLBACK. All of its changes are written to the log under its id.
+type Transaction struct {
+	id         storage.TxnID
+	state      State
+	isolation  IsolationLevel
+	snapshot   *Snapshot
+	savepoints []savepoint
+}
+
+// savepoint remembers how far the log of the transaction reached when it was
+// set; rolling back to it undoes everything logged afterwards.
+type savepoint struct {
+	name string
+	lsn  storage.LSN
+}
+
+func (t *Transaction) ID() storage.TxnID {
+	return t.id
+}
+
+func (t *Transaction) State() State {
+	return t.state
+}
+
+func (t *Transaction) Isolation() IsolationLevel {
+	return t.isolation
+}
+
+// Visible reports whether a row version created by xmin and deleted by xmax
+// (zero while the version is live) exists for the transaction. A transaction
+// always sees its own changes.
+func (t *Transaction) Visible(xmin, xmax storage.TxnID) bool {
+	return t.sees(xmin) && (xmax == storage.SystemTxn || !t.sees(xmax))
+}
+
+func (t *Transaction) sees(txn storage.TxnID) bool {
+	return txn == t.id || t.snapshot.Includes(txn)
+}
+
+func (t *Transaction) findSavepoint(name string) int {
+	for i := len(t.savepoints) - 1; i >= 0; i-- {
+		if t.savepoints[i].name == name {
+			return i
+		}
+	}
+	return -1
+}
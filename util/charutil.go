@@ -2,22 +2,43 @@ package util
 
 import (
 	"strings"
+	"unicode"
 )
 
 func IsWhitespace(ch byte) bool {
-	return ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r'
+	return ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r' || ch == '\f' || ch == '\v'
 }
 
-func IsLetter(ch byte) bool {
-	return (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z')
+func IsLetter(r rune) bool {
+	return unicode.IsLetter(r)
 }
 
 func IsDigit(char byte) bool {
 	return char >= '0' && char <= '9'
 }
 
+func IsHexDigit(char byte) bool {
+	return IsDigit(char) || (char >= 'a' && char <= 'f') || (char >= 'A' && char <= 'F')
+}
+
+func IsBinaryDigit(char byte) bool {
+	return char == '0' || char == '1'
+}
+
+// IsIdentifierStart reports whether an unquoted identifier may start with
+// the character: a letter of any script or an underscore.
+func IsIdentifierStart(r rune) bool {
+	return IsLetter(r) || r == '_'
+}
+
+// IsIdentifierPart reports whether the character may follow the first one
+// of an unquoted identifier.
+func IsIdentifierPart(r rune) bool {
+	return IsIdentifierStart(r) || unicode.IsDigit(r) || r == '$'
+}
+
 func IsOperator(char byte) bool {
-	operators := "+-*/=<>!"
+	operators := "+-*/%=<>!"
 	return strings.ContainsRune(operators, rune(char))
 }
 
@@ -27,6 +48,6 @@ func IsDelimiter(char byte) bool {
 }
 
 func IsSymbol(char byte) bool {
-	operators := "();"
+	operators := "();."
 	return strings.ContainsRune(operators, rune(char))
 }